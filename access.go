@@ -0,0 +1,149 @@
+package expiring_gocache
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// KeyReport describes the access history of a key.
+	KeyReport struct {
+		Key        interface{}
+		Hits       uint64
+		LastAccess time.Time
+		// TTL is the time remaining until the key's value expires. It is
+		// negative for values which have expired but are still stored.
+		TTL time.Duration
+		// Cost is how long the value took to compute, see CostTag.
+		Cost time.Duration
+	}
+
+	// KeyStats describes the history of one key, see Store.KeyStats.
+	KeyStats struct {
+		Key interface{}
+		// Hits counts the reads which found the key's value unexpired.
+		Hits       uint64
+		LastAccess time.Time
+		// Sets counts the values written for the key.
+		Sets    uint64
+		LastSet time.Time
+		// LastExpired is when the key's value was last found expired, by a
+		// read or the reaper, or evicted.
+		LastExpired time.Time
+		// ExpireAt is when the key's current value expires, or zero if it
+		// has none.
+		ExpireAt time.Time
+		// Cost is how long the key's current value took to compute, see
+		// CostTag.
+		Cost time.Duration
+	}
+)
+
+// HotKeys returns reports for the n keys with the most hits, most hits
+// first. Keys with the same number of hits are ordered by most recent
+// access. Access tracking must be enabled with WithAccessTracking; nil is
+// returned otherwise.
+func (es Store) HotKeys(n int) []KeyReport {
+	return es.keyReports(n, func(a, b KeyReport) bool {
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		return a.LastAccess.After(b.LastAccess)
+	})
+}
+
+// ColdKeys returns reports for the n keys with the fewest hits, fewest hits
+// first. Keys with the same number of hits are ordered by least recent
+// access. Access tracking must be enabled with WithAccessTracking; nil is
+// returned otherwise.
+func (es Store) ColdKeys(n int) []KeyReport {
+	return es.keyReports(n, func(a, b KeyReport) bool {
+		if a.Hits != b.Hits {
+			return a.Hits < b.Hits
+		}
+		return a.LastAccess.Before(b.LastAccess)
+	})
+}
+
+// KeyStats returns the history of key, e.g. to find out why it is always
+// cold. Access tracking must be enabled with WithAccessTracking;
+// UnsupportedError is returned otherwise. Keys which have expired or been
+// evicted keep their history until they are deleted, or many other keys
+// have expired since; for keys without history, KeyStats is zero but for
+// Key.
+func (es Store) KeyStats(key interface{}) (KeyStats, error) {
+	if es.tracker == nil || !es.trackAccess {
+		return KeyStats{}, UnsupportedError
+	}
+	if !trackable(key) {
+		return KeyStats{}, UntrackableKeyError
+	}
+	ks := KeyStats{Key: key}
+	entry, ok := es.tracker.history(key)
+	if !ok {
+		return ks, nil
+	}
+	ks.Hits = entry.hits
+	ks.LastAccess = entry.lastAccess
+	ks.Sets = entry.sets
+	ks.LastSet = entry.lastSet
+	ks.LastExpired = entry.lastExpired
+	if expireAt, tracked := es.tracker.expireAt(key); tracked {
+		ks.ExpireAt = expireAt
+		ks.Cost = entry.cost
+	}
+	return ks, nil
+}
+
+func (es Store) keyReports(n int, less func(a, b KeyReport) bool) []KeyReport {
+	if es.tracker == nil || !es.trackAccess || n <= 0 {
+		return nil
+	}
+	reports := es.tracker.reports(es.now())
+	if es.hashedIndex {
+		reports = es.reportedKeys(reports)
+	}
+	sort.Slice(reports, func(i, j int) bool { return less(reports[i], reports[j]) })
+	if len(reports) > n {
+		reports = reports[:n]
+	}
+	return reports
+}
+
+// reportedKeys gives back the keys of reports taken from a hashed index,
+// leaving out the reports of keys which can't be resolved, see keysOf.
+func (es Store) reportedKeys(reports []KeyReport) []KeyReport {
+	refs := make([]interface{}, len(reports))
+	for i, report := range reports {
+		refs[i] = report.Key
+	}
+	keys, err := es.refKeys(refs)
+	if err != nil {
+		return nil
+	}
+	resolved := reports[:0]
+	for _, report := range reports {
+		if key, ok := keys[report.Key]; ok {
+			report.Key = key
+			resolved = append(resolved, report)
+		}
+	}
+	return resolved
+}
+
+// reports returns a report for every tracked key, in no particular order.
+func (t *tracker) reports(now time.Time) []KeyReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reports := make([]KeyReport, 0, t.entries.len())
+	t.entries.each(func(entry *trackedEntry) {
+		reports = append(reports, KeyReport{
+			Key:        entry.key,
+			Hits:       entry.hits,
+			LastAccess: entry.lastAccess,
+			TTL:        entry.expireAt.Sub(now),
+			Cost:       entry.cost,
+		})
+	})
+	return reports
+}
@@ -0,0 +1,117 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAccessTrackedStore(t *testing.T) (*MapStore, expiring.Store) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking())
+
+	for key, hits := range map[string]int{"hot": 3, "warm": 2, "cold": 0, "cool": 1} {
+		assert.Nil(t, es.Set(key, "value", nil))
+		for i := 0; i < hits; i++ {
+			_, err := es.Get(key)
+			assert.Nil(t, err)
+		}
+	}
+	return &ms, es
+}
+
+func TestHotKeys(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	reports := es.HotKeys(2)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "hot", reports[0].Key)
+	assert.Equal(t, uint64(3), reports[0].Hits)
+	assert.False(t, reports[0].LastAccess.IsZero())
+	assert.True(t, reports[0].TTL > time.Hour-time.Second && reports[0].TTL <= time.Hour)
+	assert.Equal(t, "warm", reports[1].Key)
+
+	assert.Len(t, es.HotKeys(10), 4)
+}
+
+func TestColdKeys(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	reports := es.ColdKeys(2)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "cold", reports[0].Key)
+	assert.Equal(t, uint64(0), reports[0].Hits)
+	assert.True(t, reports[0].LastAccess.IsZero())
+	assert.Equal(t, "cool", reports[1].Key)
+}
+
+func TestAccessHistorySurvivesSet(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	assert.Nil(t, es.Set("hot", "new value", nil))
+	assert.Equal(t, uint64(3), es.HotKeys(1)[0].Hits)
+
+	// deleting the key forgets its history
+	assert.Nil(t, es.Delete("hot"))
+	assert.Nil(t, es.Set("hot", "new value", nil))
+	assert.Equal(t, "warm", es.HotKeys(1)[0].Key)
+}
+
+func TestKeyReportsWithoutAccessTracking(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithMaxEntries(10))
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	assert.Nil(t, es.HotKeys(10))
+	assert.Nil(t, es.ColdKeys(10))
+}
+
+func TestKeyStats(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithAccessTracking(), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+
+	ks, err := es.KeyStats("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "key", ks.Key)
+	assert.Equal(t, uint64(1), ks.Hits)
+	assert.Equal(t, uint64(2), ks.Sets)
+	assert.Equal(t, clk.Now(), ks.LastSet)
+	assert.Equal(t, clk.Now(), ks.LastAccess)
+	assert.Equal(t, clk.Now().Add(time.Minute), ks.ExpireAt)
+	assert.True(t, ks.LastExpired.IsZero())
+
+	// history survives the value expiring
+	clk.Advance(2 * time.Minute)
+	_, err = es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	ks, err = es.KeyStats("key")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), ks.Hits)
+	assert.Equal(t, uint64(2), ks.Sets)
+	assert.Equal(t, clk.Now(), ks.LastExpired)
+	assert.True(t, ks.ExpireAt.IsZero())
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	ks, _ = es.KeyStats("key")
+	assert.Equal(t, uint64(3), ks.Sets)
+	assert.False(t, ks.LastExpired.IsZero())
+
+	// but not the key being deleted
+	assert.Nil(t, es.Delete("key"))
+	ks, err = es.KeyStats("key")
+	assert.Nil(t, err)
+	assert.Equal(t, expiring.KeyStats{Key: "key"}, ks)
+
+	_, err = expiring.New(&ms, nil).KeyStats("key")
+	assert.Equal(t, expiring.UnsupportedError, err)
+}
@@ -0,0 +1,97 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// admissionSketch counts how often keys are requested within a window,
+	// in a count-min sketch, so that keys requested only once or twice
+	// aren't cached. Counts may be overestimated, but never under.
+	admissionSketch struct {
+		threshold uint32
+		window    time.Duration
+
+		mu      sync.Mutex
+		resetAt int64
+		rows    [admissionSketchDepth][]uint32
+	}
+)
+
+const (
+	admissionSketchDepth = 4
+	// DefaultAdmissionSketchWidth is how many counters each row of the
+	// admission sketch has.
+	DefaultAdmissionSketchWidth = 4096
+)
+
+func newAdmissionSketch(threshold int, window time.Duration) *admissionSketch {
+	s := &admissionSketch{threshold: uint32(threshold), window: window}
+	for i := range s.rows {
+		s.rows[i] = make([]uint32, DefaultAdmissionSketchWidth)
+	}
+	return s
+}
+
+// indexes returns the counter of key in each row.
+func (s *admissionSketch) indexes(key interface{}) [admissionSketchDepth]int {
+	var idx [admissionSketchDepth]int
+	h := keyHash(key)
+	for i := range idx {
+		h = mix64(h + uint64(i))
+		idx[i] = int(h % uint64(len(s.rows[i])))
+	}
+	return idx
+}
+
+// record counts a request for key at now, first starting a new window if
+// the current one has passed.
+func (s *admissionSketch) record(key interface{}, now time.Time) {
+	if now.UnixNano() >= atomic.LoadInt64(&s.resetAt) {
+		s.reset(now)
+	}
+	for i, j := range s.indexes(key) {
+		atomic.AddUint32(&s.rows[i][j], 1)
+	}
+}
+
+func (s *admissionSketch) reset(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.UnixNano() < atomic.LoadInt64(&s.resetAt) {
+		// reset by another caller
+		return
+	}
+	for _, row := range s.rows {
+		for j := range row {
+			atomic.StoreUint32(&row[j], 0)
+		}
+	}
+	atomic.StoreInt64(&s.resetAt, now.Add(s.window).UnixNano())
+}
+
+// admits reports whether key has been requested often enough in this
+// window to be cached.
+func (s *admissionSketch) admits(key interface{}) bool {
+	for i, j := range s.indexes(key) {
+		if atomic.LoadUint32(&s.rows[i][j]) < s.threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// requested counts a read of key for WithAdmission.
+func (es Store) requested(key interface{}) {
+	if es.admission != nil {
+		es.admission.record(key, es.now())
+	}
+}
+
+// admitted reports whether a Set of key should be written, see
+// WithAdmission.
+func (es Store) admitted(key interface{}) bool {
+	return es.admission == nil || es.admission.admits(key)
+}
@@ -0,0 +1,49 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmission(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithAdmission(2, time.Minute), expiring.WithClock(clk))
+
+	// requested once
+	_, err := es.Get("key")
+	assert.NotNil(t, err)
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, 0, ms.setCount)
+
+	// requested twice
+	_, err = es.Get("key")
+	assert.NotNil(t, err)
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, 1, ms.setCount)
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+
+	// the counts don't outlive the window
+	_, _, _ = es.GetWithTTL("other")
+	clk.Advance(2 * time.Minute)
+	_, _, _ = es.GetWithTTL("other")
+	assert.Nil(t, es.Set("other", "value", nil))
+	assert.Equal(t, 1, ms.setCount)
+	assert.Equal(t, uint64(2), es.Stats().UnadmittedSets)
+}
+
+func TestAdmissionDisabled(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAdmission(1, time.Minute))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, 1, ms.setCount)
+}
@@ -0,0 +1,167 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// BatchTx collects the operations of a Batch.
+	BatchTx interface {
+		Set(key interface{}, value interface{}, options *store.Options)
+		Delete(key interface{})
+	}
+
+	// BatchOp is one operation of a Batch, as passed to the underlying
+	// store. Operation is OperationSet or OperationDelete.
+	BatchOp struct {
+		Operation Operation
+		Key       interface{}
+		Value     interface{}
+		Options   *store.Options
+	}
+
+	// BatchResult reports the outcome of one operation of a Batch.
+	BatchResult struct {
+		Operation Operation
+		Key       interface{}
+		Err       error
+	}
+
+	// transactor is implemented by stores which can apply many operations
+	// atomically, e.g. with Redis MULTI/EXEC. Transact returns
+	// UnsupportedError if it can't apply ops atomically.
+	transactor interface {
+		Transact(ops []BatchOp) error
+	}
+
+	batchTx struct {
+		ops []BatchOp
+	}
+
+	// preparedOp is an operation of a Batch ready to be applied.
+	preparedOp struct {
+		index int
+		op    BatchOp
+		set   preparedSet
+	}
+)
+
+// Batch calls fn to collect Set and Delete operations, then applies them in
+// order. If the underlying store implements
+// `Transact(ops []BatchOp) error`, they are applied atomically in one call;
+// otherwise each is applied in turn, and a failed operation doesn't stop
+// the rest. A result is returned for every operation collected, along with
+// the first error.
+//
+// Nothing is written if fn returns an error, or if a Set can't be written,
+// e.g. because its key is not cacheable. Deletes in a Batch are immediate,
+// even if WithDeleteDelay was given. With WithWriteThrough, every operation
+// is also applied to the sink, as by Set and Delete.
+func (es Store) Batch(fn func(tx BatchTx) error) ([]BatchResult, error) {
+	tx := &batchTx{}
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+
+	ops := make([]sinkOp, len(tx.ops))
+	for i, op := range tx.ops {
+		ops[i] = sinkOp{op: op.Operation, key: op.Key}
+		if op.Operation == OperationSet {
+			ops[i].value = op.Value
+			ops[i].ttl = ttlFor(op.Options, es.defaultTTL(op.Key, op.Value))
+		}
+	}
+	var results []BatchResult
+	err := es.through(context.Background(), ops, func() error {
+		var err error
+		results, err = es.batch(tx)
+		return err
+	})
+	return results, err
+}
+
+// batch applies the operations collected by tx to the underlying store.
+func (es Store) batch(tx *batchTx) ([]BatchResult, error) {
+	prepared := make([]preparedOp, 0, len(tx.ops))
+	results := make([]BatchResult, len(tx.ops))
+	for i, op := range tx.ops {
+		results[i] = BatchResult{Operation: op.Operation, Key: op.Key}
+		if op.Operation == OperationDelete {
+			prepared = append(prepared, preparedOp{index: i, op: op})
+			continue
+		}
+		ttl := ttlFor(op.Options, es.defaultTTL(op.Key, op.Value))
+		p, ok, err := es.prepareSet(op.Key, op.Value, op.Options, ttl, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			op = BatchOp{Operation: OperationSet, Key: p.item.Key, Value: p.item.Value, Options: p.item.Options}
+			prepared = append(prepared, preparedOp{index: i, op: op, set: p})
+		}
+	}
+
+	errs, err := es.applyBatch(prepared)
+	for i, p := range prepared {
+		results[p.index].Err = errs[i]
+		if errs[i] != nil {
+			continue
+		}
+		if p.op.Operation == OperationDelete {
+			es.untrack(p.op.Key)
+			es.emit(Event{Type: EventDelete, Key: p.op.Key})
+			es.cascade(p.op.Key)
+		} else {
+			es.setDone(p.set)
+		}
+	}
+	es.evict()
+	return results, err
+}
+
+// applyBatch applies ops to the underlying store, returning the error of
+// each, and the first error.
+func (es Store) applyBatch(ops []preparedOp) ([]error, error) {
+	errs := make([]error, len(ops))
+	if len(ops) == 0 {
+		return errs, nil
+	}
+
+	if t, ok := es.store.(transactor); ok {
+		batch := make([]BatchOp, len(ops))
+		for i, p := range ops {
+			batch[i] = p.op
+		}
+		err := es.innerTransact(t, batch)
+		if err != UnsupportedError {
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs, err
+		}
+	}
+
+	var first error
+	for i, p := range ops {
+		if p.op.Operation == OperationDelete {
+			errs[i] = es.innerDelete(p.op.Key)
+		} else {
+			errs[i] = es.innerSet(p.op.Key, p.op.Value, p.op.Options)
+		}
+		if first == nil {
+			first = errs[i]
+		}
+	}
+	return errs, first
+}
+
+func (tx *batchTx) Set(key interface{}, value interface{}, options *store.Options) {
+	tx.ops = append(tx.ops, BatchOp{Operation: OperationSet, Key: key, Value: value, Options: options})
+}
+
+func (tx *batchTx) Delete(key interface{}) {
+	tx.ops = append(tx.ops, BatchOp{Operation: OperationDelete, Key: key})
+}
@@ -0,0 +1,126 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// TransactMapStore applies transactions, failing them with err.
+	TransactMapStore struct {
+		MapStore
+		transactions [][]expiring.BatchOp
+		err          error
+	}
+)
+
+func TestBatch(t *testing.T) {
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 1}
+	es := expiring.New(&fds, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(10))
+	assert.Nil(t, es.Set("old", "value", nil))
+
+	results, err := es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("a", "value a", nil)
+		tx.Delete("old")
+		tx.Set("b", "value b", &store.Options{Expiration: time.Minute})
+		return nil
+	})
+	assert.Equal(t, FailingDeleteError, err)
+	assert.Equal(t, []expiring.BatchResult{
+		{Operation: expiring.OperationSet, Key: "a"},
+		{Operation: expiring.OperationDelete, Key: "old", Err: FailingDeleteError},
+		{Operation: expiring.OperationSet, Key: "b"},
+	}, results)
+
+	val, err := es.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "value a", val)
+	_, ttl, err := es.GetWithTTL("b")
+	assert.Nil(t, err)
+	assert.True(t, ttl <= time.Minute)
+	n, _ := es.Len()
+	assert.Equal(t, 3, n)
+}
+
+func TestBatchAborted(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil,
+		expiring.WithDeniedKeys(func(key interface{}) bool { return key == "denied" }),
+		expiring.WithSuppressedSetErrors(),
+	)
+
+	fnErr := errors.New("abort")
+	results, err := es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("a", "value", nil)
+		return fnErr
+	})
+	assert.Equal(t, fnErr, err)
+	assert.Nil(t, results)
+
+	_, err = es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("a", "value", nil)
+		tx.Set("denied", "value", nil)
+		return nil
+	})
+	assert.Equal(t, expiring.KeyNotCacheableError, err)
+	assert.Equal(t, 0, ms.setCount)
+}
+
+func TestBatchTransaction(t *testing.T) {
+	ts := TransactMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ts, nil, expiring.WithInstanceID("id"))
+
+	results, err := es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("a", "value", nil)
+		tx.Delete("b")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Len(t, ts.transactions, 1)
+	assert.Equal(t, "id:a", ts.transactions[0][0].Key)
+	assert.Equal(t, expiring.OperationDelete, ts.transactions[0][1].Operation)
+	assert.Equal(t, 0, ts.setCount)
+	val, err := es.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+
+	ts.err = errors.New("aborted")
+	results, err = es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("c", "value", nil)
+		return nil
+	})
+	assert.Equal(t, ts.err, err)
+	assert.Equal(t, ts.err, results[0].Err)
+
+	// stores which can't apply a transaction have it applied one op at a time
+	ts.err = expiring.UnsupportedError
+	_, err = es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("c", "value", nil)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, ts.setCount)
+}
+
+func (ts *TransactMapStore) Transact(ops []expiring.BatchOp) error {
+	if ts.err != nil {
+		return ts.err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.transactions = append(ts.transactions, ops)
+	for _, op := range ops {
+		if op.Operation == expiring.OperationDelete {
+			delete(ts.cache, op.Key)
+		} else {
+			ts.cache[op.Key] = op.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package benchmarks_test
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/coocood/freecache"
+	"github.com/dgraph-io/ristretto"
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/bytesstore"
+	"github.com/nabowler/expiring_gocache/compat"
+)
+
+type (
+	// cache is the common shape of the backends benchmarked.
+	cache interface {
+		get(key string) bool
+		set(key string, value []byte)
+	}
+
+	wrapped struct {
+		es expiring.Store
+	}
+
+	bytesWrapped struct {
+		s bytesstore.Store
+	}
+
+	nativeFreecache struct {
+		client *freecache.Cache
+	}
+
+	nativeBigcache struct {
+		client *bigcache.BigCache
+	}
+
+	nativeRistretto struct {
+		client *ristretto.Cache
+	}
+
+	backend struct {
+		name  string
+		cache cache
+	}
+)
+
+const (
+	benchmarkTTL  = time.Hour
+	benchmarkKeys = 1024
+)
+
+var (
+	keys  = make([]string, benchmarkKeys)
+	value = make([]byte, 128)
+)
+
+func init() {
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+}
+
+// backends returns fresh instances of every backend, always in the same
+// order.
+func backends(b *testing.B) []backend {
+	fc := freecache.NewCache(64 << 20)
+	bc, err := bigcache.NewBigCache(bigcache.DefaultConfig(benchmarkTTL))
+	if err != nil {
+		b.Fatal(err)
+	}
+	bytesBigcache, err := bigcache.NewBigCache(bigcache.DefaultConfig(benchmarkTTL))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rc, err := ristretto.NewCache(&ristretto.Config{NumCounters: 10 * benchmarkKeys, MaxCost: 10 * benchmarkKeys, BufferItems: 64})
+	if err != nil {
+		b.Fatal(err)
+	}
+	wrappedBigcache, err := bigcache.NewBigCache(bigcache.DefaultConfig(benchmarkTTL))
+	if err != nil {
+		b.Fatal(err)
+	}
+	wrappedRistretto, err := ristretto.NewCache(&ristretto.Config{NumCounters: 10 * benchmarkKeys, MaxCost: 10 * benchmarkKeys, BufferItems: 64})
+	if err != nil {
+		b.Fatal(err)
+	}
+	options := &store.Options{Expiration: benchmarkTTL}
+
+	return []backend{
+		{"Freecache/Native", nativeFreecache{fc}},
+		{"Freecache/Wrapped", wrapped{compat.NewFreecache(freecache.NewCache(64<<20), options)}},
+		{"Bigcache/Native", nativeBigcache{bc}},
+		{"Bigcache/Wrapped", wrapped{compat.NewBigcache(wrappedBigcache, options)}},
+		{"Bigcache/Bytes", bytesWrapped{bytesstore.New(bytesBigcache, benchmarkTTL, bytesstore.WithBufferReuse())}},
+		{"Ristretto/Native", nativeRistretto{rc}},
+		{"Ristretto/Wrapped", wrapped{compat.NewRistretto(wrappedRistretto, options)}},
+	}
+}
+
+// fill writes every key, and waits for asynchronous backends to apply them.
+func fill(c cache) {
+	for _, key := range keys {
+		c.set(key, value)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, backend := range backends(b) {
+		c := backend.cache
+		fill(c)
+		b.Run(backend.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.get(keys[i%benchmarkKeys])
+			}
+		})
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	for _, backend := range backends(b) {
+		c := backend.cache
+		b.Run(backend.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.set(keys[i%benchmarkKeys], value)
+			}
+		})
+	}
+}
+
+// BenchmarkParallel mixes one Set to every nine Gets across GOMAXPROCS
+// goroutines.
+func BenchmarkParallel(b *testing.B) {
+	for _, backend := range backends(b) {
+		c := backend.cache
+		fill(c)
+		b.Run(backend.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var (
+				mu        sync.Mutex
+				latencies []time.Duration
+			)
+			b.RunParallel(func(pb *testing.PB) {
+				var local []time.Duration
+				for i := 0; pb.Next(); i++ {
+					key := keys[i%benchmarkKeys]
+					start := time.Now()
+					if i%10 == 0 {
+						c.set(key, value)
+					} else {
+						c.get(key)
+					}
+					local = append(local, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
+			})
+			reportP99(b, latencies)
+		})
+	}
+}
+
+func reportP99(b *testing.B, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(p99.Nanoseconds()), "p99-ns/op")
+}
+
+func (w wrapped) get(key string) bool {
+	_, err := w.es.Get(key)
+	return err == nil
+}
+
+func (w wrapped) set(key string, value []byte) {
+	_ = w.es.Set(key, value, nil)
+}
+
+func (w bytesWrapped) get(key string) bool {
+	_, err := w.s.Get(key)
+	return err == nil
+}
+
+func (w bytesWrapped) set(key string, value []byte) {
+	_ = w.s.Set(key, value)
+}
+
+func (n nativeFreecache) get(key string) bool {
+	_, err := n.client.Get([]byte(key))
+	return err == nil
+}
+
+func (n nativeFreecache) set(key string, value []byte) {
+	_ = n.client.Set([]byte(key), value, int(benchmarkTTL/time.Second))
+}
+
+func (n nativeBigcache) get(key string) bool {
+	_, err := n.client.Get(key)
+	return err == nil
+}
+
+// set relies on bigcache's life window, which is benchmarkTTL for every
+// entry.
+func (n nativeBigcache) set(key string, value []byte) {
+	_ = n.client.Set(key, value)
+}
+
+func (n nativeRistretto) get(key string) bool {
+	_, ok := n.client.Get(key)
+	return ok
+}
+
+// set can't expire values, as this version of ristretto has no TTLs.
+func (n nativeRistretto) set(key string, value []byte) {
+	n.client.Set(key, value, 1)
+}
@@ -0,0 +1,9 @@
+// Package benchmarks measures the overhead of wrapping cache backends with an
+// expiring Store, against the same backends expiring values natively, and
+// with the bytesstore fast path. It only contains benchmarks; run them with
+//
+//	go test -bench . -benchmem ./benchmarks
+//
+// The Parallel benchmarks also report the p99 latency of a single operation
+// under contention.
+package benchmarks
@@ -0,0 +1,48 @@
+package expiring_gocache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func isExternalKey(key interface{}) bool {
+	s, ok := key.(string)
+	return ok && strings.HasPrefix(s, "external:")
+}
+
+func TestBypassedKeysAreNotWrapped(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithBypass(isExternalKey))
+
+	assert.Nil(t, es.Set("external:key", "value", nil))
+	assert.Equal(t, "value", ms.cache["external:key"])
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.NotEqual(t, "value", ms.cache["key"])
+}
+
+func TestBypassedKeysDoNotExpire(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithClock(clk))
+	// written by a Store without the bypass, so it is wrapped
+	assert.Nil(t, es.Set("external:key", "value", nil))
+
+	es = expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithBypass(isExternalKey), expiring.WithClock(clk))
+	clk.Advance(20 * time.Millisecond)
+
+	// the stored value is returned as is, without an expiry check
+	val, err := es.Get("external:key")
+	assert.Nil(t, err)
+	assert.Equal(t, ms.cache["external:key"], val)
+	_, ttl, err := es.GetWithTTL("external:key")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.Equal(t, 0, ms.deleteCount)
+}
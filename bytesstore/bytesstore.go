@@ -0,0 +1,104 @@
+// Package bytesstore is a fast path for caches of []byte values with string
+// keys, such as those of HTTP proxies. Values are written in the binary
+// envelope of an expiring Store created WithBinaryEnvelope, so the two can
+// share a backend, but without boxing keys and values in interfaces: a Get
+// allocates nothing beyond what the backend itself does.
+package bytesstore
+
+import (
+	"sync"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// Backend holds []byte values by string key. Get returns an error on a
+	// miss. *bigcache.BigCache is a Backend.
+	Backend interface {
+		Get(key string) ([]byte, error)
+		Set(key string, entry []byte) error
+		Delete(key string) error
+	}
+
+	// Store enforces expirations on the values of a Backend.
+	Store struct {
+		backend    Backend
+		expiration time.Duration
+		buffers    *sync.Pool
+	}
+
+	// Option configures a Store.
+	Option func(*Store)
+)
+
+// New creates a Store around backend, expiring values after expiration
+// unless they are written with their own TTL.
+func New(backend Backend, expiration time.Duration, opts ...Option) Store {
+	s := Store{backend: backend, expiration: expiration}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// WithBufferReuse reuses the buffers values are enveloped in, so that a Set
+// allocates nothing either. The backend must not keep the slice passed to
+// its Set, as bigcache and freecache don't.
+func WithBufferReuse() Option {
+	return func(s *Store) {
+		s.buffers = &sync.Pool{New: func() interface{} { return new([]byte) }}
+	}
+}
+
+// Get returns the value of key. If it has expired, it is deleted from the
+// backend and expiring.ValueExpiredError is returned. Values which were not
+// written in an envelope are returned as they are.
+//
+// The value returned shares memory with the slice returned by the backend.
+func (s Store) Get(key string) ([]byte, error) {
+	value, _, err := s.GetWithTTL(key)
+	return value, err
+}
+
+// GetWithTTL is like Get, but also returns the value's remaining time to
+// live, or 0 for values which were not written in an envelope.
+func (s Store) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	b, err := s.backend.Get(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	value, expireAt, ok := expiring.ParseEnvelope(b)
+	if !ok {
+		return b, 0, nil
+	}
+	ttl := time.Until(expireAt)
+	if ttl <= 0 {
+		_ = s.backend.Delete(key)
+		return nil, 0, expiring.ValueExpiredError
+	}
+	return value, ttl, nil
+}
+
+// Set writes value, expiring it after the Store's expiration.
+func (s Store) Set(key string, value []byte) error {
+	return s.SetWithTTL(key, value, s.expiration)
+}
+
+// SetWithTTL writes value, expiring it after ttl.
+func (s Store) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	expireAt := time.Now().Add(ttl)
+	if s.buffers == nil {
+		return s.backend.Set(key, expiring.AppendEnvelope(nil, value, expireAt))
+	}
+	buf := s.buffers.Get().(*[]byte)
+	*buf = expiring.AppendEnvelope((*buf)[:0], value, expireAt)
+	err := s.backend.Set(key, *buf)
+	s.buffers.Put(buf)
+	return err
+}
+
+// Delete removes the value of key from the backend.
+func (s Store) Delete(key string) error {
+	return s.backend.Delete(key)
+}
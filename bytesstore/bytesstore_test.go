@@ -0,0 +1,121 @@
+package bytesstore_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/bytesstore"
+	"github.com/nabowler/expiring_gocache/compat"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// MapBackend is a map with the bigcache API, which keeps the slices
+	// given to Set.
+	MapBackend struct {
+		mu     sync.Mutex
+		values map[string][]byte
+	}
+
+	// CopyingMapBackend copies the slices given to Set, as bigcache does.
+	CopyingMapBackend struct {
+		MapBackend
+	}
+)
+
+var (
+	NotFoundError = errors.New("not found")
+)
+
+func TestStore(t *testing.T) {
+	mb := &MapBackend{values: map[string][]byte{}}
+	s := bytesstore.New(mb, time.Hour)
+
+	assert.Nil(t, s.Set("key", []byte("value")))
+	val, ttl, err := s.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	assert.True(t, ttl > 59*time.Minute)
+
+	assert.Nil(t, s.SetWithTTL("short", []byte("value"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, err = s.Get("short")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.NotContains(t, mb.values, "short")
+
+	mb.values["raw"] = []byte("raw")
+	val, err = s.Get("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("raw"), val)
+
+	assert.Nil(t, s.Delete("key"))
+	_, err = s.Get("key")
+	assert.Equal(t, NotFoundError, err)
+}
+
+func TestStoreSharesEnvelope(t *testing.T) {
+	mb := &MapBackend{values: map[string][]byte{}}
+	s := bytesstore.New(mb, time.Hour)
+	es := compat.NewBigcache(mb, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, s.Set("bytes", []byte("from bytesstore")))
+	val, ttl, err := es.GetWithTTL("bytes")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("from bytesstore"), val)
+	assert.True(t, ttl > 59*time.Minute)
+
+	assert.Nil(t, es.Set("bytes", []byte("from expiring"), nil))
+	got, err := s.Get("bytes")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("from expiring"), got)
+	assert.Nil(t, es.Set("string", "string", nil))
+	got, err = s.Get("string")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("string"), got)
+}
+
+func TestStoreAllocations(t *testing.T) {
+	s := bytesstore.New(&CopyingMapBackend{MapBackend{values: map[string][]byte{}}}, time.Hour, bytesstore.WithBufferReuse())
+	value := make([]byte, 128)
+	assert.Nil(t, s.Set("key", value))
+
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		_, _ = s.Get("key")
+	}))
+	// the backend's copy is the only allocation
+	assert.Equal(t, 1.0, testing.AllocsPerRun(100, func() {
+		_ = s.Set("key", value)
+	}))
+}
+
+func (mb *MapBackend) Get(key string) ([]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	val, ok := mb.values[key]
+	if !ok {
+		return nil, NotFoundError
+	}
+	return val, nil
+}
+
+func (mb *MapBackend) Set(key string, entry []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.values[key] = entry
+	return nil
+}
+
+func (mb *MapBackend) Delete(key string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	delete(mb.values, key)
+	return nil
+}
+
+func (cb *CopyingMapBackend) Set(key string, entry []byte) error {
+	return cb.MapBackend.Set(key, append([]byte(nil), entry...))
+}
@@ -0,0 +1,22 @@
+package expiring_gocache
+
+import "sync/atomic"
+
+// cacheable reports whether values may be written for key, see
+// WithAllowedKeys and WithDeniedKeys.
+func (es Store) cacheable(key interface{}) bool {
+	if es.denyKeys != nil && es.denyKeys(key) {
+		return false
+	}
+	return es.allowKeys == nil || es.allowKeys(key)
+}
+
+// suppressSet records a Set which was not written because its key may not be
+// cached.
+func (es Store) suppressSet() error {
+	atomic.AddUint64(&es.stats.suppressedSets, 1)
+	if es.errorOnSuppressedSet {
+		return KeyNotCacheableError
+	}
+	return nil
+}
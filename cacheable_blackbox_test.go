@@ -0,0 +1,39 @@
+package expiring_gocache_test
+
+import (
+	"strings"
+	"testing"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func isSecretKey(key interface{}) bool {
+	s, ok := key.(string)
+	return ok && strings.HasPrefix(s, "secret:")
+}
+
+func TestDeniedKeysAreNotCached(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithDeniedKeys(isSecretKey), expiring.WithBypass(isSecretKey))
+
+	assert.Nil(t, es.Set("secret:token", "value", nil))
+	assert.Nil(t, es.Set("public", "value", nil))
+	assert.Equal(t, 1, ms.setCount)
+	_, ok := ms.cache["secret:token"]
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), es.Stats().SuppressedSets)
+}
+
+func TestAllowedKeys(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil,
+		expiring.WithAllowedKeys(func(key interface{}) bool { return key == "allowed" }),
+		expiring.WithSuppressedSetErrors(),
+	)
+
+	assert.Nil(t, es.Set("allowed", "value", nil))
+	assert.Equal(t, expiring.KeyNotCacheableError, es.Set("other", "value", nil))
+	assert.Equal(t, 1, ms.setCount)
+	assert.Equal(t, uint64(1), es.Stats().SuppressedSets)
+}
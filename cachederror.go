@@ -0,0 +1,69 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// CachedError is returned by reads of a key whose failure was cached by
+	// SetError. Err is the error cached; values read back from a binary
+	// envelope, or any store which serializes values, keep only its message.
+	CachedError struct {
+		Err error
+	}
+
+	cachedErrorRecord struct {
+		err error
+	}
+)
+
+const (
+	// DefaultErrorTTL is how long SetError caches an error for when it isn't
+	// given a TTL.
+	DefaultErrorTTL = 10 * time.Second
+)
+
+var (
+	NilErrorError = errors.New("SetError needs a non-nil error")
+)
+
+func (e *CachedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CachedError) Unwrap() error {
+	return e.Err
+}
+
+// SetError caches err as the outcome for key for ttl, or DefaultErrorTTL if
+// ttl isn't positive, so that callers can skip repeating an expensive
+// operation which just failed. Until it expires, Get and the other reads
+// return a *CachedError wrapping err; hits are counted by Stats.ErrorHits.
+// This is distinct from negative caching, where "not found" is a value.
+func (es Store) SetError(key interface{}, err error, ttl time.Duration) error {
+	if err == nil {
+		return NilErrorError
+	}
+	if ttl <= 0 {
+		ttl = DefaultErrorTTL
+	}
+	return es.set(key, cachedErrorRecord{err: err}, nil, ttl, time.Time{})
+}
+
+// cachedError returns the *CachedError for a value read, if it is a cached
+// error, counting the hit.
+func (es Store) cachedError(ew wrappedValue) (*CachedError, bool) {
+	record, ok := ew.value.(cachedErrorRecord)
+	if !ok {
+		return nil, false
+	}
+	atomic.AddUint64(&es.stats.errorHits, 1)
+	return &CachedError{Err: record.err}, true
+}
+
+func isCachedError(err error) bool {
+	_, ok := err.(*CachedError)
+	return ok
+}
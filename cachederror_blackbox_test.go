@@ -0,0 +1,69 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetError(t *testing.T) {
+	failure := errors.New("upstream timed out")
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, append(opts, expiring.WithClock(clk))...)
+
+			assert.Nil(t, es.SetError("key", failure, time.Minute))
+			val, err := es.Get("key")
+			assert.Nil(t, val)
+			if assert.IsType(t, &expiring.CachedError{}, err) {
+				assert.Equal(t, failure.Error(), err.Error())
+			}
+			_, ttl, err := es.GetWithTTL("key")
+			assert.IsType(t, &expiring.CachedError{}, err)
+			assert.Equal(t, time.Minute, ttl)
+			_, err = es.Peek("key")
+			assert.IsType(t, &expiring.CachedError{}, err)
+			assert.Equal(t, uint64(3), es.Stats().ErrorHits)
+
+			clk.Advance(2 * time.Minute)
+			_, err = es.Get("key")
+			assert.Equal(t, expiring.ValueExpiredError, err)
+		})
+	}
+}
+
+func TestSetErrorKeepsError(t *testing.T) {
+	failure := errors.New("upstream timed out")
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	assert.Nil(t, es.SetError("key", failure, 0))
+
+	_, err := es.Get("key")
+	assert.True(t, errors.Is(err, failure))
+
+	// cached errors don't take leases
+	_, lease, err := es.GetWithLease("key")
+	assert.Equal(t, expiring.Lease{}, lease)
+	assert.True(t, errors.Is(err, failure))
+
+	assert.Equal(t, expiring.NilErrorError, es.SetError("key", nil, time.Minute))
+}
+
+func TestSetErrorSkipsFallback(t *testing.T) {
+	fallback := MapStore{cache: map[interface{}]interface{}{"key": "fallback"}}
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithFallback(&fallback))
+	assert.Nil(t, es.SetError("key", errors.New("failed"), time.Minute))
+
+	_, err := es.Get("key")
+	assert.IsType(t, &expiring.CachedError{}, err)
+	assert.Equal(t, uint64(0), es.Stats().FallbackHits)
+}
@@ -0,0 +1,47 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+)
+
+// touch records a read of key, for least recently used eviction and access
+// reports.
+func (es Store) touch(key interface{}) {
+	if es.tracker != nil && (es.trackAccess || es.settings.load().maxEntries > 0) {
+		es.tracker.touch(key, es.now())
+	}
+}
+
+// evict deletes values from the underlying store until the Store is back
+// within capacity. With WithEvictionWatermarks, crossing the high watermark
+// wakes the evictor instead, and values are only evicted here if the Store
+// is over capacity regardless.
+func (es Store) evict() {
+	if es.tracker == nil {
+		return
+	}
+	max := es.settings.load().maxEntries
+	if max <= 0 {
+		return
+	}
+	if es.evictor != nil && es.tracker.len() > watermark(max, es.watermarks.high) {
+		es.evictor.signal()
+	}
+	es.evictTo(max)
+}
+
+// evictTo deletes values from the underlying store until at most max are
+// tracked, returning how many were deleted.
+func (es Store) evictTo(max int) int {
+	evicted := es.keysOf(es.tracker.evict(max, es.pinnedRef()))[0]
+	if len(evicted) > 0 {
+		atomic.AddUint64(&es.stats.evictions, uint64(len(evicted)))
+		es.count(MetricEvictions, int64(len(evicted)))
+		es.deleteBatch(evicted)
+		for _, key := range evicted {
+			es.emit(Event{Type: EventEvict, Key: key})
+		}
+		es.cascade(evicted...)
+	}
+	return len(evicted)
+}
@@ -0,0 +1,176 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxEntriesEvictsLeastRecentlyUsed(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(2))
+
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set("b", "value", nil))
+	// reading a makes b the least recently used
+	_, err := es.Get("a")
+	assert.Nil(t, err)
+
+	assert.Nil(t, es.Set("c", "value", nil))
+	assert.Equal(t, []interface{}{"b"}, ms.deletedKeys)
+	assert.Len(t, ms.cache, 2)
+	assert.Equal(t, uint64(1), es.Stats().Evictions)
+}
+
+func TestMaxEntriesEvictsLowPriorityFirst(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(2))
+
+	assert.Nil(t, es.Set("high", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityHigh)}}))
+	assert.Nil(t, es.Set("normal", "value", nil))
+	assert.Nil(t, es.Set("low", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityLow)}}))
+	assert.Equal(t, []interface{}{"low"}, ms.deletedKeys)
+
+	assert.Nil(t, es.Set("another", "value", nil))
+	assert.Equal(t, []interface{}{"low", "normal"}, ms.deletedKeys)
+	_, ok := ms.cache["high"]
+	assert.True(t, ok)
+}
+
+func TestMaxEntriesSkipsPinned(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(1))
+
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityLow)}}))
+	assert.Nil(t, es.Set("other", "value", nil))
+	assert.Equal(t, []interface{}{"other"}, ms.deletedKeys)
+}
+
+func TestPriorityTagIsNotPassedOn(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+
+	options := &store.Options{Cost: 4, Tags: []string{"user", expiring.PriorityTag(expiring.PriorityLow)}}
+	assert.Nil(t, es.Set("key", "value", options))
+	assert.Equal(t, &store.Options{Cost: 4, Tags: []string{"user"}}, ms.lastSetOptions)
+	// the caller's options are left alone
+	assert.Len(t, options.Tags, 2)
+
+	assert.Nil(t, es.Set("key", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityHigh)}}))
+	assert.Equal(t, &store.Options{}, ms.lastSetOptions)
+}
+
+func TestReaperDeletesLowPriorityFirst(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	// all three values fall in the same bucket
+	clk := clock.NewFake(time.Now().Truncate(time.Second))
+	es := expiring.New(&ms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithClock(clk),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(time.Second),
+	)
+
+	assert.Nil(t, es.Set("high", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityHigh)}}))
+	assert.Nil(t, es.Set("normal", "value", nil))
+	assert.Nil(t, es.Set("low", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityLow)}}))
+
+	// end the bucket, and wait for the reaper to tick after it
+	clk.Advance(time.Second)
+	awaitReaper(t, clk, func() bool { return ms.len() == 0 })
+	assert.Nil(t, es.Close())
+
+	assert.Equal(t, []interface{}{"low", "normal", "high"}, ms.deletedKeys)
+}
+
+func TestPriorityString(t *testing.T) {
+	assert.Equal(t, "low", expiring.PriorityLow.String())
+	assert.Equal(t, "normal", expiring.PriorityNormal.String())
+	assert.Equal(t, "high", expiring.PriorityHigh.String())
+}
+
+func TestEvictionWatermarks(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithMaxEntries(10),
+		expiring.WithEvictionWatermarks(0.5, 0.8),
+	)
+	defer es.Close()
+
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, es.Set(i, "value", nil))
+	}
+	assert.Equal(t, uint64(0), es.Stats().Evictions)
+
+	// crossing the high watermark leaves the Set to the evictor
+	assert.Nil(t, es.Set(8, "value", nil))
+	deadline := time.Now().Add(time.Second)
+	for es.Stats().BackgroundEvictions < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stats := es.Stats()
+	assert.Equal(t, uint64(4), stats.BackgroundEvictions)
+	assert.Equal(t, uint64(4), stats.Evictions)
+	n, err := es.Len()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	_, err = es.Get(8)
+	assert.Nil(t, err)
+}
+
+func TestSegmentedLRUResistsScans(t *testing.T) {
+	for _, slru := range []bool{false, true} {
+		ms := MapStore{cache: map[interface{}]interface{}{}}
+		opts := []expiring.Option{expiring.WithMaxEntries(10)}
+		if slru {
+			opts = append(opts, expiring.WithSegmentedLRU(expiring.DefaultSLRUProtectedShare))
+		}
+		es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, opts...)
+
+		hot := []string{"hot 1", "hot 2", "hot 3", "hot 4"}
+		for _, key := range hot {
+			assert.Nil(t, es.Set(key, "value", nil))
+		}
+		for i := 0; i < 4; i++ {
+			assert.Nil(t, es.Set(i, "value", nil))
+		}
+		for _, key := range hot {
+			_, err := es.Get(key)
+			assert.Nil(t, err)
+		}
+		// a scan of keys read once
+		for i := 4; i < 20; i++ {
+			assert.Nil(t, es.Set(i, "value", nil))
+		}
+
+		for _, key := range hot {
+			_, ok := ms.cache[key]
+			assert.Equal(t, slru, ok, key)
+		}
+		assert.Len(t, ms.cache, 10)
+	}
+}
+
+func TestMaxEntriesKeepsCostlyValues(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(3), expiring.WithAccessTracking())
+
+	assert.Nil(t, es.Set("aggregate", "value", &store.Options{Tags: []string{expiring.CostTag(4 * time.Second)}}))
+	assert.Nil(t, es.Set("lookup", "value", &store.Options{Tags: []string{expiring.CostTag(2 * time.Millisecond)}}))
+	assert.Equal(t, &store.Options{}, ms.lastSetOptions)
+	assert.Nil(t, es.Set("other", "value", nil))
+
+	// aggregate is the least recently used, but the most costly
+	assert.Nil(t, es.Set("new", "value", nil))
+	assert.Equal(t, []interface{}{"other"}, ms.deletedKeys)
+	assert.Nil(t, es.Set("newer", "value", nil))
+	assert.Equal(t, []interface{}{"other", "new"}, ms.deletedKeys)
+
+	ks, err := es.KeyStats("aggregate")
+	assert.Nil(t, err)
+	assert.Equal(t, 4*time.Second, ks.Cost)
+}
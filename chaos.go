@@ -0,0 +1,93 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type (
+	// Chaos configures WithChaos, which injects faults so that applications
+	// can be tested against a misbehaving cache. Rates are fractions of
+	// calls, from 0 to 1; a rate of 0 injects nothing.
+	Chaos struct {
+		// LatencyRate is the fraction of calls to the underlying store
+		// delayed by Latency.
+		LatencyRate float64
+		Latency     time.Duration
+		// ErrorRate is the fraction of calls to the underlying store which
+		// fail with Err without being made.
+		ErrorRate float64
+		// Err is the error injected, ChaosError if nil.
+		Err error
+		// ExpireRate is the fraction of reads which find an unexpired value
+		// expired.
+		ExpireRate float64
+		// DropDeleteRate is the fraction of deletes which succeed without
+		// being made.
+		DropDeleteRate float64
+		// Seed seeds the faults injected, so that a run can be repeated. If
+		// 0, the faults are seeded from the clock.
+		Seed int64
+	}
+
+	chaosMonkey struct {
+		config Chaos
+
+		mu   sync.Mutex
+		rand *rand.Rand
+	}
+)
+
+var (
+	ChaosError = errors.New("error injected by chaos mode")
+)
+
+func newChaosMonkey(config Chaos) *chaosMonkey {
+	if config.Err == nil {
+		config.Err = ChaosError
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &chaosMonkey{config: config, rand: rand.New(rand.NewSource(seed))}
+}
+
+// roll reports whether a fault injected at rate happens this time.
+func (c *chaosMonkey) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < rate
+}
+
+// injectFault delays a call to the underlying store, or fails it, as
+// configured by WithChaos. The call must not be made if an error is
+// returned.
+func (es Store) injectFault() error {
+	if es.chaos == nil {
+		return nil
+	}
+	if es.chaos.roll(es.chaos.config.LatencyRate) {
+		time.Sleep(es.chaos.config.Latency)
+	}
+	if es.chaos.roll(es.chaos.config.ErrorRate) {
+		return es.chaos.config.Err
+	}
+	return nil
+}
+
+// dropDelete reports whether a delete should be dropped, see WithChaos.
+func (es Store) dropDelete() bool {
+	return es.chaos != nil && es.chaos.roll(es.chaos.config.DropDeleteRate)
+}
+
+// expireEarly reports whether a read should find its value expired early,
+// see WithChaos.
+func (es Store) expireEarly() bool {
+	return es.chaos != nil && es.chaos.roll(es.chaos.config.ExpireRate)
+}
@@ -0,0 +1,59 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosErrors(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithChaos(expiring.Chaos{ErrorRate: 1}))
+	assert.Equal(t, expiring.ChaosError, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ChaosError, err)
+	assert.Equal(t, 0, ms.setCount)
+	assert.Equal(t, 0, ms.getCount)
+}
+
+func TestChaosRates(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithChaos(expiring.Chaos{ErrorRate: 0.5, Seed: 1}))
+	var failed int
+	for i := 0; i < 100; i++ {
+		if es.Set(i, "value", nil) != nil {
+			failed++
+		}
+	}
+	assert.True(t, failed > 20 && failed < 80, "%d sets failed", failed)
+}
+
+func TestChaosLatency(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithChaos(expiring.Chaos{LatencyRate: 1, Latency: 10 * time.Millisecond}))
+	start := time.Now()
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+}
+
+func TestChaosExpiresEarly(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithChaos(expiring.Chaos{ExpireRate: 1}))
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, _, err = es.GetWithTTL("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
+
+func TestChaosDropsDeletes(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithChaos(expiring.Chaos{DropDeleteRate: 1}))
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Delete("key"))
+	assert.Equal(t, 0, ms.deleteCount)
+	assert.Contains(t, ms.cache, "key")
+}
@@ -0,0 +1,54 @@
+// Package clock abstracts the time read by expiring Stores, so that tests
+// can expire values without waiting for them to expire.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Clock tells the time.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// Real is the system clock.
+	Real struct{}
+
+	// Fake is a Clock which only moves when told to. It is safe for
+	// concurrent use.
+	Fake struct {
+		mu  sync.Mutex
+		now time.Time
+	}
+)
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// NewFake creates a Fake clock reading now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to now, which may be in its past.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
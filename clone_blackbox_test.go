@@ -0,0 +1,77 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	clonedStruct struct {
+		Tags    []string
+		Nested  *clonedStruct
+		private []int
+	}
+)
+
+func TestWithValueCloner(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithValueCloner(nil))
+
+	assert.Nil(t, es.Set("slice", []string{"a", "b"}, nil))
+	val, err := es.Get("slice")
+	assert.Nil(t, err)
+	val.([]string)[0] = "mutated"
+	val, err = es.Get("slice")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, val)
+
+	assert.Nil(t, es.Set("map", map[string]interface{}{"list": []interface{}{1, 2}}, nil))
+	val, _, err = es.GetWithTTL("map")
+	assert.Nil(t, err)
+	val.(map[string]interface{})["list"].([]interface{})[0] = "mutated"
+	val, err = es.Peek("map")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"list": []interface{}{1, 2}}, val)
+
+	// raw values are cloned too
+	ms.cache["raw"] = &clonedStruct{Tags: []string{"a"}, Nested: &clonedStruct{Tags: []string{"b"}}}
+	val, err = es.Get("raw")
+	assert.Nil(t, err)
+	val.(*clonedStruct).Nested.Tags[0] = "mutated"
+	assert.Equal(t, "b", ms.cache["raw"].(*clonedStruct).Nested.Tags[0])
+}
+
+func TestWithCustomValueCloner(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clones := 0
+	es := expiring.New(&ms, nil, expiring.WithValueCloner(func(v interface{}) interface{} {
+		clones++
+		return v
+	}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	_, _, err = es.GetStale("key")
+	assert.Nil(t, err)
+	ok, err := es.Has("key")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, clones)
+}
+
+func TestDeepCopy(t *testing.T) {
+	assert.Nil(t, expiring.DeepCopy(nil))
+	assert.Equal(t, 42, expiring.DeepCopy(42))
+
+	original := clonedStruct{Tags: []string{"a"}, private: []int{1}}
+	c := expiring.DeepCopy(original).(clonedStruct)
+	assert.Equal(t, original, c)
+	c.Tags[0] = "mutated"
+	assert.Equal(t, "a", original.Tags[0])
+
+	var nilSlice []string
+	assert.Nil(t, expiring.DeepCopy(nilSlice))
+}
@@ -0,0 +1,80 @@
+// Command expiring-cached serves an expiring Store over HTTP, so that
+// services not written in Go can share its expiration semantics.
+//
+// Values are read, written and deleted at /keys/{key}; see /openapi.yaml for
+// the full API. The Store is configured with a JSON Config file given by
+// -config, or from EXPIRING_ environment variables otherwise. Values are held
+// in memory by the server itself, or, with -store redis://..., in Redis,
+// where Go services wrapping the same Redis with compat.NewRedis share them.
+// Keys are listed, and reaped, only if they were written through the
+// server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+func main() {
+	var (
+		addr            = flag.String("addr", ":8080", "address to listen on")
+		configPath      = flag.String("config", "", "path to a JSON expiring.Config")
+		storeSpec       = flag.String("store", "memory", `the inner store: "memory", or a Redis URL such as redis://localhost:6379/0`)
+		shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for requests to finish on shutdown")
+	)
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	es, err := openStore(*storeSpec, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer es.Close()
+
+	server := &http.Server{Addr: *addr, Handler: newHandler(es)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("shutting down: %v", err)
+		}
+	}()
+
+	log.Printf("serving on %s", *addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
+
+func loadConfig(path string) (expiring.Config, error) {
+	if path == "" {
+		return expiring.ConfigFromEnv("EXPIRING_")
+	}
+	var cfg expiring.Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// memoryStore is the inner store of the server: a map which never
+	// expires anything itself.
+	memoryStore struct {
+		mu     sync.RWMutex
+		values map[interface{}]interface{}
+	}
+)
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[interface{}]interface{}{}}
+}
+
+func (ms *memoryStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.values[key], nil
+}
+
+func (ms *memoryStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.values[key] = value
+	return nil
+}
+
+func (ms *memoryStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.values, key)
+	return nil
+}
+
+func (ms *memoryStore) DeleteMulti(keys []interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, key := range keys {
+		delete(ms.values, key)
+	}
+	return nil
+}
+
+func (ms *memoryStore) Invalidate(options store.InvalidateOptions) error {
+	return nil
+}
+
+func (ms *memoryStore) Clear() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.values = map[interface{}]interface{}{}
+	return nil
+}
+
+func (ms *memoryStore) GetType() string {
+	return "memory"
+}
@@ -0,0 +1,118 @@
+package main
+
+// openAPI describes the server's API. It is served at /openapi.yaml.
+const openAPI = `openapi: 3.0.3
+info:
+  title: expiring-cached
+  description: An expiring cache, served over HTTP.
+  version: "1"
+paths:
+  /keys/:
+    get:
+      summary: List every stored key, including expired keys which haven't been deleted yet.
+      responses:
+        "200":
+          description: The keys.
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    key:
+                      type: string
+                    ttl:
+                      type: string
+                      description: The remaining time to live, negative once expired.
+  /reap:
+    post:
+      summary: Delete every expired value.
+      responses:
+        "200":
+          description: The number of values deleted.
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  reaped:
+                    type: integer
+  /keys/{key}:
+    parameters:
+      - name: key
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      summary: Read a value.
+      responses:
+        "200":
+          description: The value, if it exists and hasn't expired.
+          headers:
+            Expiring-TTL:
+              description: The remaining time to live, as a Go duration such as "90s".
+              schema:
+                type: string
+          content:
+            application/octet-stream:
+              schema:
+                type: string
+                format: binary
+        "400":
+          description: The key is longer than 250 bytes.
+        "404":
+          description: There is no value, or it has expired.
+    put:
+      summary: Write a value.
+      parameters:
+        - name: Expiring-TTL
+          in: header
+          description: How long to keep the value, as a Go duration such as "90s". The configured default is used if it is missing.
+          schema:
+            type: string
+      requestBody:
+        required: true
+        content:
+          application/octet-stream:
+            schema:
+              type: string
+              format: binary
+      responses:
+        "204":
+          description: The value was written.
+        "400":
+          description: The key is longer than 250 bytes, or the Expiring-TTL header is invalid.
+        "413":
+          description: The value is larger than 1MiB.
+    patch:
+      summary: Change how long a value is kept, rewriting it in one step, so that writes made through the server meanwhile aren't undone.
+      parameters:
+        - name: Expiring-TTL
+          in: header
+          required: true
+          description: How long to keep the value from now, as a Go duration such as "90s".
+          schema:
+            type: string
+      responses:
+        "204":
+          description: The value was rewritten.
+        "400":
+          description: The key is longer than 250 bytes, or the Expiring-TTL header is missing or invalid.
+        "404":
+          description: There is no value, or it has expired.
+    delete:
+      summary: Delete a value.
+      responses:
+        "204":
+          description: The value was deleted, or didn't exist.
+        "400":
+          description: The key is longer than 250 bytes.
+  /debug/stats:
+    get:
+      summary: The Store's Stats, as JSON.
+      responses:
+        "200":
+          description: The Stats.
+`
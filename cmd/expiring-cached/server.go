@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/stringkeys"
+)
+
+const (
+	// ttlHeader gives the TTL of a value written with PUT, and the remaining
+	// TTL of a value read with GET, as a Go duration such as "90s".
+	ttlHeader = "Expiring-TTL"
+
+	keysPath = "/keys/"
+
+	// maxValueSize limits the body of a PUT.
+	maxValueSize = 1 << 20
+)
+
+type (
+	// keyListing describes a stored key, as listed by GET /keys/.
+	keyListing struct {
+		Key string `json:"key"`
+		TTL string `json:"ttl"`
+	}
+
+	reapResult struct {
+		Reaped int `json:"reaped"`
+	}
+
+	// keyLocks serializes the writes of each key made through the server,
+	// so that a PATCH can't bring back a value deleted or replaced while it
+	// reads and rewrites it.
+	keyLocks [64]sync.Mutex
+)
+
+func newHandler(es expiring.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(keysPath, keysHandler(es))
+	mux.HandleFunc("/reap", reapHandler(es))
+	mux.Handle("/debug/", http.StripPrefix("/debug", es.DebugHandler()))
+	mux.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(openAPI))
+	})
+	return mux
+}
+
+func keysHandler(es expiring.Store) http.HandlerFunc {
+	sk := stringkeys.New(es)
+	var locks keyLocks
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, keysPath)
+		if key == "" {
+			if r.Method != http.MethodGet {
+				w.Header().Set("Allow", "GET")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			writeJSON(w, listKeys(es))
+			return
+		}
+		if err := sk.Validate(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			val, ttl, err := sk.GetWithTTL(r.Context(), key)
+			value, ok := val.([]byte)
+			if err != nil || !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if ttl > 0 {
+				w.Header().Set(ttlHeader, ttl.Round(time.Second).String())
+			}
+			_, _ = w.Write(value)
+
+		case http.MethodPut:
+			var options *store.Options
+			if h := r.Header.Get(ttlHeader); h != "" {
+				ttl, ok := parseTTL(h)
+				if !ok {
+					http.Error(w, "invalid "+ttlHeader+" header", http.StatusBadRequest)
+					return
+				}
+				options = &store.Options{Expiration: ttl}
+			}
+			value, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			defer locks.lock(key)()
+			if err := sk.Set(r.Context(), key, value, options); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodPatch:
+			ttl, ok := parseTTL(r.Header.Get(ttlHeader))
+			if !ok {
+				http.Error(w, "invalid "+ttlHeader+" header", http.StatusBadRequest)
+				return
+			}
+			defer locks.lock(key)()
+			val, err := sk.Get(r.Context(), key)
+			value, ok := val.([]byte)
+			if err != nil || !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			if err := sk.Set(r.Context(), key, value, &store.Options{Expiration: ttl}); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodDelete:
+			defer locks.lock(key)()
+			if err := sk.Delete(r.Context(), key); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, PATCH, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// parseTTL parses the TTL given in a ttlHeader, which must be positive.
+func parseTTL(h string) (time.Duration, bool) {
+	ttl, err := time.ParseDuration(h)
+	return ttl, err == nil && ttl > 0
+}
+
+// lock locks key's writes, returning the function which unlocks them.
+func (l *keyLocks) lock(key string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	mu := &l[h.Sum32()%uint32(len(l))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// reapHandler deletes every expired value, as a Get of it would.
+func reapHandler(es expiring.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var result reapResult
+		for _, report := range es.ColdKeys(math.MaxInt32) {
+			if report.TTL <= 0 {
+				if _, err := es.Get(report.Key); errors.Is(err, expiring.ValueExpiredError) {
+					result.Reaped++
+				}
+			}
+		}
+		writeJSON(w, result)
+	}
+}
+
+// listKeys lists every key written through the server, including expired
+// ones which haven't been deleted yet.
+func listKeys(es expiring.Store) []keyListing {
+	reports := es.ColdKeys(math.MaxInt32)
+	listing := make([]keyListing, 0, len(reports))
+	for _, report := range reports {
+		if key, ok := report.Key.(string); ok {
+			listing = append(listing, keyListing{Key: key, TTL: report.TTL.Round(time.Second).String()})
+		}
+	}
+	return listing
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	es := expiring.New(newMemoryStore(), &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking())
+	server := httptest.NewServer(newHandler(es))
+	defer server.Close()
+
+	do := func(method, key, body string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+"/keys/"+key, strings.NewReader(body))
+		assert.Nil(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return resp, string(b)
+	}
+
+	resp, _ := do(http.MethodGet, "greeting", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodPut, "greeting", "hello", http.Header{ttlHeader: {"90s"}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body := do(http.MethodGet, "greeting", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, "1m30s", resp.Header.Get(ttlHeader))
+
+	resp, _ = do(http.MethodPut, "default", "value", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "default", "", nil)
+	assert.Equal(t, "1h0m0s", resp.Header.Get(ttlHeader))
+
+	resp, _ = do(http.MethodPut, "bad", "value", http.Header{ttlHeader: {"soon"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = do(http.MethodPut, strings.Repeat("k", 251), "value", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = do(http.MethodPatch, "greeting", "", http.Header{ttlHeader: {"2m"}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body = do(http.MethodGet, "greeting", "", nil)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, "2m0s", resp.Header.Get(ttlHeader))
+	resp, _ = do(http.MethodPatch, "greeting", "", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = do(http.MethodPatch, "missing", "", http.Header{ttlHeader: {"2m"}})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodDelete, "greeting", "", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodGet, "greeting", "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodPost, "greeting", "", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, _ = do(http.MethodPut, "short", "value", http.Header{ttlHeader: {"1ms"}})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	time.Sleep(5 * time.Millisecond)
+	_, body = do(http.MethodGet, "", "", nil)
+	assert.Contains(t, body, `{"key":"default","ttl":"1h0m0s"}`)
+	assert.Contains(t, body, `"key":"short"`)
+
+	resp, err := http.Post(server.URL+"/reap", "", nil)
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "{\"reaped\":1}\n", string(b))
+	_, body = do(http.MethodGet, "", "", nil)
+	assert.NotContains(t, body, "short")
+
+	resp, err = http.Get(server.URL + "/openapi.yaml")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestOpenStore(t *testing.T) {
+	es, err := openStore("memory", expiring.Config{DefaultTTL: expiring.Duration(time.Hour)})
+	assert.Nil(t, err)
+	assert.Nil(t, es.Set("greeting", []byte("hello"), nil))
+	val, err := es.Get("greeting")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), val)
+	es.Close()
+
+	// Redis clients connect when first used
+	es, err = openStore("redis://localhost:6379/2", expiring.Config{})
+	assert.Nil(t, err)
+	es.Close()
+
+	_, err = openStore("redis://localhost:6379/db", expiring.Config{})
+	assert.NotNil(t, err)
+	_, err = openStore("memcache://localhost", expiring.Config{})
+	assert.NotNil(t, err)
+	_, err = openStore("memory", expiring.Config{Jitter: 2})
+	assert.NotNil(t, err)
+}
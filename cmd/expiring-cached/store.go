@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/compat"
+)
+
+// openStore creates the Store the server serves, as described by cfg, around
+// the inner store named by spec: "memory", for values held by the server
+// itself, or a Redis URL such as "redis://:password@localhost:6379/0", for
+// values held in Redis, in the binary envelope, where Go services using
+// compat.NewRedis read and write them too.
+func openStore(spec string, cfg expiring.Config) (expiring.Store, error) {
+	switch {
+	case spec == "memory":
+		return expiring.NewFromConfig(newMemoryStore(), cfg, expiring.WithAccessTracking())
+	case strings.HasPrefix(spec, "redis://") || strings.HasPrefix(spec, "rediss://"):
+		options, err := redis.ParseURL(spec)
+		if err != nil {
+			return expiring.Store{}, err
+		}
+		return compat.NewRedisFromConfig(redis.NewClient(options), cfg, expiring.WithAccessTracking())
+	}
+	return expiring.Store{}, fmt.Errorf("unknown store %q, want memory or a redis:// URL", spec)
+}
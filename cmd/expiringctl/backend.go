@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// backend is where expiringctl finds the values it inspects.
+	backend interface {
+		list() ([]keyListing, error)
+		// get returns key's value in its envelope, and the envelope's
+		// format.
+		get(key string) (expiring.Envelope, expiring.EnvelopeFormat, error)
+		expire(key string, ttl time.Duration) error
+		del(key string) error
+		// reap deletes every expired value, returning how many it deleted.
+		reap() (int, error)
+		close() error
+	}
+
+	keyListing struct {
+		Key string `json:"key"`
+		TTL string `json:"ttl"`
+	}
+
+	// rawStore holds the envelopes written by a Store created
+	// WithBinaryEnvelope or WithEnvelopeFormat, by their keys.
+	rawStore interface {
+		keys() ([]string, error)
+		load(key string) ([]byte, error)
+		// update replaces key's envelope with the one fn returns for it, or
+		// deletes key if fn returns nil. The stores which can make it a
+		// single atomic operation do.
+		update(key string, fn func(raw []byte) ([]byte, error)) error
+		close() error
+	}
+
+	// envelopes is the backend of a rawStore.
+	envelopes struct {
+		raw rawStore
+		now func() time.Time
+	}
+)
+
+var (
+	notFoundError = errors.New("not found")
+
+	// unchangedError is returned to update by functions which leave the
+	// envelope they are given as it is.
+	unchangedError = errors.New("unchanged")
+)
+
+func notFound(key string) error {
+	return fmt.Errorf("%s: %w", key, notFoundError)
+}
+
+// list lists every key, with "-" for the TTL of those which don't hold an
+// envelope.
+func (e envelopes) list() ([]keyListing, error) {
+	keys, err := e.raw.keys()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	listing := make([]keyListing, 0, len(keys))
+	for _, key := range keys {
+		raw, err := e.raw.load(key)
+		if errors.Is(err, notFoundError) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttl := "-"
+		if env, _, err := expiring.DecodeEnvelope(raw); err == nil {
+			ttl = env.ExpireAt.Sub(e.now()).Round(time.Second).String()
+		}
+		listing = append(listing, keyListing{Key: key, TTL: ttl})
+	}
+	return listing, nil
+}
+
+func (e envelopes) get(key string) (expiring.Envelope, expiring.EnvelopeFormat, error) {
+	raw, err := e.raw.load(key)
+	if err != nil {
+		return expiring.Envelope{}, 0, err
+	}
+	env, format, err := expiring.DecodeEnvelope(raw)
+	if err != nil {
+		return expiring.Envelope{}, 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return env, format, nil
+}
+
+// expire rewrites key's envelope, in the format it was written in, to
+// expire after ttl.
+func (e envelopes) expire(key string, ttl time.Duration) error {
+	return e.raw.update(key, func(raw []byte) ([]byte, error) {
+		env, format, err := expiring.DecodeEnvelope(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		env.ExpireAt = e.now().Add(ttl)
+		return expiring.EncodeEnvelope(env, format)
+	})
+}
+
+func (e envelopes) del(key string) error {
+	err := e.raw.update(key, func(raw []byte) ([]byte, error) {
+		return nil, nil
+	})
+	if errors.Is(err, notFoundError) {
+		return nil
+	}
+	return err
+}
+
+// reap deletes the keys whose envelopes have expired, checking each again
+// as it deletes it.
+func (e envelopes) reap() (int, error) {
+	keys, err := e.raw.keys()
+	if err != nil {
+		return 0, err
+	}
+	reaped := 0
+	for _, key := range keys {
+		err := e.raw.update(key, func(raw []byte) ([]byte, error) {
+			env, _, err := expiring.DecodeEnvelope(raw)
+			if err != nil || !env.ExpireAt.Before(e.now()) {
+				return nil, unchangedError
+			}
+			return nil, nil
+		})
+		switch {
+		case err == nil:
+			reaped++
+		case !errors.Is(err, unchangedError) && !errors.Is(err, notFoundError):
+			return reaped, err
+		}
+	}
+	return reaped, nil
+}
+
+func (e envelopes) close() error {
+	return e.raw.close()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileBackends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expiringctl")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	write := func(name string, env expiring.Envelope, format expiring.EnvelopeFormat) {
+		b, err := expiring.EncodeEnvelope(env, format)
+		assert.Nil(t, err)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), b, 0600))
+	}
+	write("greeting", expiring.Envelope{Kind: expiring.EnvelopeString, Value: []byte("hello"), ExpireAt: now.Add(time.Minute)}, expiring.JSONEnvelope)
+	write("old", expiring.Envelope{Kind: expiring.EnvelopeBytes, Value: []byte("stale"), ExpireAt: now.Add(-time.Minute)}, expiring.BinaryEnvelope)
+	write("a%2Fb%2Ec", expiring.Envelope{Kind: expiring.EnvelopeBytes, Value: []byte("c"), ExpireAt: now.Add(time.Hour)}, expiring.MsgpackEnvelope)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "plain"), []byte("not an envelope"), 0600))
+
+	ctl := func(backend string, args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(append([]string{backend}, args...), &out)
+		return out.String(), err
+	}
+	ctlDir := func(args ...string) (string, error) { return ctl("-dir="+dir, args...) }
+
+	out, err := ctlDir("ls")
+	assert.Nil(t, err)
+	assert.Equal(t, "1h0m0s\ta/b.c\n1m0s\tgreeting\n-1m0s\told\n-\tplain\n", out)
+
+	out, err = ctlDir("get", "greeting")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", out)
+	_, err = ctlDir("get", "plain")
+	assert.True(t, errors.Is(err, expiring.InvalidEnvelopeError))
+	_, err = ctlDir("get", "missing")
+	assert.EqualError(t, err, "missing: not found")
+
+	// envelopes are rewritten in their own format
+	_, err = ctlDir("expire", "greeting", "90s")
+	assert.Nil(t, err)
+	out, err = ctlDir("ttl", "greeting")
+	assert.Nil(t, err)
+	assert.Equal(t, "1m30s\n", out)
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "greeting"))
+	assert.Nil(t, err)
+	env, format, err := expiring.DecodeEnvelope(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, expiring.JSONEnvelope, format)
+	assert.Equal(t, expiring.EnvelopeString, env.Kind)
+	_, err = ctlDir("expire", "missing", "90s")
+	assert.EqualError(t, err, "missing: not found")
+
+	out, err = ctlDir("reap")
+	assert.Nil(t, err)
+	assert.Equal(t, "reaped 1\n", out)
+	_, err = os.Stat(filepath.Join(dir, "old"))
+	assert.True(t, os.IsNotExist(err))
+
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	out, err = ctlDir("snapshot", snapshotPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "wrote 2\n", out)
+
+	ctlSnapshot := func(args ...string) (string, error) { return ctl("-snapshot="+snapshotPath, args...) }
+	out, err = ctlSnapshot("get", "a/b.c")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", out)
+	_, err = ctlSnapshot("del", "greeting")
+	assert.Nil(t, err)
+	_, err = ctlSnapshot("del", "greeting")
+	assert.Nil(t, err)
+	out, err = ctlSnapshot("ls")
+	assert.Nil(t, err)
+	assert.Equal(t, "1h0m0s\ta/b.c\n", out)
+
+	// the directory still has the deleted key
+	_, err = ctlDir("get", "greeting")
+	assert.Nil(t, err)
+}
+
+func TestNativeTTL(t *testing.T) {
+	now := time.Now()
+	envelope := func(ttl time.Duration) []byte {
+		return expiring.AppendEnvelope(nil, []byte("value"), now.Add(ttl))
+	}
+
+	// keys without a TTL keep none
+	assert.Equal(t, time.Duration(0), nativeTTL(envelope(time.Minute), envelope(time.Hour), -1, now))
+	// keys keep the time they had after their envelopes expire
+	assert.Equal(t, time.Hour+time.Minute, nativeTTL(envelope(time.Minute), envelope(time.Hour), 2*time.Minute, now))
+	assert.Equal(t, time.Hour, nativeTTL(envelope(time.Minute), envelope(time.Hour), time.Second, now))
+	assert.Equal(t, time.Millisecond, nativeTTL(envelope(time.Minute), envelope(-time.Hour), time.Minute, now))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// client is the backend of values held by an expiring-cached server,
+	// reached through its HTTP API.
+	client struct {
+		base string
+	}
+)
+
+// ttlHeader is the header expiring-cached reads and writes TTLs in.
+const ttlHeader = "Expiring-TTL"
+
+func (c *client) keyURL(key string) string {
+	return strings.TrimSuffix(c.base, "/") + "/keys/" + url.PathEscape(key)
+}
+
+func (c *client) list() ([]keyListing, error) {
+	resp, err := http.Get(strings.TrimSuffix(c.base, "/") + "/keys/")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var keys []keyListing
+	err = json.NewDecoder(resp.Body).Decode(&keys)
+	return keys, err
+}
+
+// get returns the value of key in an envelope, which expires when the
+// server says it does, if it says.
+func (c *client) get(key string) (expiring.Envelope, expiring.EnvelopeFormat, error) {
+	resp, err := http.Get(c.keyURL(key))
+	if err != nil {
+		return expiring.Envelope{}, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return expiring.Envelope{}, 0, notFound(key)
+	}
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return expiring.Envelope{}, 0, err
+	}
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return expiring.Envelope{}, 0, err
+	}
+	env := expiring.Envelope{Kind: expiring.EnvelopeBytes, Value: value}
+	if ttl, err := time.ParseDuration(resp.Header.Get(ttlHeader)); err == nil {
+		env.ExpireAt = time.Now().Add(ttl)
+	}
+	return env, expiring.BinaryEnvelope, nil
+}
+
+// expire has the server rewrite key to expire after ttl, in one request.
+func (c *client) expire(key string, ttl time.Duration) error {
+	req, err := http.NewRequest(http.MethodPatch, c.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(ttlHeader, ttl.String())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return notFound(key)
+	}
+	return checkStatus(resp, http.StatusNoContent)
+}
+
+func (c *client) del(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, http.StatusNoContent)
+}
+
+func (c *client) reap() (int, error) {
+	resp, err := http.Post(strings.TrimSuffix(c.base, "/")+"/reap", "", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp, http.StatusOK); err != nil {
+		return 0, err
+	}
+	var result struct {
+		Reaped int `json:"reaped"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result.Reaped, err
+}
+
+func (c *client) close() error {
+	return nil
+}
+
+func (c *client) do(req *http.Request, status int) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp, status)
+}
+
+func checkStatus(resp *http.Response, status int) error {
+	if resp.StatusCode == status {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL, resp.Status, strings.TrimSpace(string(body)))
+}
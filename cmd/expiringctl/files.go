@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// dirStore holds each key's envelope in a file of a directory, named
+	// by the key, escaped as a URL path segment.
+	dirStore struct {
+		dir string
+	}
+
+	// snapshotStore holds envelopes in a snapshot file: a JSON object of
+	// each key's envelope, in base64, as written by the snapshot command.
+	snapshotStore struct {
+		path   string
+		values map[string][]byte
+	}
+)
+
+// tempPrefix starts the names of the files being written, which aren't
+// listed as keys.
+const tempPrefix = ".expiringctl-"
+
+func (ds dirStore) keys() ([]string, error) {
+	infos, err := ioutil.ReadDir(ds.dir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), tempPrefix) {
+			continue
+		}
+		if key, err := url.PathUnescape(info.Name()); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (ds dirStore) load(key string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(ds.path(key))
+	if os.IsNotExist(err) {
+		return nil, notFound(key)
+	}
+	return raw, err
+}
+
+// update rewrites key's file by renaming a new one over it. Files aren't
+// locked, so a process writing key meanwhile may have its write undone.
+func (ds dirStore) update(key string, fn func(raw []byte) ([]byte, error)) error {
+	raw, err := ds.load(key)
+	if err != nil {
+		return err
+	}
+	next, err := fn(raw)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		return os.Remove(ds.path(key))
+	}
+	return writeFile(ds.path(key), next)
+}
+
+func (ds dirStore) close() error {
+	return nil
+}
+
+// path returns the path of key's file. Dots are escaped too, so that no
+// key is named "." or "..", or like a file being written.
+func (ds dirStore) path(key string) string {
+	return filepath.Join(ds.dir, strings.Replace(url.PathEscape(key), ".", "%2E", -1))
+}
+
+func openSnapshot(path string) (*snapshotStore, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ss := &snapshotStore{path: path}
+	if err := json.Unmarshal(data, &ss.values); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (ss *snapshotStore) keys() ([]string, error) {
+	keys := make([]string, 0, len(ss.values))
+	for key := range ss.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (ss *snapshotStore) load(key string) ([]byte, error) {
+	raw, ok := ss.values[key]
+	if !ok {
+		return nil, notFound(key)
+	}
+	return raw, nil
+}
+
+// update rewrites the whole snapshot file. Like dirStore's, it isn't
+// locked.
+func (ss *snapshotStore) update(key string, fn func(raw []byte) ([]byte, error)) error {
+	raw, err := ss.load(key)
+	if err != nil {
+		return err
+	}
+	next, err := fn(raw)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		delete(ss.values, key)
+	} else {
+		ss.values[key] = next
+	}
+	return writeSnapshot(ss.path, ss.values)
+}
+
+func (ss *snapshotStore) close() error {
+	return nil
+}
+
+// snapshot writes the envelopes of every key held by b to a snapshot file
+// at path, returning how many it wrote.
+func snapshot(b backend, path string) (int, error) {
+	listing, err := b.list()
+	if err != nil {
+		return 0, err
+	}
+	values := make(map[string][]byte, len(listing))
+	for _, k := range listing {
+		env, format, err := b.get(k.Key)
+		if err != nil {
+			// not an envelope, or deleted since it was listed
+			continue
+		}
+		if values[k.Key], err = expiring.EncodeEnvelope(env, format); err != nil {
+			return 0, err
+		}
+	}
+	return len(values), writeSnapshot(path, values)
+}
+
+func writeSnapshot(path string, values map[string][]byte) error {
+	data, err := json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(data, '\n'))
+}
+
+// writeFile replaces the file at path with one holding data, by renaming
+// a new file over it, so that readers never see it half written.
+func writeFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
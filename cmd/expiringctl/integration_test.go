@@ -0,0 +1,49 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/go-redis/redis/v7"
+	"github.com/nabowler/expiring_gocache/compat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisIntegration(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	es := compat.NewRedis(client, &store.Options{Expiration: time.Minute})
+	assert.Nil(t, es.Set("expiringctl-integration", []byte("value"), nil))
+	defer client.Del("expiringctl-integration")
+
+	ctl := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(append([]string{"-redis", "redis://" + addr}, args...), &out)
+		return out.String(), err
+	}
+	out, err := ctl("get", "expiringctl-integration")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", out)
+
+	_, err = ctl("expire", "expiringctl-integration", "1h")
+	assert.Nil(t, err)
+	_, ttl, err := es.GetWithTTL("expiringctl-integration")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 59*time.Minute)
+	assert.True(t, client.PTTL("expiringctl-integration").Val() > 59*time.Minute)
+
+	_, err = ctl("del", "expiringctl-integration")
+	assert.Nil(t, err)
+	_, err = es.Get("expiringctl-integration")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,165 @@
+// Command expiringctl inspects and edits the values held by an expiring
+// Store, for operators debugging a production cache.
+//
+// Usage:
+//
+//	expiringctl [BACKEND] ls
+//	expiringctl [BACKEND] get KEY
+//	expiringctl [BACKEND] ttl KEY
+//	expiringctl [BACKEND] expire KEY TTL
+//	expiringctl [BACKEND] del KEY
+//	expiringctl [BACKEND] reap
+//	expiringctl [BACKEND] snapshot FILE
+//
+// Stores created WithBinaryEnvelope or WithEnvelopeFormat, such as those
+// of compat.NewRedis, write each value in an envelope along with its
+// expiration, which expiringctl decodes from the backing store itself:
+// Redis, given by -redis URL, a directory holding a file per key, given by
+// -dir, or a snapshot file written by the snapshot command, given by
+// -snapshot. Otherwise, -server reaches the Store of an expiring-cached
+// server through its HTTP API; it is the default.
+//
+// expire and reap read and rewrite each value as one operation: in a Redis
+// transaction which fails, and is retried, if another client writes the key
+// meanwhile, or in a single request to the server, which serializes it with
+// the server's other writes, though not with writes made to its inner store
+// directly. Directories and snapshots aren't locked, so only edit them while
+// no other process writes to them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const usage = `usage: expiringctl [-server URL | -redis URL | -dir DIR | -snapshot FILE] COMMAND [ARGS]
+
+commands:
+  ls               list keys with their TTLs
+  get KEY          print the value of KEY
+  ttl KEY          print the remaining TTL of KEY
+  expire KEY TTL   rewrite KEY to expire after TTL, e.g. 90s
+  del KEY          delete KEY
+  reap             delete every expired value
+  snapshot FILE    write every value, with its envelope, to a snapshot FILE
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "expiringctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("expiringctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	var (
+		server       = flags.String("server", "http://localhost:8080", "URL of the expiring-cached server")
+		redisURL     = flags.String("redis", "", "URL of the Redis server holding envelopes, e.g. redis://localhost:6379/0")
+		dir          = flags.String("dir", "", "directory holding a file per key, each an envelope")
+		snapshotPath = flags.String("snapshot", "", "snapshot file written by the snapshot command")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return fmt.Errorf("missing command")
+	}
+	command, args := args[0], args[1:]
+	want := map[string]int{"ls": 0, "get": 1, "ttl": 1, "expire": 2, "del": 1, "reap": 0, "snapshot": 1}
+	n, ok := want[command]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", command)
+	}
+	if len(args) != n {
+		return fmt.Errorf("%s takes %d arguments, got %d", command, n, len(args))
+	}
+
+	b, err := open(*server, *redisURL, *dir, *snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer b.close()
+
+	switch command {
+	case "ls":
+		keys, err := b.list()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			fmt.Fprintf(out, "%s\t%s\n", k.TTL, k.Key)
+		}
+	case "get":
+		env, _, err := b.get(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(env.Value)
+		return err
+	case "ttl":
+		env, _, err := b.get(args[0])
+		if err != nil {
+			return err
+		}
+		if env.ExpireAt.IsZero() {
+			fmt.Fprintln(out, "-")
+			return nil
+		}
+		fmt.Fprintln(out, time.Until(env.ExpireAt).Round(time.Second))
+	case "expire":
+		ttl, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("TTL must be positive, got %s", ttl)
+		}
+		return b.expire(args[0], ttl)
+	case "del":
+		return b.del(args[0])
+	case "reap":
+		reaped, err := b.reap()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reaped %d\n", reaped)
+	case "snapshot":
+		written, err := snapshot(b, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "wrote %d\n", written)
+	}
+	return nil
+}
+
+// open returns the backend given by the flags: the first of redisURL, dir
+// and snapshotPath which is set, or else server.
+func open(server, redisURL, dir, snapshotPath string) (backend, error) {
+	switch {
+	case redisURL != "":
+		rs, err := openRedis(redisURL)
+		if err != nil {
+			return nil, err
+		}
+		return envelopes{raw: rs, now: time.Now}, nil
+	case dir != "":
+		return envelopes{raw: dirStore{dir: dir}, now: time.Now}, nil
+	case snapshotPath != "":
+		ss, err := openSnapshot(snapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		return envelopes{raw: ss, now: time.Now}, nil
+	}
+	return &client{base: server}, nil
+}
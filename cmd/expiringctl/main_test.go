@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer mimics the API of expiring-cached.
+type fakeServer struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path == "/reap" {
+		_, _ = w.Write([]byte(`{"reaped":2}`))
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	switch {
+	case key == "":
+		var keys []keyListing
+		for k := range s.values {
+			keys = append(keys, keyListing{Key: k, TTL: s.ttls[k]})
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	case r.Method == http.MethodGet:
+		v, ok := s.values[key]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set(ttlHeader, s.ttls[key])
+		_, _ = w.Write([]byte(v))
+	case r.Method == http.MethodPut:
+		b, _ := ioutil.ReadAll(r.Body)
+		s.values[key], s.ttls[key] = string(b), r.Header.Get(ttlHeader)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPatch:
+		if _, ok := s.values[key]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		s.ttls[key] = r.Header.Get(ttlHeader)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(s.values, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestRun(t *testing.T) {
+	fake := &fakeServer{values: map[string]string{"greeting": "hello"}, ttls: map[string]string{"greeting": "1m0s"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctl := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(append([]string{"-server", server.URL}, args...), &out)
+		return out.String(), err
+	}
+
+	out, err := ctl("ls")
+	assert.Nil(t, err)
+	assert.Equal(t, "1m0s\tgreeting\n", out)
+
+	out, err = ctl("get", "greeting")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", out)
+
+	_, err = ctl("expire", "greeting", "90s")
+	assert.Nil(t, err)
+	out, err = ctl("ttl", "greeting")
+	assert.Nil(t, err)
+	assert.Equal(t, "1m30s\n", out)
+
+	out, err = ctl("reap")
+	assert.Nil(t, err)
+	assert.Equal(t, "reaped 2\n", out)
+
+	_, err = ctl("del", "greeting")
+	assert.Nil(t, err)
+	_, err = ctl("get", "greeting")
+	assert.EqualError(t, err, "greeting: not found")
+
+	_, err = ctl("get")
+	assert.EqualError(t, err, "get takes 1 arguments, got 0")
+	_, err = ctl("frobnicate")
+	assert.NotNil(t, err)
+}
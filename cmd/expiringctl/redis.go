@@ -0,0 +1,116 @@
+package main
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// redisStore holds envelopes in Redis, as written by compat.NewRedis.
+	redisStore struct {
+		client *redis.Client
+	}
+)
+
+const (
+	// redisScanCount is how many keys each SCAN asks for.
+	redisScanCount = 1000
+	// redisAttempts is how many times an update is tried while other
+	// clients change the key under it.
+	redisAttempts = 5
+)
+
+func openRedis(url string) (*redisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisStore{client: redis.NewClient(options)}, nil
+}
+
+func (rs *redisStore) keys() ([]string, error) {
+	var keys []string
+	iter := rs.client.Scan(0, "", redisScanCount).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (rs *redisStore) load(key string) ([]byte, error) {
+	raw, err := rs.client.Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, notFound(key)
+	}
+	return raw, err
+}
+
+// update reads and rewrites key in a transaction which fails if another
+// client writes key in between, retrying it if so.
+func (rs *redisStore) update(key string, fn func(raw []byte) ([]byte, error)) error {
+	var err error
+	for attempt := 0; attempt < redisAttempts; attempt++ {
+		err = rs.client.Watch(func(tx *redis.Tx) error {
+			raw, err := tx.Get(key).Bytes()
+			if err == redis.Nil {
+				return notFound(key)
+			}
+			if err != nil {
+				return err
+			}
+			pttl, err := tx.PTTL(key).Result()
+			if err != nil {
+				return err
+			}
+			next, err := fn(raw)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+				if next == nil {
+					pipe.Del(key)
+				} else {
+					pipe.Set(key, next, nativeTTL(raw, next, pttl, time.Now()))
+				}
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+func (rs *redisStore) close() error {
+	return rs.client.Close()
+}
+
+// nativeTTL returns the Redis TTL of next, which replaces raw, a key with
+// the Redis TTL pttl: none if the key had none, or else as long after next
+// expires as the key's was after raw did.
+func nativeTTL(raw, next []byte, pttl time.Duration, now time.Time) time.Duration {
+	if pttl <= 0 {
+		return 0
+	}
+	old, _, err := expiring.DecodeEnvelope(raw)
+	if err != nil {
+		return pttl
+	}
+	env, _, err := expiring.DecodeEnvelope(next)
+	if err != nil {
+		return pttl
+	}
+	margin := pttl - old.ExpireAt.Sub(now)
+	if margin < 0 {
+		margin = 0
+	}
+	if ttl := env.ExpireAt.Sub(now) + margin; ttl > time.Millisecond {
+		return ttl
+	}
+	// a TTL of 0 would keep the key forever
+	return time.Millisecond
+}
@@ -0,0 +1,66 @@
+package expiring_gocache
+
+import (
+	"errors"
+)
+
+type (
+	// Codec encodes values which the binary envelope can't hold itself, see
+	// WithCodec. The codecs package has implementations for common formats.
+	Codec interface {
+		Marshal(value interface{}) ([]byte, error)
+		Unmarshal(data []byte) (interface{}, error)
+	}
+
+	// codedValue is a value encoded by a Codec.
+	codedValue []byte
+)
+
+var (
+	NoCodecError = errors.New("value was encoded by a codec, but the store has none")
+)
+
+// encodable reports whether the binary envelope can hold value without a
+// codec.
+func encodable(value interface{}) bool {
+	switch value.(type) {
+	case []byte, string, leaseRecord, cachedErrorRecord, codedValue:
+		return true
+	}
+	return false
+}
+
+// encoded returns ew with its value encoded by the Store's codec, if it has
+// one and the binary envelope can't hold the value itself.
+func (es Store) encoded(ew wrappedValue) (wrappedValue, error) {
+	if es.codec == nil || encodable(ew.value) {
+		return ew, nil
+	}
+	data, err := es.codec.Marshal(ew.value)
+	if err != nil {
+		return wrappedValue{}, err
+	}
+	ew.value = codedValue(data)
+	// the ETag etagFor numbered the value with is replaced by a hash of its
+	// encoding, so writing an equal value doesn't change it
+	ew.etag = contentHash(data)
+	return ew, nil
+}
+
+// decoded returns ew with its value decoded by the Store's codec, if it was
+// encoded by one.
+func (es Store) decoded(ew wrappedValue) (wrappedValue, error) {
+	data, ok := ew.value.(codedValue)
+	if !ok {
+		return ew, nil
+	}
+	if es.codec == nil {
+		return wrappedValue{}, NoCodecError
+	}
+	value, err := es.codec.Unmarshal(data)
+	if err != nil {
+		return wrappedValue{}, err
+	}
+	ew.value = value
+	return ew, nil
+}
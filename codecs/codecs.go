@@ -0,0 +1,126 @@
+// Package codecs has expiring.Codec implementations for common encodings,
+// so that structs can be Set on stores which only hold []byte:
+//
+//	es := expiring.New(redisStore, nil, expiring.WithCodec(codecs.JSON(User{})))
+//	err := es.Set("user:42", User{Name: "Ada"}, nil)
+//
+// Each codec decodes values into the type of the prototype it is given, so
+// a Store with a codec holds values of one type. A nil prototype decodes
+// JSON into interface{}, and gob into whichever type was encoded, provided
+// it was registered with gob.Register.
+//
+// Other encodings can be plugged in with New, e.g. YAML with
+// gopkg.in/yaml.v2:
+//
+//	codec := codecs.New(User{}, yaml.Marshal, yaml.Unmarshal)
+package codecs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/internal/msgpack"
+)
+
+type (
+	// MarshalFunc and UnmarshalFunc have the signatures of json.Marshal
+	// and json.Unmarshal, which most encoding packages share.
+	MarshalFunc   func(value interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, value interface{}) error
+
+	codec struct {
+		typ       reflect.Type
+		marshal   MarshalFunc
+		unmarshal UnmarshalFunc
+	}
+)
+
+var (
+	NotAMessageError = errors.New("value is not a proto.Message")
+)
+
+// New returns a Codec which encodes values with marshal, and decodes them
+// into the type of prototype with unmarshal.
+func New(prototype interface{}, marshal MarshalFunc, unmarshal UnmarshalFunc) expiring.Codec {
+	return codec{typ: reflect.TypeOf(prototype), marshal: marshal, unmarshal: unmarshal}
+}
+
+// JSON encodes values with encoding/json.
+func JSON(prototype interface{}) expiring.Codec {
+	return New(prototype, json.Marshal, json.Unmarshal)
+}
+
+// Gob encodes values with encoding/gob. Each value is encoded on its own,
+// with its type, so gob suits values read more often than they are written.
+func Gob(prototype interface{}) expiring.Codec {
+	return New(prototype, marshalGob, unmarshalGob)
+}
+
+// Msgpack encodes values with MessagePack, as the envelope's
+// MsgpackEnvelope format does. Structs are encoded as maps of their exported
+// fields, named by their msgpack tags, if any, or else their names, and
+// values which implement encoding.BinaryMarshaler, such as time.Time, as
+// binaries. A nil prototype decodes maps into map[string]interface{}.
+func Msgpack(prototype interface{}) expiring.Codec {
+	return New(prototype, msgpack.Marshal, msgpack.Unmarshal)
+}
+
+// Proto encodes protocol buffer messages of prototype's type.
+func Proto(prototype proto.Message) expiring.Codec {
+	return New(prototype, marshalProto, unmarshalProto)
+}
+
+func (c codec) Marshal(value interface{}) ([]byte, error) {
+	if c.typ == nil {
+		// encode the interface, so that gob records the concrete type
+		return c.marshal(&value)
+	}
+	return c.marshal(value)
+}
+
+func (c codec) Unmarshal(data []byte) (interface{}, error) {
+	if c.typ == nil {
+		var value interface{}
+		err := c.unmarshal(data, &value)
+		return value, err
+	}
+	if c.typ.Kind() == reflect.Ptr {
+		value := reflect.New(c.typ.Elem())
+		err := c.unmarshal(data, value.Interface())
+		return value.Interface(), err
+	}
+	value := reflect.New(c.typ)
+	err := c.unmarshal(data, value.Interface())
+	return value.Elem().Interface(), err
+}
+
+func marshalGob(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	return buf.Bytes(), err
+}
+
+func unmarshalGob(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+func marshalProto(value interface{}) ([]byte, error) {
+	msg, ok := value.(proto.Message)
+	if !ok {
+		return nil, NotAMessageError
+	}
+	return proto.Marshal(msg)
+}
+
+func unmarshalProto(data []byte, value interface{}) error {
+	msg, ok := value.(proto.Message)
+	if !ok {
+		return NotAMessageError
+	}
+	return proto.Unmarshal(data, msg)
+}
@@ -0,0 +1,96 @@
+package codecs_test
+
+import (
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/codecs"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	Name  string
+	Admin bool
+}
+
+func TestCodecs(t *testing.T) {
+	gob.Register(user{})
+	for name, tc := range map[string]struct {
+		codec expiring.Codec
+		value interface{}
+	}{
+		"json":            {codecs.JSON(user{}), user{Name: "Ada", Admin: true}},
+		"json pointer":    {codecs.JSON(&user{}), &user{Name: "Ada"}},
+		"gob":             {codecs.Gob(user{}), user{Name: "Ada", Admin: true}},
+		"gob, registered": {codecs.Gob(nil), user{Name: "Ada"}},
+		"msgpack":         {codecs.Msgpack(user{}), user{Name: "Ada", Admin: true}},
+		"msgpack pointer": {codecs.Msgpack(&user{}), &user{Name: "Ada"}},
+		"msgpack, nil":    {codecs.Msgpack(nil), map[string]interface{}{"Name": "Ada", "Admin": true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			inner := expiringtest.New()
+			es := expiring.New(inner, &store.Options{Expiration: time.Hour}, expiring.WithCodec(tc.codec))
+
+			assert.Nil(t, es.Set("key", tc.value, nil))
+			raw, _ := inner.Raw("key")
+			assert.IsType(t, []byte(nil), raw)
+
+			val, err := es.Get("key")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.value, val)
+
+			// strings are still written as they are
+			assert.Nil(t, es.Set("string", "value", nil))
+			val, err = es.Get("string")
+			assert.Nil(t, err)
+			assert.Equal(t, "value", val)
+		})
+	}
+}
+
+func TestProto(t *testing.T) {
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour},
+		expiring.WithCodec(codecs.Proto(&wrappers.StringValue{})))
+
+	assert.Nil(t, es.Set("key", &wrappers.StringValue{Value: "value"}, nil))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(&wrappers.StringValue{Value: "value"}, val.(proto.Message)))
+
+	assert.Equal(t, codecs.NotAMessageError, es.Set("other", struct{}{}, nil))
+}
+
+func TestNoCodec(t *testing.T) {
+	inner := expiringtest.New()
+	writer := expiring.New(inner, &store.Options{Expiration: time.Hour}, expiring.WithCodec(codecs.JSON(user{})))
+	reader := expiring.New(inner, &store.Options{Expiration: time.Hour}, expiring.WithBinaryEnvelope())
+
+	assert.Nil(t, writer.Set("key", user{Name: "Ada"}, nil))
+	_, err := reader.Get("key")
+	assert.Equal(t, expiring.NoCodecError, err)
+}
+
+func TestCodecETags(t *testing.T) {
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithCodec(codecs.JSON(user{})))
+
+	// equal values encode the same, and so keep their etag
+	assert.Nil(t, es.Set("key", user{Name: "Ada"}, nil))
+	_, etag, _, err := es.GetIfChanged("key", "")
+	assert.Nil(t, err)
+	assert.Nil(t, es.Set("key", user{Name: "Ada"}, nil))
+	_, _, changed, err := es.GetIfChanged("key", etag)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	assert.Nil(t, es.Set("key", user{Name: "Ada", Admin: true}, nil))
+	val, _, changed, err := es.GetIfChanged("key", etag)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, user{Name: "Ada", Admin: true}, val)
+}
@@ -0,0 +1,16 @@
+package compat
+
+import (
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+// NewBigcache creates an expiring Store around a Bigcache. Bigcache only
+// holds []byte values with string keys, and expires every entry after the
+// same life window, so values are written in a binary envelope and their
+// TTL is enforced by the Store. Only []byte and string values can be Set.
+func NewBigcache(client store.BigcacheClientInterface, options *store.Options, opts ...expiring.Option) expiring.Store {
+	s := checkedStore{StoreInterface: store.NewBigcache(client, options), check: stringKeys(0)}
+	defaults := []expiring.Option{expiring.WithBinaryEnvelope(), expiring.WithErrorClassifier(BigcacheErrorClassifier)}
+	return expiring.New(s, options, append(defaults, opts...)...)
+}
@@ -0,0 +1,144 @@
+// Package compat creates expiring Stores around popular cache backends,
+// configured for each backend's quirks: backends which only hold bytes get
+// a binary envelope, backends which expire values themselves are given each
+// value's TTL, backends' misses are told apart from their failures, and keys
+// the backend can't hold are rejected with an error instead of a panic.
+//
+// Integration tests against the real client libraries build with the
+// integration tag; the Redis test also needs REDIS_ADDR.
+package compat
+
+import (
+	"errors"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// checkedStore rejects keys the wrapped store can't hold.
+	checkedStore struct {
+		store.StoreInterface
+		check func(key interface{}) error
+	}
+
+	clearer interface {
+		Clear() error
+	}
+
+	batchDeleter interface {
+		DeleteMulti(keys []interface{}) error
+	}
+
+	batchSetter interface {
+		SetMulti(items []expiring.SetItem) error
+	}
+
+	transactor interface {
+		Transact(ops []expiring.BatchOp) error
+	}
+)
+
+var (
+	UnsupportedKeyError = errors.New("key type is not supported by this store")
+
+	KeyTooLongError = errors.New("key is too long for this store")
+)
+
+var _ store.StoreInterface = checkedStore{}
+
+func (cs checkedStore) Get(key interface{}) (interface{}, error) {
+	if err := cs.check(key); err != nil {
+		return nil, err
+	}
+	return cs.StoreInterface.Get(key)
+}
+
+func (cs checkedStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	if err := cs.check(key); err != nil {
+		return err
+	}
+	return cs.StoreInterface.Set(key, value, options)
+}
+
+func (cs checkedStore) Delete(key interface{}) error {
+	if err := cs.check(key); err != nil {
+		return err
+	}
+	return cs.StoreInterface.Delete(key)
+}
+
+// DeleteMulti deletes keys from the wrapped store, in one call if it
+// implements DeleteMulti itself.
+func (cs checkedStore) DeleteMulti(keys []interface{}) error {
+	for _, key := range keys {
+		if err := cs.check(key); err != nil {
+			return err
+		}
+	}
+	if bd, ok := cs.StoreInterface.(batchDeleter); ok {
+		return bd.DeleteMulti(keys)
+	}
+	for _, key := range keys {
+		if err := cs.StoreInterface.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMulti writes items to the wrapped store, in one call if it implements
+// SetMulti itself.
+func (cs checkedStore) SetMulti(items []expiring.SetItem) error {
+	for _, item := range items {
+		if err := cs.check(item.Key); err != nil {
+			return err
+		}
+	}
+	if bs, ok := cs.StoreInterface.(batchSetter); ok {
+		return bs.SetMulti(items)
+	}
+	for _, item := range items {
+		if err := cs.StoreInterface.Set(item.Key, item.Value, item.Options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transact applies ops to the wrapped store atomically, if it can.
+func (cs checkedStore) Transact(ops []expiring.BatchOp) error {
+	t, ok := cs.StoreInterface.(transactor)
+	if !ok {
+		return expiring.UnsupportedError
+	}
+	for _, op := range ops {
+		if err := cs.check(op.Key); err != nil {
+			return err
+		}
+	}
+	return t.Transact(ops)
+}
+
+// Clear clears the wrapped store, if it can be cleared.
+func (cs checkedStore) Clear() error {
+	if c, ok := cs.StoreInterface.(clearer); ok {
+		return c.Clear()
+	}
+	return nil
+}
+
+// stringKeys accepts string keys of up to max bytes, or of any length if max
+// is 0.
+func stringKeys(max int) func(key interface{}) error {
+	return func(key interface{}) error {
+		s, ok := key.(string)
+		if !ok {
+			return UnsupportedKeyError
+		}
+		if max > 0 && len(s) > max {
+			return KeyTooLongError
+		}
+		return nil
+	}
+}
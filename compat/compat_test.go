@@ -0,0 +1,312 @@
+package compat_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/compat"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// FakeFreecache is a map with the freecache API.
+	FakeFreecache struct {
+		mu      sync.Mutex
+		values  map[string][]byte
+		expires map[string]int
+	}
+
+	// FakeBigcache is a map with the bigcache API.
+	FakeBigcache struct {
+		mu     sync.Mutex
+		values map[string][]byte
+	}
+
+	// FakeRedis is a map with the go-redis API, which returns values as
+	// strings.
+	FakeRedis struct {
+		mu          sync.Mutex
+		values      map[string]string
+		expirations map[string]time.Duration
+		delCalls    int
+	}
+
+	// FakePipeliningRedis is a FakeRedis which can pipeline Sets and Dels,
+	// with or without a transaction.
+	FakePipeliningRedis struct {
+		FakeRedis
+		execCalls int
+		txCalls   int
+	}
+
+	// FakePipeline queues Sets and Dels until Exec. Every other method
+	// panics.
+	FakePipeline struct {
+		redis.Pipeliner
+		rc   *FakePipeliningRedis
+		tx   bool
+		cmds []func()
+	}
+)
+
+var (
+	NotFoundError = errors.New("not found")
+)
+
+var _ compat.FreecacheClient = &FakeFreecache{}
+
+func TestFreecache(t *testing.T) {
+	fc := &FakeFreecache{values: map[string][]byte{}, expires: map[string]int{}}
+	es := compat.NewFreecache(fc, &store.Options{Expiration: 90 * time.Second})
+
+	assert.Nil(t, es.Set("key", []byte("value"), nil))
+	assert.Equal(t, 90, fc.expires["key"])
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	assert.Nil(t, es.Set("short", "value", &store.Options{Expiration: 1500 * time.Millisecond}))
+	assert.Equal(t, 2, fc.expires["short"])
+
+	assert.Equal(t, expiring.UnencodableValueError, es.Set("key", 42, nil))
+	assert.Equal(t, compat.UnsupportedKeyError, es.Set(42, []byte("value"), nil))
+	assert.Equal(t, compat.KeyTooLongError, es.Set(string(make([]byte, 1<<16)), []byte("value"), nil))
+	assert.Equal(t, compat.TagsUnsupportedError, es.Invalidate(store.InvalidateOptions{Tags: []string{"tag"}}))
+
+	assert.Nil(t, es.Delete("key"))
+	_, err = es.Get("key")
+	assert.Equal(t, NotFoundError, err)
+
+	assert.Nil(t, es.Clear())
+	assert.Empty(t, fc.values)
+}
+
+func TestBigcache(t *testing.T) {
+	bc := &FakeBigcache{values: map[string][]byte{}}
+	es := compat.NewBigcache(bc, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, compat.UnsupportedKeyError, es.Set(struct{}{}, "value", nil))
+	_, err = es.Get(struct{}{})
+	assert.Equal(t, compat.UnsupportedKeyError, err)
+}
+
+func TestRedis(t *testing.T) {
+	rc := &FakeRedis{values: map[string]string{}, expirations: map[string]time.Duration{}}
+	es := compat.NewRedis(rc, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("key", []byte("value"), nil))
+	assert.True(t, rc.expirations["key"] > 59*time.Minute)
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	assert.True(t, ttl > 59*time.Minute)
+	assert.Equal(t, compat.UnsupportedKeyError, es.Delete(1))
+}
+
+func TestRedisFromConfig(t *testing.T) {
+	rc := &FakeRedis{values: map[string]string{}, expirations: map[string]time.Duration{}}
+	es, err := compat.NewRedisFromConfig(rc, expiring.Config{DefaultTTL: expiring.Duration(time.Hour)})
+	assert.Nil(t, err)
+
+	assert.Nil(t, es.Set("key", []byte("value"), nil))
+	assert.True(t, rc.expirations["key"] > 59*time.Minute)
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	_, err = compat.NewRedisFromConfig(rc, expiring.Config{Jitter: 2})
+	assert.IsType(t, &expiring.ConfigError{}, err)
+}
+
+func TestRedisBatches(t *testing.T) {
+	rc := &FakeRedis{values: map[string]string{}, expirations: map[string]time.Duration{}}
+	es := compat.NewRedis(rc, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(1))
+
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{
+		{Key: "a", Value: "value"},
+		{Key: "b", Value: "value"},
+		{Key: "c", Value: "value"},
+	}))
+	assert.Equal(t, 1, rc.delCalls)
+	assert.Len(t, rc.values, 1)
+	assert.Equal(t, compat.UnsupportedKeyError, es.SetMulti([]expiring.SetItem{{Key: 1, Value: "value"}}))
+}
+
+func TestRedisPipeline(t *testing.T) {
+	rc := &FakePipeliningRedis{FakeRedis: FakeRedis{values: map[string]string{}, expirations: map[string]time.Duration{}}}
+	es := compat.NewRedis(rc, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{
+		{Key: "a", Value: []byte("value a")},
+		{Key: "b", Value: []byte("value b"), Options: &store.Options{Expiration: time.Minute}},
+	}))
+	assert.Equal(t, 1, rc.execCalls)
+	assert.True(t, rc.expirations["a"] > 59*time.Minute)
+	assert.True(t, rc.expirations["b"] <= time.Minute)
+	val, err := es.Get("b")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value b"), val)
+
+	results, err := es.Batch(func(tx expiring.BatchTx) error {
+		tx.Delete("a")
+		tx.Set("c", "value c", nil)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1, rc.txCalls)
+	assert.NotContains(t, rc.values, "a")
+	val, err = es.Get("c")
+	assert.Nil(t, err)
+	assert.Equal(t, "value c", val)
+}
+
+func (fc *FakeFreecache) Get(key []byte) ([]byte, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	val, ok := fc.values[string(key)]
+	if !ok {
+		return nil, NotFoundError
+	}
+	return val, nil
+}
+
+func (fc *FakeFreecache) Set(key, value []byte, expireSeconds int) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.values[string(key)] = value
+	fc.expires[string(key)] = expireSeconds
+	return nil
+}
+
+func (fc *FakeFreecache) Del(key []byte) bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	_, ok := fc.values[string(key)]
+	delete(fc.values, string(key))
+	return ok
+}
+
+func (fc *FakeFreecache) Clear() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.values = map[string][]byte{}
+}
+
+func (bc *FakeBigcache) Get(key string) ([]byte, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	val, ok := bc.values[key]
+	if !ok {
+		return nil, NotFoundError
+	}
+	return val, nil
+}
+
+func (bc *FakeBigcache) Set(key string, entry []byte) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.values[key] = entry
+	return nil
+}
+
+func (bc *FakeBigcache) Delete(key string) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	delete(bc.values, key)
+	return nil
+}
+
+func (rc *FakeRedis) Get(key string) *redis.StringCmd {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	val, ok := rc.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(val, nil)
+}
+
+func (rc *FakeRedis) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	switch v := value.(type) {
+	case []byte:
+		rc.values[key] = string(v)
+	case string:
+		rc.values[key] = v
+	}
+	rc.expirations[key] = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (rc *FakeRedis) Del(keys ...string) *redis.IntCmd {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.delCalls++
+	for _, key := range keys {
+		delete(rc.values, key)
+	}
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+func (rc *FakePipeliningRedis) Pipeline() redis.Pipeliner {
+	return &FakePipeline{rc: rc}
+}
+
+func (rc *FakePipeliningRedis) TxPipeline() redis.Pipeliner {
+	return &FakePipeline{rc: rc, tx: true}
+}
+
+func (p *FakePipeline) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	p.cmds = append(p.cmds, func() { p.rc.Set(key, value, expiration) })
+	return redis.NewStatusResult("", nil)
+}
+
+func (p *FakePipeline) Del(keys ...string) *redis.IntCmd {
+	p.cmds = append(p.cmds, func() { p.rc.Del(keys...) })
+	return redis.NewIntResult(0, nil)
+}
+
+func (p *FakePipeline) Exec() ([]redis.Cmder, error) {
+	if p.tx {
+		p.rc.txCalls++
+	} else {
+		p.rc.execCalls++
+	}
+	for _, cmd := range p.cmds {
+		cmd()
+	}
+	p.cmds = nil
+	return nil, nil
+}
+
+func (p *FakePipeline) Close() error {
+	return nil
+}
+
+func TestErrorClassifiers(t *testing.T) {
+	down := errors.New("connection refused")
+	assert.Equal(t, expiring.ErrorMiss, compat.RedisErrorClassifier.Classify(expiring.OperationGet, redis.Nil))
+	assert.Equal(t, expiring.ErrorTransient, compat.RedisErrorClassifier.Classify(expiring.OperationGet, down))
+	assert.Equal(t, expiring.ErrorPermanent, compat.RedisErrorClassifier.Classify(expiring.OperationSet, compat.UnsupportedKeyError))
+	assert.Equal(t, expiring.ErrorMiss, compat.BigcacheErrorClassifier.Classify(expiring.OperationGet, down))
+	assert.Equal(t, expiring.ErrorPermanent, compat.BigcacheErrorClassifier.Classify(expiring.OperationGet, compat.UnsupportedKeyError))
+
+	rc := &FakeRedis{values: map[string]string{}, expirations: map[string]time.Duration{}}
+	es := compat.NewRedis(rc, nil)
+	_, err := es.Get("missing")
+	assert.Equal(t, redis.Nil, err)
+	assert.Equal(t, expiring.Stats{}, es.Stats())
+	assert.Equal(t, compat.UnsupportedKeyError, es.Set(42, "value", nil))
+	assert.Equal(t, uint64(1), es.Stats().PermanentErrors)
+}
@@ -0,0 +1,43 @@
+package compat
+
+import (
+	"github.com/coocood/freecache"
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+// ristrettoMiss is the error gocache's Ristretto store returns for a miss.
+const ristrettoMiss = "Value not found in Ristretto store"
+
+// ErrorClassifiers for each backend, used by the Stores this package
+// creates. Keys and tags a backend can't hold are permanent failures.
+// gocache's Bigcache store reports every failed read the same way, so
+// Bigcache's are misses, as with expiring.DefaultErrorClassifier; the other
+// backends' misses can be told apart from their failures.
+var (
+	BigcacheErrorClassifier = classifier(expiring.DefaultErrorClassifier)
+
+	RedisErrorClassifier = classifier(expiring.MissClassifier(func(err error) bool {
+		return err == redis.Nil
+	}))
+
+	RistrettoErrorClassifier = classifier(expiring.MissClassifier(func(err error) bool {
+		return err.Error() == ristrettoMiss
+	}))
+
+	FreecacheErrorClassifier = classifier(expiring.MissClassifier(func(err error) bool {
+		return err == freecache.ErrNotFound
+	}))
+)
+
+// classifier classifies this package's errors as permanent, and the rest by
+// backend.
+func classifier(backend expiring.ErrorClassifier) expiring.ErrorClassifier {
+	return expiring.ErrorClassifierFunc(func(op expiring.Operation, err error) expiring.ErrorClass {
+		switch err {
+		case UnsupportedKeyError, KeyTooLongError, TagsUnsupportedError:
+			return expiring.ErrorPermanent
+		}
+		return backend.Classify(op, err)
+	})
+}
@@ -0,0 +1,87 @@
+package compat
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// FreecacheClient is implemented by *freecache.Cache.
+	FreecacheClient interface {
+		Get(key []byte) ([]byte, error)
+		Set(key, value []byte, expireSeconds int) error
+		Del(key []byte) bool
+		Clear()
+	}
+
+	// freecacheStore is a store.StoreInterface for a FreecacheClient,
+	// which gocache doesn't provide.
+	freecacheStore struct {
+		client FreecacheClient
+	}
+)
+
+const (
+	FreecacheType = "freecache"
+
+	// freecacheMaxKeyLength is the longest key freecache accepts.
+	freecacheMaxKeyLength = 65535
+)
+
+var (
+	TagsUnsupportedError = errors.New("store doesn't support tags")
+)
+
+var _ store.StoreInterface = freecacheStore{}
+
+// NewFreecache creates an expiring Store around a Freecache. Freecache only
+// holds []byte values, with keys of up to 65535 bytes, and expires values
+// with a resolution of one second, so values are written in a binary
+// envelope with their TTL rounded up to the next second. Only []byte and
+// string values can be Set, with string keys.
+func NewFreecache(client FreecacheClient, options *store.Options, opts ...expiring.Option) expiring.Store {
+	s := checkedStore{StoreInterface: freecacheStore{client: client}, check: stringKeys(freecacheMaxKeyLength)}
+	defaults := []expiring.Option{expiring.WithBinaryEnvelope(), expiring.WithNativeExpiration(), expiring.WithErrorClassifier(FreecacheErrorClassifier)}
+	return expiring.New(s, options, append(defaults, opts...)...)
+}
+
+func (fs freecacheStore) Get(key interface{}) (interface{}, error) {
+	return fs.client.Get([]byte(key.(string)))
+}
+
+func (fs freecacheStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return expiring.UnencodableValueError
+	}
+	var seconds int
+	if options != nil && options.ExpirationValue() > 0 {
+		seconds = int((options.ExpirationValue() + time.Second - 1) / time.Second)
+	}
+	return fs.client.Set([]byte(key.(string)), b, seconds)
+}
+
+func (fs freecacheStore) Delete(key interface{}) error {
+	fs.client.Del([]byte(key.(string)))
+	return nil
+}
+
+// Invalidate fails for any tag, as freecache doesn't support them.
+func (fs freecacheStore) Invalidate(options store.InvalidateOptions) error {
+	if len(options.TagsValue()) > 0 {
+		return TagsUnsupportedError
+	}
+	return nil
+}
+
+func (fs freecacheStore) Clear() error {
+	fs.client.Clear()
+	return nil
+}
+
+func (fs freecacheStore) GetType() string {
+	return FreecacheType
+}
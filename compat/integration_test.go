@@ -0,0 +1,98 @@
+//go:build integration
+// +build integration
+
+package compat_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/coocood/freecache"
+	"github.com/dgraph-io/ristretto"
+	"github.com/eko/gocache/store"
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/compat"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ store.RistrettoClientInterface = (*ristretto.Cache)(nil)
+	_ store.BigcacheClientInterface  = (*bigcache.BigCache)(nil)
+	_ store.RedisClientInterface     = (*redis.Client)(nil)
+	_ compat.FreecacheClient         = (*freecache.Cache)(nil)
+)
+
+const integrationExpiration = 1 * time.Second
+
+// assertExpires checks that es holds a value until integrationExpiration
+// passes.
+func assertExpires(t *testing.T, es expiring.Store, key string, value interface{}) {
+	assert.Nil(t, es.Set(key, value, nil))
+	val, err := es.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, value, val)
+
+	time.Sleep(integrationExpiration + 100*time.Millisecond)
+	_, err = es.Get(key)
+	assert.NotNil(t, err)
+}
+
+func TestRistrettoIntegration(t *testing.T) {
+	client, err := ristretto.NewCache(&ristretto.Config{NumCounters: 1000, MaxCost: 100, BufferItems: 64})
+	assert.Nil(t, err)
+	es := compat.NewRistretto(client, &store.Options{Expiration: integrationExpiration})
+
+	assert.Nil(t, es.Set("key", struct{ A int }{1}, nil))
+	// ristretto applies sets asynchronously
+	time.Sleep(10 * time.Millisecond)
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, struct{ A int }{1}, val)
+	assert.Equal(t, compat.UnsupportedKeyError, es.Set(1.5, "value", nil))
+}
+
+func TestBigcacheIntegration(t *testing.T) {
+	client, err := bigcache.NewBigCache(bigcache.DefaultConfig(time.Hour))
+	assert.Nil(t, err)
+	assertExpires(t, compat.NewBigcache(client, &store.Options{Expiration: integrationExpiration}), "key", []byte("value"))
+}
+
+func TestFreecacheIntegration(t *testing.T) {
+	client := freecache.NewCache(1 << 20)
+	assertExpires(t, compat.NewFreecache(client, &store.Options{Expiration: integrationExpiration}), "key", []byte("value"))
+}
+
+func TestRedisIntegration(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	es := compat.NewRedis(client, &store.Options{Expiration: integrationExpiration})
+	assertExpires(t, es, "expiring-compat-integration", []byte("value"))
+
+	// Redis expired the key itself
+	assert.Equal(t, redis.Nil, client.Get("expiring-compat-integration").Err())
+}
+
+func TestRedisPipelineIntegration(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	es := compat.NewRedis(client, &store.Options{Expiration: integrationExpiration})
+
+	keys := []string{"expiring-compat-pipeline-a", "expiring-compat-pipeline-b"}
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{{Key: keys[0], Value: "a"}, {Key: keys[1], Value: "b"}}))
+	for i, key := range keys {
+		val, err := es.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{'a' + byte(i)}, val)
+	}
+}
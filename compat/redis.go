@@ -0,0 +1,143 @@
+package compat
+
+import (
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/go-redis/redis/v7"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// redisStore adds batch operations to store.RedisStore, so the reaper,
+	// eviction and SetMulti cost one round trip per batch instead of one
+	// per key.
+	redisStore struct {
+		*store.RedisStore
+		client  store.RedisClientInterface
+		options *store.Options
+	}
+
+	// pipeliner is implemented by go-redis clients which can pipeline
+	// commands, such as *redis.Client and *redis.ClusterClient.
+	pipeliner interface {
+		Pipeline() redis.Pipeliner
+	}
+
+	// txPipeliner is implemented by go-redis clients which can wrap a
+	// pipeline in MULTI/EXEC.
+	txPipeliner interface {
+		TxPipeline() redis.Pipeliner
+	}
+)
+
+// NewRedis creates an expiring Store around a Redis client. Redis only holds
+// bytes, with string keys, so values are written in a binary envelope, and
+// each value's TTL is also set on its key so that Redis frees values which
+// are never read again. Only []byte and string values can be Set.
+//
+// Batches of deletes are sent as a single DEL, and SetMulti is pipelined if
+// the client has a `Pipeline() redis.Pipeliner` method. Batch is applied in
+// a MULTI/EXEC transaction if the client has a
+// `TxPipeline() redis.Pipeliner` method.
+func NewRedis(client store.RedisClientInterface, options *store.Options, opts ...expiring.Option) expiring.Store {
+	if options == nil {
+		options = &store.Options{}
+	}
+	s, defaults := newRedisStore(client, options)
+	return expiring.New(s, options, append(defaults, opts...)...)
+}
+
+// NewRedisFromConfig is NewRedis for a Store described by cfg, see
+// expiring.NewFromConfig.
+func NewRedisFromConfig(client store.RedisClientInterface, cfg expiring.Config, opts ...expiring.Option) (expiring.Store, error) {
+	s, defaults := newRedisStore(client, &store.Options{Expiration: time.Duration(cfg.DefaultTTL)})
+	return expiring.NewFromConfig(s, cfg, append(defaults, opts...)...)
+}
+
+// newRedisStore returns the store NewRedis wraps, and the options it wraps
+// it with.
+func newRedisStore(client store.RedisClientInterface, options *store.Options) (store.StoreInterface, []expiring.Option) {
+	rs := redisStore{RedisStore: store.NewRedis(client, options), client: client, options: options}
+	s := checkedStore{StoreInterface: rs, check: stringKeys(0)}
+	return s, []expiring.Option{expiring.WithBinaryEnvelope(), expiring.WithNativeExpiration(), expiring.WithErrorClassifier(RedisErrorClassifier)}
+}
+
+// DeleteMulti deletes keys with a single DEL.
+func (rs redisStore) DeleteMulti(keys []interface{}) error {
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		strs[i] = key.(string)
+	}
+	return rs.client.Del(strs...).Err()
+}
+
+// SetMulti writes items in a single pipeline. Items with tags are written
+// with Set, since the tag index has to be read before it's updated.
+func (rs redisStore) SetMulti(items []expiring.SetItem) error {
+	p, ok := rs.client.(pipeliner)
+	if !ok {
+		return rs.setEach(items)
+	}
+
+	pipe := p.Pipeline()
+	defer pipe.Close()
+	var tagged []expiring.SetItem
+	for _, item := range items {
+		options := rs.optionsFor(item.Options)
+		if len(options.TagsValue()) > 0 {
+			tagged = append(tagged, item)
+			continue
+		}
+		pipe.Set(item.Key.(string), item.Value, options.ExpirationValue())
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return err
+	}
+	return rs.setEach(tagged)
+}
+
+// Transact applies ops in a MULTI/EXEC transaction. Tagged values can't be
+// set in a transaction, since the tag index has to be read before it's
+// updated.
+func (rs redisStore) Transact(ops []expiring.BatchOp) error {
+	tp, ok := rs.client.(txPipeliner)
+	if !ok {
+		return expiring.UnsupportedError
+	}
+	for _, op := range ops {
+		if op.Operation == expiring.OperationSet && len(rs.optionsFor(op.Options).TagsValue()) > 0 {
+			return expiring.UnsupportedError
+		}
+	}
+
+	pipe := tp.TxPipeline()
+	defer pipe.Close()
+	for _, op := range ops {
+		if op.Operation == expiring.OperationDelete {
+			pipe.Del(op.Key.(string))
+			continue
+		}
+		pipe.Set(op.Key.(string), op.Value, rs.optionsFor(op.Options).ExpirationValue())
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+func (rs redisStore) setEach(items []expiring.SetItem) error {
+	for _, item := range items {
+		if err := rs.RedisStore.Set(item.Key, item.Value, item.Options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionsFor returns options, or the store's options if there are none, as
+// store.RedisStore does.
+func (rs redisStore) optionsFor(options *store.Options) *store.Options {
+	if options == nil {
+		return rs.options
+	}
+	return options
+}
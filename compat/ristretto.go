@@ -0,0 +1,29 @@
+package compat
+
+import (
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+// NewRistretto creates an expiring Store around a Ristretto cache. Ristretto
+// holds any value, but only hashes strings, []byte and integer keys, and
+// gives every value a cost of 1 unless options says otherwise. Ristretto
+// applies Sets asynchronously, and may drop them, so a value isn't
+// necessarily readable straight after it is Set.
+func NewRistretto(client store.RistrettoClientInterface, options *store.Options, opts ...expiring.Option) expiring.Store {
+	inner := store.Options{Cost: 1}
+	if options != nil && options.CostValue() > 0 {
+		inner.Cost = options.CostValue()
+	}
+	s := checkedStore{StoreInterface: store.NewRistretto(client, &inner), check: ristrettoKeys}
+	defaults := []expiring.Option{expiring.WithErrorClassifier(RistrettoErrorClassifier)}
+	return expiring.New(s, options, append(defaults, opts...)...)
+}
+
+func ristrettoKeys(key interface{}) error {
+	switch key.(type) {
+	case string, []byte, byte, int, int32, uint32, int64, uint64:
+		return nil
+	}
+	return UnsupportedKeyError
+}
@@ -0,0 +1,216 @@
+package expiring_gocache
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Config is a plain description of a Store, which can be loaded from
+	// JSON, YAML or the environment (see ConfigFromEnv) so that deployments
+	// can tune caching without code changes. Zero fields are left at their
+	// defaults.
+	Config struct {
+		DefaultTTL     Duration       `json:"default_ttl" yaml:"default_ttl"`
+		Jitter         float64        `json:"jitter" yaml:"jitter"`
+		PrefixTTLs     []PrefixPolicy `json:"prefix_ttls" yaml:"prefix_ttls"`
+		ReaperInterval Duration       `json:"reaper_interval" yaml:"reaper_interval"`
+		BucketWidth    Duration       `json:"bucket_width" yaml:"bucket_width"`
+		MaxEntries     int            `json:"max_entries" yaml:"max_entries"`
+		TypeName       string         `json:"type_name" yaml:"type_name"`
+		InstanceID     string         `json:"instance_id" yaml:"instance_id"`
+	}
+
+	// PrefixPolicy sets the TTL of values with string keys starting with
+	// Prefix, see WithPrefixTTL.
+	PrefixPolicy struct {
+		Prefix string   `json:"prefix" yaml:"prefix"`
+		TTL    Duration `json:"ttl" yaml:"ttl"`
+	}
+
+	// Duration is a time.Duration which is written in configuration as a
+	// string such as "90s" or "1h30m".
+	Duration time.Duration
+
+	// ConfigError lists everything wrong with a Config.
+	ConfigError struct {
+		Problems []string
+	}
+)
+
+// NewFromConfig creates a Store around store as described by cfg. Options
+// given in opts are applied after cfg, and override it. An error is returned,
+// and no Store created, if cfg is invalid, or the options are, see
+// NewValidated.
+func NewFromConfig(store store.StoreInterface, cfg Config, opts ...Option) (Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return Store{}, err
+	}
+	return NewValidated(store, nil, append(cfg.options(), opts...)...)
+}
+
+// Validate checks cfg, returning a *ConfigError describing every problem
+// found.
+func (cfg Config) Validate() error {
+	var problems []string
+	nonNegative := func(name string, d Duration) {
+		if d < 0 {
+			problems = append(problems, fmt.Sprintf("%s must not be negative, got %s", name, d))
+		}
+	}
+	nonNegative("default_ttl", cfg.DefaultTTL)
+	nonNegative("reaper_interval", cfg.ReaperInterval)
+	nonNegative("bucket_width", cfg.BucketWidth)
+	if cfg.Jitter < 0 || cfg.Jitter >= 1 {
+		problems = append(problems, fmt.Sprintf("jitter must be at least 0 and less than 1, got %v", cfg.Jitter))
+	}
+	if cfg.MaxEntries < 0 {
+		problems = append(problems, fmt.Sprintf("max_entries must not be negative, got %d", cfg.MaxEntries))
+	}
+	seen := map[string]bool{}
+	for i, p := range cfg.PrefixTTLs {
+		switch {
+		case p.Prefix == "":
+			problems = append(problems, fmt.Sprintf("prefix_ttls[%d] has an empty prefix", i))
+		case seen[p.Prefix]:
+			problems = append(problems, fmt.Sprintf("prefix_ttls[%d] repeats prefix %q", i, p.Prefix))
+		}
+		seen[p.Prefix] = true
+		if p.TTL <= 0 {
+			problems = append(problems, fmt.Sprintf("prefix_ttls[%d] must have a positive ttl, got %s", i, p.TTL))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// options translates cfg into Options.
+func (cfg Config) options() []Option {
+	var opts []Option
+	if cfg.DefaultTTL > 0 {
+		opts = append(opts, withExpiration(time.Duration(cfg.DefaultTTL)))
+	}
+	if cfg.Jitter > 0 {
+		opts = append(opts, WithJitter(cfg.Jitter))
+	}
+	for _, p := range cfg.PrefixTTLs {
+		opts = append(opts, WithPrefixTTL(p.Prefix, time.Duration(p.TTL)))
+	}
+	if cfg.ReaperInterval > 0 {
+		opts = append(opts, WithReaper(time.Duration(cfg.ReaperInterval)))
+	}
+	if cfg.BucketWidth > 0 {
+		opts = append(opts, WithBucketWidth(time.Duration(cfg.BucketWidth)))
+	}
+	if cfg.MaxEntries > 0 {
+		opts = append(opts, WithMaxEntries(cfg.MaxEntries))
+	}
+	if cfg.TypeName != "" {
+		opts = append(opts, WithTypeName(cfg.TypeName))
+	}
+	if cfg.InstanceID != "" {
+		opts = append(opts, WithInstanceID(cfg.InstanceID))
+	}
+	return opts
+}
+
+// withExpiration sets the default expiration, as the options given to New do.
+func withExpiration(expiration time.Duration) Option {
+	return func(es *Store) {
+		es.settings.update(func(s *settings) {
+			s.expiration = expiration
+		})
+	}
+}
+
+// ConfigFromEnv reads a Config from environment variables named with
+// prefix: <prefix>DEFAULT_TTL, <prefix>JITTER, <prefix>PREFIX_TTLS,
+// <prefix>REAPER_INTERVAL, <prefix>BUCKET_WIDTH, <prefix>MAX_ENTRIES,
+// <prefix>TYPE_NAME and <prefix>INSTANCE_ID. PREFIX_TTLS is a comma
+// separated list of prefix=ttl pairs, e.g. "session:=5m,catalog:=1h".
+// Unset variables are left at their zero values.
+func ConfigFromEnv(prefix string) (Config, error) {
+	var (
+		cfg      Config
+		problems []string
+	)
+	lookup := func(name string) (string, bool) {
+		v, ok := os.LookupEnv(prefix + name)
+		return v, ok && v != ""
+	}
+	duration := func(name string, d *Duration) {
+		if v, ok := lookup(name); ok {
+			if err := d.UnmarshalText([]byte(v)); err != nil {
+				problems = append(problems, fmt.Sprintf("%s%s: %v", prefix, name, err))
+			}
+		}
+	}
+
+	duration("DEFAULT_TTL", &cfg.DefaultTTL)
+	duration("REAPER_INTERVAL", &cfg.ReaperInterval)
+	duration("BUCKET_WIDTH", &cfg.BucketWidth)
+	if v, ok := lookup("JITTER"); ok {
+		jitter, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%sJITTER: %v", prefix, err))
+		}
+		cfg.Jitter = jitter
+	}
+	if v, ok := lookup("MAX_ENTRIES"); ok {
+		max, err := strconv.Atoi(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%sMAX_ENTRIES: %v", prefix, err))
+		}
+		cfg.MaxEntries = max
+	}
+	if v, ok := lookup("PREFIX_TTLS"); ok {
+		for _, pair := range strings.Split(v, ",") {
+			i := strings.LastIndex(pair, "=")
+			if i < 0 {
+				problems = append(problems, fmt.Sprintf("%sPREFIX_TTLS: %q is not a prefix=ttl pair", prefix, pair))
+				continue
+			}
+			p := PrefixPolicy{Prefix: strings.TrimSpace(pair[:i])}
+			if err := p.TTL.UnmarshalText([]byte(strings.TrimSpace(pair[i+1:]))); err != nil {
+				problems = append(problems, fmt.Sprintf("%sPREFIX_TTLS: %v", prefix, err))
+			}
+			cfg.PrefixTTLs = append(cfg.PrefixTTLs, p)
+		}
+	}
+	cfg.TypeName, _ = lookup("TYPE_NAME")
+	cfg.InstanceID, _ = lookup("INSTANCE_ID")
+
+	if len(problems) > 0 {
+		return cfg, &ConfigError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid expiring config: " + strings.Join(e.Problems, "; ")
+}
@@ -0,0 +1,126 @@
+package expiring_gocache_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const configJSON = `{
+	"default_ttl": "1h",
+	"jitter": 0.1,
+	"prefix_ttls": [{"prefix": "session:", "ttl": "5m"}, {"prefix": "session:admin:", "ttl": "1m"}],
+	"max_entries": 100,
+	"type_name": "expiring-sessions"
+}`
+
+const configYAML = `
+default_ttl: 1h
+jitter: 0.1
+prefix_ttls:
+  - prefix: "session:"
+    ttl: 5m
+  - prefix: "session:admin:"
+    ttl: 1m
+max_entries: 100
+type_name: expiring-sessions
+`
+
+func expectedConfig() expiring.Config {
+	return expiring.Config{
+		DefaultTTL: expiring.Duration(time.Hour),
+		Jitter:     0.1,
+		PrefixTTLs: []expiring.PrefixPolicy{
+			{Prefix: "session:", TTL: expiring.Duration(5 * time.Minute)},
+			{Prefix: "session:admin:", TTL: expiring.Duration(time.Minute)},
+		},
+		MaxEntries: 100,
+		TypeName:   "expiring-sessions",
+	}
+}
+
+func TestConfigFromJSONAndYAML(t *testing.T) {
+	var fromJSON, fromYAML expiring.Config
+	assert.Nil(t, json.Unmarshal([]byte(configJSON), &fromJSON))
+	assert.Nil(t, yaml.Unmarshal([]byte(configYAML), &fromYAML))
+	assert.Equal(t, expectedConfig(), fromJSON)
+	assert.Equal(t, expectedConfig(), fromYAML)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"CACHE_DEFAULT_TTL": "1h",
+		"CACHE_JITTER":      "0.1",
+		"CACHE_PREFIX_TTLS": "session:=5m, session:admin:=1m",
+		"CACHE_MAX_ENTRIES": "100",
+		"CACHE_TYPE_NAME":   "expiring-sessions",
+	}
+	for k, v := range env {
+		assert.Nil(t, os.Setenv(k, v))
+		defer os.Unsetenv(k)
+	}
+
+	cfg, err := expiring.ConfigFromEnv("CACHE_")
+	assert.Nil(t, err)
+	assert.Equal(t, expectedConfig(), cfg)
+
+	assert.Nil(t, os.Setenv("CACHE_DEFAULT_TTL", "soon"))
+	_, err = expiring.ConfigFromEnv("CACHE_")
+	assert.IsType(t, &expiring.ConfigError{}, err)
+	assert.Contains(t, err.Error(), "CACHE_DEFAULT_TTL")
+}
+
+func TestNewFromConfig(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	cfg := expectedConfig()
+	cfg.Jitter = 0
+	es, err := expiring.NewFromConfig(&ms, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "expiring-sessions", es.GetType())
+
+	for key, expected := range map[string]time.Duration{
+		"other":            time.Hour,
+		"session:user":     5 * time.Minute,
+		"session:admin:me": time.Minute,
+	} {
+		assert.Nil(t, es.Set(key, "value", nil))
+		_, ttl, err := es.GetWithTTL(key)
+		assert.Nil(t, err)
+		assert.True(t, ttl > expected-time.Second && ttl <= expected, "%s has ttl %s", key, ttl)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	cfg := expiring.Config{
+		DefaultTTL: expiring.Duration(-time.Second),
+		Jitter:     1.5,
+		PrefixTTLs: []expiring.PrefixPolicy{{Prefix: "", TTL: expiring.Duration(time.Second)}, {Prefix: "a", TTL: 0}},
+		MaxEntries: -1,
+	}
+	_, err := expiring.NewFromConfig(&MapStore{}, cfg)
+	configErr, ok := err.(*expiring.ConfigError)
+	assert.True(t, ok)
+	assert.Len(t, configErr.Problems, 5)
+	assert.Contains(t, err.Error(), "default_ttl must not be negative")
+}
+
+func TestJitter(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithJitter(0.5))
+
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, es.Set("key", "value", &store.Options{Expiration: time.Hour}))
+		_, ttl, err := es.GetWithTTL("key")
+		assert.Nil(t, err)
+		assert.True(t, ttl >= 30*time.Minute && ttl <= 90*time.Minute)
+		seen[ttl.Round(time.Second)] = true
+	}
+	assert.True(t, len(seen) > 1)
+}
@@ -0,0 +1,72 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type ttlContextKey struct{}
+
+// WithTTLContext returns a copy of ctx carrying a TTL, which SetWithContext
+// uses for values whose options don't give an expiration. This lets request
+// scoped freshness requirements pass through layers which don't pass options.
+func WithTTLContext(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlContextKey{}, ttl)
+}
+
+// TTLFromContext returns the TTL set with WithTTLContext, if any.
+func TTLFromContext(ctx context.Context) (time.Duration, bool) {
+	ttl, ok := ctx.Value(ttlContextKey{}).(time.Duration)
+	if !ok || ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// GetWithContext is like Get, but if ctx is already done, ctx.Err() is
+// returned without calling the underlying store. ctx is passed to the Source
+// given to WithReadThrough, and to the hooks called, see Event. If ctx
+// carries a request cache, see WithRequestCache, values are read from it
+// first.
+func (es Store) GetWithContext(ctx context.Context, key interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	es.ctx = ctx
+	if rc := requestCacheFrom(ctx); rc != nil && trackable(key) {
+		return es.getRequestCached(ctx, rc, key)
+	}
+	return es.load(ctx, key)
+}
+
+// SetWithContext is like Set. If the options don't give an expiration, the
+// TTL from WithTTLContext is used, falling back to the key's default. If
+// WithDeadlineClamp was given and ctx has a deadline, the TTL is clamped to
+// the configured multiple of the time remaining until the deadline. If ctx is
+// already done, ctx.Err() is returned without calling the underlying store.
+// ctx is passed to the sink given to WithWriteThrough, and to the hooks
+// called, see Event.
+func (es Store) SetWithContext(ctx context.Context, key interface{}, value interface{}, options *store.Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	es.ctx = ctx
+	fallback := es.defaultTTL(key, value)
+	if ttl, ok := TTLFromContext(ctx); ok {
+		fallback = ttl
+	}
+	ttl := ttlFor(options, fallback)
+	if deadline, ok := ctx.Deadline(); ok && es.deadlineClamp > 0 {
+		if max := time.Duration(float64(deadline.Sub(es.now())) * es.deadlineClamp); max > 0 && ttl > max {
+			ttl = max
+		}
+	}
+	if rc := requestCacheFrom(ctx); rc != nil {
+		rc.forget(es.stats, key)
+	}
+	return es.through(ctx, []sinkOp{{op: OperationSet, key: key, value: value, ttl: ttl}}, func() error {
+		return es.set(key, value, options, ttl, time.Time{})
+	})
+}
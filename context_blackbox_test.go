@@ -0,0 +1,111 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWithContextTTL(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+	ctx := expiring.WithTTLContext(context.Background(), time.Minute)
+
+	// the context's TTL replaces the default
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", nil))
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > time.Minute-time.Second && ttl <= time.Minute)
+
+	// per-call expirations win over the context
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", &store.Options{Expiration: 2 * time.Hour}))
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > time.Hour)
+
+	// without a TTL in the context, the default applies
+	assert.Nil(t, es.SetWithContext(context.Background(), "key", "value", nil))
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > time.Minute && ttl <= time.Hour)
+}
+
+func TestTTLFromContext(t *testing.T) {
+	_, ok := expiring.TTLFromContext(context.Background())
+	assert.False(t, ok)
+
+	ttl, ok := expiring.TTLFromContext(expiring.WithTTLContext(context.Background(), time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, ttl)
+}
+
+func TestGetWithContextDone(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	val, err := es.GetWithContext(context.Background(), "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 1, ms.getCount)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	val, err = es.GetWithContext(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, val)
+	assert.Equal(t, 1, ms.getCount)
+}
+
+func TestSetWithContextDone(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, es.SetWithContext(ctx, "key", "value", nil))
+	assert.Equal(t, 0, ms.setCount)
+}
+
+func TestSetWithContextDeadlineClamp(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDeadlineClamp(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", nil))
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 2*time.Minute-time.Second && ttl <= 2*time.Minute)
+
+	// TTLs below the clamp are kept
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", &store.Options{Expiration: time.Second}))
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl <= time.Second)
+
+	// Set ignores contexts entirely
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 2*time.Minute)
+}
+
+func TestDeadlineClampUsesStoreClock(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	deadline := time.Now().Add(time.Hour)
+	clk := clock.NewFake(deadline.Add(-time.Minute))
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk), expiring.WithDeadlineClamp(2))
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", nil))
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Minute, ttl)
+}
@@ -0,0 +1,46 @@
+package expiring_gocache
+
+import (
+	"sort"
+	"time"
+)
+
+// costWindow is how many times as many eviction candidates as it needs the
+// tracker considers, so that costly values among them are kept.
+const costWindow = 4
+
+// CostTag returns a tag which, when included in the Tags of the options
+// passed to Set, records how long the value took to compute, e.g. the
+// duration of the query it caches. When the Store is over capacity (see
+// WithMaxEntries), the cheapest values among those next in line for
+// eviction are evicted first, so that a value which takes seconds to
+// recompute outlives one which takes milliseconds. Costs are reported by
+// KeyStats and HotKeys, e.g. for a job refreshing costly keys before they
+// expire. Values without a cost tag cost nothing.
+func CostTag(cost time.Duration) string {
+	return directiveTag(costDirective, cost.String())
+}
+
+// cheapest returns the n cheapest of candidates, keeping the order of those
+// which cost the same.
+func (t *tracker) cheapest(candidates []interface{}, n int) []interface{} {
+	costs := make([]time.Duration, len(candidates))
+	for i, key := range candidates {
+		if entry, ok := t.entries.get(key); ok {
+			costs[i] = entry.cost
+		}
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return costs[order[i]] < costs[order[j]] })
+	if len(order) > n {
+		order = order[:n]
+	}
+	cheapest := make([]interface{}, len(order))
+	for i, j := range order {
+		cheapest[i] = candidates[j]
+	}
+	return cheapest
+}
@@ -0,0 +1,106 @@
+package expiring_gocache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	debugKeyReport struct {
+		Key        string     `json:"key"`
+		Hits       uint64     `json:"hits"`
+		LastAccess *time.Time `json:"last_access,omitempty"`
+		TTLSeconds float64    `json:"ttl_seconds"`
+	}
+
+	debugKeyStats struct {
+		Key         string     `json:"key"`
+		Hits        uint64     `json:"hits"`
+		LastAccess  *time.Time `json:"last_access,omitempty"`
+		Sets        uint64     `json:"sets"`
+		LastSet     *time.Time `json:"last_set,omitempty"`
+		LastExpired *time.Time `json:"last_expired,omitempty"`
+		ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	}
+)
+
+const defaultDebugKeyCount = 10
+
+// DebugHandler returns an http.Handler serving JSON reports about the Store,
+// for operators. Mount it with http.StripPrefix under an internal-only path;
+// it serves:
+//
+//	/stats           the Store's Stats
+//	/hotkeys?n=10    HotKeys(n)
+//	/coldkeys?n=10   ColdKeys(n)
+//	/keystats?key=k  KeyStats(k), for string keys
+//
+// Keys are rendered with fmt's %v verb.
+func (es Store) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeDebugJSON(w, es.Stats())
+	})
+	mux.HandleFunc("/hotkeys", func(w http.ResponseWriter, r *http.Request) {
+		writeDebugJSON(w, debugKeyReports(es.HotKeys(debugKeyCount(r))))
+	})
+	mux.HandleFunc("/coldkeys", func(w http.ResponseWriter, r *http.Request) {
+		writeDebugJSON(w, debugKeyReports(es.ColdKeys(debugKeyCount(r))))
+	})
+	mux.HandleFunc("/keystats", func(w http.ResponseWriter, r *http.Request) {
+		ks, err := es.KeyStats(r.URL.Query().Get("key"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		writeDebugJSON(w, debugKeyStats{
+			Key:         fmt.Sprintf("%v", ks.Key),
+			Hits:        ks.Hits,
+			LastAccess:  debugTime(ks.LastAccess),
+			Sets:        ks.Sets,
+			LastSet:     debugTime(ks.LastSet),
+			LastExpired: debugTime(ks.LastExpired),
+			ExpireAt:    debugTime(ks.ExpireAt),
+		})
+	})
+	return mux
+}
+
+func debugKeyCount(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		return defaultDebugKeyCount
+	}
+	return n
+}
+
+func debugKeyReports(reports []KeyReport) []debugKeyReport {
+	out := make([]debugKeyReport, len(reports))
+	for i, report := range reports {
+		out[i] = debugKeyReport{
+			Key:        fmt.Sprintf("%v", report.Key),
+			Hits:       report.Hits,
+			TTLSeconds: report.TTL.Seconds(),
+		}
+		out[i].LastAccess = debugTime(report.LastAccess)
+	}
+	return out
+}
+
+// debugTime returns a pointer to t, so that it is omitted if zero.
+func debugTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,58 @@
+package expiring_gocache_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func getDebugJSON(t *testing.T, handler http.Handler, target string, v interface{}) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), v))
+}
+
+func TestDebugHandlerStats(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	var stats expiring.Stats
+	getDebugJSON(t, es.DebugHandler(), "/stats", &stats)
+	assert.Equal(t, es.Stats(), stats)
+}
+
+func TestDebugHandlerKeyReports(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	var reports []map[string]interface{}
+	getDebugJSON(t, es.DebugHandler(), "/hotkeys?n=1", &reports)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "hot", reports[0]["key"])
+	assert.Equal(t, float64(3), reports[0]["hits"])
+	assert.NotNil(t, reports[0]["last_access"])
+
+	var coldReports []map[string]interface{}
+	getDebugJSON(t, es.DebugHandler(), "/coldkeys", &coldReports)
+	assert.Len(t, coldReports, 4)
+	assert.Equal(t, "cold", coldReports[0]["key"])
+	_, ok := coldReports[0]["last_access"]
+	assert.False(t, ok)
+}
+
+func TestDebugHandlerKeyStats(t *testing.T) {
+	_, es := newAccessTrackedStore(t)
+
+	var ks map[string]interface{}
+	getDebugJSON(t, es.DebugHandler(), "/keystats?key=warm", &ks)
+	assert.Equal(t, "warm", ks["key"])
+	assert.Equal(t, float64(2), ks["hits"])
+	assert.Equal(t, float64(1), ks["sets"])
+	assert.NotNil(t, ks["expire_at"])
+	_, ok := ks["last_expired"]
+	assert.False(t, ok)
+}
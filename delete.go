@@ -0,0 +1,160 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	deleteRetrier struct {
+		es       Store
+		queue    chan deleteRetry
+		attempts int
+		backoff  time.Duration
+		done     chan struct{}
+		once     sync.Once
+		wg       sync.WaitGroup
+	}
+
+	deleteRetry struct {
+		key     interface{}
+		attempt int
+	}
+
+	// inflightDeletes tracks the best effort deletes currently being sent to
+	// the underlying store.
+	inflightDeletes struct {
+		mu   sync.Mutex
+		keys map[interface{}]struct{}
+	}
+)
+
+// bestEffortDelete deletes an expired or evicted value from the underlying
+// store, reporting and retrying the delete if it fails. If a delete of the
+// same key is already in flight, e.g. because many goroutines read the key as
+// it expired, no further delete is sent.
+func (es Store) bestEffortDelete(key interface{}) {
+	if !es.inflight.start(key) {
+		atomic.AddUint64(&es.stats.deletesDeduplicated, 1)
+		return
+	}
+	defer es.inflight.finish(key)
+
+	if err := es.innerDelete(key); err != nil {
+		es.deleteFailed(key, err, 1)
+	}
+}
+
+// start marks a delete of key as in flight, returning false if one already
+// is. Keys which can't be tracked are never deduplicated.
+func (d *inflightDeletes) start(key interface{}) bool {
+	if !trackable(key) {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+	d.keys[key] = struct{}{}
+	return true
+}
+
+func (d *inflightDeletes) finish(key interface{}) {
+	if !trackable(key) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.keys, key)
+}
+
+// deleteFailed records that the given attempt to delete key failed, and
+// queues another attempt if retries are enabled and the failure isn't
+// permanent. A miss means the key is already gone, and isn't a failure.
+func (es Store) deleteFailed(key interface{}, err error, attempt int) {
+	class := es.classify(OperationDelete, err)
+	if class == ErrorMiss {
+		// already gone
+		return
+	}
+	atomic.AddUint64(&es.stats.deleteFailures, 1)
+	if es.onDeleteFailure != nil {
+		_ = es.guard("delete failure hook", func() error {
+			es.onDeleteFailure(key, err)
+			return nil
+		})
+	}
+
+	if es.retrier == nil || attempt >= es.retrier.attempts || class == ErrorPermanent {
+		atomic.AddUint64(&es.stats.deleteRetriesDropped, 1)
+		return
+	}
+	select {
+	case es.retrier.queue <- deleteRetry{key: key, attempt: attempt + 1}:
+	default:
+		atomic.AddUint64(&es.stats.deleteRetriesDropped, 1)
+	}
+}
+
+func startDeleteRetrier(es Store) *deleteRetrier {
+	r := &deleteRetrier{
+		queue:    make(chan deleteRetry, es.retryQueueSize),
+		attempts: es.retryAttempts,
+		backoff:  es.retryBackoff,
+		done:     make(chan struct{}),
+	}
+	// the worker's copy of the Store must be able to requeue
+	es.retrier = r
+	r.es = es
+
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+func (r *deleteRetrier) run() {
+	defer r.wg.Done()
+	r.es.supervise("delete retrier", r.done, r.retry)
+}
+
+// retry retries queued deletes until the retrier is stopped.
+func (r *deleteRetrier) retry() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case retry := <-r.queue:
+			if !r.wait() {
+				return
+			}
+			if err := r.es.innerDelete(retry.key); err != nil {
+				r.es.deleteFailed(retry.key, err, retry.attempt)
+			}
+		}
+	}
+}
+
+// wait sleeps for the backoff, returning false if the retrier was stopped.
+func (r *deleteRetrier) wait() bool {
+	if r.backoff <= 0 {
+		return true
+	}
+	timer := time.NewTimer(r.backoff)
+	defer timer.Stop()
+	select {
+	case <-r.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// stop signals the retrier to exit and waits for it to do so.
+func (r *deleteRetrier) stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+}
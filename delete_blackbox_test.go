@@ -0,0 +1,193 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// FailingDeleteStore fails the first `failures` deletes.
+	FailingDeleteStore struct {
+		*MapStore
+		failures int
+	}
+
+	// BlockingDeleteStore blocks deletes until release is closed.
+	BlockingDeleteStore struct {
+		*MapStore
+		started chan struct{}
+		release chan struct{}
+		once    sync.Once
+	}
+)
+
+var (
+	FailingDeleteError = errors.New("delete failed")
+)
+
+func TestDeleteFailureHookAndStats(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 1}
+	var failedKeys []interface{}
+	es := expiring.New(&fds, &store.Options{Expiration: 10 * time.Millisecond},
+		expiring.WithDeleteFailureHook(func(key interface{}, err error) {
+			assert.Equal(t, FailingDeleteError, err)
+			failedKeys = append(failedKeys, key)
+		}),
+		expiring.WithClock(clk),
+	)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, []interface{}{"key"}, failedKeys)
+	assert.Equal(t, expiring.Stats{DeleteFailures: 1, DeleteRetriesDropped: 1, TransientErrors: 1}, es.Stats())
+
+	// the value is still in the store, so the next Get tries again
+	_, err = es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, uint64(1), es.Stats().DeleteFailures)
+	_, err = es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestDeleteRetries(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 2}
+	es := expiring.New(&fds, &store.Options{Expiration: 10 * time.Millisecond},
+		expiring.WithDeleteRetries(10, 3, time.Millisecond),
+		expiring.WithClock(clk),
+	)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, es.Close())
+
+	// the first attempt and first retry failed, the second retry succeeded
+	assert.Equal(t, expiring.Stats{DeleteFailures: 2, TransientErrors: 2}, es.Stats())
+	assert.Equal(t, 3, fds.deleteCount)
+	assert.Empty(t, fds.cache)
+}
+
+func TestDeleteRetriesExhausted(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 10}
+	es := expiring.New(&fds, &store.Options{Expiration: 10 * time.Millisecond},
+		expiring.WithDeleteRetries(10, 2, time.Millisecond),
+		expiring.WithClock(clk),
+	)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, es.Close())
+
+	assert.Equal(t, expiring.Stats{DeleteFailures: 2, DeleteRetriesDropped: 1, TransientErrors: 2}, es.Stats())
+	assert.Equal(t, 2, fds.deleteCount)
+}
+
+func TestDeleteRetriesQueueFull(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 10}
+	es := expiring.New(&fds, &store.Options{Expiration: 10 * time.Millisecond},
+		// a long backoff keeps the first retry in flight
+		expiring.WithDeleteRetries(1, 2, time.Hour),
+		expiring.WithClock(clk),
+	)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, es.Set(key, "value", nil))
+	}
+	clk.Advance(20 * time.Millisecond)
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := es.Get(key)
+		assert.Equal(t, expiring.ValueExpiredError, err)
+	}
+	assert.Nil(t, es.Close())
+
+	// one retry is being waited on, one fills the queue, one is dropped
+	stats := es.Stats()
+	assert.Equal(t, uint64(3), stats.DeleteFailures)
+	assert.True(t, stats.DeleteRetriesDropped >= 1)
+}
+
+func TestConcurrentExpiryDeletesAreDeduplicated(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	bds := BlockingDeleteStore{
+		MapStore: &MapStore{cache: map[interface{}]interface{}{}},
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	es := expiring.New(&bds, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := es.Get("key")
+		assert.Equal(t, expiring.ValueExpiredError, err)
+	}()
+	<-bds.started
+
+	// the first delete is still in flight, so these don't send another
+	for i := 0; i < 9; i++ {
+		_, err := es.Get("key")
+		assert.Equal(t, expiring.ValueExpiredError, err)
+	}
+	close(bds.release)
+	<-done
+
+	assert.Equal(t, 1, bds.deleteCount)
+	assert.Equal(t, uint64(9), es.Stats().DeletesDeduplicated)
+
+	// once the delete finished, later expiries delete again
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 2, bds.deleteCount)
+}
+
+// FailingDeleteStore implementation
+
+func (fds *FailingDeleteStore) Delete(key interface{}) error {
+	fds.mu.Lock()
+	if fds.failures > 0 {
+		fds.failures--
+		fds.deleteCount++
+		fds.mu.Unlock()
+		return FailingDeleteError
+	}
+	fds.mu.Unlock()
+	return fds.MapStore.Delete(key)
+}
+
+// BlockingDeleteStore implementation
+
+func (bds *BlockingDeleteStore) Delete(key interface{}) error {
+	bds.once.Do(func() {
+		close(bds.started)
+	})
+	<-bds.release
+	return bds.MapStore.Delete(key)
+}
@@ -0,0 +1,41 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+)
+
+// DependsOn returns tags which, when included in the Tags of the options
+// passed to Set, make the value depend on the values of keys: when any of
+// them is deleted, expires or is evicted, the value is deleted too, as are
+// the values depending on it in turn. Setting a dependency again leaves its
+// dependents alone.
+//
+// Dependencies are kept by the tracking index, so they need WithDependencies,
+// and only hold between values written through the same Store.
+//
+//	options := &store.Options{Tags: expiring.DependsOn("user:42", "org:7")}
+func DependsOn(keys ...string) []string {
+	tags := make([]string, len(keys))
+	for i, key := range keys {
+		tags[i] = directiveTag(dependsOnDirective, key)
+	}
+	return tags
+}
+
+// cascade deletes the values which depend on keys, which have just been
+// removed. Pinned values are kept. Deletes are best effort, like the delete
+// of an expired value in Get.
+func (es Store) cascade(keys ...interface{}) {
+	if es.tracker == nil {
+		return
+	}
+	dependents := es.keysOf(es.tracker.dependentsOf(keys, es.pinnedRef()))[0]
+	if len(dependents) == 0 {
+		return
+	}
+	atomic.AddUint64(&es.stats.cascadedDeletes, uint64(len(dependents)))
+	es.deleteBatch(dependents)
+	for _, key := range dependents {
+		es.emit(Event{Type: EventDelete, Key: key})
+	}
+}
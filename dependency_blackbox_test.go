@@ -0,0 +1,87 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteCascadesToDependents(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDependencies())
+
+	assert.Nil(t, es.Set("user:1", "alice", nil))
+	assert.Nil(t, es.Set("view:1", "alice's view", &store.Options{Tags: expiring.DependsOn("user:1")}))
+	assert.Nil(t, es.Set("summary", "summary", &store.Options{Tags: expiring.DependsOn("view:1")}))
+	assert.Nil(t, es.Set("other", "value", nil))
+
+	assert.Nil(t, es.Delete("user:1"))
+	assert.Equal(t, map[interface{}]interface{}{"other": ms.cache["other"]}, ms.cache)
+	assert.Equal(t, uint64(2), es.Stats().CascadedDeletes)
+}
+
+func TestExpiryCascadesToDependents(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDependencies(), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("source", "value", &store.Options{Expiration: time.Minute}))
+	assert.Nil(t, es.Set("view", "value", &store.Options{Tags: expiring.DependsOn("source")}))
+
+	clk.Advance(2 * time.Minute)
+	_, err := es.Get("source")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Empty(t, ms.cache)
+}
+
+func TestDependencyCycleTerminates(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDependencies())
+
+	assert.Nil(t, es.Set("a", "value", &store.Options{Tags: expiring.DependsOn("b")}))
+	assert.Nil(t, es.Set("b", "value", &store.Options{Tags: expiring.DependsOn("a")}))
+
+	assert.Nil(t, es.Delete("a"))
+	assert.Empty(t, ms.cache)
+	assert.Equal(t, uint64(1), es.Stats().CascadedDeletes)
+}
+
+func TestSettingDependencyAgainKeepsDependents(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDependencies())
+
+	assert.Nil(t, es.Set("source", "v1", nil))
+	assert.Nil(t, es.Set("view", "value", &store.Options{Tags: expiring.DependsOn("source")}))
+	assert.Nil(t, es.Set("source", "v2", nil))
+	_, ok := ms.cache["view"]
+	assert.True(t, ok)
+
+	// the dependency is still kept
+	assert.Nil(t, es.Delete("source"))
+	assert.Empty(t, ms.cache)
+}
+
+func TestDependentSetWithoutDependencyForgetsIt(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithDependencies())
+
+	assert.Nil(t, es.Set("source", "value", nil))
+	assert.Nil(t, es.Set("view", "v1", &store.Options{Tags: expiring.DependsOn("source")}))
+	assert.Nil(t, es.Set("view", "v2", nil))
+
+	assert.Nil(t, es.Delete("source"))
+	_, ok := ms.cache["view"]
+	assert.True(t, ok)
+}
+
+func TestDependsOnTagIsNotPassedOn(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithDependencies())
+
+	assert.Nil(t, es.Set("view", "value", &store.Options{Tags: append([]string{"user"}, expiring.DependsOn("source")...)}))
+	assert.Equal(t, &store.Options{Tags: []string{"user"}}, ms.lastSetOptions)
+}
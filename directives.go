@@ -0,0 +1,93 @@
+package expiring_gocache
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// directives are per-value settings passed to Set as specially prefixed
+	// tags in the store options, so they pass through layers such as
+	// cache.Cache which only know about store.Options.
+	directives struct {
+		priority  Priority
+		dependsOn []interface{}
+		maxReads  uint64
+		cost      time.Duration
+	}
+)
+
+const (
+	directivePrefix = "expiring:"
+
+	priorityDirective  = "priority"
+	dependsOnDirective = "depends-on"
+	maxReadsDirective  = "max-reads"
+	costDirective      = "cost"
+)
+
+func directiveTag(name, value string) string {
+	return directivePrefix + name + "=" + value
+}
+
+// parseDirectives extracts the directives from options, returning options
+// without the directive tags so they aren't passed on to the underlying
+// store.
+func parseDirectives(options *store.Options) (*store.Options, directives) {
+	var d directives
+	if options == nil || !hasDirectives(options.Tags) {
+		return options, d
+	}
+
+	stripped := *options
+	stripped.Tags = make([]string, 0, len(options.Tags))
+	for _, tag := range options.Tags {
+		if !strings.HasPrefix(tag, directivePrefix) {
+			stripped.Tags = append(stripped.Tags, tag)
+			continue
+		}
+		name, value := splitDirective(strings.TrimPrefix(tag, directivePrefix))
+		switch name {
+		case priorityDirective:
+			if p, ok := parsePriority(value); ok {
+				d.priority = p
+			}
+		case dependsOnDirective:
+			if value != "" {
+				d.dependsOn = append(d.dependsOn, value)
+			}
+		case maxReadsDirective:
+			if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+				d.maxReads = n
+			}
+		case costDirective:
+			if cost, err := time.ParseDuration(value); err == nil && cost > 0 {
+				d.cost = cost
+			}
+		}
+	}
+	if len(stripped.Tags) == 0 {
+		stripped.Tags = nil
+	}
+	return &stripped, d
+}
+
+func hasDirectives(tags []string) bool {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, directivePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitDirective(directive string) (name, value string) {
+	i := strings.Index(directive, "=")
+	if i < 0 {
+		return directive, ""
+	}
+	return directive[:i], directive[i+1:]
+}
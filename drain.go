@@ -0,0 +1,89 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// DrainProgress reports how far a Store started draining by Drain has
+	// got.
+	DrainProgress struct {
+		// Since is when Drain was called.
+		Since time.Time
+		// Entries is how many values the Store held then, and Remaining how
+		// many it holds now. Both are -1 unless the Store tracks its keys,
+		// see Len.
+		Entries   int
+		Remaining int
+		// DroppedSets counts the Sets ignored since.
+		DroppedSets uint64
+	}
+
+	// drainState is shared by the copies of a Store.
+	drainState struct {
+		draining int32
+		mu       sync.Mutex
+		since    time.Time
+		entries  int
+	}
+)
+
+// Drain stops the Store taking new values, so that it can be retired
+// without a cold start on its replacement: Sets, including those of
+// SetMulti, Batch and read-through fetches, are ignored and return nil,
+// while Gets go on serving the values already held until they expire.
+// Deletes still apply. The progress of the drain is reported by Stats.
+// Draining can't be undone; calling Drain again does nothing.
+func (es Store) Drain() {
+	es.drain.mu.Lock()
+	defer es.drain.mu.Unlock()
+	if es.draining() {
+		return
+	}
+	es.drain.since = es.now()
+	es.drain.entries = es.trackedLen()
+	atomic.StoreInt32(&es.drain.draining, 1)
+}
+
+func (es Store) draining() bool {
+	return atomic.LoadInt32(&es.drain.draining) == 1
+}
+
+// drainProgress returns the progress of the drain, or nil if the Store isn't
+// draining.
+func (es Store) drainProgress() *DrainProgress {
+	if !es.draining() {
+		return nil
+	}
+	es.drain.mu.Lock()
+	defer es.drain.mu.Unlock()
+	return &DrainProgress{
+		Since:       es.drain.since,
+		Entries:     es.drain.entries,
+		Remaining:   es.trackedLen(),
+		DroppedSets: atomic.LoadUint64(&es.stats.drainedSets),
+	}
+}
+
+// trackedLen returns how many keys the tracker holds, or -1 if there is no
+// tracker.
+func (es Store) trackedLen() int {
+	if es.tracker == nil {
+		return -1
+	}
+	return es.tracker.len()
+}
+
+// withoutSets returns ops without its Sets, which a draining Store doesn't
+// pass on to its sink.
+func withoutSets(ops []sinkOp) []sinkOp {
+	kept := ops[:0:0]
+	for _, op := range ops {
+		if op.op != OperationSet {
+			kept = append(kept, op)
+		}
+	}
+	return kept
+}
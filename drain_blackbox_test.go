@@ -0,0 +1,62 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute},
+		expiring.WithMaxEntries(100), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("a", 1, nil))
+	assert.Nil(t, es.Set("b", 2, &store.Options{Expiration: time.Hour}))
+	assert.Nil(t, es.Stats().Drain)
+
+	es.Drain()
+	assert.Nil(t, es.Set("c", 3, nil))
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{{Key: "a", Value: 4}}))
+	assert.Equal(t, 2, ms.setCount)
+
+	val, err := es.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, val)
+	_, err = es.Get("c")
+	assert.NotNil(t, err)
+
+	progress := es.Stats().Drain
+	if assert.NotNil(t, progress) {
+		assert.Equal(t, clk.Now(), progress.Since)
+		assert.Equal(t, 2, progress.Entries)
+		assert.Equal(t, 2, progress.Remaining)
+		assert.Equal(t, uint64(2), progress.DroppedSets)
+	}
+	assert.Equal(t, uint64(2), es.Stats().DrainedSets)
+
+	clk.Advance(2 * time.Minute)
+	_, err = es.Get("a")
+	assert.NotNil(t, err)
+	assert.Nil(t, es.Delete("b"))
+	assert.Equal(t, 0, es.Stats().Drain.Remaining)
+}
+
+func TestDrainWriteThrough(t *testing.T) {
+	sink := newRecordingSink()
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	es.Drain()
+	assert.Nil(t, es.Set("other", "value", nil))
+	assert.Nil(t, es.Delete("key"))
+
+	assert.Equal(t, []string{"write", "remove"}, sink.calls)
+	assert.Equal(t, -1, es.Stats().Drain.Entries)
+}
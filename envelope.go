@@ -0,0 +1,305 @@
+package expiring_gocache
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// The binary envelope, written by stores created WithBinaryEnvelope, is
+//
+//	magic (2 bytes) | version (1) | kind (1) | expireAt, Unix nanoseconds (8, big endian) |
+//	timestamp, Unix nanoseconds (8, big endian) | etag (8, big endian) |
+//	createdAt, Unix nanoseconds (8, big endian) | instance length (uvarint) | instance |
+//	tag count (uvarint) | tag length (uvarint) | tag ... | generation (uvarint) |
+//	max reads (uvarint) | extensions length (uvarint) | extensions | value
+//
+// where kind records whether value was a []byte, a string, a lease token,
+// the message of an error cached by SetError, or encoded by a Codec,
+// timestamp is 0 for values without one, etag is the value's content hash,
+// or 0 if it wasn't computed when the value was written, and createdAt is
+// when it was written by the writer's clock, or 0. Tags are only recorded by
+// stores created WithTagExpiry, and generation, the generation of the key's
+// namespace, by stores created WithGenerations. Max reads is the limit set
+// by MaxReadsTag, or 0. Envelopes of earlier versions, which end their
+// header after expireAt (version 1), timestamp (2) or etag (3), or have no
+// tags (4), generation (5), max reads (6) or extensions (7), are still read.
+//
+// Extensions are reserved for fields added by later versions, which must
+// keep the layout of version 8 and add their fields there, so that readers
+// of earlier versions can skip them. Envelopes of later versions are read as
+// far as the fields of this one, and then handled as WithFutureEnvelopes
+// says.
+const (
+	envelopeMagic   = "\xe7\x78"
+	envelopeVersion = 8
+
+	envelopeBytes  byte = 0
+	envelopeString byte = 1
+	envelopeLease  byte = 2
+	envelopeError  byte = 3
+	envelopeCodec  byte = 4
+
+	envelopeHeaderSize = len(envelopeMagic) + 2 + 8 + 8 + 8 + 8
+	// envelopeHeaderVersion is the latest version to change the header size.
+	envelopeHeaderVersion = 4
+)
+
+var (
+	UnencodableValueError = errors.New("only []byte and string values can be stored in a binary envelope")
+)
+
+// wrap returns ew as it is written to the underlying store.
+func (es Store) wrap(ew wrappedValue) (interface{}, error) {
+	if !es.binaryEnvelope {
+		ew.version = envelopeVersion
+		return ew, nil
+	}
+	ew, err := es.encoded(ew)
+	if err != nil {
+		return nil, err
+	}
+	if es.envelopeFormat != BinaryEnvelope {
+		return encodeEnvelopeAs(ew, es.envelopeFormat)
+	}
+	return encodeEnvelope(ew)
+}
+
+// unwrap returns the wrappedValue stored as val, in either form, and whether
+// val is one.
+func unwrap(val interface{}) (wrappedValue, bool) {
+	ew, env, ok := unwrapHeader(val)
+	if !ok || !env.encoded {
+		return ew, ok
+	}
+	if decoded, ok := env.decode(ew); ok {
+		return decoded, true
+	}
+	// a kind of value added by a later version
+	ew.value = futureValue{}
+	return ew, true
+}
+
+// unwrapHeader is like unwrap, but leaves the value of a binary envelope
+// undecoded, so that callers which may not need it don't pay to copy it.
+// If val isn't an envelope, ew holds the value.
+func unwrapHeader(val interface{}) (wrappedValue, envelopePayload, bool) {
+	switch v := val.(type) {
+	case wrappedValue:
+		return v, envelopePayload{}, true
+	case []byte:
+		return decodeEnvelopeBytes(v)
+	case string:
+		// stores such as Redis return bytes written to them as strings
+		return decodeEnvelopeBytes([]byte(v))
+	}
+	return wrappedValue{}, envelopePayload{}, false
+}
+
+// envelopePayload is the undecoded value of a binary envelope.
+type envelopePayload struct {
+	encoded bool
+	kind    byte
+	payload []byte
+}
+
+// AppendEnvelope appends value to dst in the binary envelope written by
+// Stores created WithBinaryEnvelope, expiring at expireAt, and returns the
+// extended slice. The envelope can be read by any such Store without an
+// instance ID.
+func AppendEnvelope(dst, value []byte, expireAt time.Time) []byte {
+	var header [envelopeHeaderSize + 5]byte
+	copy(header[:], envelopeMagic)
+	header[len(envelopeMagic)] = envelopeVersion
+	header[len(envelopeMagic)+1] = envelopeBytes
+	binary.BigEndian.PutUint64(header[len(envelopeMagic)+2:], uint64(expireAt.UnixNano()))
+	// no timestamp, etag or createdAt, an empty instance, no tags,
+	// generation 0, no read limit and no extensions
+	return append(append(dst, header[:]...), value...)
+}
+
+// ParseEnvelope returns the []byte or string value held in a binary
+// envelope, without copying it, and when it expires. ok is false if b isn't
+// an envelope, or was written by a Store with an instance ID.
+func ParseEnvelope(b []byte) (value []byte, expireAt time.Time, ok bool) {
+	ew, env, ok := decodeEnvelopeHeader(b)
+	if !ok || ew.instance != "" || (env.kind != envelopeBytes && env.kind != envelopeString) {
+		return nil, time.Time{}, false
+	}
+	return env.payload, ew.expireAt, true
+}
+
+func encodeEnvelope(ew wrappedValue) ([]byte, error) {
+	kind, payload, err := payloadOf(ew.value)
+	if err != nil {
+		return nil, err
+	}
+
+	size := envelopeHeaderSize + binary.MaxVarintLen64*(4+len(ew.tags)) + 1 + len(ew.instance) + len(payload)
+	for _, tag := range ew.tags {
+		size += len(tag)
+	}
+	b := make([]byte, envelopeHeaderSize, size)
+	copy(b, envelopeMagic)
+	b[len(envelopeMagic)] = envelopeVersion
+	b[len(envelopeMagic)+1] = kind
+	binary.BigEndian.PutUint64(b[len(envelopeMagic)+2:], uint64(ew.expireAt.UnixNano()))
+	binary.BigEndian.PutUint64(b[len(envelopeMagic)+10:], uint64(unixNano(ew.timestamp)))
+	binary.BigEndian.PutUint64(b[len(envelopeMagic)+18:], ew.etag)
+	binary.BigEndian.PutUint64(b[len(envelopeMagic)+26:], uint64(unixNano(ew.createdAt)))
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(ew.instance)))]...)
+	b = append(b, ew.instance...)
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(ew.tags)))]...)
+	for _, tag := range ew.tags {
+		b = append(b, n[:binary.PutUvarint(n[:], uint64(len(tag)))]...)
+		b = append(b, tag...)
+	}
+	b = append(b, n[:binary.PutUvarint(n[:], ew.generation)]...)
+	b = append(b, n[:binary.PutUvarint(n[:], ew.maxReads)]...)
+	// no extensions
+	b = append(b, 0)
+	return append(b, payload...), nil
+}
+
+func decodeEnvelopeHeader(b []byte) (wrappedValue, envelopePayload, bool) {
+	if len(b) <= len(envelopeMagic) || string(b[:len(envelopeMagic)]) != envelopeMagic {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+	var headerSize int
+	version := b[len(envelopeMagic)]
+	switch {
+	case version == 0:
+		return wrappedValue{}, envelopePayload{}, false
+	case version <= envelopeHeaderVersion:
+		headerSize = envelopeHeaderSize - 8*int(envelopeHeaderVersion-version)
+	default:
+		headerSize = envelopeHeaderSize
+	}
+	if len(b) < headerSize {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+
+	kind := b[len(envelopeMagic)+1]
+	ew := wrappedValue{expireAt: time.Unix(0, int64(binary.BigEndian.Uint64(b[len(envelopeMagic)+2:]))), version: version}
+	if headerSize > len(envelopeMagic)+10 {
+		if ts := int64(binary.BigEndian.Uint64(b[len(envelopeMagic)+10:])); ts != 0 {
+			ew.timestamp = time.Unix(0, ts)
+		}
+	}
+	if headerSize > len(envelopeMagic)+18 {
+		ew.etag = binary.BigEndian.Uint64(b[len(envelopeMagic)+18:])
+	}
+	if headerSize > len(envelopeMagic)+26 {
+		if createdAt := int64(binary.BigEndian.Uint64(b[len(envelopeMagic)+26:])); createdAt != 0 {
+			ew.createdAt = time.Unix(0, createdAt)
+		}
+	}
+	rest := b[headerSize:]
+	size, n := binary.Uvarint(rest)
+	if n <= 0 || uint64(len(rest)-n) < size {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+	ew.instance = string(rest[n : n+int(size)])
+	rest = rest[n+int(size):]
+	if version >= 5 {
+		var ok bool
+		if ew.tags, rest, ok = decodeEnvelopeTags(rest); !ok {
+			return wrappedValue{}, envelopePayload{}, false
+		}
+	}
+	if version >= 6 {
+		generation, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return wrappedValue{}, envelopePayload{}, false
+		}
+		ew.generation, rest = generation, rest[n:]
+	}
+	if version >= 7 {
+		maxReads, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return wrappedValue{}, envelopePayload{}, false
+		}
+		ew.maxReads, rest = maxReads, rest[n:]
+	}
+	if version >= 8 {
+		size, n := binary.Uvarint(rest)
+		if n <= 0 || uint64(len(rest)-n) < size {
+			return wrappedValue{}, envelopePayload{}, false
+		}
+		// extensions of later versions
+		rest = rest[n+int(size):]
+	}
+	payload := rest
+	if kind > envelopeCodec && version <= envelopeVersion {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+	return ew, envelopePayload{encoded: true, kind: kind, payload: payload}, true
+}
+
+// payloadOf returns the kind and payload of value in an envelope.
+func payloadOf(value interface{}) (byte, []byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return envelopeBytes, v, nil
+	case string:
+		return envelopeString, []byte(v), nil
+	case leaseRecord:
+		return envelopeLease, []byte(v.token), nil
+	case cachedErrorRecord:
+		return envelopeError, []byte(v.err.Error()), nil
+	case codedValue:
+		return envelopeCodec, v, nil
+	}
+	return 0, nil, UnencodableValueError
+}
+
+// decodeEnvelopeTags reads the tags at the start of b, returning them and
+// the rest of b.
+func decodeEnvelopeTags(b []byte) ([]string, []byte, bool) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 || count > uint64(len(b)-n) {
+		return nil, nil, false
+	}
+	b = b[n:]
+	if count == 0 {
+		return nil, b, true
+	}
+	tags := make([]string, count)
+	for i := range tags {
+		size, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < size {
+			return nil, nil, false
+		}
+		tags[i] = string(b[n : n+int(size)])
+		b = b[n+int(size):]
+	}
+	return tags, b, true
+}
+
+// decode returns ew with the value held by env.
+func (env envelopePayload) decode(ew wrappedValue) (wrappedValue, bool) {
+	switch env.kind {
+	case envelopeBytes:
+		ew.value = append([]byte(nil), env.payload...)
+	case envelopeString:
+		ew.value = string(env.payload)
+	case envelopeLease:
+		ew.value = leaseRecord{token: string(env.payload)}
+	case envelopeError:
+		ew.value = cachedErrorRecord{err: errors.New(string(env.payload))}
+	case envelopeCodec:
+		ew.value = codedValue(append([]byte(nil), env.payload...))
+	default:
+		return wrappedValue{}, false
+	}
+	return ew, true
+}
+
+// unixNano returns t in Unix nanoseconds, or 0 for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
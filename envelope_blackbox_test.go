@@ -0,0 +1,109 @@
+package expiring_gocache_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// StringMapStore holds []byte values as strings, as Redis does.
+	StringMapStore struct {
+		MapStore
+	}
+)
+
+func TestBinaryEnvelope(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration},
+		expiring.WithClock(clk),
+		expiring.WithBinaryEnvelope(),
+		expiring.WithInstanceID("instance"),
+	)
+
+	assert.Nil(t, es.Set("bytes", []byte("value"), nil))
+	assert.Nil(t, es.Set("string", "value", nil))
+	assert.IsType(t, []byte{}, ms.cache["instance:bytes"])
+	assert.IsType(t, []byte{}, ms.cache["instance:string"])
+	assert.Equal(t, expiring.UnencodableValueError, es.Set("struct", struct{}{}, nil))
+
+	val, err := es.Get("bytes")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	val, ttl, err := es.GetWithTTL("string")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.True(t, ttl > 0 && ttl <= defaultExpiration)
+
+	// values written without the envelope are returned as they are
+	ms.cache["instance:raw"] = []byte("raw")
+	val, err = es.Get("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("raw"), val)
+
+	clk.Advance(defaultSleep)
+	_, err = es.Get("bytes")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
+
+func TestBinaryEnvelopeReadAsString(t *testing.T) {
+	ms := StringMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+
+	assert.Nil(t, es.Set("key", []byte("value"), nil))
+	assert.IsType(t, "", ms.cache["key"])
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	// a lease is encoded too
+	_, lease, err := es.GetWithLease("missing")
+	assert.NotNil(t, err)
+	assert.NotEqual(t, expiring.Lease{}, lease)
+	_, _, err = es.GetWithLease("missing")
+	assert.Equal(t, expiring.LeaseHeldError, err)
+	assert.Nil(t, es.SetWithLease("missing", "value", lease, nil))
+}
+
+func TestBinaryEnvelopeVersion1(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+
+	// magic, version 1, string kind, expireAt, no instance, value
+	v1 := []byte{0xe7, 0x78, 1, 1}
+	expireAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(expireAt, uint64(time.Now().Add(time.Hour).UnixNano()))
+	v1 = append(append(append(v1, expireAt...), 0), "value"...)
+	ms.cache["key"] = v1
+
+	val, md, err := es.GetStale("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.False(t, md.Expired)
+	assert.True(t, md.Timestamp.IsZero())
+}
+
+func TestNativeExpiration(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithNativeExpiration())
+
+	assert.Nil(t, es.Set("key", "value", &store.Options{Tags: []string{"tag"}}))
+	assert.True(t, ms.lastSetOptions.Expiration > 59*time.Minute && ms.lastSetOptions.Expiration <= time.Hour)
+	assert.Equal(t, []string{"tag"}, ms.lastSetOptions.Tags)
+
+	assert.Nil(t, es.Set("key", "value", &store.Options{Expiration: time.Minute}))
+	assert.True(t, ms.lastSetOptions.Expiration > 59*time.Second && ms.lastSetOptions.Expiration <= time.Minute)
+}
+
+func (ms *StringMapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	return ms.MapStore.Set(key, value, options)
+}
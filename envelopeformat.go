@@ -0,0 +1,371 @@
+package expiring_gocache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// The JSON and MessagePack envelopes, written by stores created
+// WithEnvelopeFormat for services written in other languages to share, are
+// maps of
+//
+//	v: version | kind: "bytes", "string", "lease", "error" or "codec" |
+//	expireAt: Unix milliseconds | timestamp: Unix milliseconds |
+//	createdAt: Unix milliseconds | etag: 16 hex digits | instance |
+//	tags: [tag ...] | generation | maxReads | value
+//
+// in that order. v must come first, so that envelopes can be told apart
+// from other values by their first bytes; fields other than v, kind,
+// expireAt and value are left out when they are zero. The value of the
+// string, lease and error kinds is a string; that of the bytes and codec
+// kinds is base64, with padding, in JSON, and binary in MessagePack. Times
+// are in milliseconds, and the etag a string, so that JSON decoders which
+// read numbers as doubles, such as JavaScript's, read them exactly. Keys
+// not listed here are ignored, so that later versions can add them.
+//
+// Both carry the same fields as the binary envelope, and the same version.
+
+type (
+	// Envelope is a value as a Store created WithBinaryEnvelope or
+	// WithEnvelopeFormat writes it, with its expiration and the metadata
+	// written along with it, for reading and writing entries outside a
+	// Store, e.g. in tools, or to check other services' encoders against.
+	Envelope struct {
+		// Version is the envelope version the value was written with.
+		// EncodeEnvelope always writes the current one.
+		Version int
+		// Kind is what Value holds. It is empty for kinds of a later
+		// version which this one doesn't know.
+		Kind  EnvelopeKind
+		Value []byte
+		// ExpireAt is when the value expires. Timestamp, if set, is the
+		// time given to SetIfNewer, and CreatedAt when the value was
+		// written, by the writer's clock.
+		ExpireAt  time.Time
+		Timestamp time.Time
+		CreatedAt time.Time
+		// ETag is the value's content hash, or 0.
+		ETag uint64
+		// Instance is the ID of the Store which wrote the value, see
+		// WithInstanceID.
+		Instance string
+		// Tags are the value's tags, recorded by Stores created
+		// WithTagExpiry.
+		Tags []string
+		// Generation is the generation of the key's namespace, recorded by
+		// Stores created WithGenerations.
+		Generation uint64
+		// MaxReads is the read limit set by MaxReadsTag, or 0.
+		MaxReads uint64
+	}
+
+	// EnvelopeKind is what an Envelope's value holds.
+	EnvelopeKind string
+
+	// EnvelopeFormat is an encoding of an Envelope.
+	EnvelopeFormat int
+
+	// jsonEnvelope is the JSON envelope, and the fields of the MessagePack
+	// one other than its value.
+	jsonEnvelope struct {
+		Version    int          `json:"v"`
+		Kind       EnvelopeKind `json:"kind"`
+		ExpireAt   int64        `json:"expireAt"`
+		Timestamp  int64        `json:"timestamp,omitempty"`
+		CreatedAt  int64        `json:"createdAt,omitempty"`
+		ETag       string       `json:"etag,omitempty"`
+		Instance   string       `json:"instance,omitempty"`
+		Tags       []string     `json:"tags,omitempty"`
+		Generation uint64       `json:"generation,omitempty"`
+		MaxReads   uint64       `json:"maxReads,omitempty"`
+		Value      string       `json:"value"`
+	}
+)
+
+const (
+	EnvelopeBytes  EnvelopeKind = "bytes"
+	EnvelopeString EnvelopeKind = "string"
+	// EnvelopeLease is a lease token, see GetWithLease.
+	EnvelopeLease EnvelopeKind = "lease"
+	// EnvelopeError is the message of an error cached by SetError.
+	EnvelopeError EnvelopeKind = "error"
+	// EnvelopeCodec is a value encoded by a Codec.
+	EnvelopeCodec EnvelopeKind = "codec"
+)
+
+const (
+	// BinaryEnvelope is the compact envelope written by WithBinaryEnvelope.
+	BinaryEnvelope EnvelopeFormat = iota
+	// JSONEnvelope is the envelope as a JSON object.
+	JSONEnvelope
+	// MsgpackEnvelope is the envelope as a MessagePack map.
+	MsgpackEnvelope
+)
+
+// envelopeUnknown is the kind of values of a kind added by a later version.
+const envelopeUnknown byte = 0xff
+
+var (
+	InvalidEnvelopeError       = errors.New("not a valid envelope")
+	UnknownEnvelopeFormatError = errors.New("unknown envelope format")
+
+	envelopeKinds = map[byte]EnvelopeKind{
+		envelopeBytes:  EnvelopeBytes,
+		envelopeString: EnvelopeString,
+		envelopeLease:  EnvelopeLease,
+		envelopeError:  EnvelopeError,
+		envelopeCodec:  EnvelopeCodec,
+	}
+	envelopeKindBytes = map[EnvelopeKind]byte{
+		EnvelopeBytes:  envelopeBytes,
+		EnvelopeString: envelopeString,
+		EnvelopeLease:  envelopeLease,
+		EnvelopeError:  envelopeError,
+		EnvelopeCodec:  envelopeCodec,
+	}
+)
+
+// EncodeEnvelope returns env in format, as a Store created WithBinaryEnvelope
+// or WithEnvelopeFormat would write it. InvalidEnvelopeError is returned if
+// env's Kind is unknown.
+func EncodeEnvelope(env Envelope, format EnvelopeFormat) ([]byte, error) {
+	env.Version = envelopeVersion
+	switch format {
+	case BinaryEnvelope:
+		ew, payload, ok := env.parts()
+		if !ok {
+			return nil, InvalidEnvelopeError
+		}
+		if ew, ok = payload.decode(ew); !ok {
+			return nil, InvalidEnvelopeError
+		}
+		return encodeEnvelope(ew)
+	case JSONEnvelope, MsgpackEnvelope:
+		if _, ok := envelopeKindBytes[env.Kind]; !ok {
+			return nil, InvalidEnvelopeError
+		}
+		if format == MsgpackEnvelope {
+			return appendMsgpackEnvelope(nil, env), nil
+		}
+		return encodeJSONEnvelope(env)
+	}
+	return nil, UnknownEnvelopeFormatError
+}
+
+// DecodeEnvelope returns the envelope held by b, in any of the formats, and
+// which it is. InvalidEnvelopeError is returned if b isn't an envelope.
+func DecodeEnvelope(b []byte) (Envelope, EnvelopeFormat, error) {
+	format, ok := envelopeFormatOf(b)
+	if !ok {
+		return Envelope{}, 0, InvalidEnvelopeError
+	}
+	ew, payload, ok := decodeEnvelopeBytes(b)
+	if !ok {
+		return Envelope{}, 0, InvalidEnvelopeError
+	}
+	payload.payload = append([]byte(nil), payload.payload...)
+	return envelopeFrom(ew, payload), format, nil
+}
+
+// encodeEnvelopeAs encodes ew in format, other than BinaryEnvelope.
+func encodeEnvelopeAs(ew wrappedValue, format EnvelopeFormat) ([]byte, error) {
+	kind, payload, err := payloadOf(ew.value)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeEnvelope(envelopeFrom(ew, envelopePayload{encoded: true, kind: kind, payload: payload}), format)
+}
+
+// decodeEnvelopeBytes is decodeEnvelopeHeader for envelopes in any of the
+// formats.
+func decodeEnvelopeBytes(b []byte) (wrappedValue, envelopePayload, bool) {
+	format, ok := envelopeFormatOf(b)
+	if !ok {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+	var env Envelope
+	switch format {
+	case JSONEnvelope:
+		env, ok = decodeJSONEnvelope(b)
+	case MsgpackEnvelope:
+		env, ok = decodeMsgpackEnvelope(b)
+	default:
+		return decodeEnvelopeHeader(b)
+	}
+	if !ok {
+		return wrappedValue{}, envelopePayload{}, false
+	}
+	return env.parts()
+}
+
+// envelopeFormatOf returns the format of the envelope b holds, from its
+// first bytes, or false if it can't be one.
+func envelopeFormatOf(b []byte) (EnvelopeFormat, bool) {
+	switch {
+	case len(b) > len(envelopeMagic) && string(b[:len(envelopeMagic)]) == envelopeMagic:
+		return BinaryEnvelope, true
+	case len(b) > 0 && b[0] == '{':
+		return JSONEnvelope, bytes.HasPrefix(bytes.TrimLeft(b[1:], " \t\r\n"), []byte(`"v"`))
+	case len(b) > 2 && b[0]&0xf0 == 0x80:
+		// fixmap, then the fixstr "v"
+		return MsgpackEnvelope, b[1] == 0xa1 && b[2] == 'v'
+	case len(b) > 4 && b[0] == 0xde:
+		// map 16
+		return MsgpackEnvelope, b[3] == 0xa1 && b[4] == 'v'
+	}
+	return 0, false
+}
+
+// envelopeFrom returns the Envelope held by ew and payload.
+func envelopeFrom(ew wrappedValue, payload envelopePayload) Envelope {
+	return Envelope{
+		Version:    int(ew.version),
+		Kind:       envelopeKinds[payload.kind],
+		Value:      payload.payload,
+		ExpireAt:   ew.expireAt,
+		Timestamp:  ew.timestamp,
+		CreatedAt:  ew.createdAt,
+		ETag:       ew.etag,
+		Instance:   ew.instance,
+		Tags:       ew.tags,
+		Generation: ew.generation,
+		MaxReads:   ew.maxReads,
+	}
+}
+
+// parts returns env as the Store holds it, or false if it isn't valid.
+// Kinds this version doesn't know are only allowed in envelopes of later
+// versions.
+func (env Envelope) parts() (wrappedValue, envelopePayload, bool) {
+	kind, known := envelopeKindBytes[env.Kind]
+	switch {
+	case env.Version <= 0:
+		return wrappedValue{}, envelopePayload{}, false
+	case !known && env.Version <= envelopeVersion:
+		return wrappedValue{}, envelopePayload{}, false
+	case !known:
+		kind = envelopeUnknown
+	}
+	version := env.Version
+	if version > 0xff {
+		version = 0xff
+	}
+	ew := wrappedValue{
+		expireAt:   env.ExpireAt,
+		instance:   env.Instance,
+		timestamp:  env.Timestamp,
+		etag:       env.ETag,
+		createdAt:  env.CreatedAt,
+		tags:       env.Tags,
+		generation: env.Generation,
+		maxReads:   env.MaxReads,
+		version:    byte(version),
+	}
+	return ew, envelopePayload{encoded: true, kind: kind, payload: env.Value}, true
+}
+
+func encodeJSONEnvelope(env Envelope) ([]byte, error) {
+	je := jsonEnvelopeOf(env)
+	if binaryKind(env.Kind) {
+		je.Value = base64.StdEncoding.EncodeToString(env.Value)
+	} else {
+		je.Value = string(env.Value)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(je); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func decodeJSONEnvelope(b []byte) (Envelope, bool) {
+	var je jsonEnvelope
+	if err := json.Unmarshal(b, &je); err != nil {
+		return Envelope{}, false
+	}
+	value := []byte(je.Value)
+	if binaryKind(je.Kind) {
+		var err error
+		if value, err = base64.StdEncoding.DecodeString(je.Value); err != nil {
+			return Envelope{}, false
+		}
+	}
+	return je.envelope(value)
+}
+
+// jsonEnvelopeOf returns the fields of env other than its value.
+func jsonEnvelopeOf(env Envelope) jsonEnvelope {
+	je := jsonEnvelope{
+		Version:    env.Version,
+		Kind:       env.Kind,
+		ExpireAt:   unixMilli(env.ExpireAt),
+		Timestamp:  unixMilli(env.Timestamp),
+		CreatedAt:  unixMilli(env.CreatedAt),
+		Instance:   env.Instance,
+		Tags:       env.Tags,
+		Generation: env.Generation,
+		MaxReads:   env.MaxReads,
+	}
+	if env.ETag != 0 {
+		je.ETag = fmt.Sprintf("%016x", env.ETag)
+	}
+	return je
+}
+
+// envelope returns je as an Envelope holding value.
+func (je jsonEnvelope) envelope(value []byte) (Envelope, bool) {
+	env := Envelope{
+		Version:    je.Version,
+		Kind:       je.Kind,
+		Value:      value,
+		ExpireAt:   fromUnixMilli(je.ExpireAt),
+		Timestamp:  fromUnixMilli(je.Timestamp),
+		CreatedAt:  fromUnixMilli(je.CreatedAt),
+		Instance:   je.Instance,
+		Tags:       je.Tags,
+		Generation: je.Generation,
+		MaxReads:   je.MaxReads,
+	}
+	if je.ETag != "" {
+		etag, err := strconv.ParseUint(je.ETag, 16, 64)
+		if err != nil {
+			return Envelope{}, false
+		}
+		env.ETag = etag
+	}
+	if _, known := envelopeKindBytes[env.Kind]; !known {
+		env.Kind = ""
+		if env.Version <= envelopeVersion {
+			return Envelope{}, false
+		}
+	}
+	return env, env.Version > 0
+}
+
+// binaryKind reports whether values of kind are bytes rather than text.
+func binaryKind(kind EnvelopeKind) bool {
+	return kind == EnvelopeBytes || kind == EnvelopeCodec
+}
+
+// unixMilli returns t in Unix milliseconds, or 0 for the zero time.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// fromUnixMilli reverses unixMilli.
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
@@ -0,0 +1,111 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeEnvelope(t *testing.T) {
+	at := time.Unix(1600000000, 0)
+	env := expiring.Envelope{
+		Version:    8,
+		Kind:       expiring.EnvelopeBytes,
+		Value:      []byte{0xff, 0x00, 'v'},
+		ExpireAt:   at.Add(time.Hour),
+		Timestamp:  at.Add(-time.Second),
+		CreatedAt:  at,
+		ETag:       0xfedcba9876543210,
+		Instance:   "one",
+		Tags:       []string{"a", "b"},
+		Generation: 3,
+		MaxReads:   2,
+	}
+	for _, format := range []expiring.EnvelopeFormat{expiring.BinaryEnvelope, expiring.JSONEnvelope, expiring.MsgpackEnvelope} {
+		b, err := expiring.EncodeEnvelope(env, format)
+		assert.Nil(t, err, "format %d", format)
+		decoded, decodedFormat, err := expiring.DecodeEnvelope(b)
+		assert.Nil(t, err, "format %d", format)
+		assert.Equal(t, format, decodedFormat)
+		assert.Equal(t, env.Value, decoded.Value, "format %d", format)
+		assert.True(t, env.ExpireAt.Equal(decoded.ExpireAt), "format %d", format)
+		assert.True(t, env.Timestamp.Equal(decoded.Timestamp), "format %d", format)
+		assert.True(t, env.CreatedAt.Equal(decoded.CreatedAt), "format %d", format)
+		decoded.ExpireAt, decoded.Timestamp, decoded.CreatedAt = env.ExpireAt, env.Timestamp, env.CreatedAt
+		assert.Equal(t, env, decoded, "format %d", format)
+	}
+
+	_, err := expiring.EncodeEnvelope(expiring.Envelope{Kind: "unknown"}, expiring.JSONEnvelope)
+	assert.Equal(t, expiring.InvalidEnvelopeError, err)
+	_, _, err = expiring.DecodeEnvelope([]byte(`{"name":"not an envelope"}`))
+	assert.Equal(t, expiring.InvalidEnvelopeError, err)
+}
+
+func TestCanonicalEnvelopes(t *testing.T) {
+	env := expiring.Envelope{Kind: expiring.EnvelopeString, Value: []byte("<value>"), ExpireAt: time.Unix(1600000000, 0)}
+
+	b, err := expiring.EncodeEnvelope(env, expiring.JSONEnvelope)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"v":8,"kind":"string","expireAt":1600000000000,"value":"<value>"}`, string(b))
+
+	b, err = expiring.EncodeEnvelope(env, expiring.MsgpackEnvelope)
+	assert.Nil(t, err)
+	want := []byte{0x84, 0xa1, 'v', 8, 0xa4, 'k', 'i', 'n', 'd', 0xa6, 's', 't', 'r', 'i', 'n', 'g',
+		0xa8, 'e', 'x', 'p', 'i', 'r', 'e', 'A', 't', 0xcf, 0, 0, 0x01, 0x74, 0x87, 0x6e, 0x80, 0x00,
+		0xa5, 'v', 'a', 'l', 'u', 'e', 0xa7, '<', 'v', 'a', 'l', 'u', 'e', '>'}
+	assert.Equal(t, want, b)
+}
+
+func TestEnvelopeFormat(t *testing.T) {
+	for _, format := range []expiring.EnvelopeFormat{expiring.JSONEnvelope, expiring.MsgpackEnvelope} {
+		ms := MapStore{cache: map[interface{}]interface{}{}}
+		es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithEnvelopeFormat(format))
+		assert.Nil(t, es.Set("key", "value", nil))
+
+		env, decodedFormat, err := expiring.DecodeEnvelope(ms.cache["key"].([]byte))
+		assert.Nil(t, err)
+		assert.Equal(t, format, decodedFormat)
+		assert.Equal(t, expiring.EnvelopeString, env.Kind)
+		val, err := es.Get("key")
+		assert.Nil(t, err)
+		assert.Equal(t, "value", val)
+
+		// and by a Store writing the binary envelope
+		binary := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+		val, err = binary.Get("key")
+		assert.Nil(t, err)
+		assert.Equal(t, "value", val)
+	}
+}
+
+func TestEnvelopesFromOtherLanguages(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+
+	// as written by Python's json.dumps, with a field of a later version
+	ms.cache["python"] = `{"v": 8, "kind": "bytes", "expireAt": 32503680000000, "extra": [1, 2], "value": "dmFsdWU="}`
+	val, err := es.Get("python")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	ms.cache["expired"] = []byte(`{"v":8,"kind":"string","expireAt":1600000000000,"value":"value"}`)
+	_, err = es.Get("expired")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+
+	// JSON which isn't an envelope is returned as it is
+	ms.cache["raw"] = []byte(`{"name":"value"}`)
+	val, err = es.Get("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`{"name":"value"}`), val)
+
+	// MessagePack from a Node encoder, with a map for an unknown field
+	ms.cache["node"] = []byte{0x84, 0xa1, 'v', 8, 0xa4, 'k', 'i', 'n', 'd', 0xa6, 's', 't', 'r', 'i', 'n', 'g',
+		0xa5, 'e', 'x', 't', 'r', 'a', 0x81, 0xa1, 'k', 0xc3,
+		0xa5, 'v', 'a', 'l', 'u', 'e', 0xa5, 'v', 'a', 'l', 'u', 'e'}
+	_, err = es.Get("node")
+	// no expireAt, so it has expired
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
@@ -0,0 +1,174 @@
+package expiring_gocache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+type (
+	// ErrorClass is what an error from the underlying store means for the
+	// Store: whether the key was missing, or the call failed, and whether it
+	// is worth trying again.
+	ErrorClass int
+
+	// ErrorClassifier classifies the errors returned by an underlying store,
+	// so that delete retries, shard ejection, Stats and WithMissError agree
+	// on what each error means. See WithErrorClassifier.
+	ErrorClassifier interface {
+		Classify(op Operation, err error) ErrorClass
+	}
+
+	// ErrorClassifierFunc is an ErrorClassifier.
+	ErrorClassifierFunc func(op Operation, err error) ErrorClass
+
+	defaultClassifier struct{}
+)
+
+const (
+	// ErrorMiss is a key the store doesn't hold.
+	ErrorMiss ErrorClass = iota
+	// ErrorTransient is a failure which may not happen again, e.g. a
+	// dropped connection.
+	ErrorTransient
+	// ErrorPermanent is a failure which will happen again, e.g. a key or
+	// value the store can't hold.
+	ErrorPermanent
+	// ErrorTimeout is a call which took too long.
+	ErrorTimeout
+)
+
+// DefaultErrorClassifier classifies errors as well as it can without knowing
+// the store: context deadlines and errors with a `Timeout() bool` method
+// returning true are timeouts, errors about keys and values the Store can't
+// handle, such as UnsupportedError, are permanent, and errors with a
+// `Temporary() bool` method returning true are transient. Since stores
+// report misses as errors, any other error from a read is a miss, and from
+// a write transient.
+var DefaultErrorClassifier ErrorClassifier = defaultClassifier{}
+
+// permanentErrors are the Store's errors which classify as ErrorPermanent.
+var permanentErrors = []error{
+	UnsupportedError,
+	UnencodableValueError,
+	KeyNotCacheableError,
+	UntrackableKeyError,
+	NotACounterError,
+	NoCodecError,
+}
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorMiss:
+		return "miss"
+	case ErrorTransient:
+		return "transient"
+	case ErrorPermanent:
+		return "permanent"
+	case ErrorTimeout:
+		return "timeout"
+	default:
+		return "unknown"
+	}
+}
+
+func (f ErrorClassifierFunc) Classify(op Operation, err error) ErrorClass {
+	return f(op, err)
+}
+
+func (defaultClassifier) Classify(op Operation, err error) ErrorClass {
+	if class, ok := classifyKnown(err); ok {
+		return class
+	}
+	if isRead(op) {
+		return ErrorMiss
+	}
+	return ErrorTransient
+}
+
+// MissClassifier returns an ErrorClassifier for a store whose misses can be
+// told apart from its failures by isMiss, e.g. by comparing against
+// redis.Nil. Errors for which isMiss returns true are misses; the rest are
+// classified as by DefaultErrorClassifier, except that errors from reads
+// are transient rather than misses.
+func MissClassifier(isMiss func(err error) bool) ErrorClassifier {
+	return ErrorClassifierFunc(func(op Operation, err error) ErrorClass {
+		if isMiss(err) {
+			return ErrorMiss
+		}
+		if class, ok := classifyKnown(err); ok {
+			return class
+		}
+		return ErrorTransient
+	})
+}
+
+// classifyKnown classifies the errors DefaultErrorClassifier recognises
+// whatever the operation.
+func classifyKnown(err error) (ErrorClass, bool) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTimeout, true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return ErrorTimeout, true
+	}
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return ErrorPermanent, true
+		}
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return ErrorTransient, true
+	}
+	return 0, false
+}
+
+func isRead(op Operation) bool {
+	return op == OperationGet || op == OperationGetMulti
+}
+
+// classify classifies err, returned by the underlying store for op, by the
+// classifier given to WithErrorClassifier.
+func (es Store) classify(op Operation, err error) ErrorClass {
+	if es.classifier != nil {
+		return es.classifier.Classify(op, err)
+	}
+	return DefaultErrorClassifier.Classify(op, err)
+}
+
+// countError counts a failed call to the underlying store in Stats.
+func (es Store) countError(op Operation, err error) {
+	switch es.classify(op, err) {
+	case ErrorTransient:
+		atomic.AddUint64(&es.stats.transientErrors, 1)
+	case ErrorPermanent:
+		atomic.AddUint64(&es.stats.permanentErrors, 1)
+	case ErrorTimeout:
+		atomic.AddUint64(&es.stats.timeouts, 1)
+	}
+}
+
+// missed returns the error for a read of the underlying store which failed
+// with err: the error given to WithMissError if err is a miss, or else err.
+func (es Store) missed(err error) error {
+	if es.missErr != nil && err != nil && es.classify(OperationGet, err) == ErrorMiss {
+		return es.missErr
+	}
+	return err
+}
+
+// IsMiss reports whether err, returned by a read of the Store, is a miss of
+// the underlying store, as classified by WithErrorClassifier, rather than a
+// failure or an error of the Store's own. Expired values are reported with
+// ValueExpiredError instead, which errors.Is matches.
+func (es Store) IsMiss(err error) bool {
+	switch {
+	case err == nil || err == ForeignValueError || err == FutureEnvelopeError || isCachedError(err):
+		return false
+	case es.missErr != nil && err == es.missErr:
+		return true
+	}
+	return es.classify(OperationGet, err) == ErrorMiss
+}
@@ -0,0 +1,80 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// timeoutError is a net.Error which timed out.
+	timeoutError struct{}
+)
+
+var (
+	NotFoundError = errors.New("not found")
+)
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	c := expiring.DefaultErrorClassifier
+	for _, tc := range []struct {
+		op    expiring.Operation
+		err   error
+		class expiring.ErrorClass
+	}{
+		{expiring.OperationGet, MapStoreMiss, expiring.ErrorMiss},
+		{expiring.OperationDelete, FailingDeleteError, expiring.ErrorTransient},
+		{expiring.OperationGet, context.DeadlineExceeded, expiring.ErrorTimeout},
+		{expiring.OperationSet, timeoutError{}, expiring.ErrorTimeout},
+		{expiring.OperationSet, fmt.Errorf("wrapped: %w", expiring.UnencodableValueError), expiring.ErrorPermanent},
+	} {
+		assert.Equal(t, tc.class, c.Classify(tc.op, tc.err), "%s %v", tc.op, tc.err)
+	}
+
+	c = expiring.MissClassifier(func(err error) bool { return err == MapStoreMiss })
+	assert.Equal(t, expiring.ErrorMiss, c.Classify(expiring.OperationGet, MapStoreMiss))
+	assert.Equal(t, expiring.ErrorTransient, c.Classify(expiring.OperationGet, FailingDeleteError))
+	assert.Equal(t, "timeout", c.Classify(expiring.OperationGet, timeoutError{}).String())
+}
+
+func TestErrorClassifierStatsAndMissError(t *testing.T) {
+	fds := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 1}
+	permanent := expiring.ErrorClassifierFunc(func(op expiring.Operation, err error) expiring.ErrorClass {
+		if err == MapStoreMiss {
+			return expiring.ErrorMiss
+		}
+		return expiring.ErrorPermanent
+	})
+	fake := clock.NewFake(time.Unix(0, 0))
+	es := expiring.New(&fds, &store.Options{Expiration: time.Minute},
+		expiring.WithClock(fake),
+		expiring.WithErrorClassifier(permanent),
+		expiring.WithMissError(NotFoundError),
+		expiring.WithDeleteRetries(10, 3, time.Millisecond),
+	)
+	defer es.Close()
+
+	_, err := es.Get("missing")
+	assert.Equal(t, NotFoundError, err)
+	_, _, err = es.GetWithTTL("missing")
+	assert.Equal(t, NotFoundError, err)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	fake.Advance(time.Hour)
+	_, err = es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	// permanent failures aren't retried
+	assert.Equal(t, expiring.Stats{DeleteFailures: 1, DeleteRetriesDropped: 1, PermanentErrors: 1}, es.Stats())
+	assert.Equal(t, 1, fds.deleteCount)
+}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,135 @@
+package expiring_gocache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
+
+var (
+	// writeTags numbers the values which can't be hashed, starting from a
+	// point unlikely to be reused by another process.
+	writeTags = uint64(time.Now().UnixNano())
+)
+
+// GetIfChanged is like Get, but if etag is the ETag of the stored value, the
+// value is not returned, nor decoded from a binary envelope; changed is then
+// false. Otherwise the value is returned with its ETag, which can be passed
+// to a later call. An empty etag matches no value.
+//
+// The ETag of a []byte or string value is a hash of its content, so writing
+// the same content again doesn't change it, as is that of a value encoded by
+// the codec given to WithCodec, a hash of its encoding. Other values get a
+// new ETag each time they are written. Values which were not written through
+// the Store have no ETag, and are always reported as changed.
+func (es Store) GetIfChanged(key interface{}, etag string) (value interface{}, newEtag string, changed bool, err error) {
+	val, err := es.innerGet(key)
+	if err != nil || val == nil || es.bypassed(key) {
+		return val, "", true, err
+	}
+	ew, env, ok := unwrapHeader(val)
+	if !ok {
+		return val, "", true, nil
+	}
+	if ew.instance != es.instanceID {
+		return nil, "", true, ForeignValueError
+	}
+	if ew.version > envelopeVersion {
+		// read in full, for the policy given to WithFutureEnvelopes
+		full, _ := unwrap(val)
+		if _, err := es.futureEnvelope(key, full); err != nil {
+			return nil, "", true, err
+		}
+	}
+	if ew, err = es.withGeneration(key, es.withTagExpiry(ew)); err != nil {
+		return nil, "", true, err
+	}
+
+	now := es.now()
+	es.observeSkew(ew, now)
+	if es.isExpired(ew, now) && !es.pins.has(key) {
+		es.observeRead(ew, now, true)
+		es.expire(key)
+		return nil, "", true, es.expired()
+	}
+	es.observeRead(ew, now, false)
+	if ok, err := es.readAllowed(key, ew); err != nil {
+		return nil, "", true, err
+	} else if !ok {
+		return nil, "", true, es.expired()
+	}
+
+	es.touch(key)
+	if ew.etag == 0 && env.encoded && env.kind != envelopeLease {
+		// the envelope was written without an etag
+		ew.etag = contentHash(env.payload)
+	}
+	newEtag = formatETag(ew.etag)
+	if etag != "" && etag == newEtag {
+		return nil, newEtag, false, nil
+	}
+	if env.encoded {
+		if ew, ok = env.decode(ew); !ok {
+			return val, "", true, nil
+		}
+		if ew, err = es.decoded(ew); err != nil {
+			return nil, "", true, err
+		}
+	}
+	if cerr, ok := es.cachedError(ew); ok {
+		return nil, newEtag, true, cerr
+	}
+	return es.clone(ew.value), newEtag, true, nil
+}
+
+// etagFor returns the ETag stored with value, unless it is then encoded by
+// the Store's codec, see encoded.
+func etagFor(value interface{}) uint64 {
+	switch v := value.(type) {
+	case []byte:
+		return contentHash(v)
+	case string:
+		return contentHashString(v)
+	}
+	return atomic.AddUint64(&writeTags, 1)
+}
+
+// contentHash returns the FNV-1a hash of b, which is never 0.
+func contentHash(b []byte) uint64 {
+	h := fnvOffset
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnvPrime
+	}
+	return nonZero(h)
+}
+
+// contentHashString is contentHash for a string, without copying it.
+func contentHashString(s string) uint64 {
+	h := fnvOffset
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return nonZero(h)
+}
+
+// nonZero keeps 0 free to mean "no ETag".
+func nonZero(h uint64) uint64 {
+	if h == 0 {
+		return 1
+	}
+	return h
+}
+
+func formatETag(etag uint64) string {
+	if etag == 0 {
+		return ""
+	}
+	return strconv.FormatUint(etag, 16)
+}
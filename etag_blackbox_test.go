@@ -0,0 +1,76 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetIfChanged(t *testing.T) {
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, append(opts, expiring.WithClock(clk))...)
+
+			assert.Nil(t, es.Set("key", []byte("value"), nil))
+			val, etag, changed, err := es.GetIfChanged("key", "")
+			assert.Nil(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, []byte("value"), val)
+			assert.NotEmpty(t, etag)
+
+			val, newEtag, changed, err := es.GetIfChanged("key", etag)
+			assert.Nil(t, err)
+			assert.False(t, changed)
+			assert.Nil(t, val)
+			assert.Equal(t, etag, newEtag)
+
+			// the same content keeps its etag
+			assert.Nil(t, es.Set("key", []byte("value"), nil))
+			_, _, changed, _ = es.GetIfChanged("key", etag)
+			assert.False(t, changed)
+
+			assert.Nil(t, es.Set("key", "other", nil))
+			val, newEtag, changed, err = es.GetIfChanged("key", etag)
+			assert.Nil(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, "other", val)
+			assert.NotEqual(t, etag, newEtag)
+
+			clk.Advance(defaultSleep)
+			_, _, _, err = es.GetIfChanged("key", newEtag)
+			assert.Equal(t, expiring.ValueExpiredError, err)
+		})
+	}
+}
+
+func TestGetIfChangedWrittenValues(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+
+	// values which can't be hashed get a new etag on every write
+	assert.Nil(t, es.Set("key", 42, nil))
+	_, etag, _, err := es.GetIfChanged("key", "")
+	assert.Nil(t, err)
+	assert.Nil(t, es.Set("key", 42, nil))
+	val, _, changed, err := es.GetIfChanged("key", etag)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 42, val)
+
+	// values not written through the Store have no etag
+	ms.cache["raw"] = "raw"
+	val, etag, changed, err = es.GetIfChanged("raw", "")
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "raw", val)
+	assert.Empty(t, etag)
+}
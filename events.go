@@ -0,0 +1,68 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// EventType names a change to the values held for a Store.
+	EventType string
+
+	// Event describes a change to the values held for a Store, see
+	// WithEventHook.
+	Event struct {
+		Type EventType
+		// Key is the key changed. It is nil for EventClear and
+		// EventInvalidate.
+		Key interface{}
+		// TTL is how long a value written by EventSet lives, or 0 for
+		// values passed straight through, see WithBypass.
+		TTL time.Duration
+		// Tags are the tags invalidated by EventInvalidate.
+		Tags []string
+		Time time.Time
+		// Context is the context given to the call which made the change,
+		// e.g. to read trace or tenant IDs from, if it was made by
+		// GetWithContext, SetWithContext or ForEach. It is nil otherwise.
+		Context context.Context
+
+		// value is the value written by EventSet, for the mutation log.
+		value interface{}
+	}
+)
+
+const (
+	// EventSet is a value written by Set, or any of its variants.
+	EventSet EventType = "set"
+	// EventDelete is a value deleted, or soft deleted, by Delete or
+	// DeleteAfter.
+	EventDelete EventType = "delete"
+	// EventExpire is an expired value deleted by a read or the reaper.
+	EventExpire EventType = "expire"
+	// EventEvict is a value deleted because the Store was over capacity.
+	EventEvict EventType = "evict"
+	// EventClear is the underlying store being cleared.
+	EventClear EventType = "clear"
+	// EventInvalidate is the underlying store invalidating tags.
+	EventInvalidate EventType = "invalidate"
+)
+
+// callHook passes e to hook, recovering if it panics.
+func (es Store) callHook(hook func(Event), e Event) {
+	defer es.recoverPanic("event hook", nil)
+	hook(e)
+}
+
+// emit passes an event to every hook given to WithEventHook, and records it
+// in the mutation log.
+func (es Store) emit(e Event) {
+	if len(es.eventHooks) == 0 && es.mutationLog == nil {
+		return
+	}
+	e.Time, e.Context = es.now(), es.ctx
+	es.logMutation(e)
+	for _, hook := range es.eventHooks {
+		es.callHook(hook, e)
+	}
+}
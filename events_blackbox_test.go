@@ -0,0 +1,85 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHook(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	var (
+		mu     sync.Mutex
+		events []expiring.Event
+	)
+	hook := func(e expiring.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, expiring.WithEventHook(hook), expiring.WithMaxEntries(1), expiring.WithClock(clk))
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("key2", "value", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+	_, err := es.Get("key2")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Nil(t, es.Delete("key"))
+	assert.Nil(t, es.Invalidate(store.InvalidateOptions{Tags: []string{"tag"}}))
+	assert.Nil(t, es.Clear())
+
+	mu.Lock()
+	defer mu.Unlock()
+	var types []expiring.EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+		assert.False(t, e.Time.IsZero())
+	}
+	assert.Equal(t, []expiring.EventType{
+		expiring.EventSet,
+		expiring.EventSet,
+		expiring.EventEvict,
+		expiring.EventExpire,
+		expiring.EventDelete,
+		expiring.EventInvalidate,
+		expiring.EventClear,
+	}, types)
+	assert.Equal(t, "key", events[0].Key)
+	assert.InDelta(t, float64(defaultExpiration), float64(events[0].TTL), float64(10*time.Millisecond))
+	assert.Equal(t, "key", events[2].Key)
+	assert.Equal(t, "key2", events[3].Key)
+	assert.Equal(t, []string{"tag"}, events[5].Tags)
+}
+
+type traceIDKey struct{}
+
+func TestEventHookContext(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	var traces []interface{}
+	hook := func(e expiring.Event) {
+		if e.Context == nil {
+			traces = append(traces, nil)
+			return
+		}
+		traces = append(traces, e.Context.Value(traceIDKey{}))
+	}
+
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithEventHook(hook), expiring.WithClock(clk))
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "trace")
+
+	assert.Nil(t, es.SetWithContext(ctx, "key", "value", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+	_, err := es.GetWithContext(ctx, "key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Nil(t, es.Delete("key"))
+
+	assert.Equal(t, []interface{}{"trace", "trace", nil}, traces)
+}
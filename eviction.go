@@ -0,0 +1,144 @@
+package expiring_gocache
+
+import (
+	"container/list"
+)
+
+type (
+	// EvictionPolicy orders the keys of one priority of a Store capped by
+	// WithMaxEntries for eviction, see WithEvictionPolicy. It is told of
+	// every key added, accessed by a read or another Set, and removed, and
+	// asked for the keys to evict. The Store serializes its calls, with its
+	// own lock held, so a policy needn't be safe for concurrent use, and
+	// mustn't call the Store.
+	EvictionPolicy interface {
+		Added(key interface{})
+		Accessed(key interface{})
+		Removed(key interface{})
+		// Victims returns up to n keys to evict, the first first, skipping
+		// keys for which keep returns true. It doesn't remove them; the
+		// Store calls Removed for each key it evicts.
+		Victims(n int, keep func(key interface{}) bool) []interface{}
+	}
+
+	// lruPolicy evicts the least recently used keys first.
+	lruPolicy struct {
+		order    *list.List
+		elements map[interface{}]*list.Element
+	}
+
+	// slruPolicy is a segmented LRU: keys are added to a probation segment,
+	// and promoted to a protected segment when they are accessed again.
+	// Keys are evicted from probation first, so that keys read once, e.g.
+	// by a scan, don't push out keys read repeatedly. When the protected
+	// segment outgrows its share, its least recently used keys are demoted
+	// back to probation.
+	slruPolicy struct {
+		share     float64
+		probation *list.List
+		protected *list.List
+		elements  map[interface{}]*list.Element
+	}
+
+	slruEntry struct {
+		key       interface{}
+		protected bool
+	}
+)
+
+// DefaultSLRUProtectedShare is the share of keys NewSLRU keeps in its
+// protected segment.
+const DefaultSLRUProtectedShare = 0.8
+
+// NewLRU returns the default EvictionPolicy, which evicts the least recently
+// used keys first.
+func NewLRU() EvictionPolicy {
+	return &lruPolicy{order: list.New(), elements: map[interface{}]*list.Element{}}
+}
+
+func (p *lruPolicy) Added(key interface{}) {
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) Accessed(key interface{}) {
+	if element, ok := p.elements[key]; ok {
+		p.order.MoveToFront(element)
+	}
+}
+
+func (p *lruPolicy) Removed(key interface{}) {
+	if element, ok := p.elements[key]; ok {
+		p.order.Remove(element)
+		delete(p.elements, key)
+	}
+}
+
+func (p *lruPolicy) Victims(n int, keep func(key interface{}) bool) []interface{} {
+	return victims(p.order, n, keep, nil, func(element *list.Element) interface{} { return element.Value })
+}
+
+// NewSLRU returns a segmented LRU EvictionPolicy which keeps up to share of
+// its keys in the protected segment. A share outside (0, 1) is replaced by
+// DefaultSLRUProtectedShare.
+func NewSLRU(share float64) EvictionPolicy {
+	if share <= 0 || share >= 1 {
+		share = DefaultSLRUProtectedShare
+	}
+	return &slruPolicy{share: share, probation: list.New(), protected: list.New(), elements: map[interface{}]*list.Element{}}
+}
+
+func (p *slruPolicy) Added(key interface{}) {
+	p.elements[key] = p.probation.PushFront(&slruEntry{key: key})
+}
+
+func (p *slruPolicy) Accessed(key interface{}) {
+	element, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*slruEntry)
+	if entry.protected {
+		p.protected.MoveToFront(element)
+		return
+	}
+	p.probation.Remove(element)
+	entry.protected = true
+	p.elements[key] = p.protected.PushFront(entry)
+
+	max := int(p.share * float64(len(p.elements)))
+	for p.protected.Len() > max && p.protected.Len() > 0 {
+		demoted := p.protected.Remove(p.protected.Back()).(*slruEntry)
+		demoted.protected = false
+		p.elements[demoted.key] = p.probation.PushFront(demoted)
+	}
+}
+
+func (p *slruPolicy) Removed(key interface{}) {
+	element, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	if element.Value.(*slruEntry).protected {
+		p.protected.Remove(element)
+	} else {
+		p.probation.Remove(element)
+	}
+	delete(p.elements, key)
+}
+
+func (p *slruPolicy) Victims(n int, keep func(key interface{}) bool) []interface{} {
+	keyOf := func(element *list.Element) interface{} { return element.Value.(*slruEntry).key }
+	found := victims(p.probation, n, keep, nil, keyOf)
+	return victims(p.protected, n, keep, found, keyOf)
+}
+
+// victims appends to found the keys of order, from the back, until found
+// holds n keys.
+func victims(order *list.List, n int, keep func(key interface{}) bool, found []interface{}, keyOf func(*list.Element) interface{}) []interface{} {
+	for element := order.Back(); element != nil && len(found) < n; element = element.Prev() {
+		if key := keyOf(element); !keep(key) {
+			found = append(found, key)
+		}
+	}
+	return found
+}
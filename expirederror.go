@@ -0,0 +1,30 @@
+package expiring_gocache
+
+type (
+	// expiredError is returned for an expired value by Stores created with
+	// WithExpiredError. It reads as the caller's error, and matches both it
+	// and ValueExpiredError with errors.Is.
+	expiredError struct {
+		err error
+	}
+)
+
+func (e expiredError) Error() string {
+	return e.err.Error()
+}
+
+func (e expiredError) Unwrap() error {
+	return e.err
+}
+
+func (e expiredError) Is(target error) bool {
+	return target == ValueExpiredError
+}
+
+// expired returns the error for an expired value.
+func (es Store) expired() error {
+	if es.expiredErr != nil {
+		return expiredError{err: es.expiredErr}
+	}
+	return ValueExpiredError
+}
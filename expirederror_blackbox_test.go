@@ -0,0 +1,35 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithExpiredError(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Millisecond}, expiring.WithClock(clk), expiring.WithExpiredError(MapStoreMiss))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("other", "value", nil))
+	clk.Advance(5 * time.Millisecond)
+
+	_, err := es.Get("key")
+	assert.Equal(t, MapStoreMiss.Error(), err.Error())
+	assert.True(t, errors.Is(err, MapStoreMiss))
+	assert.True(t, errors.Is(err, expiring.ValueExpiredError))
+
+	_, _, err = es.GetWithTTL("other")
+	assert.True(t, errors.Is(err, MapStoreMiss))
+	assert.True(t, errors.Is(err, expiring.ValueExpiredError))
+
+	// once deleted, the underlying store's own miss is returned
+	_, err = es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+}
@@ -0,0 +1,269 @@
+// Package expiringevents publishes the Events of expiring Stores as
+// CloudEvents (https://cloudevents.io), so that other services can react to
+// values being set, deleted, expired and cleared, e.g. to invalidate their
+// own caches.
+//
+//	emitter := expiringevents.NewEmitter("/caches/sessions", expiringevents.NewHTTPSink(url, nil))
+//	defer emitter.Close()
+//	sessions := expiring.New(s, options, expiring.WithEventHook(emitter.Hook))
+package expiringevents
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// CloudEvent is a CloudEvents 1.0 event in the structured JSON format.
+	CloudEvent struct {
+		SpecVersion     string    `json:"specversion"`
+		ID              string    `json:"id"`
+		Source          string    `json:"source"`
+		Type            string    `json:"type"`
+		Time            time.Time `json:"time"`
+		Subject         string    `json:"subject,omitempty"`
+		DataContentType string    `json:"datacontenttype,omitempty"`
+		Data            *Data     `json:"data,omitempty"`
+	}
+
+	// Data is the data of a CloudEvent. The key changed, if any, is the
+	// event's Subject.
+	Data struct {
+		TTLSeconds float64  `json:"ttl_seconds,omitempty"`
+		Tags       []string `json:"tags,omitempty"`
+	}
+
+	// Sink delivers CloudEvents, e.g. over HTTP or to a Kafka topic.
+	Sink interface {
+		Send(ctx context.Context, event CloudEvent) error
+	}
+
+	// Emitter turns Events into CloudEvents and sends them to a Sink in the
+	// background, so that Stores don't wait on the Sink. Events which
+	// arrive while the buffer is full are dropped, and counted by Dropped.
+	Emitter struct {
+		source  string
+		sink    Sink
+		types   map[expiring.EventType]bool
+		timeout time.Duration
+		onError func(error)
+
+		idPrefix string
+		nextID   uint64
+		dropped  uint64
+
+		mu     sync.RWMutex
+		closed bool
+		queue  chan CloudEvent
+		done   chan struct{}
+	}
+
+	// EmitterOption configures an Emitter.
+	EmitterOption func(*Emitter)
+
+	httpSink struct {
+		url    string
+		client *http.Client
+	}
+
+	channelSink chan<- CloudEvent
+)
+
+const (
+	SpecVersion = "1.0"
+
+	// TypePrefix prefixes the expiring.EventType of each CloudEvent's type,
+	// e.g. "io.github.nabowler.expiring_gocache.set".
+	TypePrefix = "io.github.nabowler.expiring_gocache."
+
+	// ContentType is the media type of a CloudEvent in the structured JSON
+	// format.
+	ContentType = "application/cloudevents+json"
+
+	DefaultBufferSize  = 1024
+	DefaultSendTimeout = 5 * time.Second
+)
+
+// NewEmitter creates an Emitter of CloudEvents from source, a URI reference
+// naming the Store, to sink.
+func NewEmitter(source string, sink Sink, opts ...EmitterOption) *Emitter {
+	e := &Emitter{
+		source:   source,
+		sink:     sink,
+		timeout:  DefaultSendTimeout,
+		idPrefix: newIDPrefix(),
+		queue:    make(chan CloudEvent, DefaultBufferSize),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	go e.run()
+	return e
+}
+
+// WithBufferSize sets how many events may wait to be sent. Defaults to
+// DefaultBufferSize.
+func WithBufferSize(size int) EmitterOption {
+	return func(e *Emitter) {
+		e.queue = make(chan CloudEvent, size)
+	}
+}
+
+// WithTypes emits only Events of the given types.
+func WithTypes(types ...expiring.EventType) EmitterOption {
+	return func(e *Emitter) {
+		e.types = map[expiring.EventType]bool{}
+		for _, t := range types {
+			e.types[t] = true
+		}
+	}
+}
+
+// WithSendTimeout bounds each Send. Defaults to DefaultSendTimeout.
+func WithSendTimeout(timeout time.Duration) EmitterOption {
+	return func(e *Emitter) {
+		e.timeout = timeout
+	}
+}
+
+// WithErrorHook passes errors sending events to onError.
+func WithErrorHook(onError func(error)) EmitterOption {
+	return func(e *Emitter) {
+		e.onError = onError
+	}
+}
+
+// Hook queues ev to be sent. Pass it to expiring.WithEventHook.
+func (e *Emitter) Hook(ev expiring.Event) {
+	if e.types != nil && !e.types[ev.Type] {
+		return
+	}
+	ce := e.cloudEvent(ev)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		atomic.AddUint64(&e.dropped, 1)
+		return
+	}
+	select {
+	case e.queue <- ce:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// Dropped returns how many events were dropped because the buffer was full,
+// or the Emitter was closed.
+func (e *Emitter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Close stops accepting events, and waits for those queued to be sent.
+func (e *Emitter) Close() error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+	<-e.done
+	return nil
+}
+
+func (e *Emitter) run() {
+	defer close(e.done)
+	for ce := range e.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+		err := e.sink.Send(ctx, ce)
+		cancel()
+		if err != nil && e.onError != nil {
+			e.onError(err)
+		}
+	}
+}
+
+func (e *Emitter) cloudEvent(ev expiring.Event) CloudEvent {
+	ce := CloudEvent{
+		SpecVersion: SpecVersion,
+		ID:          e.idPrefix + strconv.FormatUint(atomic.AddUint64(&e.nextID, 1), 10),
+		Source:      e.source,
+		Type:        TypePrefix + string(ev.Type),
+		Time:        ev.Time,
+	}
+	if ev.Key != nil {
+		ce.Subject = fmt.Sprintf("%v", ev.Key)
+	}
+	if ev.TTL > 0 || len(ev.Tags) > 0 {
+		ce.DataContentType = "application/json"
+		ce.Data = &Data{TTLSeconds: ev.TTL.Seconds(), Tags: ev.Tags}
+	}
+	return ce
+}
+
+// newIDPrefix returns a random prefix for event IDs, so that IDs are unique
+// across Emitters with the same source.
+func newIDPrefix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16) + "-"
+	}
+	return hex.EncodeToString(b) + "-"
+}
+
+// NewHTTPSink POSTs each event to url in the structured JSON format, with
+// client, or http.DefaultClient if client is nil.
+func NewHTTPSink(url string, client *http.Client) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return httpSink{url: url, client: client}
+}
+
+func (s httpSink) Send(ctx context.Context, event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sending event %s: %s", event.ID, resp.Status)
+	}
+	return nil
+}
+
+// NewChannelSink sends each event to ch, waiting until it is received or
+// the send times out.
+func NewChannelSink(ch chan<- CloudEvent) Sink {
+	return channelSink(ch)
+}
+
+func (s channelSink) Send(ctx context.Context, event CloudEvent) error {
+	select {
+	case s <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
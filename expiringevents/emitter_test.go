@@ -0,0 +1,156 @@
+package expiringevents_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringevents"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// MapStore is a minimal store.StoreInterface.
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+func TestEmitterWithChannelSink(t *testing.T) {
+	events := make(chan expiringevents.CloudEvent, 10)
+	emitter := expiringevents.NewEmitter("/caches/test", expiringevents.NewChannelSink(events))
+
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Minute}, expiring.WithEventHook(emitter.Hook))
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Delete("key"))
+	assert.Nil(t, emitter.Close())
+	close(events)
+
+	var received []expiringevents.CloudEvent
+	for ce := range events {
+		received = append(received, ce)
+	}
+	if assert.Len(t, received, 2) {
+		set := received[0]
+		assert.Equal(t, "1.0", set.SpecVersion)
+		assert.Equal(t, "/caches/test", set.Source)
+		assert.Equal(t, "io.github.nabowler.expiring_gocache.set", set.Type)
+		assert.Equal(t, "key", set.Subject)
+		if assert.NotNil(t, set.Data) {
+			assert.InDelta(t, 60, set.Data.TTLSeconds, 1)
+		}
+		assert.False(t, set.Time.IsZero())
+
+		del := received[1]
+		assert.Equal(t, "io.github.nabowler.expiring_gocache.delete", del.Type)
+		assert.Nil(t, del.Data)
+		assert.NotEqual(t, set.ID, del.ID)
+	}
+}
+
+func TestEmitterWithHTTPSink(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		contentType string
+		received    []expiringevents.CloudEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ce expiringevents.CloudEvent
+		if err := json.NewDecoder(r.Body).Decode(&ce); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		contentType = r.Header.Get("Content-Type")
+		received = append(received, ce)
+	}))
+	defer server.Close()
+
+	emitter := expiringevents.NewEmitter("/caches/test", expiringevents.NewHTTPSink(server.URL, nil),
+		expiringevents.WithTypes(expiring.EventClear))
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithEventHook(emitter.Hook))
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Clear())
+	assert.Nil(t, emitter.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, expiringevents.ContentType, contentType)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, "io.github.nabowler.expiring_gocache.clear", received[0].Type)
+		assert.Empty(t, received[0].Subject)
+	}
+}
+
+func TestEmitterErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var errs []error
+	emitter := expiringevents.NewEmitter("/caches/test", expiringevents.NewHTTPSink(server.URL, nil),
+		expiringevents.WithErrorHook(func(err error) { errs = append(errs, err) }))
+	emitter.Hook(expiring.Event{Type: expiring.EventSet, Key: "key"})
+	assert.Nil(t, emitter.Close())
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "503")
+	}
+
+	// events after Close are dropped
+	emitter.Hook(expiring.Event{Type: expiring.EventSet, Key: "key"})
+	assert.Equal(t, uint64(1), emitter.Dropped())
+}
+
+func TestEmitterDropsWhenFull(t *testing.T) {
+	block := make(chan expiringevents.CloudEvent)
+	emitter := expiringevents.NewEmitter("/caches/test", expiringevents.NewChannelSink(block),
+		expiringevents.WithBufferSize(1), expiringevents.WithSendTimeout(50*time.Millisecond))
+	for i := 0; i < 10; i++ {
+		emitter.Hook(expiring.Event{Type: expiring.EventSet, Key: i})
+	}
+	assert.Nil(t, emitter.Close())
+	assert.True(t, emitter.Dropped() >= 8, "%d events dropped", emitter.Dropped())
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error {
+	return nil
+}
+
+func (ms *MapStore) Clear() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache = map[interface{}]interface{}{}
+	return nil
+}
+
+func (ms *MapStore) GetType() string {
+	return "map"
+}
@@ -0,0 +1,59 @@
+// Package expiringgroupcache fills groupcache groups from an expiring Store,
+// so that a fleet shares one fill per key and every peer agrees on when the
+// value expires.
+package expiringgroupcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/mailgun/groupcache/v2"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// LoadFunc loads the value of key from its source, along with how long it
+	// may be cached. A ttl of 0 uses the Store's default expiration.
+	LoadFunc func(ctx context.Context, key string) (value []byte, ttl time.Duration, err error)
+)
+
+// NewGetter returns a groupcache.Getter which serves values from es, calling
+// load on a miss and writing what it returns to es. The expiration given to
+// the group is the Store's, so the group drops a value when es does.
+func NewGetter(es expiring.Store, load LoadFunc) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		if val, ttl, err := es.GetWithTTL(key); err == nil {
+			if b, ok := val.([]byte); ok {
+				return dest.SetBytes(b, expireAt(es, ttl))
+			}
+		}
+
+		value, ttl, err := load(ctx, key)
+		if err != nil {
+			return err
+		}
+		var options *store.Options
+		if ttl > 0 {
+			options = &store.Options{Expiration: ttl}
+		}
+		if err := es.Set(key, value, options); err == nil {
+			// read the TTL back, as the Store may have chosen or jittered it
+			if _, stored, err := es.GetWithTTL(key); err == nil {
+				ttl = stored
+			}
+		}
+		return dest.SetBytes(value, expireAt(es, ttl))
+	})
+}
+
+// expireAt converts ttl to a groupcache expiration, by es's clock. A ttl of
+// 0, as of a value which isn't written or never expires in es, is given the
+// Store's default expiration rather than groupcache's zero time, which never
+// expires, so that the group reads it from es again.
+func expireAt(es expiring.Store, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		ttl = es.DefaultExpiration()
+	}
+	return es.Clock().Now().Add(ttl)
+}
@@ -0,0 +1,106 @@
+package expiringgroupcache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/mailgun/groupcache/v2"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringgroupcache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+func TestGetter(t *testing.T) {
+	ms := &MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(ms, &store.Options{Expiration: time.Hour})
+	var loads uint64
+	getter := expiringgroupcache.NewGetter(es, func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+		atomic.AddUint64(&loads, 1)
+		if key == "short" {
+			return []byte("short value"), 50 * time.Millisecond, nil
+		}
+		return []byte(key + " value"), 0, nil
+	})
+	group := groupcache.NewGroup("expiringgroupcache-test", 1<<20, getter)
+	ctx := context.Background()
+
+	get := func(key string) string {
+		var b []byte
+		assert.Nil(t, group.Get(ctx, key, groupcache.AllocatingByteSliceSink(&b)))
+		return string(b)
+	}
+
+	assert.Equal(t, "key value", get("key"))
+	assert.Equal(t, "key value", get("key"))
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&loads))
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 59*time.Minute)
+
+	// values already in the Store aren't loaded again
+	assert.Nil(t, es.Set("stored", []byte("stored value"), nil))
+	assert.Equal(t, "stored value", get("stored"))
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&loads))
+
+	assert.Equal(t, "short value", get("short"))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "short value", get("short"))
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&loads))
+}
+
+func TestGetterExpiresValuesWithoutTTL(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: 50 * time.Millisecond})
+	getter := expiringgroupcache.NewGetter(es, func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+		return nil, 0, errors.New("not loaded")
+	})
+	group := groupcache.NewGroup("expiringgroupcache-pinned-test", 1<<20, getter)
+	get := func(key string) string {
+		var b []byte
+		assert.Nil(t, group.Get(context.Background(), key, groupcache.AllocatingByteSliceSink(&b)))
+		return string(b)
+	}
+
+	// pinned values have no TTL, but the group reads them again
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", []byte("first"), nil))
+	assert.Equal(t, "first", get("pinned"))
+	assert.Nil(t, es.Set("pinned", []byte("second"), nil))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "second", get("pinned"))
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error { return nil }
+
+func (ms *MapStore) GetType() string { return "map" }
@@ -0,0 +1,193 @@
+// Package expiringgrpc caches the responses of gRPC unary calls in an
+// expiring Store.
+package expiringgrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type (
+	// Interceptor caches the responses of the unary methods it is configured
+	// with, see WithMethod. Only configure idempotent methods: a cached
+	// response is returned without calling the server.
+	//
+	// Responses are cached by method and request, so that callers share
+	// them. Calls whose outgoing metadata identifies the caller, with an
+	// authorization or cookie key, aren't cached, unless the key is made part
+	// of the cache key with WithMetadataKeys. Credentials added by
+	// grpc.WithPerRPCCredentials are added after the interceptor runs, and
+	// so aren't seen by it: only cache methods whose responses don't depend
+	// on them.
+	Interceptor struct {
+		cache        store.StoreInterface
+		methods      map[string]time.Duration
+		metadataKeys []string
+		stats        *stats
+	}
+
+	// Option configures an Interceptor.
+	Option func(*Interceptor)
+
+	// Stats counts the calls seen by an Interceptor.
+	Stats struct {
+		// Hits is the number of calls answered from the cache.
+		Hits uint64
+		// Misses is the number of cacheable calls passed on to the server.
+		Misses uint64
+		// Uncached is the number of calls to methods which aren't cached,
+		// whose request or response isn't a proto.Message, or which carry
+		// metadata identifying the caller.
+		Uncached uint64
+	}
+
+	stats struct {
+		hits, misses, uncached uint64
+	}
+)
+
+// NewInterceptor creates an Interceptor which caches responses in cache.
+func NewInterceptor(cache store.StoreInterface, opts ...Option) *Interceptor {
+	i := &Interceptor{
+		cache:   cache,
+		methods: map[string]time.Duration{},
+		stats:   &stats{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// privateMetadata are the outgoing metadata keys which identify the caller.
+var privateMetadata = []string{"authorization", "cookie"}
+
+// WithMethod caches the responses of the method with full name method, e.g.
+// "/package.Service/Method", for ttl. A ttl of 0 uses the cache's default
+// expiration.
+func WithMethod(method string, ttl time.Duration) Option {
+	return func(i *Interceptor) {
+		i.methods[method] = ttl
+	}
+}
+
+// WithMetadataKeys makes the values of the outgoing metadata keys part of
+// the cache key, so that calls are cached separately for each, e.g. for each
+// caller by "authorization". Keys are case insensitive.
+func WithMetadataKeys(keys ...string) Option {
+	return func(i *Interceptor) {
+		for _, key := range keys {
+			i.metadataKeys = append(i.metadataKeys, strings.ToLower(key))
+		}
+		sort.Strings(i.metadataKeys)
+	}
+}
+
+// Unary returns the interceptor, for use with grpc.WithUnaryInterceptor.
+func (i *Interceptor) Unary() grpc.UnaryClientInterceptor {
+	return i.intercept
+}
+
+// Stats returns a snapshot of the Interceptor's counters.
+func (i *Interceptor) Stats() Stats {
+	return Stats{
+		Hits:     atomic.LoadUint64(&i.stats.hits),
+		Misses:   atomic.LoadUint64(&i.stats.misses),
+		Uncached: atomic.LoadUint64(&i.stats.uncached),
+	}
+}
+
+func (i *Interceptor) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ttl, ok := i.methods[method]
+	reqMsg, reqOK := req.(proto.Message)
+	replyMsg, replyOK := reply.(proto.Message)
+	if !ok || !reqOK || !replyOK {
+		atomic.AddUint64(&i.stats.uncached, 1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if i.private(md) {
+		atomic.AddUint64(&i.stats.uncached, 1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	key, err := cacheKey(method, reqMsg, md, i.metadataKeys)
+	if err != nil {
+		atomic.AddUint64(&i.stats.uncached, 1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	if val, err := i.cache.Get(key); err == nil {
+		if b, ok := val.([]byte); ok && proto.Unmarshal(b, replyMsg) == nil {
+			atomic.AddUint64(&i.stats.hits, 1)
+			return nil
+		}
+	}
+
+	atomic.AddUint64(&i.stats.misses, 1)
+	if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+		return err
+	}
+	if b, err := proto.Marshal(replyMsg); err == nil {
+		_ = i.cache.Set(key, b, &store.Options{Expiration: ttl}) // best effort
+	}
+	return nil
+}
+
+// private reports whether md identifies the caller by a key which isn't
+// part of the cache key.
+func (i *Interceptor) private(md metadata.MD) bool {
+	for _, key := range privateMetadata {
+		if len(md.Get(key)) == 0 {
+			continue
+		}
+		if n := sort.SearchStrings(i.metadataKeys, key); n == len(i.metadataKeys) || i.metadataKeys[n] != key {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey identifies a call to method with req by the hash of its
+// deterministic encoding and the values of the metadata keys in md.
+func cacheKey(method string, req proto.Message, md metadata.MD, keys []string) (string, error) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(req); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	writeBytes(h, buf.Bytes())
+	for _, key := range keys {
+		values := md.Get(key)
+		writeBytes(h, []byte(key))
+		writeUvarint(h, uint64(len(values)))
+		for _, v := range values {
+			writeBytes(h, []byte(v))
+		}
+	}
+	return "expiringgrpc:" + method + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeBytes writes b to h prefixed with its length, so that no two
+// sequences of values hash the same.
+func writeBytes(h hash.Hash, b []byte) {
+	writeUvarint(h, uint64(len(b)))
+	h.Write(b)
+}
+
+func writeUvarint(h hash.Hash, n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
@@ -0,0 +1,141 @@
+package expiringgrpc_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringgrpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type (
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+func TestInterceptor(t *testing.T) {
+	cache := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour})
+	interceptor := expiringgrpc.NewInterceptor(cache, expiringgrpc.WithMethod(checkMethod, time.Minute))
+	client, calls, stop := serveHealth(t, interceptor)
+	defer stop()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "cached"})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	}
+	assert.Equal(t, uint64(1), atomic.LoadUint64(calls))
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "other"})
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(calls))
+
+	// errors aren't cached
+	for i := 0; i < 2; i++ {
+		_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, uint64(4), atomic.LoadUint64(calls))
+
+	assert.Equal(t, expiringgrpc.Stats{Hits: 2, Misses: 4}, interceptor.Stats())
+}
+
+func TestInterceptorMetadata(t *testing.T) {
+	cache := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour})
+	req := &healthpb.HealthCheckRequest{Service: "cached"}
+	as := func(user string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "Authorization", "Bearer "+user)
+	}
+
+	// calls made as a caller aren't cached
+	interceptor := expiringgrpc.NewInterceptor(cache, expiringgrpc.WithMethod(checkMethod, time.Minute))
+	client, calls, stop := serveHealth(t, interceptor)
+	for i := 0; i < 2; i++ {
+		_, err := client.Check(as("alice"), req)
+		assert.Nil(t, err)
+	}
+	stop()
+	assert.Equal(t, uint64(2), atomic.LoadUint64(calls))
+	assert.Equal(t, expiringgrpc.Stats{Uncached: 2}, interceptor.Stats())
+
+	// unless they are cached for each caller
+	interceptor = expiringgrpc.NewInterceptor(cache, expiringgrpc.WithMethod(checkMethod, time.Minute), expiringgrpc.WithMetadataKeys("Authorization"))
+	client, calls, stop = serveHealth(t, interceptor)
+	defer stop()
+	for _, user := range []string{"alice", "bob", "alice", "bob"} {
+		_, err := client.Check(as(user), req)
+		assert.Nil(t, err)
+	}
+	_, err := client.Check(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), atomic.LoadUint64(calls))
+	assert.Equal(t, expiringgrpc.Stats{Hits: 2, Misses: 3}, interceptor.Stats())
+}
+
+// serveHealth serves the health service to a client using interceptor,
+// counting the calls which reach the server.
+func serveHealth(t *testing.T, interceptor *expiringgrpc.Interceptor) (healthpb.HealthClient, *uint64, func()) {
+	calls := new(uint64)
+	listener := bufconn.Listen(1 << 16)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		atomic.AddUint64(calls, 1)
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("cached", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("other", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithUnaryInterceptor(interceptor.Unary()),
+	)
+	assert.Nil(t, err)
+	return healthpb.NewHealthClient(conn), calls, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error { return nil }
+
+func (ms *MapStore) GetType() string { return "map" }
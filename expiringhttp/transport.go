@@ -0,0 +1,280 @@
+// Package expiringhttp caches HTTP responses in an expiring Store.
+package expiringhttp
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Transport is an http.RoundTripper which caches the responses to GET
+	// requests. Cached responses are fresh for as long as their
+	// Cache-Control max-age or Expires header allows; responses without
+	// either are fresh until the cache expires them, so wrap the cache with
+	// expiring.New to give them a default TTL. Stale responses with an ETag
+	// or Last-Modified header are revalidated with a conditional request.
+	//
+	// Requests with credentials, in an Authorization or Cookie header, are
+	// neither served from nor stored in the cache, since the cache is shared
+	// and the response may be meant for that user alone (RFC 9111, section
+	// 3.5). For the same reason, responses marked Cache-Control private, or
+	// which set cookies, aren't stored, and s-maxage is honored over
+	// max-age. Responses are cached whole, in memory, and the Vary header is
+	// not honored.
+	Transport struct {
+		cache              store.StoreInterface
+		next               http.RoundTripper
+		revalidationWindow time.Duration
+	}
+
+	// TransportOption configures a Transport.
+	TransportOption func(*Transport)
+
+	// entry is a cached response.
+	entry struct {
+		response []byte
+		// freshUntil is zero when the response doesn't say how long it is
+		// fresh, in which case the cache decides.
+		freshUntil time.Time
+	}
+)
+
+const (
+	// CacheHeader is set to "1" on responses served from the cache, including
+	// revalidated ones.
+	CacheHeader = "X-From-Cache"
+
+	// DefaultRevalidationWindow is how long stale responses are kept for
+	// revalidation, see WithRevalidationWindow.
+	DefaultRevalidationWindow = 1 * time.Hour
+)
+
+// cacheableStatus lists the status codes whose responses are cached.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// NewTransport creates a Transport which caches responses in cache and
+// performs requests with next, or http.DefaultTransport if next is nil.
+func NewTransport(cache store.StoreInterface, next http.RoundTripper, opts ...TransportOption) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{
+		cache:              cache,
+		next:               next,
+		revalidationWindow: DefaultRevalidationWindow,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// WithRevalidationWindow keeps responses with an ETag or Last-Modified
+// header for window after they become stale, so that they can be
+// revalidated instead of fetched again.
+func WithRevalidationWindow(window time.Duration) TransportOption {
+	return func(t *Transport) {
+		t.revalidationWindow = window
+	}
+}
+
+// RoundTrip serves req from the cache when it holds a fresh response, and
+// otherwise performs it, caching the response if it may be.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || hasDirective(req.Header, "no-store") || credentialed(req) {
+		return t.next.RoundTrip(req)
+	}
+	key := cacheKey(req)
+
+	var cached *http.Response
+	if e, ok := t.lookup(key); ok {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(e.response)), req)
+		if err == nil {
+			fresh := e.freshUntil.IsZero() || time.Now().Before(e.freshUntil)
+			if fresh && !hasDirective(req.Header, "no-cache") {
+				resp.Header.Set(CacheHeader, "1")
+				return resp, nil
+			}
+			cached = resp
+		}
+	}
+
+	outgoing := req
+	if cached != nil {
+		outgoing = conditional(req, cached)
+	}
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		for name, values := range resp.Header {
+			cached.Header[name] = values
+		}
+		if !shareable(cached) {
+			_ = t.cache.Delete(key) // best effort
+		} else if err := t.store(key, cached); err != nil {
+			return nil, err
+		}
+		cached.Header.Set(CacheHeader, "1")
+		return cached, nil
+	}
+
+	if cacheableStatus[resp.StatusCode] && shareable(resp) {
+		if err := t.store(key, resp); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (t *Transport) lookup(key string) (entry, bool) {
+	val, err := t.cache.Get(key)
+	if err != nil {
+		return entry{}, false
+	}
+	e, ok := val.(entry)
+	return e, ok
+}
+
+// store caches resp, replacing its body with an unread copy. Responses which
+// are already stale and can't be revalidated are not cached. Errors writing
+// to the cache are ignored; only an error reading the body is returned.
+func (t *Transport) store(key string, resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	dumped, err := httputil.DumpResponse(resp, true)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	e := entry{response: dumped}
+	var options *store.Options
+	if lifetime, ok := freshness(resp.Header, time.Now()); ok {
+		e.freshUntil = time.Now().Add(lifetime)
+		ttl := lifetime
+		if resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" {
+			ttl += t.revalidationWindow
+		}
+		if ttl <= 0 {
+			return nil
+		}
+		options = &store.Options{Expiration: ttl}
+	}
+	_ = t.cache.Set(key, e, options) // best effort
+	return nil
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+// credentialed reports whether req carries a user's credentials.
+func credentialed(req *http.Request) bool {
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" || req.URL.User != nil
+}
+
+// shareable reports whether resp may be stored in a cache shared between
+// users: it isn't marked no-store or private, and sets no cookies.
+func shareable(resp *http.Response) bool {
+	return !hasDirective(resp.Header, "no-store") && !hasDirective(resp.Header, "private") && resp.Header.Get("Set-Cookie") == ""
+}
+
+// conditional returns a copy of req which asks the server whether cached has
+// changed.
+func conditional(req *http.Request, cached *http.Response) *http.Request {
+	etag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+	if etag == "" && modified == "" {
+		return req
+	}
+	out := req.Clone(req.Context())
+	if etag != "" {
+		out.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		out.Header.Set("If-Modified-Since", modified)
+	}
+	return out
+}
+
+// freshness returns how much longer, from now, a response with header is
+// fresh, and whether its headers say.
+func freshness(header http.Header, now time.Time) (time.Duration, bool) {
+	if hasDirective(header, "no-cache") {
+		return 0, true
+	}
+	date := now
+	if d, err := http.ParseTime(header.Get("Date")); err == nil {
+		date = d
+	}
+
+	var lifetime time.Duration
+	maxAge, ok := directive(header, "s-maxage")
+	if !ok {
+		maxAge, ok = directive(header, "max-age")
+	}
+	if ok {
+		seconds, err := strconv.ParseInt(maxAge, 10, 64)
+		if err != nil {
+			return 0, true
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires := header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			// an invalid Expires means already expired
+			return 0, true
+		}
+		lifetime = at.Sub(date)
+	} else {
+		return 0, false
+	}
+
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	return lifetime, true
+}
+
+func hasDirective(header http.Header, name string) bool {
+	_, ok := directive(header, name)
+	return ok
+}
+
+// directive returns the value of the Cache-Control directive name, and
+// whether it is present.
+func directive(header http.Header, name string) (string, bool) {
+	for _, part := range strings.Split(header.Get("Cache-Control"), ",") {
+		part = strings.TrimSpace(part)
+		key, value := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			key, value = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
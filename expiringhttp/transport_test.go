@@ -0,0 +1,223 @@
+package expiringhttp_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+func newClient(handler http.HandlerFunc) (*http.Client, *httptest.Server, *uint64) {
+	var requests uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&requests, 1)
+		handler(w, r)
+	}))
+	cache := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour})
+	client := &http.Client{Transport: expiringhttp.NewTransport(cache, nil)}
+	return client, server, &requests
+}
+
+func get(t *testing.T, client *http.Client, url string) (string, *http.Response) {
+	resp, err := client.Get(url)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return string(body), resp
+}
+
+func TestTransportCachesFreshResponses(t *testing.T) {
+	var n uint64
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "response %d", atomic.AddUint64(&n, 1))
+	})
+	defer server.Close()
+
+	body, resp := get(t, client, server.URL)
+	assert.Equal(t, "response 1", body)
+	assert.Equal(t, "", resp.Header.Get(expiringhttp.CacheHeader))
+
+	body, resp = get(t, client, server.URL)
+	assert.Equal(t, "response 1", body)
+	assert.Equal(t, "1", resp.Header.Get(expiringhttp.CacheHeader))
+	assert.Equal(t, uint64(1), atomic.LoadUint64(requests))
+
+	body, _ = get(t, client, server.URL+"/other")
+	assert.Equal(t, "response 2", body)
+}
+
+func TestTransportSkipsCredentialedRequests(t *testing.T) {
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(w, "for %s%s", r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+	})
+	defer server.Close()
+
+	for _, header := range []string{"Authorization", "Cookie"} {
+		for _, user := range []string{"alice", "bob"} {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			assert.Nil(t, err)
+			req.Header.Set(header, user)
+			resp, err := client.Do(req)
+			assert.Nil(t, err)
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Nil(t, err)
+			assert.Equal(t, "for "+user, string(body))
+			assert.Equal(t, "", resp.Header.Get(expiringhttp.CacheHeader))
+		}
+	}
+	assert.Equal(t, uint64(4), atomic.LoadUint64(requests))
+
+	// nor is an anonymous request served a credentialed response
+	body, _ := get(t, client, server.URL)
+	assert.Equal(t, "for ", body)
+}
+
+func TestTransportSkipsUncacheableResponses(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"no-store": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+		},
+		"private": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		},
+		"set-cookie": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "session=alice")
+		},
+		"expired": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Expires", "0")
+		},
+		"server error": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	} {
+		client, server, requests := newClient(handler)
+		get(t, client, server.URL)
+		get(t, client, server.URL)
+		assert.Equal(t, uint64(2), atomic.LoadUint64(requests), name)
+		server.Close()
+	}
+}
+
+func TestTransportPrefersSharedMaxAge(t *testing.T) {
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, s-maxage=0")
+		fmt.Fprint(w, "body")
+	})
+	defer server.Close()
+
+	get(t, client, server.URL)
+	get(t, client, server.URL)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(requests))
+}
+
+func TestTransportRevalidates(t *testing.T) {
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "body")
+	})
+	defer server.Close()
+
+	body, _ := get(t, client, server.URL)
+	assert.Equal(t, "body", body)
+
+	body, resp := get(t, client, server.URL)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get(expiringhttp.CacheHeader))
+	assert.Equal(t, uint64(2), atomic.LoadUint64(requests))
+}
+
+func TestTransportDropsRevalidatedPrivateResponses(t *testing.T) {
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.Header().Set("Set-Cookie", "session=alice")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, "body")
+	})
+	defer server.Close()
+
+	get(t, client, server.URL)
+	body, resp := get(t, client, server.URL)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, "session=alice", resp.Header.Get("Set-Cookie"))
+	assert.Equal(t, "1", resp.Header.Get(expiringhttp.CacheHeader))
+
+	// the response was dropped rather than revalidated again
+	_, resp = get(t, client, server.URL)
+	assert.Equal(t, "", resp.Header.Get(expiringhttp.CacheHeader))
+	assert.Equal(t, uint64(3), atomic.LoadUint64(requests))
+}
+
+func TestTransportUsesStoreDefault(t *testing.T) {
+	client, server, requests := newClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "body")
+	})
+	defer server.Close()
+
+	get(t, client, server.URL)
+	body, _ := get(t, client, server.URL)
+	assert.Equal(t, "body", body)
+	assert.Equal(t, uint64(1), atomic.LoadUint64(requests))
+
+	// requests can insist on revalidation, and skip the cache entirely
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, uint64(2), atomic.LoadUint64(requests))
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error { return nil }
+
+func (ms *MapStore) GetType() string { return "map" }
@@ -0,0 +1,294 @@
+// Package expiringmemcache serves an expiring Store over the memcached text
+// protocol, so that existing memcached clients can use it.
+package expiringmemcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Server answers the get, gets, set, delete, touch and quit commands of
+	// the memcached text protocol. Every value written by a client is kept
+	// with its flags, and a cas unique which gets reports, and which changes
+	// with every set; the cas command itself isn't supported. Values written
+	// to the store by other means are not visible to clients.
+	Server struct {
+		cache store.StoreInterface
+		// lastCas is the cas unique of the latest value set.
+		lastCas uint64
+
+		mu        sync.Mutex
+		listeners map[net.Listener]struct{}
+		conns     map[net.Conn]struct{}
+		closed    bool
+	}
+
+	// item is a value written by a client.
+	item struct {
+		flags uint32
+		data  []byte
+		cas   uint64
+	}
+)
+
+const (
+	// maxKeyLength is the longest key memcached accepts.
+	maxKeyLength = 250
+
+	// maxRelativeExptime is the largest exptime memcached treats as a number
+	// of seconds; larger ones are Unix timestamps.
+	maxRelativeExptime = 60 * 60 * 24 * 30
+
+	// MaxValueSize is the largest value a client may set.
+	MaxValueSize = 1 << 20
+)
+
+// NewServer creates a Server which keeps values in cache.
+func NewServer(cache store.StoreInterface) *Server {
+	return &Server{
+		cache:     cache,
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// Serve accepts connections on l until it is closed, or Close is called,
+// serving each one on its own goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return l.Close()
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops every Serve call and closes every open connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else if fields[0] == "quit" {
+			w.Flush()
+			return
+		} else if err := s.command(fields, r, w); err != nil {
+			// the connection can't be read any further
+			w.Flush()
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// command runs one command, writing its response to w. It only returns an
+// error if the connection is broken.
+func (s *Server) command(fields []string, r *bufio.Reader, w *bufio.Writer) error {
+	switch fields[0] {
+	case "get", "gets":
+		if len(fields) < 2 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		for _, key := range fields[1:] {
+			if it, ok := s.get(key); ok {
+				if fields[0] == "gets" {
+					fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.data), it.cas)
+				} else {
+					fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.flags, len(it.data))
+				}
+				w.Write(it.data)
+				w.WriteString("\r\n")
+			}
+		}
+		w.WriteString("END\r\n")
+
+	case "set":
+		// set <key> <flags> <exptime> <bytes> [noreply]
+		if len(fields) != 5 && len(fields) != 6 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		flags, flagsErr := strconv.ParseUint(fields[2], 10, 32)
+		exptime, exptimeErr := strconv.ParseInt(fields[3], 10, 64)
+		size, sizeErr := strconv.Atoi(fields[4])
+		if sizeErr != nil || size < 0 || size > MaxValueSize {
+			// the data block can't be skipped without a valid size
+			w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+			return fmt.Errorf("bad data size %q", fields[4])
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		switch {
+		case string(data[size:]) != "\r\n":
+			if data[size+1] != '\n' {
+				// discard the rest of the oversized data block
+				if _, err := r.ReadString('\n'); err != nil {
+					return err
+				}
+			}
+			reply(w, fields[5:], "CLIENT_ERROR bad data chunk")
+		case !validKey(fields[1]) || flagsErr != nil || exptimeErr != nil:
+			reply(w, fields[5:], "CLIENT_ERROR bad command line format")
+		case s.set(fields[1], item{flags: uint32(flags), data: data[:size], cas: atomic.AddUint64(&s.lastCas, 1)}, exptime) != nil:
+			reply(w, fields[5:], "SERVER_ERROR store failed")
+		default:
+			reply(w, fields[5:], "STORED")
+		}
+
+	case "delete":
+		// delete <key> [noreply]
+		if len(fields) != 2 && len(fields) != 3 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		if _, ok := s.get(fields[1]); !ok {
+			reply(w, fields[2:], "NOT_FOUND")
+		} else if s.cache.Delete(fields[1]) != nil {
+			reply(w, fields[2:], "SERVER_ERROR delete failed")
+		} else {
+			reply(w, fields[2:], "DELETED")
+		}
+
+	case "touch":
+		// touch <key> <exptime> [noreply]
+		if len(fields) != 3 && len(fields) != 4 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		exptime, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			reply(w, fields[3:], "CLIENT_ERROR bad command line format")
+			return nil
+		}
+		// rewriting the value is not atomic; a concurrent set may be lost
+		if it, ok := s.get(fields[1]); !ok {
+			reply(w, fields[3:], "NOT_FOUND")
+		} else if s.set(fields[1], it, exptime) != nil {
+			reply(w, fields[3:], "SERVER_ERROR store failed")
+		} else {
+			reply(w, fields[3:], "TOUCHED")
+		}
+
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+func (s *Server) get(key string) (item, bool) {
+	val, err := s.cache.Get(key)
+	if err != nil {
+		return item{}, false
+	}
+	it, ok := val.(item)
+	return it, ok
+}
+
+// set writes it with memcached's exptime: 0 for the store's default
+// expiration, up to 30 days as a number of seconds, and a Unix timestamp
+// otherwise. A value which would already have expired is deleted.
+func (s *Server) set(key string, it item, exptime int64) error {
+	var options *store.Options
+	if exptime != 0 {
+		ttl := time.Duration(exptime) * time.Second
+		if exptime > maxRelativeExptime {
+			ttl = time.Until(time.Unix(exptime, 0))
+		}
+		if ttl <= 0 {
+			return s.cache.Delete(key)
+		}
+		options = &store.Options{Expiration: ttl}
+	}
+	return s.cache.Set(key, it, options)
+}
+
+// reply writes response, unless the command's remaining fields ask for no
+// reply.
+func reply(w *bufio.Writer, rest []string, response string) {
+	if len(rest) == 1 && rest[0] == "noreply" {
+		return
+	}
+	w.WriteString(response + "\r\n")
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
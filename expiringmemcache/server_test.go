@@ -0,0 +1,104 @@
+package expiringmemcache_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringmemcache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+func TestServer(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour})
+	server := expiringmemcache.NewServer(es)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	served := make(chan error)
+	go func() { served <- server.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	exchange := func(request string, lines int) string {
+		_, err := conn.Write([]byte(request))
+		assert.Nil(t, err)
+		var response []string
+		for i := 0; i < lines; i++ {
+			line, err := r.ReadString('\n')
+			assert.Nil(t, err)
+			response = append(response, line)
+		}
+		return strings.Join(response, "")
+	}
+
+	assert.Equal(t, "END\r\n", exchange("get greeting\r\n", 1))
+	assert.Equal(t, "STORED\r\n", exchange("set greeting 42 0 5\r\nhello\r\n", 1))
+	assert.Equal(t, "STORED\r\n", exchange("set short 0 1 2\r\nhi\r\n", 1))
+	assert.Equal(t, "VALUE greeting 42 5\r\nhello\r\nVALUE short 0 2\r\nhi\r\nEND\r\n",
+		exchange("get greeting missing short\r\n", 5))
+
+	// gets adds the cas unique, which changes with every set
+	assert.Equal(t, "VALUE greeting 42 5 1\r\nhello\r\nEND\r\n", exchange("gets greeting\r\n", 3))
+	assert.Equal(t, "STORED\r\n", exchange("set greeting 42 0 5\r\nhello\r\n", 1))
+	assert.Equal(t, "VALUE greeting 42 5 3\r\nhello\r\nEND\r\n", exchange("gets greeting\r\n", 3))
+
+	time.Sleep(1100 * time.Millisecond)
+	assert.Equal(t, "END\r\n", exchange("get short\r\n", 1))
+
+	assert.Equal(t, "TOUCHED\r\n", exchange("touch greeting 1\r\n", 1))
+	assert.Equal(t, "NOT_FOUND\r\n", exchange("touch short 1\r\n", 1))
+	time.Sleep(1100 * time.Millisecond)
+	assert.Equal(t, "END\r\n", exchange("get greeting\r\n", 1))
+
+	assert.Equal(t, "STORED\r\n", exchange("set gone 0 -1 1\r\nx\r\n", 1))
+	assert.Equal(t, "END\r\n", exchange("get gone\r\n", 1))
+
+	// noreply suppresses the response
+	assert.Equal(t, "DELETED\r\n", exchange("set key 0 0 1 noreply\r\nx\r\ndelete key\r\n", 1))
+	assert.Equal(t, "NOT_FOUND\r\n", exchange("delete key\r\n", 1))
+
+	assert.Equal(t, "CLIENT_ERROR bad data chunk\r\n", exchange("set key 0 0 1\r\nxyz\r\n", 1))
+	assert.Equal(t, "ERROR\r\n", exchange("cas key 0 0 1 1\r\n", 1))
+	assert.Equal(t, "END\r\n", exchange("get key\r\n", 1))
+
+	assert.Nil(t, server.Close())
+	assert.Nil(t, <-served)
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error { return nil }
+
+func (ms *MapStore) GetType() string { return "map" }
@@ -0,0 +1,191 @@
+// Package expiringprom exports the Stats of an expiring Store as Prometheus
+// metrics.
+package expiringprom
+
+import (
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// Source is implemented by expiring.Store.
+	Source interface {
+		Stats() expiring.Stats
+		GetType() string
+	}
+
+	// Collector is a prometheus.Collector for the Stats of one or more
+	// Sources. Each Source is labelled with its GetType, so use
+	// expiring.WithTypeName to tell several Stores apart.
+	Collector struct {
+		sources      []Source
+		counters     []counter
+		latency      *prometheus.Desc
+		skew         *prometheus.Desc
+		setTTL       *prometheus.Desc
+		remainingTTL *prometheus.Desc
+		shadow       *prometheus.Desc
+	}
+
+	counter struct {
+		desc  *prometheus.Desc
+		value func(expiring.Stats) uint64
+	}
+)
+
+const namespace = "expiring_gocache"
+
+// counters lists the Stats counters exported by a Collector.
+var counters = []struct {
+	name  string
+	help  string
+	value func(expiring.Stats) uint64
+}{
+	{"delete_failures_total", "Failed best effort deletes of expired or evicted values.",
+		func(s expiring.Stats) uint64 { return s.DeleteFailures }},
+	{"delete_retries_dropped_total", "Failed deletes which were given up on.",
+		func(s expiring.Stats) uint64 { return s.DeleteRetriesDropped }},
+	{"deletes_deduplicated_total", "Best effort deletes skipped because a delete of the same key was in flight.",
+		func(s expiring.Stats) uint64 { return s.DeletesDeduplicated }},
+	{"evictions_total", "Values deleted because the store was over capacity.",
+		func(s expiring.Stats) uint64 { return s.Evictions }},
+	{"background_evictions_total", "Evictions made in the background by the evictor.",
+		func(s expiring.Stats) uint64 { return s.BackgroundEvictions }},
+	{"transient_errors_total", "Calls to the underlying store which failed with a transient error.",
+		func(s expiring.Stats) uint64 { return s.TransientErrors }},
+	{"permanent_errors_total", "Calls to the underlying store which failed with a permanent error.",
+		func(s expiring.Stats) uint64 { return s.PermanentErrors }},
+	{"timeouts_total", "Calls to the underlying store which timed out.",
+		func(s expiring.Stats) uint64 { return s.Timeouts }},
+	{"panics_total", "Panics recovered from functions given to the store.",
+		func(s expiring.Stats) uint64 { return s.Panics }},
+	{"worker_restarts_total", "Background workers restarted after they failed.",
+		func(s expiring.Stats) uint64 { return s.WorkerRestarts }},
+	{"idle_suspensions_total", "Times the background workers were suspended because the store was idle.",
+		func(s expiring.Stats) uint64 { return s.IdleSuspensions }},
+	{"future_envelopes_total", "Reads of values written by a later version of the package.",
+		func(s expiring.Stats) uint64 { return s.FutureEnvelopes }},
+	{"suppressed_sets_total", "Sets not written because their key may not be cached.",
+		func(s expiring.Stats) uint64 { return s.SuppressedSets }},
+	{"sampled_out_sets_total", "Sets not written because they weren't sampled.",
+		func(s expiring.Stats) uint64 { return s.SampledOutSets }},
+	{"unadmitted_sets_total", "Sets not written because their key hadn't been requested often enough.",
+		func(s expiring.Stats) uint64 { return s.UnadmittedSets }},
+	{"error_hits_total", "Reads which found an error cached by SetError.",
+		func(s expiring.Stats) uint64 { return s.ErrorHits }},
+	{"fallback_hits_total", "Gets served by the fallback store.",
+		func(s expiring.Stats) uint64 { return s.FallbackHits }},
+	{"source_fetches_total", "Values fetched from the read-through source.",
+		func(s expiring.Stats) uint64 { return s.SourceFetches }},
+	{"source_errors_total", "Fetches from the read-through source which failed.",
+		func(s expiring.Stats) uint64 { return s.SourceErrors }},
+	{"scheduled_refreshes_total", "Keys loaded by a scheduled refresh.",
+		func(s expiring.Stats) uint64 { return s.ScheduledRefreshes }},
+	{"scheduled_refresh_failures_total", "Scheduled refreshes whose load failed.",
+		func(s expiring.Stats) uint64 { return s.ScheduledRefreshFailures }},
+	{"sink_failures_total", "Failed writes to the write-through sink.",
+		func(s expiring.Stats) uint64 { return s.SinkFailures }},
+	{"write_behind_queued_total", "Writes queued for the write-behind sink.",
+		func(s expiring.Stats) uint64 { return s.WriteBehindQueued }},
+	{"write_behind_written_total", "Queued writes written to the write-behind sink.",
+		func(s expiring.Stats) uint64 { return s.WriteBehindWritten }},
+	{"write_behind_retries_total", "Failed writes to the write-behind sink which were retried.",
+		func(s expiring.Stats) uint64 { return s.WriteBehindRetries }},
+	{"write_behind_dropped_total", "Writes lost because the write-behind queue was full or closed.",
+		func(s expiring.Stats) uint64 { return s.WriteBehindDropped }},
+	{"write_behind_failed_total", "Writes lost because they ran out of attempts.",
+		func(s expiring.Stats) uint64 { return s.WriteBehindFailed }},
+	{"request_cache_hits_total", "Gets served by the request cache of their context.",
+		func(s expiring.Stats) uint64 { return s.RequestCacheHits }},
+	{"cascaded_deletes_total", "Values deleted because a value they depend on was removed.",
+		func(s expiring.Stats) uint64 { return s.CascadedDeletes }},
+	{"hit_jitter_rewrites_total", "Values rewritten with a nudged expiration after a hit.",
+		func(s expiring.Stats) uint64 { return s.HitJitterRewrites }},
+	{"drained_sets_total", "Sets ignored because the store is draining.",
+		func(s expiring.Stats) uint64 { return s.DrainedSets }},
+	{"hedged_reads_total", "Gets which were also sent to the hedge replica.",
+		func(s expiring.Stats) uint64 { return s.HedgedReads }},
+	{"hedge_wins_total", "Hedged reads answered by the replica.",
+		func(s expiring.Stats) uint64 { return s.HedgeWins }},
+	{"shadow_reads_total", "Gets mirrored to the shadow store.",
+		func(s expiring.Stats) uint64 { return s.ShadowReads }},
+	{"shadow_divergences_total", "Mirrored Gets whose results disagreed with the shadow store's.",
+		func(s expiring.Stats) uint64 { return s.ShadowDivergences }},
+	{"leases_granted_total", "Leases taken after a miss.",
+		func(s expiring.Stats) uint64 { return s.LeasesGranted }},
+	{"lease_conflicts_total", "Misses which found the lease held by another caller.",
+		func(s expiring.Stats) uint64 { return s.LeaseConflicts }},
+	{"skewed_reads_total", "Reads of values written by a node whose clock is ahead.",
+		func(s expiring.Stats) uint64 { return s.SkewedReads }},
+}
+
+func NewCollector(sources ...Source) *Collector {
+	c := &Collector{
+		sources: sources,
+		latency: prometheus.NewDesc(namespace+"_inner_store_latency_seconds",
+			"Latency of calls to the underlying store.", []string{"store", "operation"}, nil),
+		skew: prometheus.NewDesc(namespace+"_max_clock_skew_seconds",
+			"Largest clock skew shown by a value written by another node.", []string{"store"}, nil),
+		setTTL: prometheus.NewDesc(namespace+"_set_ttl_seconds",
+			"TTLs given to values when they are set.", []string{"store"}, nil),
+		remainingTTL: prometheus.NewDesc(namespace+"_remaining_ttl_seconds",
+			"TTLs left to unexpired values when they are read.", []string{"store"}, nil),
+		shadow: prometheus.NewDesc(namespace+"_shadow_get_latency_seconds",
+			"Latency of Gets mirrored to the shadow store, on each store.", []string{"store", "backend"}, nil),
+	}
+	for _, def := range counters {
+		c.counters = append(c.counters, counter{
+			desc:  prometheus.NewDesc(namespace+"_"+def.name, def.help, []string{"store"}, nil),
+			value: def.value,
+		})
+	}
+	return c
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, counter := range c.counters {
+		ch <- counter.desc
+	}
+	ch <- c.latency
+	ch <- c.skew
+	ch <- c.setTTL
+	ch <- c.remainingTTL
+	ch <- c.shadow
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, source := range c.sources {
+		name := source.GetType()
+		stats := source.Stats()
+
+		for _, counter := range c.counters {
+			ch <- prometheus.MustNewConstMetric(counter.desc, prometheus.CounterValue, float64(counter.value(stats)), name)
+		}
+		ch <- prometheus.MustNewConstMetric(c.skew, prometheus.GaugeValue, stats.MaxClockSkew.Seconds(), name)
+
+		for op, summary := range stats.Latencies {
+			ch <- histogram(c.latency, summary, name, string(op))
+		}
+		if stats.SetTTLs != nil {
+			ch <- histogram(c.setTTL, *stats.SetTTLs, name)
+		}
+		if stats.RemainingTTLs != nil {
+			ch <- histogram(c.remainingTTL, *stats.RemainingTTLs, name)
+		}
+		if stats.PrimaryLatency != nil && stats.ShadowLatency != nil {
+			ch <- histogram(c.shadow, *stats.PrimaryLatency, name, "primary")
+			ch <- histogram(c.shadow, *stats.ShadowLatency, name, "shadow")
+		}
+	}
+}
+
+func histogram(desc *prometheus.Desc, summary expiring.LatencySummary, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(summary.Buckets))
+	for _, b := range summary.Buckets {
+		// the final bucket is +Inf, which prometheus adds itself
+		if b.UpperBound > 0 {
+			buckets[b.UpperBound.Seconds()] = b.Count
+		}
+	}
+	return prometheus.MustNewConstHistogram(desc, summary.Count, summary.Sum.Seconds(), buckets, labels...)
+}
@@ -0,0 +1,90 @@
+package expiringprom_test
+
+import (
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringprom"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	StaticSource struct {
+		name  string
+		stats expiring.Stats
+	}
+)
+
+func TestCollector(t *testing.T) {
+	source := StaticSource{
+		name: "expiring-sessions",
+		stats: expiring.Stats{
+			DeleteFailures: 3,
+			Evictions:      2,
+			MaxClockSkew:   1500 * time.Millisecond,
+			Latencies: map[expiring.Operation]expiring.LatencySummary{
+				expiring.OperationGet: {
+					Count: 4,
+					Sum:   10 * time.Millisecond,
+					Buckets: []expiring.LatencyBucket{
+						{UpperBound: time.Millisecond, Count: 1},
+						{UpperBound: 5 * time.Millisecond, Count: 3},
+						{Count: 4},
+					},
+				},
+			},
+		},
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	assert.Nil(t, registry.Register(expiringprom.NewCollector(source)))
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	byName := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	failures := byName["expiring_gocache_delete_failures_total"]
+	if assert.NotNil(t, failures) {
+		assert.Equal(t, 3.0, failures.Metric[0].GetCounter().GetValue())
+		assert.Equal(t, "store", failures.Metric[0].Label[0].GetName())
+		assert.Equal(t, "expiring-sessions", failures.Metric[0].Label[0].GetValue())
+	}
+	assert.Equal(t, 2.0, byName["expiring_gocache_evictions_total"].Metric[0].GetCounter().GetValue())
+	assert.Equal(t, 1.5, byName["expiring_gocache_max_clock_skew_seconds"].Metric[0].GetGauge().GetValue())
+
+	latency := byName["expiring_gocache_inner_store_latency_seconds"]
+	if assert.NotNil(t, latency) {
+		histogram := latency.Metric[0].GetHistogram()
+		assert.Equal(t, uint64(4), histogram.GetSampleCount())
+		assert.Equal(t, 0.01, histogram.GetSampleSum())
+		assert.Len(t, histogram.Bucket, 2)
+		assert.Equal(t, uint64(3), histogram.Bucket[1].GetCumulativeCount())
+	}
+}
+
+func TestCollectorWithStore(t *testing.T) {
+	es := expiring.New(nil, nil, expiring.WithLatencyHistograms(), expiring.WithTTLHistograms())
+
+	registry := prometheus.NewPedanticRegistry()
+	assert.Nil(t, registry.Register(expiringprom.NewCollector(es)))
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "expiring_gocache_set_ttl_seconds")
+	assert.Contains(t, names, "expiring_gocache_remaining_ttl_seconds")
+}
+
+// StaticSource implementation
+
+func (ss StaticSource) Stats() expiring.Stats { return ss.stats }
+func (ss StaticSource) GetType() string       { return ss.name }
@@ -0,0 +1,128 @@
+// Package expiringstatsd pushes the metrics of expiring Stores to StatsD or
+// DogStatsD over UDP, for observability stacks which aren't pull based.
+//
+//	sink, err := expiringstatsd.New("127.0.0.1:8125", expiringstatsd.WithSampleRate(0.1))
+//	...
+//	sessions := expiring.New(s, options, expiring.WithMetricsSink(sink.Tagged("store:sessions")))
+package expiringstatsd
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// Sink is an expiring.MetricsSink which sends each metric as a UDP
+	// packet. Sends are fire and forget; a failed send is passed to the
+	// error hook, if there is one, and otherwise dropped.
+	Sink struct {
+		conn    net.Conn
+		prefix  string
+		tags    []string
+		rate    float64
+		onError func(error)
+	}
+
+	// Option configures a Sink.
+	Option func(*Sink)
+)
+
+const DefaultPrefix = "expiring_gocache."
+
+var _ expiring.MetricsSink = &Sink{}
+
+// New creates a Sink sending to the StatsD server at addr.
+func New(addr string, opts ...Option) (*Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{conn: conn, prefix: DefaultPrefix, rate: 1}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// WithPrefix prefixes every metric name with prefix. Defaults to
+// DefaultPrefix.
+func WithPrefix(prefix string) Option {
+	return func(s *Sink) {
+		s.prefix = prefix
+	}
+}
+
+// WithTags adds DogStatsD tags, such as "env:prod", to every metric. Plain
+// StatsD servers don't support tags.
+func WithTags(tags ...string) Option {
+	return func(s *Sink) {
+		s.tags = append(s.tags, tags...)
+	}
+}
+
+// WithSampleRate sends only the given fraction of metrics, telling the
+// server the rate so that it can scale counts back up.
+func WithSampleRate(rate float64) Option {
+	return func(s *Sink) {
+		s.rate = rate
+	}
+}
+
+// WithErrorHook passes errors sending metrics to onError.
+func WithErrorHook(onError func(error)) Option {
+	return func(s *Sink) {
+		s.onError = onError
+	}
+}
+
+// Tagged returns a Sink sharing s's connection and options, which adds tags
+// to every metric, e.g. to tell the Stores sharing a server apart.
+func (s *Sink) Tagged(tags ...string) *Sink {
+	t := *s
+	t.tags = append(append([]string(nil), s.tags...), tags...)
+	return &t
+}
+
+func (s *Sink) Count(name string, delta int64) {
+	s.send(name, strconv.FormatInt(delta, 10), "c")
+}
+
+// Timing sends d in milliseconds.
+func (s *Sink) Timing(name string, d time.Duration) {
+	s.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms")
+}
+
+// Close closes the connection shared by s and the Sinks made from it by
+// Tagged.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Sink) send(name, value, kind string) {
+	if s.rate < 1 && rand.Float64() >= s.rate {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if s.rate < 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(s.rate, 'f', -1, 64))
+	}
+	if len(s.tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(s.tags, ","))
+	}
+	if _, err := s.conn.Write([]byte(b.String())); err != nil && s.onError != nil {
+		s.onError(err)
+	}
+}
@@ -0,0 +1,122 @@
+package expiringstatsd_test
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringstatsd"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// MapStore is a minimal store.StoreInterface.
+	MapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+	}
+)
+
+// listen returns a UDP server's address, and a func returning the packets
+// it has received once n have arrived or a second has passed.
+func listen(t *testing.T) (string, func(n int) []string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn.LocalAddr().String(), func(n int) []string {
+		defer conn.Close()
+		var packets []string
+		buf := make([]byte, 1024)
+		for len(packets) < n {
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			size, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			packets = append(packets, string(buf[:size]))
+		}
+		sort.Strings(packets)
+		return packets
+	}
+}
+
+func TestSink(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := expiringstatsd.New(addr, expiringstatsd.WithPrefix("cache."), expiringstatsd.WithTags("env:test"))
+	assert.Nil(t, err)
+	defer sink.Close()
+
+	sink.Count("evictions", 3)
+	sink.Tagged("store:sessions").Timing("inner.get", 1500*time.Microsecond)
+	assert.Equal(t, []string{
+		"cache.evictions:3|c|#env:test",
+		"cache.inner.get:1.5|ms|#env:test,store:sessions",
+	}, received(2))
+}
+
+func TestSinkSampling(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := expiringstatsd.New(addr, expiringstatsd.WithSampleRate(0.5))
+	assert.Nil(t, err)
+	defer sink.Close()
+
+	for i := 0; i < 100; i++ {
+		sink.Count("reads.hit", 1)
+	}
+	packets := received(100)
+	assert.True(t, len(packets) > 10 && len(packets) < 90, "%d packets sent", len(packets))
+	assert.Equal(t, "expiring_gocache.reads.hit:1|c|@0.5", packets[0])
+}
+
+func TestSinkWithStore(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := expiringstatsd.New(addr)
+	assert.Nil(t, err)
+	defer sink.Close()
+
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithMetricsSink(sink))
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err = es.Get("key")
+	assert.Nil(t, err)
+
+	packets := received(3)
+	if assert.Len(t, packets, 3) {
+		assert.True(t, strings.HasPrefix(packets[0], "expiring_gocache.inner.get:"))
+		assert.True(t, strings.HasPrefix(packets[1], "expiring_gocache.inner.set:"))
+		assert.Equal(t, "expiring_gocache.reads.hit:1|c", packets[2])
+	}
+}
+
+func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	return nil
+}
+
+func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *MapStore) Invalidate(options store.InvalidateOptions) error {
+	return nil
+}
+
+func (ms *MapStore) GetType() string {
+	return "map"
+}
@@ -0,0 +1,299 @@
+// Package expiringtest provides an in-memory store.StoreInterface for
+// testing code which uses expiring Stores, without running Redis or any
+// other backend.
+//
+//	s := expiringtest.New()
+//	es := expiring.New(s, &store.Options{Expiration: time.Minute})
+//	s.FailNext(expiring.OperationGet, errors.New("connection refused"))
+//	// ... exercise code using es ...
+//	assert.Equal(t, 1, s.Calls(expiring.OperationSet))
+package expiringtest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// Store is a race-safe, in-memory store.StoreInterface which counts its
+	// calls, and can be made to fail them. It also implements Clear,
+	// GetWithTTL, DeleteMulti, Keys and Len, so that the Store's optional
+	// fast paths are exercised.
+	Store struct {
+		mu       sync.Mutex
+		clock    clock.Clock
+		native   bool
+		entries  map[interface{}]entry
+		calls    map[expiring.Operation]int
+		errs     map[expiring.Operation]error
+		failNext map[expiring.Operation][]error
+
+		lastSetOptions *store.Options
+		deletedKeys    []interface{}
+	}
+
+	// Option configures a Store.
+	Option func(*Store)
+
+	entry struct {
+		value    interface{}
+		expireAt time.Time
+		tags     []string
+	}
+)
+
+const (
+	StoreType = "expiringtest"
+)
+
+var (
+	// NotFoundError is returned by Get for keys the Store doesn't hold.
+	NotFoundError = errors.New("value not found in expiringtest store")
+)
+
+// New creates an empty Store.
+func New(opts ...Option) *Store {
+	s := &Store{
+		clock:    clock.Real{},
+		entries:  map[interface{}]entry{},
+		calls:    map[expiring.Operation]int{},
+		errs:     map[expiring.Operation]error{},
+		failNext: map[expiring.Operation][]error{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithClock sets the clock the Store reads the time from, for
+// WithNativeExpiration and GetWithTTL. Give it the clock.Fake given to the
+// expiring Store to expire values in both at once.
+func WithClock(c clock.Clock) Option {
+	return func(s *Store) {
+		s.clock = c
+	}
+}
+
+// WithNativeExpiration makes the Store expire values itself, after the
+// Expiration given in their store.Options, as Redis does. By default values
+// are kept until deleted, as by stores which ignore the option.
+func WithNativeExpiration() Option {
+	return func(s *Store) {
+		s.native = true
+	}
+}
+
+// SetError makes every call of op fail with err, until SetError is called
+// again with a nil err.
+func (s *Store) SetError(op expiring.Operation, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.errs, op)
+		return
+	}
+	s.errs[op] = err
+}
+
+// FailNext makes the next call of op fail with err. Calls queue up, so
+// FailNext can be called again to fail the call after.
+func (s *Store) FailNext(op expiring.Operation, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext[op] = append(s.failNext[op], err)
+}
+
+// Calls returns how many times op has been called, including failed calls.
+func (s *Store) Calls(op expiring.Operation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// ResetCalls zeroes the call counters.
+func (s *Store) ResetCalls() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = map[expiring.Operation]int{}
+}
+
+// LastSetOptions returns the options of the latest Set.
+func (s *Store) LastSetOptions() *store.Options {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSetOptions
+}
+
+// DeletedKeys returns the keys deleted, in order, including by DeleteMulti.
+func (s *Store) DeletedKeys() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]interface{}(nil), s.deletedKeys...)
+}
+
+// Raw returns the value held for key as it was written, i.e. wrapped by an
+// expiring Store, without counting a call.
+func (s *Store) Raw(key interface{}) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.liveLocked(key)
+	return e.value, ok
+}
+
+// callLocked counts a call of op, returning the error it should fail with.
+func (s *Store) callLocked(op expiring.Operation) error {
+	s.calls[op]++
+	if queued := s.failNext[op]; len(queued) > 0 {
+		s.failNext[op] = queued[1:]
+		return queued[0]
+	}
+	return s.errs[op]
+}
+
+func (s *Store) liveLocked(key interface{}) (entry, bool) {
+	e, ok := s.entries[key]
+	if ok && !e.expireAt.IsZero() && !s.clock.Now().Before(e.expireAt) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (s *Store) Get(key interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationGet); err != nil {
+		return nil, err
+	}
+	e, ok := s.liveLocked(key)
+	if !ok {
+		return nil, NotFoundError
+	}
+	return e.value, nil
+}
+
+// GetWithTTL returns the value held for key, and its remaining native TTL,
+// or 0 if it has none.
+func (s *Store) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationGet); err != nil {
+		return nil, 0, err
+	}
+	e, ok := s.liveLocked(key)
+	if !ok {
+		return nil, 0, NotFoundError
+	}
+	if e.expireAt.IsZero() {
+		return e.value, 0, nil
+	}
+	return e.value, e.expireAt.Sub(s.clock.Now()), nil
+}
+
+func (s *Store) Set(key interface{}, value interface{}, options *store.Options) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationSet); err != nil {
+		return err
+	}
+	s.lastSetOptions = options
+	e := entry{value: value}
+	if options != nil {
+		e.tags = options.TagsValue()
+		if s.native && options.ExpirationValue() > 0 {
+			e.expireAt = s.clock.Now().Add(options.ExpirationValue())
+		}
+	}
+	s.entries[key] = e
+	return nil
+}
+
+func (s *Store) Delete(key interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationDelete); err != nil {
+		return err
+	}
+	s.deletedKeys = append(s.deletedKeys, key)
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *Store) DeleteMulti(keys []interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationDeleteMulti); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s.deletedKeys = append(s.deletedKeys, key)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Invalidate deletes the values set with any of the tags.
+func (s *Store) Invalidate(options store.InvalidateOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationInvalidate); err != nil {
+		return err
+	}
+	for key, e := range s.entries {
+		if hasAny(e.tags, options.TagsValue()) {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.callLocked(expiring.OperationClear); err != nil {
+		return err
+	}
+	s.entries = map[interface{}]entry{}
+	return nil
+}
+
+// Keys returns the keys held, in no particular order, without counting a
+// call.
+func (s *Store) Keys() ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]interface{}, 0, len(s.entries))
+	for key := range s.entries {
+		if _, ok := s.liveLocked(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Len returns how many values are held, without counting a call.
+func (s *Store) Len() (int, error) {
+	keys, err := s.Keys()
+	return len(keys), err
+}
+
+func (s *Store) GetType() string {
+	return StoreType
+}
+
+func hasAny(tags, of []string) bool {
+	for _, tag := range tags {
+		for _, t := range of {
+			if tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
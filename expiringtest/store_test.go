@@ -0,0 +1,91 @@
+package expiringtest_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s := expiringtest.New()
+	es := expiring.New(s, &store.Options{Expiration: time.Hour})
+	assert.Nil(t, es.Set("key", "value", &store.Options{Tags: []string{"tag"}}))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 1, s.Calls(expiring.OperationSet))
+	assert.Equal(t, 1, s.Calls(expiring.OperationGet))
+	assert.Equal(t, []string{"tag"}, s.LastSetOptions().TagsValue())
+
+	raw, ok := s.Raw("key")
+	assert.True(t, ok)
+	assert.NotEqual(t, "value", raw)
+
+	assert.Nil(t, es.Invalidate(store.InvalidateOptions{Tags: []string{"tag"}}))
+	_, err = es.Get("key")
+	assert.Equal(t, expiringtest.NotFoundError, err)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Delete("key"))
+	assert.Equal(t, []interface{}{"key"}, s.DeletedKeys())
+
+	s.ResetCalls()
+	assert.Equal(t, 0, s.Calls(expiring.OperationGet))
+}
+
+func TestStoreErrors(t *testing.T) {
+	s := expiringtest.New()
+	es := expiring.New(s, nil)
+	failure := errors.New("connection refused")
+
+	s.FailNext(expiring.OperationSet, failure)
+	assert.Equal(t, failure, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	s.SetError(expiring.OperationGet, failure)
+	for i := 0; i < 3; i++ {
+		_, err := es.Get("key")
+		assert.Equal(t, failure, err)
+	}
+	s.SetError(expiring.OperationGet, nil)
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+}
+
+func TestStoreNativeExpiration(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	s := expiringtest.New(expiringtest.WithClock(clk), expiringtest.WithNativeExpiration())
+	assert.Nil(t, s.Set("key", "value", &store.Options{Expiration: time.Minute}))
+	_, ttl, err := s.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	clk.Advance(time.Minute)
+	_, err = s.Get("key")
+	assert.Equal(t, expiringtest.NotFoundError, err)
+	n, _ := s.Len()
+	assert.Equal(t, 0, n)
+}
+
+func TestStoreConcurrentUse(t *testing.T) {
+	s := expiringtest.New()
+	es := expiring.New(s, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Nil(t, es.Set(i, "value", nil))
+			_, _ = es.Get(i)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 10, s.Calls(expiring.OperationSet))
+}
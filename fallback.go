@@ -0,0 +1,31 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// getFallback reads key from the fallback store. Values wrapped by an
+// expiring Store are unwrapped, and are a miss if they have expired; they are
+// not deleted, since the fallback store is not ours to clean up.
+func (es Store) getFallback(key interface{}) (interface{}, bool) {
+	val, err := es.fallback.Get(key)
+	if err != nil || val == nil {
+		return nil, false
+	}
+	if ew, ok := unwrap(val); ok {
+		if es.isExpired(ew, es.now()) {
+			return nil, false
+		}
+		if ew, err = es.decoded(ew); err != nil {
+			return nil, false
+		}
+		val = ew.value
+	}
+
+	atomic.AddUint64(&es.stats.fallbackHits, 1)
+	if es.promoteFallback {
+		_ = es.set(key, val, nil, es.defaultTTL(key, val), time.Time{}) // best effort promotion
+	}
+	return val, true
+}
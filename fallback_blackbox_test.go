@@ -0,0 +1,65 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackOnMiss(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	secondary := MapStore{cache: map[interface{}]interface{}{"key": "secondary"}}
+	es := expiring.New(&primary, nil, expiring.WithFallback(&secondary))
+
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "secondary", val)
+	assert.Equal(t, 0, primary.setCount)
+	assert.Equal(t, uint64(1), es.Stats().FallbackHits)
+
+	_, err = es.Get("missing")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestFallbackOnExpiry(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	secondary := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&primary, &store.Options{Expiration: 10 * time.Millisecond},
+		expiring.WithClock(clk),
+		expiring.WithFallback(&secondary),
+		expiring.WithFallbackPromotion(),
+	)
+	// the secondary holds a fresher wrapped value, written by another Store
+	assert.Nil(t, es.Set("key", "stale", nil))
+	clk.Advance(20 * time.Millisecond)
+	assert.Nil(t, expiring.New(&secondary, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk)).Set("key", "fresh", nil))
+
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "fresh", val)
+
+	// the hit was promoted into the primary with a fresh TTL
+	assert.Equal(t, 2, primary.setCount)
+	val, err = expiring.New(&primary, nil).Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "fresh", val)
+}
+
+func TestFallbackExpired(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	secondary := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&primary, nil, expiring.WithClock(clk), expiring.WithFallback(&secondary))
+	assert.Nil(t, expiring.New(&secondary, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithClock(clk)).Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	_, err := es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+	// expired fallback values are left alone
+	assert.Equal(t, 0, secondary.deleteCount)
+}
@@ -0,0 +1,58 @@
+package expiring_gocache
+
+import (
+	"context"
+)
+
+type (
+	// ForEachOption configures ForEach.
+	ForEachOption func(*forEachOptions)
+
+	forEachOptions struct {
+		includeExpired bool
+	}
+)
+
+// IncludeExpired makes ForEach visit expired values which are still stored,
+// as well as live ones.
+func IncludeExpired() ForEachOption {
+	return func(o *forEachOptions) {
+		o.includeExpired = true
+	}
+}
+
+// ForEach calls fn for each unexpired value held for the Store, in no
+// particular order, until fn returns false. Keys are listed as with Keys, so
+// UnsupportedError is returned if they can't be; values are then read one at
+// a time, as with GetStale, so only one is held in memory at once. Values
+// which are deleted during the iteration are skipped.
+//
+// ForEach stops and returns ctx.Err() if ctx is done.
+func (es Store) ForEach(ctx context.Context, fn func(key, value interface{}, meta Metadata) bool, opts ...ForEachOption) error {
+	es.ctx = ctx
+	var o forEachOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	keys, err := es.Keys()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		val, md, err := es.GetStale(key)
+		if err != nil || val == nil {
+			continue
+		}
+		if md.Expired && !o.includeExpired && !es.pins.has(key) {
+			continue
+		}
+		if !fn(key, val, md) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEach(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking(), expiring.WithClock(clk))
+	assert.Nil(t, es.Set("a", "value a", nil))
+	assert.Nil(t, es.Set("b", "value b", nil))
+	assert.Nil(t, es.Set("short", "value short", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+
+	collect := func(opts ...expiring.ForEachOption) map[interface{}]interface{} {
+		seen := map[interface{}]interface{}{}
+		assert.Nil(t, es.ForEach(context.Background(), func(key, value interface{}, meta expiring.Metadata) bool {
+			seen[key] = value
+			assert.Equal(t, key == "short", meta.Expired)
+			return true
+		}, opts...))
+		return seen
+	}
+	assert.Equal(t, map[interface{}]interface{}{"a": "value a", "b": "value b"}, collect())
+	assert.Len(t, collect(expiring.IncludeExpired()), 3)
+	assert.Equal(t, 0, ms.deleteCount)
+
+	var visited int
+	assert.Nil(t, es.ForEach(context.Background(), func(key, value interface{}, meta expiring.Metadata) bool {
+		visited++
+		return false
+	}))
+	assert.Equal(t, 1, visited)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, es.ForEach(ctx, func(key, value interface{}, meta expiring.Metadata) bool {
+		return true
+	}))
+
+	unsupported := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithClock(clk))
+	assert.Equal(t, expiring.UnsupportedError, unsupported.ForEach(context.Background(), nil))
+}
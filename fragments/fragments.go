@@ -0,0 +1,249 @@
+// Package fragments caches rendered fragments, such as the output of
+// html/template, in an expiring Store, keyed by the fragment's name and a
+// hash of the parameters it was rendered with. Fragments can be compressed,
+// and served stale for a grace period while they are rendered again in the
+// background, so that a slow render never holds up a request for a fragment
+// which was cached recently.
+//
+//	fc := fragments.New(es, time.Minute, fragments.WithGrace(time.Hour), fragments.WithCompression(1024))
+//	err := fc.ExecuteTemplate(w, tmpl, "sidebar", user)
+package fragments
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// Cache caches rendered fragments.
+	Cache struct {
+		es              expiring.Store
+		ttl             time.Duration
+		grace           time.Duration
+		compressMinSize int
+		prefix          string
+		clock           clock.Clock
+		onRenderError   func(key string, err error)
+
+		mu        sync.Mutex
+		rendering map[string]struct{}
+	}
+
+	// Option configures a Cache.
+	Option func(*Cache)
+
+	// RenderFunc renders a fragment to w.
+	RenderFunc func(w io.Writer) error
+)
+
+// DefaultPrefix prefixes the keys fragments are written to the Store under.
+const DefaultPrefix = "fragment:"
+
+// the record a fragment is written as is
+//
+//	flags (1 byte) | fresh until, Unix nanoseconds (8, big endian) | fragment
+//
+// where the fragment is gzipped if flags has flagCompressed.
+const (
+	flagCompressed byte = 1 << 0
+
+	recordHeaderSize = 1 + 8
+)
+
+var (
+	CorruptFragmentError = errors.New("cached fragment is corrupt")
+)
+
+// New creates a Cache whose fragments are fresh for ttl.
+func New(es expiring.Store, ttl time.Duration, opts ...Option) *Cache {
+	c := &Cache{es: es, ttl: ttl, prefix: DefaultPrefix, clock: clock.Real{}, rendering: map[string]struct{}{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithGrace keeps fragments for d after they go stale. A stale fragment is
+// served as it is, and rendered again in the background; if rendering it
+// fails, it is served until the grace period ends.
+func WithGrace(d time.Duration) Option {
+	return func(c *Cache) {
+		c.grace = d
+	}
+}
+
+// WithCompression gzips fragments of at least minSize bytes before they
+// are written to the Store.
+func WithCompression(minSize int) Option {
+	return func(c *Cache) {
+		c.compressMinSize = minSize
+	}
+}
+
+// WithPrefix sets the prefix of the keys fragments are written under.
+func WithPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithClock sets the clock the Cache reads the time from. Defaults to
+// clock.Real.
+func WithClock(clk clock.Clock) Option {
+	return func(c *Cache) {
+		c.clock = clk
+	}
+}
+
+// WithRenderErrorHandler calls f with the errors of renders made in the
+// background during the grace period, which otherwise go unreported.
+func WithRenderErrorHandler(f func(key string, err error)) Option {
+	return func(c *Cache) {
+		c.onRenderError = f
+	}
+}
+
+// Key returns the key of the fragment name rendered with params. Params are
+// hashed by their fmt representation, so they should print every field
+// which changes the output.
+func Key(name string, params ...interface{}) string {
+	h := fnv.New64a()
+	for _, p := range params {
+		_, _ = fmt.Fprintf(h, "%#v\x00", p)
+	}
+	return fmt.Sprintf("%s:%016x", name, h.Sum64())
+}
+
+// Render returns the fragment cached under key, calling render to render it
+// if it isn't cached. The rendered fragment is cached unless render fails.
+func (c *Cache) Render(key string, render RenderFunc) ([]byte, error) {
+	if b, freshUntil, err := c.load(key); err == nil {
+		if c.clock.Now().After(freshUntil) {
+			c.refresh(key, render)
+		}
+		return b, nil
+	}
+	return c.render(key, render)
+}
+
+// ExecuteTemplate writes the template of t named name, executed with data, to
+// w, caching it under the key of name and data.
+func (c *Cache) ExecuteTemplate(w io.Writer, t *template.Template, name string, data interface{}) error {
+	b, err := c.Render(Key(name, data), func(w io.Writer) error {
+		return t.ExecuteTemplate(w, name, data)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// render renders a fragment and caches it.
+func (c *Cache) render(key string, render RenderFunc) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return nil, err
+	}
+	b := buf.Bytes()
+	if err := c.save(key, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// save caches a rendered fragment. Only encoding errors are returned; the
+// write to the Store is best effort.
+func (c *Cache) save(key string, b []byte) error {
+	record, err := c.encode(b)
+	if err != nil {
+		return err
+	}
+	_ = c.es.Set(c.prefix+key, record, &store.Options{Expiration: c.ttl + c.grace})
+	return nil
+}
+
+// refresh renders a stale fragment again in the background, unless it is
+// already being rendered.
+func (c *Cache) refresh(key string, render RenderFunc) {
+	c.mu.Lock()
+	if _, ok := c.rendering[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.rendering[key] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.rendering, key)
+			c.mu.Unlock()
+		}()
+		if _, err := c.render(key, render); err != nil && c.onRenderError != nil {
+			c.onRenderError(key, err)
+		}
+	}()
+}
+
+func (c *Cache) encode(b []byte) ([]byte, error) {
+	var flags byte
+	if c.compressMinSize > 0 && len(b) >= c.compressMinSize {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		flags, b = flags|flagCompressed, buf.Bytes()
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(b))
+	record[0] = flags
+	binary.BigEndian.PutUint64(record[1:], uint64(c.clock.Now().Add(c.ttl).UnixNano()))
+	return append(record, b...), nil
+}
+
+// load returns the fragment cached under key, and when it goes stale.
+func (c *Cache) load(key string) ([]byte, time.Time, error) {
+	val, err := c.es.Get(c.prefix + key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var record []byte
+	switch v := val.(type) {
+	case []byte:
+		record = v
+	case string:
+		record = []byte(v)
+	}
+	if len(record) < recordHeaderSize {
+		return nil, time.Time{}, CorruptFragmentError
+	}
+	freshUntil := time.Unix(0, int64(binary.BigEndian.Uint64(record[1:])))
+	b := record[recordHeaderSize:]
+	if record[0]&flagCompressed != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, time.Time{}, CorruptFragmentError
+		}
+		if b, err = ioutil.ReadAll(zr); err != nil {
+			return nil, time.Time{}, CorruptFragmentError
+		}
+	}
+	return b, freshUntil, nil
+}
@@ -0,0 +1,153 @@
+package fragments_test
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/nabowler/expiring_gocache/fragments"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCache(opts ...fragments.Option) (*fragments.Cache, expiring.Store, *clock.Fake) {
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	return fragments.New(es, time.Minute, append(opts, fragments.WithClock(clk))...), es, clk
+}
+
+func TestKey(t *testing.T) {
+	type params struct{ User, Page int }
+	assert.Equal(t, fragments.Key("sidebar", params{1, 2}), fragments.Key("sidebar", params{1, 2}))
+	assert.NotEqual(t, fragments.Key("sidebar", params{1, 2}), fragments.Key("sidebar", params{1, 3}))
+	assert.NotEqual(t, fragments.Key("sidebar", params{1, 2}), fragments.Key("footer", params{1, 2}))
+	assert.True(t, strings.HasPrefix(fragments.Key("sidebar"), "sidebar:"))
+}
+
+func TestRender(t *testing.T) {
+	fc, _, _ := newCache()
+	var renders int32
+	render := func(w io.Writer) error {
+		atomic.AddInt32(&renders, 1)
+		_, err := io.WriteString(w, "<p>hello</p>")
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		b, err := fc.Render("key", render)
+		assert.Nil(t, err)
+		assert.Equal(t, "<p>hello</p>", string(b))
+	}
+	assert.Equal(t, int32(1), renders)
+
+	// failed renders aren't cached
+	failure := errors.New("template failed")
+	_, err := fc.Render("failing", func(w io.Writer) error { return failure })
+	assert.Equal(t, failure, err)
+}
+
+func TestGracePeriod(t *testing.T) {
+	fc, _, clk := newCache(fragments.WithGrace(time.Hour))
+	rendered := make(chan struct{}, 1)
+	version := "v1"
+	render := func(w io.Writer) error {
+		_, err := io.WriteString(w, version)
+		select {
+		case rendered <- struct{}{}:
+		default:
+		}
+		return err
+	}
+
+	_, err := fc.Render("key", render)
+	assert.Nil(t, err)
+	<-rendered
+
+	// a stale fragment is served while it is rendered again
+	clk.Advance(2 * time.Minute)
+	version = "v2"
+	b, err := fc.Render("key", render)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(b))
+	<-rendered
+	// the new version is written once the render returns
+	for deadline := time.Now().Add(time.Second); string(b) != "v2" && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		b, err = fc.Render("key", render)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, "v2", string(b))
+}
+
+func TestCompression(t *testing.T) {
+	fc, es, _ := newCache(fragments.WithCompression(100))
+	fragment := strings.Repeat("<li>item</li>", 100)
+
+	b, err := fc.Render("list", func(w io.Writer) error {
+		_, err := io.WriteString(w, fragment)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, fragment, string(b))
+
+	record, err := es.Get(fragments.DefaultPrefix + "list")
+	assert.Nil(t, err)
+	assert.True(t, len(record.([]byte)) < len(fragment)/4)
+	b, err = fc.Render("list", func(w io.Writer) error { return errors.New("not called") })
+	assert.Nil(t, err)
+	assert.Equal(t, fragment, string(b))
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	fc, _, _ := newCache()
+	tmpl := template.Must(template.New("greeting").Parse("<p>Hello, {{.}}</p>"))
+
+	var buf bytes.Buffer
+	assert.Nil(t, fc.ExecuteTemplate(&buf, tmpl, "greeting", "<world>"))
+	assert.Equal(t, "<p>Hello, &lt;world&gt;</p>", buf.String())
+}
+
+func TestHandler(t *testing.T) {
+	fc, _, _ := newCache()
+	var calls int32
+	h := fc.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "page "+r.URL.RawQuery)
+	}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page?n=1", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "page n=1", rec.Body.String())
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		assert.Equal(t, i == 1, rec.Header().Get(fragments.CacheHeader) == "1")
+	}
+	assert.Equal(t, int32(1), calls)
+
+	// other routes, other methods and errors aren't served from the cache
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/page?n=2", nil),
+		httptest.NewRequest(http.MethodPost, "/page?n=1", nil),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, int32(5), calls)
+}
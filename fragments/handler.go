@@ -0,0 +1,129 @@
+package fragments
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+)
+
+type (
+	// recorder buffers a response.
+	recorder struct {
+		header http.Header
+		status int
+		body   bytes.Buffer
+	}
+)
+
+// CacheHeader is set to "1" on responses served from the cache by Handler.
+const CacheHeader = "X-From-Cache"
+
+var (
+	uncacheableError = errors.New("response can't be cached")
+)
+
+// Handler caches the whole responses of next to GET requests, by route:
+// the request's path and query. Only 200 OK responses are cached, with their
+// headers, as fragments, so they are compressed and served stale in a grace
+// period like any other fragment. Stale responses are refreshed by calling
+// next again in the background with a copy of the request.
+//
+// Responses which vary by anything other than the route, such as by cookie
+// or Accept-Encoding, shouldn't be cached with it.
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := Key("route", r.URL.RequestURI())
+		if b, freshUntil, err := c.load(key); err == nil {
+			if err := writeResponse(w, b); err == nil {
+				if c.clock.Now().After(freshUntil) {
+					bg := r.Clone(context.Background())
+					c.refresh(key, func(out io.Writer) error {
+						rec := newRecorder()
+						next.ServeHTTP(rec, bg)
+						return rec.writeTo(out)
+					})
+				}
+				return
+			}
+		}
+
+		rec := newRecorder()
+		next.ServeHTTP(rec, r)
+		var buf bytes.Buffer
+		if err := rec.writeTo(&buf); err == nil {
+			_ = c.save(key, buf.Bytes())
+		}
+		rec.flush(w)
+	})
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+// writeTo writes the response as it is cached: its headers, in the wire
+// format, followed by its body. uncacheableError is returned for responses
+// other than 200 OK.
+func (rec *recorder) writeTo(w io.Writer) error {
+	if rec.status != http.StatusOK {
+		return uncacheableError
+	}
+	if err := rec.header.Write(w); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	_, err := w.Write(rec.body.Bytes())
+	return err
+}
+
+// flush writes the buffered response to w.
+func (rec *recorder) flush(w http.ResponseWriter) {
+	for name, values := range rec.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(rec.body.Bytes())
+}
+
+// writeResponse writes a response cached by Handler to w.
+func writeResponse(w http.ResponseWriter, b []byte) error {
+	br := bufio.NewReader(bytes.NewReader(b))
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		return CorruptFragmentError
+	}
+	body, err := ioutil.ReadAll(br)
+	if err != nil {
+		return CorruptFragmentError
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(CacheHeader, "1")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	return nil
+}
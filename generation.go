@@ -0,0 +1,86 @@
+package expiring_gocache
+
+import (
+	"strconv"
+)
+
+// generationPrefix prefixes the keys of the namespace generations
+// BumpGeneration writes to the underlying store.
+const generationPrefix = "expiring:generation:"
+
+// BumpGeneration invalidates every value in namespace at once, by moving the
+// namespace on to its next generation: values record the generation of their
+// namespace when they are written, and are read as expired once it has
+// moved on, without each of them being deleted. They are deleted lazily,
+// when read, or dropped by the underlying store's own expiration.
+//
+// The generation is kept in the underlying store, under a key made from the
+// namespace, so that every Store sharing it sees it. It is read and written
+// back, so concurrent bumps of the same namespace may count as one, which
+// still invalidates every value written before them. Reads and writes of
+// namespaced keys fail with the underlying store's error if the generation
+// can't be read. It needs WithGenerations, and returns UnsupportedError
+// otherwise.
+func (es Store) BumpGeneration(namespace string) error {
+	if es.namespaceOf == nil {
+		return UnsupportedError
+	}
+	generation, err := es.generation(namespace)
+	if err != nil {
+		return err
+	}
+	record, err := es.wrap(wrappedValue{value: strconv.FormatUint(generation+1, 10), instance: es.instanceID, createdAt: es.now()})
+	if err != nil {
+		return err
+	}
+	return es.innerSet(generationPrefix+namespace, record, nil)
+}
+
+// generation reads the current generation of namespace from the underlying
+// store. Namespaces which have never been bumped are at generation 0. Errors
+// other than a miss are returned, since the generation isn't known.
+func (es Store) generation(namespace string) (uint64, error) {
+	val, err := es.innerGet(generationPrefix + namespace)
+	if err != nil {
+		if es.classify(OperationGet, err) == ErrorMiss {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if val == nil {
+		return 0, nil
+	}
+	ew, ok := unwrap(val)
+	if !ok || ew.instance != es.instanceID {
+		return 0, nil
+	}
+	s, _ := ew.value.(string)
+	generation, _ := strconv.ParseUint(s, 10, 64)
+	return generation, nil
+}
+
+// keyGeneration returns the current generation of key's namespace, or 0 if
+// it has none.
+func (es Store) keyGeneration(key interface{}) (uint64, error) {
+	if es.namespaceOf == nil {
+		return 0, nil
+	}
+	namespace := es.namespaceOf(key)
+	if namespace == "" {
+		return 0, nil
+	}
+	return es.generation(namespace)
+}
+
+// withGeneration expires ew if its namespace has moved on since it was
+// written.
+func (es Store) withGeneration(key interface{}, ew wrappedValue) (wrappedValue, error) {
+	generation, err := es.keyGeneration(key)
+	if err != nil {
+		return ew, err
+	}
+	if generation != ew.generation {
+		ew.expireAt = ew.createdAt
+	}
+	return ew, nil
+}
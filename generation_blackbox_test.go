@@ -0,0 +1,131 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// GenerationDownStore fails reads of namespace generations while down.
+	GenerationDownStore struct {
+		MapStore
+		down bool
+	}
+)
+
+var GenerationStoreDown = errors.New("connection refused")
+
+// namespaceOf puts string keys in the namespace before their first colon.
+func namespaceOf(key interface{}) string {
+	s, _ := key.(string)
+	if i := strings.Index(s, ":"); i > 0 {
+		return s[:i]
+	}
+	return ""
+}
+
+func TestBumpGeneration(t *testing.T) {
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, append(opts, expiring.WithGenerations(namespaceOf))...)
+
+			assert.Nil(t, es.Set("user:1", "value", nil))
+			assert.Nil(t, es.Set("user:2", "value", nil))
+			assert.Nil(t, es.Set("org:1", "value", nil))
+			assert.Nil(t, es.Set("plain", "value", nil))
+			deletes := ms.deleteCount
+
+			assert.Nil(t, es.BumpGeneration("user"))
+			// nothing is deleted up front
+			assert.Equal(t, deletes, ms.deleteCount)
+
+			_, err := es.Get("user:1")
+			assert.Equal(t, expiring.ValueExpiredError, err)
+			_, _, err = es.GetWithTTL("user:2")
+			assert.Equal(t, expiring.ValueExpiredError, err)
+			for _, key := range []string{"org:1", "plain"} {
+				val, err := es.Get(key)
+				assert.Nil(t, err)
+				assert.Equal(t, "value", val)
+			}
+
+			// values written in the new generation are read
+			assert.Nil(t, es.Set("user:1", "new value", nil))
+			val, err := es.Get("user:1")
+			assert.Nil(t, err)
+			assert.Equal(t, "new value", val)
+		})
+	}
+}
+
+func TestBumpGenerationUnsupported(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+
+	assert.Equal(t, expiring.UnsupportedError, es.BumpGeneration("user"))
+}
+
+func TestBumpGenerationSideEffectFreeReads(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithGenerations(namespaceOf), expiring.WithAccessTracking())
+	assert.Nil(t, es.Set("user:1", "value", nil))
+	assert.Nil(t, es.BumpGeneration("user"))
+
+	_, err := es.Peek("user:1")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	_, md, err := es.GetStale("user:1")
+	assert.Nil(t, err)
+	assert.True(t, md.Expired)
+	has, err := es.Has("user:1")
+	assert.Nil(t, err)
+	assert.False(t, has)
+	val, _, _, err := es.GetIfChanged("user:1", "")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Nil(t, val)
+	assert.Nil(t, es.ForEach(context.Background(), func(key, value interface{}, meta expiring.Metadata) bool {
+		t.Errorf("unexpected key %v", key)
+		return true
+	}))
+}
+
+func TestGenerationReadErrors(t *testing.T) {
+	gs := GenerationDownStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&gs, &store.Options{Expiration: time.Hour}, expiring.WithGenerations(namespaceOf),
+		expiring.WithErrorClassifier(expiring.MissClassifier(func(err error) bool { return err == MapStoreMiss })))
+	assert.Nil(t, es.BumpGeneration("user"))
+	assert.Nil(t, es.Set("user:1", "value", nil))
+
+	gs.down = true
+	_, err := es.Get("user:1")
+	assert.Equal(t, GenerationStoreDown, err)
+	_, err = es.Peek("user:1")
+	assert.Equal(t, GenerationStoreDown, err)
+	assert.Equal(t, GenerationStoreDown, es.BumpGeneration("user"))
+	assert.Equal(t, GenerationStoreDown, es.Set("user:2", "value", nil))
+	// the value wasn't taken for expired
+	_, ok := gs.cache["user:1"]
+	assert.True(t, ok)
+
+	gs.down = false
+	val, err := es.Get("user:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func (gs *GenerationDownStore) Get(key interface{}) (interface{}, error) {
+	if s, _ := key.(string); gs.down && strings.HasPrefix(s, "expiring:generation:") {
+		return nil, GenerationStoreDown
+	}
+	return gs.MapStore.Get(key)
+}
@@ -3,6 +3,16 @@ module github.com/nabowler/expiring_gocache
 go 1.13
 
 require (
+	github.com/allegro/bigcache v1.2.1
+	github.com/coocood/freecache v1.1.0
+	github.com/dgraph-io/ristretto v0.0.1
 	github.com/eko/gocache v0.2.0
+	github.com/go-redis/redis/v7 v7.0.0-beta.4
+	github.com/golang/protobuf v1.3.2
+	github.com/mailgun/groupcache/v2 v2.1.0
+	github.com/prometheus/client_golang v1.1.0
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
 	github.com/stretchr/testify v1.4.0
+	google.golang.org/grpc v1.27.1
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,44 @@
+package expiring_gocache
+
+import (
+	"errors"
+)
+
+type (
+	hasChecker interface {
+		Has(key interface{}) (bool, error)
+	}
+)
+
+// Has reports whether an unexpired value is stored for key, like Peek but
+// without returning the value. Keys tracked by the Store (see WithReaper,
+// WithMaxEntries and WithAccessTracking) are answered from the Store's own
+// index, unless generations, tag expiry or read limits are enabled, which
+// only the stored value knows of. Keys the underlying store reports absent
+// through `Has(key interface{}) (bool, error)` aren't read at all. Otherwise
+// the value is read as with Peek.
+//
+// Errors other than the value having expired are returned as they are, so a
+// miss in an underlying store which reports misses as errors is that error.
+func (es Store) Has(key interface{}) (bool, error) {
+	if es.tracker != nil && es.namespaceOf == nil && !es.tagExpiry && !es.readLimits && !es.bypassed(key) {
+		if expireAt, ok := es.tracker.expireAt(key); ok {
+			return !es.isExpired(wrappedValue{expireAt: expireAt}, es.now()) || es.pins.has(key), nil
+		}
+	}
+	if hc, ok := es.store.(hasChecker); ok {
+		present, err := es.innerHas(hc, key)
+		if err != nil || !present {
+			return false, err
+		}
+	}
+
+	val, err := es.peek(key)
+	switch {
+	case err == nil:
+		return val != nil, nil
+	case errors.Is(err, ValueExpiredError) || err == ForeignValueError || err == FutureEnvelopeError:
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,113 @@
+package expiring_gocache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// HasMapStore can check whether it holds a key without reading it.
+	HasMapStore struct {
+		MapStore
+		hasMu    sync.Mutex
+		hasCount int
+	}
+)
+
+func TestHas(t *testing.T) {
+	ms := HasMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("short", "value", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+
+	for key, expected := range map[string]bool{"key": true, "short": false, "missing": false} {
+		has, err := es.Has(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, has, key)
+	}
+	assert.Equal(t, 3, ms.hasCount)
+	// the missing key was never read, and the expired one wasn't deleted
+	assert.Equal(t, 2, ms.getCount)
+	assert.Equal(t, 0, ms.deleteCount)
+}
+
+func TestHasFromIndex(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk), expiring.WithAccessTracking())
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("short", "value", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+
+	has, err := es.Has("key")
+	assert.Nil(t, err)
+	assert.True(t, has)
+	has, err = es.Has("short")
+	assert.Nil(t, err)
+	assert.False(t, has)
+	assert.Equal(t, 0, ms.getCount)
+
+	// untracked keys fall back to a read
+	_, err = es.Has("missing")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Equal(t, 1, ms.getCount)
+}
+
+func TestHasFromIndexAgreesWithGet(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk),
+		expiring.WithAccessTracking(), expiring.WithClockSkewTolerance(time.Minute))
+
+	// within the skew tolerance, the value hasn't expired
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(90 * time.Second)
+	has, err := es.Has("key")
+	assert.Nil(t, err)
+	assert.True(t, has)
+	_, err = es.Get("key")
+	assert.Nil(t, err)
+
+	clk.Advance(time.Minute)
+	has, err = es.Has("key")
+	assert.Nil(t, err)
+	assert.False(t, has)
+}
+
+func TestHasWithReadLimits(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking(), expiring.WithReadLimits())
+
+	assert.Nil(t, es.Set("key", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(1)}}))
+	has, err := es.Has("key")
+	assert.Nil(t, err)
+	assert.True(t, has)
+	// Has reads the value, but doesn't count as one of its reads
+	assert.Equal(t, 1, ms.getCount)
+	_, err = es.Get("key")
+	assert.Nil(t, err)
+
+	has, _ = es.Has("key")
+	assert.False(t, has)
+}
+
+func (ms *HasMapStore) Has(key interface{}) (bool, error) {
+	ms.hasMu.Lock()
+	ms.hasCount++
+	ms.hasMu.Unlock()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	_, ok := ms.cache[key]
+	return ok, nil
+}
@@ -0,0 +1,57 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	hedgeResult struct {
+		val     interface{}
+		err     error
+		replica bool
+	}
+)
+
+// hedgedGet reads key from the underlying store, and also from the hedge
+// replica if the underlying store hasn't answered within the hedge delay.
+// An answer from the underlying store within the delay is returned as is,
+// even if it is an error such as a miss. Once hedged, the first successful
+// answer wins; if both fail, the underlying store's error is returned.
+func (es Store) hedgedGet(key interface{}) (interface{}, error) {
+	// buffered so that the losing read doesn't block forever
+	results := make(chan hedgeResult, 2)
+	read := func(s store.StoreInterface, replica bool) {
+		val, err := s.Get(key)
+		results <- hedgeResult{val: val, err: err, replica: replica}
+	}
+	go read(es.store, false)
+
+	timer := time.NewTimer(es.hedgeDelay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.val, r.err
+	case <-timer.C:
+	}
+
+	atomic.AddUint64(&es.stats.hedgedReads, 1)
+	go read(es.hedgeReplica, true)
+
+	var primaryErr error
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err == nil {
+			if r.replica {
+				atomic.AddUint64(&es.stats.hedgeWins, 1)
+			}
+			return r.val, nil
+		}
+		if !r.replica {
+			primaryErr = r.err
+		}
+	}
+	return nil, primaryErr
+}
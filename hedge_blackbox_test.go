@@ -0,0 +1,50 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgedReadWonByReplica(t *testing.T) {
+	primary := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: 50 * time.Millisecond}
+	replica := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&primary, &store.Options{Expiration: time.Hour}, expiring.WithHedgedReads(&replica, 5*time.Millisecond))
+	assert.Nil(t, expiring.New(&replica, &store.Options{Expiration: time.Hour}).Set("key", "replica", nil))
+
+	start := time.Now()
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "replica", val)
+	assert.True(t, time.Since(start) < primary.delay)
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(1), stats.HedgedReads)
+	assert.Equal(t, uint64(1), stats.HedgeWins)
+}
+
+func TestHedgedReadNotNeeded(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	replica := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&primary, nil, expiring.WithHedgedReads(&replica, time.Second))
+
+	// a quick miss from the primary is returned without hedging
+	_, err := es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Equal(t, 0, replica.getCount)
+	assert.Equal(t, uint64(0), es.Stats().HedgedReads)
+}
+
+func TestHedgedReadBothMiss(t *testing.T) {
+	primary := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: 20 * time.Millisecond}
+	replica := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&primary, nil, expiring.WithHedgedReads(&replica, time.Millisecond))
+
+	_, err := es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Equal(t, 1, replica.getCount)
+	assert.Equal(t, uint64(0), es.Stats().HedgeWins)
+}
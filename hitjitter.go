@@ -0,0 +1,38 @@
+package expiring_gocache
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+// nudge rewrites a value just read with its expiration moved randomly within
+// the window given to WithHitJitter, for the fraction of hits it gives.
+// Rewrites are best effort, and keep the value as it is.
+func (es Store) nudge(key interface{}, ew wrappedValue, now time.Time) {
+	if es.hitJitterRate <= 0 || es.hitJitterWindow <= 0 || es.draining() || rand.Float64() >= es.hitJitterRate {
+		return
+	}
+	expireAt := ew.expireAt.Add(time.Duration((2*rand.Float64() - 1) * float64(es.hitJitterWindow)))
+	if !expireAt.After(now) {
+		return
+	}
+	ew.expireAt = expireAt
+	wrapped, err := es.wrap(ew)
+	if err != nil {
+		return
+	}
+	var options *store.Options
+	if es.nativeExpiration {
+		options = withNativeExpiration(nil, expireAt.Sub(now))
+	}
+	if err := es.innerSet(key, wrapped, options); err != nil {
+		return
+	}
+	if es.tracker != nil {
+		es.tracker.reschedule(key, expireAt)
+	}
+	atomic.AddUint64(&es.stats.hitJitterRewrites, 1)
+}
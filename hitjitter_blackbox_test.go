@@ -0,0 +1,45 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHitJitter(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithHitJitter(1, 10*time.Minute), expiring.WithClock(clk))
+
+	ttls := map[time.Duration]bool{}
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, es.Set(i, "value", nil))
+		val, err := es.Get(i)
+		assert.Nil(t, err)
+		assert.Equal(t, "value", val)
+
+		_, ttl, err := es.GetWithTTL(i)
+		assert.Nil(t, err)
+		// nudged by the Get
+		assert.True(t, ttl >= 50*time.Minute && ttl <= 70*time.Minute, "ttl %s", ttl)
+		ttls[ttl] = true
+	}
+	assert.True(t, len(ttls) > 1)
+	assert.Equal(t, uint64(10), es.Stats().HitJitterRewrites)
+}
+
+func TestHitJitterDisabled(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithHitJitter(0, time.Minute))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, ms.setCount)
+	assert.Equal(t, uint64(0), es.Stats().HitJitterRewrites)
+}
@@ -0,0 +1,70 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// idleState tracks when the Store was last used, so that its background
+	// workers can suspend themselves while it is idle, see WithIdleSuspend.
+	// It is shared by the copies of a Store.
+	idleState struct {
+		period time.Duration
+		// last is the UnixNano time of the latest operation.
+		last      int64
+		suspended int32
+		mu        sync.Mutex
+		// wake is closed by the first operation after a suspension.
+		wake chan struct{}
+	}
+)
+
+func newIdleState(period time.Duration) *idleState {
+	return &idleState{period: period, wake: make(chan struct{})}
+}
+
+// active records an operation on the Store, resuming its suspended workers.
+// The workers' own calls don't count.
+func (es Store) active() {
+	s := es.idle
+	if s == nil || es.background {
+		return
+	}
+	atomic.StoreInt64(&s.last, es.now().UnixNano())
+	if atomic.LoadInt32(&s.suspended) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atomic.LoadInt32(&s.suspended) == 1 {
+		close(s.wake)
+		s.wake = make(chan struct{})
+		atomic.StoreInt32(&s.suspended, 0)
+	}
+}
+
+// idleWait returns a channel which is closed when the Store is next used, if
+// it has been idle for the idle period, or else nil.
+func (es Store) idleWait() <-chan struct{} {
+	s := es.idle
+	if s == nil || !es.isIdle() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atomic.CompareAndSwapInt32(&s.suspended, 0, 1) {
+		atomic.AddUint64(&es.stats.idleSuspensions, 1)
+	}
+	// an operation may have slipped in before the suspension
+	if !es.isIdle() {
+		atomic.StoreInt32(&s.suspended, 0)
+		return nil
+	}
+	return s.wake
+}
+
+func (es Store) isIdle() bool {
+	return es.now().Sub(time.Unix(0, atomic.LoadInt64(&es.idle.last))) >= es.idle.period
+}
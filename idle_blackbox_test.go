@@ -0,0 +1,48 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleSuspend(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	fake := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Second},
+		expiring.WithClock(fake),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(time.Second),
+		expiring.WithIdleSuspend(time.Minute),
+	)
+	defer es.Close()
+	deleted := func() int {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		return ms.deleteCount
+	}
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	fake.Advance(2 * time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for es.Stats().IdleSuspensions == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(1), es.Stats().IdleSuspensions)
+	// the suspended reaper leaves the expired value alone
+	time.Sleep(5 * reaperInterval)
+	assert.Equal(t, 0, deleted())
+
+	// until the Store is used again
+	_, err := es.Get("other")
+	assert.Equal(t, MapStoreMiss, err)
+	for deleted() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1, deleted())
+	assert.Equal(t, uint64(1), es.Stats().IdleSuspensions)
+}
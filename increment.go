@@ -0,0 +1,130 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// incrementer is implemented by stores which can add to a counter
+	// atomically, e.g. with Redis' INCRBY. A counter which doesn't exist is
+	// created with the expiration; the expiration of one which does is left
+	// alone.
+	incrementer interface {
+		Increment(key interface{}, delta int64, expiration time.Duration) (int64, error)
+	}
+
+	// counterLocks serializes the increments of a key made in this process,
+	// for stores which can't increment atomically.
+	counterLocks struct {
+		locks [64]sync.Mutex
+	}
+)
+
+var (
+	NotACounterError = errors.New("value is not a counter")
+)
+
+// Increment adds delta to the counter at key, returning its new value. A
+// counter which doesn't exist, or has expired, starts from 0 and expires
+// after ttl; incrementing it again leaves its expiration alone, so that it
+// counts within a fixed window. NotACounterError is returned if key holds
+// another value, and the error of a read which fails other than with a
+// miss is returned without writing the counter.
+//
+// If the underlying store implements
+// `Increment(key interface{}, delta int64, expiration time.Duration) (int64, error)`
+// the counter is kept by it, atomically across every process sharing it.
+// Otherwise the counter is read and written back as an int64 value, or as a
+// decimal string with WithBinaryEnvelope, which is only atomic within this
+// process.
+func (es Store) Increment(key interface{}, delta int64, ttl time.Duration) (int64, error) {
+	if inc, ok := es.store.(incrementer); ok {
+		return es.innerIncrement(inc, key, delta, ttl)
+	}
+
+	mu := &es.counters.locks[keyHash(key)%uint64(len(es.counters.locks))]
+	mu.Lock()
+	defer mu.Unlock()
+	var n int64
+	val, left, err := es.getWithTTL(key)
+	switch {
+	case err == nil:
+		var ok bool
+		if n, ok = counterValue(val); !ok {
+			return 0, NotACounterError
+		}
+	case errors.Is(err, ValueExpiredError) || es.IsMiss(err):
+		// start a new counter
+	default:
+		return 0, err
+	}
+	if err != nil || left <= 0 {
+		left = ttl
+	}
+
+	n += delta
+	if err := es.writeCounter(key, n, left); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// writeCounter writes the counter at key, expiring after ttl.
+func (es Store) writeCounter(key interface{}, n int64, ttl time.Duration) error {
+	var stored interface{} = n
+	if es.binaryEnvelope {
+		stored = strconv.FormatInt(n, 10)
+	}
+	return es.SetExact(key, stored, ttl)
+}
+
+// SetExact writes value at key, expiring after exactly ttl. Unlike Set, the
+// write isn't sampled, admitted, filtered by WithAllowedKeys or WithDeniedKeys
+// or jittered, any of which would lose or move state such as a counter or a
+// rate limiter's bucket, which must be written whenever it changes.
+func (es Store) SetExact(key interface{}, value interface{}, ttl time.Duration) error {
+	if es.bypassed(key) {
+		return es.innerSet(key, value, nil)
+	}
+	generation, err := es.keyGeneration(key)
+	if err != nil {
+		return err
+	}
+	now := es.now()
+	p := preparedSet{value: value, wrapped: true, expireAt: now.Add(ttl)}
+	wrapped, err := es.wrap(wrappedValue{expireAt: p.expireAt, value: value, instance: es.instanceID, etag: etagFor(value), createdAt: now, generation: generation})
+	if err != nil {
+		return err
+	}
+	var options *store.Options
+	if es.nativeExpiration {
+		options = withNativeExpiration(nil, ttl)
+	}
+	p.item = SetItem{Key: key, Value: wrapped, Options: options}
+	if err := es.innerSet(key, wrapped, options); err != nil {
+		return err
+	}
+	es.setDone(p)
+	es.evict()
+	return nil
+}
+
+// counterValue returns the value of a counter written by Increment.
+func counterValue(val interface{}) (int64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return v, true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
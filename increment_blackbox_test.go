@@ -0,0 +1,125 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// FailingGetStore fails every Get with err, if it is set.
+	FailingGetStore struct {
+		MapStore
+		err error
+	}
+)
+
+var FailingGetError = errors.New("connection refused")
+
+func TestIncrement(t *testing.T) {
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, append(opts, expiring.WithClock(clk))...)
+
+			n, err := es.Increment("hits", 1, time.Minute)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), n)
+			clk.Advance(30 * time.Second)
+			n, err = es.Increment("hits", 2, time.Minute)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(3), n)
+
+			// the counter keeps its first expiration
+			_, ttl, err := es.GetWithTTL("hits")
+			assert.Nil(t, err)
+			assert.Equal(t, 30*time.Second, ttl)
+
+			clk.Advance(time.Minute)
+			n, err = es.Increment("hits", 1, time.Minute)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), n)
+		})
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := es.Increment("hits", 1, time.Minute)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	n, err := es.Increment("hits", 0, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), n)
+}
+
+func TestIncrementNotACounter(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Increment("key", 1, time.Minute)
+	assert.Equal(t, expiring.NotACounterError, err)
+}
+
+func TestIncrementFailedRead(t *testing.T) {
+	fs := FailingGetStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&fs, &store.Options{Expiration: time.Hour},
+		expiring.WithErrorClassifier(expiring.MissClassifier(func(err error) bool { return err == MapStoreMiss })))
+
+	for i := 0; i < 5; i++ {
+		_, err := es.Increment("hits", 1, time.Minute)
+		assert.Nil(t, err)
+	}
+	fs.err = FailingGetError
+	_, err := es.Increment("hits", 1, time.Minute)
+	assert.Equal(t, FailingGetError, err)
+
+	// the counter wasn't restarted
+	fs.err = nil
+	n, err := es.Increment("hits", 1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+}
+
+func TestIncrementIgnoresSetPolicies(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk),
+		expiring.WithJitter(0.5), expiring.WithSetSampling(0.000001), expiring.WithAdmission(2, time.Minute))
+
+	for i := int64(1); i <= 3; i++ {
+		n, err := es.Increment("hits", 1, time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, i, n)
+	}
+	_, ttl, err := es.GetWithTTL("hits")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func (fs *FailingGetStore) Get(key interface{}) (interface{}, error) {
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	return fs.MapStore.Get(key)
+}
@@ -0,0 +1,403 @@
+package expiring_gocache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// IndexStats describes the tracker's key index, see Stats.Index.
+	IndexStats struct {
+		// Entries is how many keys are tracked, and Retired how many
+		// removed keys' history is kept for KeyStats.
+		Entries int
+		Retired int
+		// Shards is how many maps the index is split into.
+		Shards int
+		// Hashed reports whether the index holds hashes of keys rather than
+		// the keys, see WithHashedIndex.
+		Hashed bool
+		// Bytes estimates the memory the index holds, including that not yet
+		// given back by shards which have shrunk. It leaves out the eviction
+		// policy's own bookkeeping, and, unless the index is hashed, the
+		// keys' own data, such as the bytes of string keys.
+		Bytes uint64
+		// Compactions counts the shards rebuilt to give back the memory of
+		// removed keys.
+		Compactions uint64
+		// Collisions counts the keys of a hashed index left untracked
+		// because their hash collided with that of a key already tracked.
+		Collisions uint64
+	}
+
+	// trackedIndex maps keys to their entries. It is split into shards,
+	// each with its own lock, so that reads of different keys' entries
+	// don't wait on each other. A shard is rebuilt once it has shrunk to a
+	// fraction of the size it grew to: Go maps never give back the memory
+	// of deleted keys, and rebuilding a shard at a time keeps each rebuild
+	// short.
+	//
+	// A hashed index holds no keys. Each key is stood for by its
+	// hashedKey, in the index and in the tracker's other structures, and
+	// the index is keyed by the key's 64 bit hash. A key whose hash
+	// collides with a tracked key's is told apart by the second hash of
+	// its hashedKey, and left untracked.
+	trackedIndex struct {
+		hashed      bool
+		shards      []indexShard
+		compactions uint64
+		collisions  uint64
+	}
+
+	// indexShard is one shard of a trackedIndex. Its lock guards its maps,
+	// and those fields of its entries which are updated without the
+	// tracker's lock, see tracker.
+	indexShard struct {
+		mu     sync.Mutex
+		byKey  map[interface{}]*trackedEntry
+		byHash map[uint64]*trackedEntry
+		// peak is the most keys the shard has held since it was built.
+		peak int
+	}
+
+	// hashedKey stands for a key in a hashed index: its hash, and a
+	// second, independent hash to tell apart keys whose hashes collide.
+	hashedKey struct {
+		hash  uint64
+		check uint32
+	}
+)
+
+const (
+	// indexShards is how many shards a tracker's index is split into.
+	indexShards = 64
+	// minCompactSize is the smallest peak a shard is compacted from, and
+	// compactRatio how many times smaller than its peak it must be.
+	minCompactSize = 1024
+	compactRatio   = 4
+
+	// The sizes, in bytes, of the parts of the index on 64 bit platforms,
+	// rounded up to Go's allocation size classes, for IndexStats.
+	pointerBytes      = 8
+	interfaceBytes    = 16
+	hashBytes         = 8
+	hashedKeyBytes    = 16
+	trackedEntryBytes = 192
+	listElementBytes  = 48
+
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+func newTrackedIndex(hashed bool) *trackedIndex {
+	ix := &trackedIndex{hashed: hashed, shards: make([]indexShard, indexShards)}
+	for i := range ix.shards {
+		ix.shards[i].build(hashed, 0)
+	}
+	return ix
+}
+
+func (s *indexShard) build(hashed bool, size int) {
+	if hashed {
+		s.byHash = make(map[uint64]*trackedEntry, size)
+	} else {
+		s.byKey = make(map[interface{}]*trackedEntry, size)
+	}
+	s.peak = size
+}
+
+func (s *indexShard) len() int {
+	if s.byHash != nil {
+		return len(s.byHash)
+	}
+	return len(s.byKey)
+}
+
+// ref returns what stands for key in the tracker: key itself, or its
+// hashedKey in a hashed index. Refs are accepted wherever keys are.
+func (ix *trackedIndex) ref(key interface{}) interface{} {
+	if !ix.hashed {
+		return key
+	}
+	if hk, ok := key.(hashedKey); ok {
+		return hk
+	}
+	return indexHash(key)
+}
+
+// shard returns the shard of key, or of the key ref stands for, along with
+// its hashedKey in a hashed index.
+func (ix *trackedIndex) shard(key interface{}) (*indexShard, hashedKey) {
+	if !ix.hashed {
+		return &ix.shards[mix64(shardHash(key))%uint64(len(ix.shards))], hashedKey{}
+	}
+	hk := ix.ref(key).(hashedKey)
+	return &ix.shards[mix64(hk.hash)%uint64(len(ix.shards))], hk
+}
+
+// getLocked returns the entry of key, with s, its shard, locked.
+func (ix *trackedIndex) getLocked(s *indexShard, key interface{}, hk hashedKey) (*trackedEntry, bool) {
+	if !ix.hashed {
+		entry, ok := s.byKey[key]
+		return entry, ok
+	}
+	entry, ok := s.byHash[hk.hash]
+	if !ok || entry.key != hk {
+		return nil, false
+	}
+	return entry, true
+}
+
+// get returns the entry of key. Only the fields which are never updated
+// without the tracker's lock may be read from it, see with.
+func (ix *trackedIndex) get(key interface{}) (*trackedEntry, bool) {
+	s, hk := ix.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ix.getLocked(s, key, hk)
+}
+
+// with calls fn with the entry of key, if it is tracked, holding its
+// shard's lock, and reports whether it was.
+func (ix *trackedIndex) with(key interface{}, fn func(entry *trackedEntry)) bool {
+	s, hk := ix.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := ix.getLocked(s, key, hk)
+	if ok {
+		fn(entry)
+	}
+	return ok
+}
+
+// put adds entry, which mustn't be held yet. It reports false, and counts a
+// collision, if entry's key is hashed and its hash collides with that of
+// another key, which is left be.
+func (ix *trackedIndex) put(entry *trackedEntry) bool {
+	s, hk := ix.shard(entry.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ix.hashed {
+		if _, ok := s.byHash[hk.hash]; ok {
+			atomic.AddUint64(&ix.collisions, 1)
+			return false
+		}
+		s.byHash[hk.hash] = entry
+	} else {
+		s.byKey[entry.key] = entry
+	}
+	if n := s.len(); n > s.peak {
+		s.peak = n
+	}
+	return true
+}
+
+// remove removes entry, compacting its shard if it has shrunk enough.
+func (ix *trackedIndex) remove(entry *trackedEntry) {
+	s, hk := ix.shard(entry.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if held, ok := ix.getLocked(s, entry.key, hk); !ok || held != entry {
+		return
+	}
+	if ix.hashed {
+		delete(s.byHash, hk.hash)
+	} else {
+		delete(s.byKey, entry.key)
+	}
+	if s.peak >= minCompactSize && s.len()*compactRatio <= s.peak {
+		ix.compactLocked(s)
+	}
+}
+
+// compactLocked rebuilds s with only the entries it holds.
+func (ix *trackedIndex) compactLocked(s *indexShard) {
+	byKey, byHash := s.byKey, s.byHash
+	s.build(ix.hashed, s.len())
+	for key, entry := range byKey {
+		s.byKey[key] = entry
+	}
+	for h, entry := range byHash {
+		s.byHash[h] = entry
+	}
+	atomic.AddUint64(&ix.compactions, 1)
+}
+
+// clear removes every entry.
+func (ix *trackedIndex) clear() {
+	for i := range ix.shards {
+		s := &ix.shards[i]
+		s.mu.Lock()
+		s.build(ix.hashed, 0)
+		s.mu.Unlock()
+	}
+}
+
+func (ix *trackedIndex) len() int {
+	n := 0
+	for i := range ix.shards {
+		s := &ix.shards[i]
+		s.mu.Lock()
+		n += s.len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// each calls fn with every entry, in no particular order, holding the lock
+// of the entry's shard.
+func (ix *trackedIndex) each(fn func(entry *trackedEntry)) {
+	for i := range ix.shards {
+		s := &ix.shards[i]
+		s.mu.Lock()
+		for _, entry := range s.byKey {
+			fn(entry)
+		}
+		for _, entry := range s.byHash {
+			fn(entry)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// bytes estimates the memory the index holds: that of the shards at their
+// peaks, then of the entries, their hashedKeys, and their places in the
+// expiration buckets.
+func (ix *trackedIndex) bytes() uint64 {
+	keyBytes, entryBytes := uint64(interfaceBytes), uint64(trackedEntryBytes)
+	if ix.hashed {
+		keyBytes, entryBytes = hashBytes, trackedEntryBytes+hashedKeyBytes
+	}
+	var peaks, entries uint64
+	for i := range ix.shards {
+		s := &ix.shards[i]
+		s.mu.Lock()
+		peaks += uint64(s.peak)
+		entries += uint64(s.len())
+		s.mu.Unlock()
+	}
+	return peaks*mapSlotBytes(keyBytes, pointerBytes) + entries*(entryBytes+mapSlotBytes(pointerBytes, 0))
+}
+
+// retiredBytes estimates the memory the history of n retired keys holds.
+func (ix *trackedIndex) retiredBytes(n int) uint64 {
+	entryBytes := uint64(trackedEntryBytes)
+	if ix.hashed {
+		entryBytes += hashedKeyBytes
+	}
+	return uint64(n) * (entryBytes + listElementBytes + mapSlotBytes(interfaceBytes, pointerBytes))
+}
+
+// mapSlotBytes estimates the bytes a map takes per key, for keys and values
+// of the given sizes: both, and a byte of their hash, at Go's average load
+// factor of 6.5 keys per 8 slots.
+func mapSlotBytes(key, value uint64) uint64 {
+	return (key + value + 1) * 16 / 13
+}
+
+// indexHash returns the hashedKey of key. Unlike keyHash, keys of different
+// types, such as 1 and "1", hash differently. String and integer keys, the
+// most common, are hashed without formatting them.
+func indexHash(key interface{}) hashedKey {
+	switch k := key.(type) {
+	case string:
+		return hashString(k, 0)
+	case int:
+		return hashedKey{hash: mix64(uint64(k)) ^ 1, check: 1}
+	case int64:
+		return hashedKey{hash: mix64(uint64(k)) ^ 2, check: 2}
+	case uint64:
+		return hashedKey{hash: mix64(k) ^ 3, check: 3}
+	case int32:
+		return hashedKey{hash: mix64(uint64(k)) ^ 4, check: 4}
+	case uint32:
+		return hashedKey{hash: mix64(uint64(k)) ^ 5, check: 5}
+	}
+	return hashString(fmt.Sprintf("%T %#v", key, key), 6)
+}
+
+// hashString hashes s with 64 bit FNV-1a, and checks it with 32 bit FNV-1
+// seeded with kind, the type of the key s was taken from. Integers of one
+// kind need no check, since mix64 never maps two to the same hash.
+func hashString(s string, kind uint32) hashedKey {
+	h, c := uint64(fnvOffset64), (fnvOffset32^kind)*fnvPrime32
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * fnvPrime64
+		c = (c * fnvPrime32) ^ uint32(s[i])
+	}
+	return hashedKey{hash: h, check: c}
+}
+
+// shardHash returns the hash which picks the shard of key in an index which
+// isn't hashed. Equal keys hash the same, but unlike indexHash it needn't
+// tell keys apart, so other keys than strings and integers are hashed by
+// walking their values rather than formatting them.
+func shardHash(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k, 0).hash
+	case int:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	}
+	return valueHash(fnvOffset64, reflect.ValueOf(key))
+}
+
+// valueHash adds v to h, the way equal values compare: fields and elements
+// in turn, and pointers by their address.
+func valueHash(h uint64, v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		for i := 0; i < len(s); i++ {
+			h = (h ^ uint64(s[i])) * fnvPrime64
+		}
+		return h
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(h ^ uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix64(h ^ v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			return mix64(h ^ 1)
+		}
+		return mix64(h)
+	case reflect.Float32, reflect.Float64:
+		return floatHash(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return floatHash(floatHash(h, real(c)), imag(c))
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return mix64(h ^ uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return h
+		}
+		return valueHash(h, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h = valueHash(h, v.Field(i))
+		}
+		return h
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = valueHash(h, v.Index(i))
+		}
+		return h
+	}
+	return h
+}
+
+// floatHash adds f to h, with -0 hashed as 0, which it equals.
+func floatHash(h uint64, f float64) uint64 {
+	if f == 0 {
+		f = 0
+	}
+	return mix64(h ^ math.Float64bits(f))
+}
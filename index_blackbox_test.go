@@ -0,0 +1,210 @@
+package expiring_gocache_test
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashedIndexEvictsLeastRecentlyUsed(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(3), expiring.WithHashedIndex())
+
+	// keys of different types are tracked apart
+	assert.Nil(t, es.Set("1", "value", nil))
+	assert.Nil(t, es.Set(1, "value", nil))
+	assert.Nil(t, es.Set("b", "value", nil))
+	_, err := es.Get("1")
+	assert.Nil(t, err)
+
+	assert.Nil(t, es.Set("c", "value", nil))
+	assert.Equal(t, []interface{}{1}, ms.deletedKeys)
+
+	index := es.Stats().Index
+	if assert.NotNil(t, index) {
+		assert.True(t, index.Hashed)
+		assert.Equal(t, 3, index.Entries)
+		assert.NotZero(t, index.Bytes)
+	}
+}
+
+func TestHashedIndexReapsAndReportsKeys(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithClock(clk),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+		expiring.WithAccessTracking(),
+		expiring.WithHashedIndex(),
+	)
+
+	assert.Nil(t, es.Set("short", "value", nil))
+	assert.Nil(t, es.Set("long", "value", &store.Options{Expiration: time.Hour}))
+	_, err := es.Get("long")
+	assert.Nil(t, err)
+
+	keys, err := es.Keys()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []interface{}{"short", "long"}, keys)
+	hot := es.HotKeys(1)
+	if assert.Len(t, hot, 1) {
+		assert.Equal(t, "long", hot[0].Key)
+		assert.Equal(t, uint64(1), hot[0].Hits)
+	}
+
+	awaitReaper(t, clk, func() bool { return !ms.holds("short") })
+	assert.Nil(t, es.Close())
+	assert.Equal(t, []interface{}{"short"}, ms.deletedKeys)
+}
+
+func TestHashedIndexKeepsCollidingKey(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking(), expiring.WithHashedIndex())
+
+	// the hashes of these keys collide
+	tracked, colliding := 7, int64(5700067511832721039)
+	assert.Nil(t, es.Set(tracked, "value", nil))
+	assert.Nil(t, es.Set(colliding, "value", nil))
+
+	index := es.Stats().Index
+	assert.Equal(t, 1, index.Entries)
+	assert.Equal(t, uint64(1), index.Collisions)
+	ks, err := es.KeyStats(tracked)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), ks.Sets)
+	ks, err = es.KeyStats(colliding)
+	assert.Nil(t, err)
+	assert.Zero(t, ks.Sets)
+
+	// the untracked key is still held
+	val, err := es.Get(colliding)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestHashedIndexNeedsKeyListing(t *testing.T) {
+	_, err := expiring.NewValidated(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithMaxEntries(10), expiring.WithHashedIndex())
+	assert.IsType(t, &expiring.ConfigError{}, err)
+
+	es, err := expiring.NewValidated(&ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}, nil, expiring.WithMaxEntries(10), expiring.WithHashedIndex())
+	assert.Nil(t, err)
+	assert.Nil(t, es.Close())
+}
+
+func TestIndexConcurrentReads(t *testing.T) {
+	for _, hashed := range []bool{false, true} {
+		options := []expiring.Option{expiring.WithMaxEntries(100), expiring.WithAccessTracking()}
+		if hashed {
+			options = append(options, expiring.WithHashedIndex())
+		}
+		es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, options...)
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, es.Set(i, "value", nil))
+		}
+
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					_, _ = es.Get(i % 10)
+					_ = es.Set(10+g, "value", nil)
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		ks, err := es.KeyStats(0)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(40), ks.Hits, "hashed %t", hashed)
+	}
+}
+
+func TestIndexStatsNilWithoutTracking(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Stats().Index)
+}
+
+func TestIndexCompactsAfterDeletes(t *testing.T) {
+	const n = 70000
+	for _, hashed := range []bool{false, true} {
+		ms := MapStore{cache: map[interface{}]interface{}{}}
+		options := []expiring.Option{expiring.WithMaxEntries(n)}
+		if hashed {
+			options = append(options, expiring.WithHashedIndex())
+		}
+		es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, options...)
+
+		for i := 0; i < n; i++ {
+			assert.Nil(t, es.Set(strconv.Itoa(i), "value", nil))
+		}
+		full := es.Stats().Index
+		assert.Equal(t, n, full.Entries)
+		assert.Zero(t, full.Compactions)
+
+		for i := 0; i < n; i++ {
+			assert.Nil(t, es.Delete(strconv.Itoa(i)))
+		}
+		empty := es.Stats().Index
+		assert.Zero(t, empty.Entries)
+		assert.NotZero(t, empty.Compactions, "hashed %t", hashed)
+		assert.Less(t, empty.Bytes, full.Bytes/4, "hashed %t", hashed)
+	}
+}
+
+type indexKey struct {
+	Tenant string
+	ID     int
+	Score  float64
+	Owner  *string
+}
+
+func TestIndexStructKeys(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(10))
+	owner := "ada"
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, es.Set(indexKey{Tenant: "acme", ID: i, Owner: &owner}, "value", nil))
+	}
+	// equal keys are tracked as one, -0 included
+	assert.Nil(t, es.Set(indexKey{Tenant: "acme", ID: 0, Score: math.Copysign(0, -1), Owner: &owner}, "value", nil))
+	assert.Equal(t, 5, es.Stats().Index.Entries)
+
+	assert.Nil(t, es.Delete(indexKey{Tenant: "acme", ID: 1, Owner: &owner}))
+	assert.Equal(t, 4, es.Stats().Index.Entries)
+}
+
+func BenchmarkIndexStructKeys(b *testing.B) {
+	for _, hashed := range []bool{false, true} {
+		b.Run("hashed="+strconv.FormatBool(hashed), func(b *testing.B) {
+			options := []expiring.Option{expiring.WithMaxEntries(1000), expiring.WithAccessTracking()}
+			if hashed {
+				options = append(options, expiring.WithHashedIndex())
+			}
+			es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, options...)
+			keys := make([]indexKey, 100)
+			for i := range keys {
+				keys[i] = indexKey{Tenant: "acme", ID: i}
+				_ = es.Set(keys[i], "value", nil)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = es.Get(keys[i%len(keys)])
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package expiring_gocache
+
+import (
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+// Operation names a call made to the underlying store.
+type Operation string
+
+const (
+	OperationGet         Operation = "get"
+	OperationSet         Operation = "set"
+	OperationDelete      Operation = "delete"
+	OperationDeleteMulti Operation = "delete_multi"
+	OperationInvalidate  Operation = "invalidate"
+	OperationClear       Operation = "clear"
+	OperationHas         Operation = "has"
+	OperationSetMulti    Operation = "set_multi"
+	OperationTransact    Operation = "transact"
+	OperationSetCounter  Operation = "set_counter"
+	OperationDecrement   Operation = "decrement"
+	OperationIncrement   Operation = "increment"
+	OperationGetMulti    Operation = "get_multi"
+)
+
+// The inner* methods make every call to the underlying store, so that calls
+// can be observed, and keys namespaced, in one place.
+
+func (es Store) innerGet(key interface{}) (interface{}, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationGet, key, start, err)
+		return nil, err
+	}
+	var (
+		val interface{}
+		err error
+	)
+	if es.hedgeReplica != nil {
+		val, err = es.hedgedGet(es.innerKey(key))
+	} else {
+		val, err = es.store.Get(es.innerKey(key))
+	}
+	es.observe(OperationGet, key, start, err)
+	es.mirror(es.innerKey(key), val, err, time.Since(start))
+	return val, err
+}
+
+func (es Store) innerGetWithTTL(tg ttlGetter, key interface{}) (interface{}, time.Duration, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationGet, key, start, err)
+		return nil, 0, err
+	}
+	val, ttl, err := tg.GetWithTTL(es.innerKey(key))
+	es.observe(OperationGet, key, start, err)
+	es.mirror(es.innerKey(key), val, err, time.Since(start))
+	return val, ttl, err
+}
+
+func (es Store) innerSet(key interface{}, value interface{}, options *store.Options) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationSet, key, start, err)
+		return err
+	}
+	err := es.store.Set(es.innerKey(key), value, options)
+	es.observe(OperationSet, key, start, err)
+	return err
+}
+
+func (es Store) innerSetMulti(bs batchSetter, items []SetItem) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationSetMulti, nil, start, err)
+		return err
+	}
+	if es.instanceID != "" {
+		innerItems := make([]SetItem, len(items))
+		for i, item := range items {
+			item.Key = es.innerKey(item.Key)
+			innerItems[i] = item
+		}
+		items = innerItems
+	}
+	err := bs.SetMulti(items)
+	es.observe(OperationSetMulti, nil, start, err)
+	return err
+}
+
+func (es Store) innerGetMulti(bg batchGetter, keys []interface{}) (map[interface{}]interface{}, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationGetMulti, nil, start, err)
+		return nil, err
+	}
+	innerKeys := keys
+	if es.instanceID != "" {
+		innerKeys = make([]interface{}, len(keys))
+		for i, key := range keys {
+			innerKeys[i] = es.innerKey(key)
+		}
+	}
+	vals, err := bg.GetMulti(innerKeys)
+	es.observe(OperationGetMulti, nil, start, err)
+	if err != nil || es.instanceID == "" {
+		return vals, err
+	}
+	outer := make(map[interface{}]interface{}, len(vals))
+	for i, key := range keys {
+		if val, ok := vals[innerKeys[i]]; ok {
+			outer[key] = val
+		}
+	}
+	return outer, nil
+}
+
+func (es Store) innerTransact(t transactor, ops []BatchOp) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationTransact, nil, start, err)
+		return err
+	}
+	if es.instanceID != "" {
+		innerOps := make([]BatchOp, len(ops))
+		for i, op := range ops {
+			op.Key = es.innerKey(op.Key)
+			innerOps[i] = op
+		}
+		ops = innerOps
+	}
+	err := t.Transact(ops)
+	es.observe(OperationTransact, nil, start, err)
+	return err
+}
+
+func (es Store) innerSetCounter(c counter, key interface{}, value int64, expiration time.Duration) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationSetCounter, key, start, err)
+		return err
+	}
+	err := c.SetCounter(es.innerKey(key), value, expiration)
+	es.observe(OperationSetCounter, key, start, err)
+	return err
+}
+
+func (es Store) innerDecrement(c counter, key interface{}) (int64, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationDecrement, key, start, err)
+		return 0, err
+	}
+	n, err := c.Decrement(es.innerKey(key))
+	es.observe(OperationDecrement, key, start, err)
+	return n, err
+}
+
+func (es Store) innerIncrement(inc incrementer, key interface{}, delta int64, expiration time.Duration) (int64, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationIncrement, key, start, err)
+		return 0, err
+	}
+	n, err := inc.Increment(es.innerKey(key), delta, expiration)
+	es.observe(OperationIncrement, key, start, err)
+	return n, err
+}
+
+func (es Store) innerDelete(key interface{}) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationDelete, key, start, err)
+		return err
+	}
+	if es.dropDelete() {
+		return nil
+	}
+	err := es.store.Delete(es.innerKey(key))
+	es.observe(OperationDelete, key, start, err)
+	return err
+}
+
+func (es Store) innerDeleteMulti(bd batchDeleter, keys []interface{}) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationDeleteMulti, nil, start, err)
+		return err
+	}
+	if es.dropDelete() {
+		return nil
+	}
+	if es.instanceID != "" {
+		innerKeys := make([]interface{}, len(keys))
+		for i, key := range keys {
+			innerKeys[i] = es.innerKey(key)
+		}
+		keys = innerKeys
+	}
+	err := bd.DeleteMulti(keys)
+	es.observe(OperationDeleteMulti, nil, start, err)
+	return err
+}
+
+func (es Store) innerHas(hc hasChecker, key interface{}) (bool, error) {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationHas, key, start, err)
+		return false, err
+	}
+	ok, err := hc.Has(es.innerKey(key))
+	es.observe(OperationHas, key, start, err)
+	return ok, err
+}
+
+func (es Store) innerInvalidate(options store.InvalidateOptions) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationInvalidate, nil, start, err)
+		return err
+	}
+	err := es.store.Invalidate(options)
+	es.observe(OperationInvalidate, nil, start, err)
+	return err
+}
+
+func (es Store) innerClear(c clearer) error {
+	start := time.Now()
+	if err := es.injectFault(); err != nil {
+		es.observe(OperationClear, nil, start, err)
+		return err
+	}
+	err := c.Clear()
+	es.observe(OperationClear, nil, start, err)
+	return err
+}
+
+// observe records a completed call to the underlying store.
+func (es Store) observe(op Operation, key interface{}, start time.Time, err error) {
+	if err != nil {
+		es.countError(op, err)
+	}
+	if es.slowLog == nil && es.latencies == nil && es.sink == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	if es.sink != nil {
+		es.sink.Timing(MetricInnerPrefix+string(op), elapsed)
+		if err != nil {
+			es.sink.Count(MetricInnerPrefix+string(op)+MetricErrorsSuffix, 1)
+		}
+	}
+	if es.latencies != nil {
+		es.latencies[op].observe(elapsed)
+	}
+	if es.slowLog != nil && elapsed >= es.slowLog.threshold {
+		es.slowLog.record(SlowOperation{
+			Operation: op,
+			KeyHash:   keyHash(key),
+			Start:     start,
+			Duration:  elapsed,
+			Err:       err,
+			Context:   es.ctx,
+		})
+	}
+}
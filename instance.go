@@ -0,0 +1,47 @@
+package expiring_gocache
+
+import (
+	"strings"
+)
+
+type (
+	// namespacedKey is the key in the underlying store for a non-string key
+	// of a Store with an instance ID.
+	namespacedKey struct {
+		instance string
+		key      interface{}
+	}
+)
+
+// innerKey returns the key used in the underlying store for key. Keys are
+// namespaced by the Store's instance ID, if it has one: string keys are
+// prefixed with "<id>:", so they remain strings for stores which require
+// them, and other keys are paired with the ID.
+func (es Store) innerKey(key interface{}) interface{} {
+	if es.instanceID == "" {
+		return key
+	}
+	if s, ok := key.(string); ok {
+		return es.instanceID + ":" + s
+	}
+	return namespacedKey{instance: es.instanceID, key: key}
+}
+
+// outerKey reverses innerKey, reporting false for keys of the underlying
+// store which don't belong to the Store.
+func (es Store) outerKey(key interface{}) (interface{}, bool) {
+	if es.instanceID == "" {
+		return key, true
+	}
+	switch k := key.(type) {
+	case string:
+		if strings.HasPrefix(k, es.instanceID+":") {
+			return strings.TrimPrefix(k, es.instanceID+":"), true
+		}
+	case namespacedKey:
+		if k.instance == es.instanceID {
+			return k.key, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,68 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstancesShareStore(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	sessions := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithInstanceID("sessions"))
+	catalog := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithInstanceID("catalog"))
+
+	assert.Nil(t, sessions.Set("key", "session", nil))
+	assert.Nil(t, catalog.Set("key", "product", nil))
+	assert.Nil(t, catalog.Set(42, "answer", nil))
+	assert.Len(t, ms.cache, 3)
+	_, ok := ms.cache["sessions:key"]
+	assert.True(t, ok)
+
+	val, err := sessions.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "session", val)
+	val, err = catalog.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "product", val)
+	val, err = catalog.Get(42)
+	assert.Nil(t, err)
+	assert.Equal(t, "answer", val)
+	_, err = sessions.Get(42)
+	assert.Equal(t, MapStoreMiss, err)
+
+	assert.Nil(t, sessions.Delete("key"))
+	_, err = catalog.Get("key")
+	assert.Nil(t, err)
+}
+
+func TestForeignValue(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	// a Store without an ID can write a key which collides with a namespaced one
+	assert.Nil(t, expiring.New(&ms, nil).Set("sessions:key", "value", nil))
+
+	es := expiring.New(&ms, nil, expiring.WithInstanceID("sessions"))
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ForeignValueError, err)
+	_, _, err = es.GetWithTTL("key")
+	assert.Equal(t, expiring.ForeignValueError, err)
+}
+
+func TestInstanceReaperDeletesNamespacedKeys(t *testing.T) {
+	bms := BatchMapStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&bms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithClock(clk),
+		expiring.WithInstanceID("sessions"),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	awaitReaper(t, clk, func() bool { return bms.len() == 0 })
+	assert.Nil(t, es.Close())
+	assert.Empty(t, bms.cache)
+}
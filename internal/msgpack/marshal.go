@@ -0,0 +1,417 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var (
+	InvalidDataError     = errors.New("msgpack: invalid data")
+	UnsupportedTypeError = errors.New("msgpack: unsupported type")
+
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// Marshal encodes value. Booleans, numbers, strings, slices, arrays, maps
+// and pointers to them are encoded as MessagePack's own types, []byte as a
+// binary, and structs as maps of their exported fields, named by their
+// msgpack tags, if any, or else their names; fields tagged "-" are left
+// out. Values which implement encoding.BinaryMarshaler, such as time.Time,
+// are encoded as binaries. Map keys are sorted, so that equal values are
+// encoded alike.
+func Marshal(value interface{}) ([]byte, error) {
+	return encode(nil, reflect.ValueOf(value), 0)
+}
+
+// Unmarshal decodes data, as Marshal encodes it, into value, which must be a
+// non-nil pointer. Into an interface{}, integers are decoded as int64, or
+// uint64 if they don't fit, floats as float64, binaries as []byte, arrays as
+// []interface{} and maps as map[string]interface{}, or
+// map[interface{}]interface{} if any of their keys isn't a string.
+func Unmarshal(data []byte, value interface{}) error {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: %T", UnsupportedTypeError, value)
+	}
+	rest, err := decode(data, rv.Elem(), 0)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return InvalidDataError
+	}
+	return nil
+}
+
+func encode(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	if !rv.IsValid() || (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface || rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice) && rv.IsNil() {
+		return AppendNil(b), nil
+	}
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%w: nested more than %d deep", UnsupportedTypeError, MaxDepth)
+	}
+	if rv.Type().Implements(binaryMarshalerType) {
+		data, err := rv.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return AppendBin(b, data), nil
+	}
+
+	var err error
+	switch rv.Kind() {
+	case reflect.Bool:
+		return AppendBool(b, rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint(b, rv.Uint()), nil
+	case reflect.Float32:
+		return AppendFloat32(b, float32(rv.Float())), nil
+	case reflect.Float64:
+		return AppendFloat64(b, rv.Float()), nil
+	case reflect.String:
+		return AppendStr(b, rv.String()), nil
+	case reflect.Ptr, reflect.Interface:
+		return encode(b, rv.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			return AppendBin(b, data), nil
+		}
+		b = AppendArrayLen(b, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if b, err = encode(b, rv.Index(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		return encodeMap(b, rv, depth)
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		b = AppendMapLen(b, len(fields))
+		for _, f := range fields {
+			b = AppendStr(b, f.name)
+			if b, err = encode(b, rv.Field(f.index), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", UnsupportedTypeError, rv.Type())
+}
+
+// encodeMap appends the map rv with its entries sorted by their encoded
+// keys.
+func encodeMap(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	type entry struct{ key, value []byte }
+	entries := make([]entry, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := encode(nil, iter.Key(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := encode(nil, iter.Value(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+
+	b = AppendMapLen(b, len(entries))
+	for _, e := range entries {
+		b = append(append(b, e.key...), e.value...)
+	}
+	return b, nil
+}
+
+func decode(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	if depth > MaxDepth {
+		return nil, InvalidDataError
+	}
+	if rest, ok := ReadNil(b); ok {
+		rv.Set(reflect.Zero(rv.Type()))
+		return rest, nil
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() && rv.Addr().Type().Implements(binaryUnmarshalerType) {
+		data, rest, ok := ReadBytes(b)
+		if !ok {
+			return nil, InvalidDataError
+		}
+		err := rv.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(append([]byte(nil), data...))
+		return rest, err
+	}
+
+	var ok bool
+	switch rv.Kind() {
+	case reflect.Bool:
+		var v bool
+		if v, b, ok = ReadBool(b); ok {
+			rv.SetBool(v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, b, ok = ReadInt(b); ok && !rv.OverflowInt(i) {
+			rv.SetInt(i)
+		} else {
+			ok = false
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, b, ok = ReadUint(b); ok && !rv.OverflowUint(u) {
+			rv.SetUint(u)
+		} else {
+			ok = false
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, b, ok = ReadFloat(b); ok {
+			rv.SetFloat(f)
+		}
+	case reflect.String:
+		var s []byte
+		if s, b, ok = ReadBytes(b); ok {
+			rv.SetString(string(s))
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decode(b, rv.Elem(), depth+1)
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return nil, fmt.Errorf("%w: %s", UnsupportedTypeError, rv.Type())
+		}
+		v, rest, err := decodeAny(b, depth)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(v))
+		}
+		return rest, nil
+	case reflect.Slice, reflect.Array:
+		return decodeList(b, rv, depth)
+	case reflect.Map:
+		return decodeMap(b, rv, depth)
+	case reflect.Struct:
+		return decodeStruct(b, rv, depth)
+	default:
+		return nil, fmt.Errorf("%w: %s", UnsupportedTypeError, rv.Type())
+	}
+	if !ok {
+		return nil, InvalidDataError
+	}
+	return b, nil
+}
+
+func decodeList(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		data, rest, ok := ReadBytes(b)
+		if !ok || rv.Kind() == reflect.Array && len(data) != rv.Len() {
+			return nil, InvalidDataError
+		}
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), len(data), len(data)))
+		}
+		reflect.Copy(rv, reflect.ValueOf(data))
+		return rest, nil
+	}
+
+	n, b, ok := ReadArrayLen(b)
+	if !ok || n > len(b) || rv.Kind() == reflect.Array && n != rv.Len() {
+		return nil, InvalidDataError
+	}
+	if rv.Kind() == reflect.Slice {
+		rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+	}
+	var err error
+	for i := 0; i < n; i++ {
+		if b, err = decode(b, rv.Index(i), depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func decodeMap(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	n, b, ok := ReadMapLen(b)
+	if !ok || n > len(b) {
+		return nil, InvalidDataError
+	}
+	t := rv.Type()
+	rv.Set(reflect.MakeMapWithSize(t, n))
+	var err error
+	for i := 0; i < n; i++ {
+		key, value := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+		if b, err = decode(b, key, depth+1); err != nil {
+			return nil, err
+		}
+		if b, err = decode(b, value, depth+1); err != nil {
+			return nil, err
+		}
+		rv.SetMapIndex(key, value)
+	}
+	return b, nil
+}
+
+// decodeStruct decodes a map into the fields of rv, skipping entries which
+// name none.
+func decodeStruct(b []byte, rv reflect.Value, depth int) ([]byte, error) {
+	n, b, ok := ReadMapLen(b)
+	if !ok || n > len(b) {
+		return nil, InvalidDataError
+	}
+	byName := map[string]int{}
+	for _, f := range structFields(rv.Type()) {
+		byName[f.name] = f.index
+	}
+	rv.Set(reflect.Zero(rv.Type()))
+	var (
+		name []byte
+		err  error
+	)
+	for i := 0; i < n; i++ {
+		if name, b, ok = ReadBytes(b); !ok {
+			return nil, InvalidDataError
+		}
+		index, found := byName[string(name)]
+		if !found {
+			if b, ok = Skip(b, depth+1); !ok {
+				return nil, InvalidDataError
+			}
+			continue
+		}
+		if b, err = decode(b, rv.Field(index), depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// decodeAny decodes the value at the start of b into the types Unmarshal
+// decodes an interface{} into.
+func decodeAny(b []byte, depth int) (interface{}, []byte, error) {
+	if len(b) == 0 || depth > MaxDepth {
+		return nil, nil, InvalidDataError
+	}
+	code := b[0]
+	switch {
+	case code == 0xc0:
+		return nil, b[1:], nil
+	case code == 0xc2 || code == 0xc3:
+		return code == 0xc3, b[1:], nil
+	case code == 0xca || code == 0xcb:
+		f, rest, _ := ReadFloat(b)
+		return f, rest, nil
+	case code&0xe0 == 0xa0 || code >= 0xd9 && code <= 0xdb:
+		s, rest, ok := ReadBytes(b)
+		if !ok {
+			return nil, nil, InvalidDataError
+		}
+		return string(s), rest, nil
+	case code >= 0xc4 && code <= 0xc6:
+		data, rest, ok := ReadBytes(b)
+		if !ok {
+			return nil, nil, InvalidDataError
+		}
+		return append([]byte(nil), data...), rest, nil
+	case code&0xf0 == 0x90 || code == 0xdc || code == 0xdd:
+		n, rest, ok := ReadArrayLen(b)
+		if !ok || n > len(rest) {
+			return nil, nil, InvalidDataError
+		}
+		list := make([]interface{}, n)
+		var err error
+		for i := range list {
+			if list[i], rest, err = decodeAny(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return list, rest, nil
+	case code&0xf0 == 0x80 || code == 0xde || code == 0xdf:
+		return decodeAnyMap(b, depth)
+	}
+	if i, rest, ok := ReadInt(b); ok {
+		return i, rest, nil
+	}
+	if u, rest, ok := ReadUint(b); ok {
+		return u, rest, nil
+	}
+	return nil, nil, InvalidDataError
+}
+
+func decodeAnyMap(b []byte, depth int) (interface{}, []byte, error) {
+	n, b, ok := ReadMapLen(b)
+	if !ok || n > len(b) {
+		return nil, nil, InvalidDataError
+	}
+	keys, values := make([]interface{}, n), make([]interface{}, n)
+	strs := true
+	var err error
+	for i := 0; i < n; i++ {
+		if keys[i], b, err = decodeAny(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		if values[i], b, err = decodeAny(b, depth+1); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := keys[i].(string); !ok {
+			strs = false
+			if keys[i] != nil && !reflect.TypeOf(keys[i]).Comparable() {
+				return nil, nil, InvalidDataError
+			}
+		}
+	}
+	if strs {
+		m := make(map[string]interface{}, n)
+		for i, key := range keys {
+			m[key.(string)] = values[i]
+		}
+		return m, b, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, key := range keys {
+		m[key] = values[i]
+	}
+	return m, b, nil
+}
+
+type field struct {
+	name  string
+	index int
+}
+
+// structFields returns the exported fields of t, by the names they are
+// encoded with.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("msgpack"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	return fields
+}
@@ -0,0 +1,323 @@
+// Package msgpack is just enough MessagePack for the envelope, see
+// envelopeformat.go, and for codecs.Msgpack.
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// MaxDepth limits how deeply nested the values read may be.
+const MaxDepth = 32
+
+var (
+	// intSizes are the sizes of the integers following their codes, for
+	// integers which aren't fixints.
+	intSizes = map[byte]int{0xcc: 1, 0xcd: 2, 0xce: 4, 0xcf: 8, 0xd0: 1, 0xd1: 2, 0xd2: 4, 0xd3: 8}
+	// fixedSizes are the sizes of the data following the codes of nil, the
+	// booleans, the floats and the fixexts.
+	fixedSizes = map[byte]int{0xc0: 0, 0xc2: 0, 0xc3: 0, 0xca: 4, 0xcb: 8, 0xd4: 2, 0xd5: 3, 0xd6: 5, 0xd7: 9, 0xd8: 17}
+)
+
+func AppendMapLen(b []byte, n int) []byte {
+	if n < 16 {
+		return append(b, 0x80|byte(n))
+	}
+	return appendLen(b, 0xde, n)
+}
+
+func AppendArrayLen(b []byte, n int) []byte {
+	if n < 16 {
+		return append(b, 0x90|byte(n))
+	}
+	return appendLen(b, 0xdc, n)
+}
+
+// appendLen appends the 16 bit length n, with the code for a 16 bit
+// length, or else the 32 bit one, which MessagePack gives the next code.
+func appendLen(b []byte, code byte, n int) []byte {
+	if n <= math.MaxUint16 {
+		return append(b, code, byte(n>>8), byte(n))
+	}
+	return append(b, code+1, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func AppendStr(b []byte, s string) []byte {
+	switch {
+	case len(s) < 32:
+		b = append(b, 0xa0|byte(len(s)))
+	case len(s) <= math.MaxUint8:
+		b = append(b, 0xd9, byte(len(s)))
+	default:
+		b = appendLen(b, 0xda, len(s))
+	}
+	return append(b, s...)
+}
+
+func AppendBin(b []byte, v []byte) []byte {
+	if len(v) <= math.MaxUint8 {
+		b = append(b, 0xc4, byte(len(v)))
+	} else {
+		b = appendLen(b, 0xc5, len(v))
+	}
+	return append(b, v...)
+}
+
+func AppendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return AppendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return append(b, 0xd1, byte(i>>8), byte(i))
+	case i >= math.MinInt32:
+		return append(b, 0xd2, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	}
+	b = append(b, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], uint64(i))
+	return b
+}
+
+func AppendUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 128:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return append(b, 0xcd, byte(u>>8), byte(u))
+	case u <= math.MaxUint32:
+		return append(b, 0xce, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+	}
+	b = append(b, 0xcf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], u)
+	return b
+}
+
+func AppendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func AppendFloat32(b []byte, f float32) []byte {
+	b = append(b, 0xca, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], math.Float32bits(f))
+	return b
+}
+
+func AppendFloat64(b []byte, f float64) []byte {
+	b = append(b, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], math.Float64bits(f))
+	return b
+}
+
+// ReadNil reports whether b starts with nil, and returns b after it if so.
+func ReadNil(b []byte) ([]byte, bool) {
+	if len(b) > 0 && b[0] == 0xc0 {
+		return b[1:], true
+	}
+	return b, false
+}
+
+func ReadBool(b []byte) (bool, []byte, bool) {
+	if len(b) > 0 && (b[0] == 0xc2 || b[0] == 0xc3) {
+		return b[0] == 0xc3, b[1:], true
+	}
+	return false, nil, false
+}
+
+// ReadUint reads an integer in any of MessagePack's encodings, which must
+// not be negative.
+func ReadUint(b []byte) (uint64, []byte, bool) {
+	if len(b) >= 9 && b[0] == 0xcf {
+		return binary.BigEndian.Uint64(b[1:]), b[9:], true
+	}
+	i, rest, ok := ReadInt(b)
+	return uint64(i), rest, ok && i >= 0
+}
+
+// ReadFloat reads a float, or an integer, as a float64.
+func ReadFloat(b []byte) (float64, []byte, bool) {
+	switch {
+	case len(b) >= 5 && b[0] == 0xca:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b[1:]))), b[5:], true
+	case len(b) >= 9 && b[0] == 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(b[1:])), b[9:], true
+	}
+	if u, rest, ok := ReadUint(b); ok {
+		return float64(u), rest, true
+	}
+	i, rest, ok := ReadInt(b)
+	return float64(i), rest, ok
+}
+
+func ReadMapLen(b []byte) (int, []byte, bool) {
+	if len(b) > 0 && b[0]&0xf0 == 0x80 {
+		return int(b[0] & 0x0f), b[1:], true
+	}
+	return readLen(b, 0xde)
+}
+
+func ReadArrayLen(b []byte) (int, []byte, bool) {
+	if len(b) > 0 && b[0]&0xf0 == 0x90 {
+		return int(b[0] & 0x0f), b[1:], true
+	}
+	return readLen(b, 0xdc)
+}
+
+// readLen reverses appendLen.
+func readLen(b []byte, code byte) (int, []byte, bool) {
+	switch {
+	case len(b) >= 3 && b[0] == code:
+		return int(binary.BigEndian.Uint16(b[1:])), b[3:], true
+	case len(b) >= 5 && b[0] == code+1:
+		return int(binary.BigEndian.Uint32(b[1:])), b[5:], true
+	}
+	return 0, nil, false
+}
+
+// ReadInt reads an integer in any of MessagePack's encodings, which
+// must fit in an int64.
+func ReadInt(b []byte) (int64, []byte, bool) {
+	if len(b) == 0 {
+		return 0, nil, false
+	}
+	code := b[0]
+	switch {
+	case code < 0x80:
+		return int64(code), b[1:], true
+	case code >= 0xe0:
+		return int64(int8(code)), b[1:], true
+	}
+	size := intSizes[code]
+	if size == 0 || len(b) < 1+size {
+		return 0, nil, false
+	}
+	var u uint64
+	for _, c := range b[1 : 1+size] {
+		u = u<<8 | uint64(c)
+	}
+	rest := b[1+size:]
+	if code <= 0xcf {
+		return int64(u), rest, u <= math.MaxInt64
+	}
+	// sign extend
+	shift := uint(64 - 8*size)
+	return int64(u<<shift) >> shift, rest, true
+}
+
+// ReadBytes reads a string or a binary, without copying it.
+func ReadBytes(b []byte) ([]byte, []byte, bool) {
+	if len(b) == 0 {
+		return nil, nil, false
+	}
+	var n int
+	switch code := b[0]; {
+	case code&0xe0 == 0xa0:
+		n, b = int(code&0x1f), b[1:]
+	case (code == 0xd9 || code == 0xc4) && len(b) >= 2:
+		n, b = int(b[1]), b[2:]
+	case code == 0xda || code == 0xdb:
+		var ok bool
+		if n, b, ok = readLen(b, 0xda); !ok {
+			return nil, nil, false
+		}
+	case code == 0xc5 || code == 0xc6:
+		var ok bool
+		if n, b, ok = readLen(b, 0xc5); !ok {
+			return nil, nil, false
+		}
+	default:
+		return nil, nil, false
+	}
+	if n > len(b) {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
+
+func ReadStrings(b []byte) ([]string, []byte, bool) {
+	n, b, ok := ReadArrayLen(b)
+	if !ok || n > len(b) {
+		return nil, nil, false
+	}
+	strs := make([]string, n)
+	for i := range strs {
+		var s []byte
+		if s, b, ok = ReadBytes(b); !ok {
+			return nil, nil, false
+		}
+		strs[i] = string(s)
+	}
+	return strs, b, true
+}
+
+// Skip returns b after the value at its start.
+func Skip(b []byte, depth int) ([]byte, bool) {
+	if len(b) == 0 || depth > MaxDepth {
+		return nil, false
+	}
+	code := b[0]
+	if size, ok := fixedSizes[code]; ok {
+		if len(b) < 1+size {
+			return nil, false
+		}
+		return b[1+size:], true
+	}
+	if _, rest, ok := ReadInt(b); ok {
+		return rest, true
+	}
+	if code == 0xcf && len(b) >= 9 {
+		// too large for ReadInt
+		return b[9:], true
+	}
+	if _, rest, ok := ReadBytes(b); ok {
+		return rest, true
+	}
+
+	var (
+		n     int
+		ok    bool
+		elems = 1
+	)
+	switch {
+	case code&0xf0 == 0x80 || code == 0xde || code == 0xdf:
+		n, b, ok = ReadMapLen(b)
+		elems = 2
+	case code&0xf0 == 0x90 || code == 0xdc || code == 0xdd:
+		n, b, ok = ReadArrayLen(b)
+	case code >= 0xc7 && code <= 0xc9:
+		// ext 8, 16 and 32: a length, a type and the data
+		size := 1 << (code - 0xc7)
+		if len(b) < 2+size {
+			return nil, false
+		}
+		var length uint64
+		for _, c := range b[1 : 1+size] {
+			length = length<<8 | uint64(c)
+		}
+		b = b[1+size:]
+		if uint64(len(b)) < 1+length {
+			return nil, false
+		}
+		return b[1+length:], true
+	}
+	if !ok {
+		return nil, false
+	}
+	for i := 0; i < n*elems; i++ {
+		if b, ok = Skip(b, depth+1); !ok {
+			return nil, false
+		}
+	}
+	return b, true
+}
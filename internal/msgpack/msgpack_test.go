@@ -0,0 +1,80 @@
+package msgpack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nabowler/expiring_gocache/internal/msgpack"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	inner struct {
+		Values []float64
+	}
+
+	record struct {
+		Name    string `msgpack:"name"`
+		Age     uint8
+		Delta   int64
+		Big     uint64
+		Ratio   float32
+		Admin   bool
+		Data    []byte
+		Key     [2]byte
+		Tags    map[string]int
+		Inner   *inner
+		Nested  []inner
+		At      time.Time
+		Any     interface{}
+		Ignored string `msgpack:"-"`
+		private string
+	}
+)
+
+func TestRoundTrip(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	want := record{
+		Name:   "Ada",
+		Age:    36,
+		Delta:  -1 << 40,
+		Big:    1<<64 - 1,
+		Ratio:  0.5,
+		Admin:  true,
+		Data:   []byte{0, 1, 2},
+		Key:    [2]byte{3, 4},
+		Tags:   map[string]int{"a": 1, "b": -200},
+		Inner:  &inner{Values: []float64{1.5}},
+		Nested: []inner{{}, {Values: []float64{}}},
+		At:     at,
+		Any:    map[string]interface{}{"n": int64(1), "s": "s", "l": []interface{}{nil, true, 2.5, []byte("b")}},
+	}
+	data, err := msgpack.Marshal(want)
+	assert.Nil(t, err)
+
+	var got record
+	assert.Nil(t, msgpack.Unmarshal(data, &got))
+	assert.Equal(t, want, got)
+
+	// maps are encoded alike however they were built
+	again, err := msgpack.Marshal(map[string]int{"b": -200, "a": 1})
+	assert.Nil(t, err)
+	tags, err := msgpack.Marshal(want.Tags)
+	assert.Nil(t, err)
+	assert.Equal(t, tags, again)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	data, err := msgpack.Marshal(300)
+	assert.Nil(t, err)
+	var small int8
+	assert.Equal(t, msgpack.InvalidDataError, msgpack.Unmarshal(data, &small))
+	var s string
+	assert.Equal(t, msgpack.InvalidDataError, msgpack.Unmarshal(data, &s))
+	assert.Equal(t, msgpack.InvalidDataError, msgpack.Unmarshal(append(data, 0), new(int)))
+	assert.Equal(t, msgpack.InvalidDataError, msgpack.Unmarshal(data[:1], new(int)))
+	assert.Error(t, msgpack.Unmarshal(data, small))
+
+	_, err = msgpack.Marshal(make(chan int))
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"strings"
+)
+
+type (
+	keyLister interface {
+		Keys() ([]interface{}, error)
+	}
+
+	lenReporter interface {
+		Len() (int, error)
+	}
+)
+
+var (
+	UnsupportedError = errors.New("operation is not supported by the underlying store")
+)
+
+// Len returns the number of values held for the Store. Keys tracked by the
+// Store (see WithReaper, WithMaxEntries and WithAccessTracking) are counted
+// from its own index, which includes expired values not yet deleted.
+// Otherwise the underlying store must implement `Len() (int, error)` or
+// `Keys() ([]interface{}, error)`; UnsupportedError is returned if it
+// doesn't. Only Keys is used for Stores with an instance ID, as the
+// underlying store's Len would count other instances' values too.
+func (es Store) Len() (int, error) {
+	if es.tracker != nil {
+		return es.tracker.len(), nil
+	}
+	if lr, ok := es.store.(lenReporter); ok && es.instanceID == "" {
+		return lr.Len()
+	}
+	if _, ok := es.store.(keyLister); ok {
+		keys, err := es.Keys()
+		return len(keys), err
+	}
+	return 0, UnsupportedError
+}
+
+// Keys returns the keys of the values held for the Store, in no particular
+// order, from the same sources as Len, except that a hashed index (see
+// WithHashedIndex) holds no keys, so they are listed from the underlying
+// store. UnsupportedError is returned if there are none.
+func (es Store) Keys() ([]interface{}, error) {
+	if es.tracker != nil && !es.hashedIndex {
+		return es.tracker.keys(), nil
+	}
+	return es.listKeys()
+}
+
+// listKeys lists the keys of the values held for the Store from the
+// underlying store, leaving out the records kept by the Store itself.
+func (es Store) listKeys() ([]interface{}, error) {
+	kl, ok := es.store.(keyLister)
+	if !ok {
+		return nil, UnsupportedError
+	}
+	innerKeys, err := kl.Keys()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, 0, len(innerKeys))
+	for _, innerKey := range innerKeys {
+		key, ok := es.outerKey(innerKey)
+		if s, reserved := key.(string); reserved && strings.HasPrefix(s, directivePrefix) {
+			// leases and other records kept by the Store itself
+			continue
+		}
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,66 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// ListingMapStore can list its keys.
+	ListingMapStore struct {
+		MapStore
+	}
+)
+
+func TestLenAndKeysFromIndex(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking())
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set("b", "value", nil))
+	ms.cache["untracked"] = "value"
+
+	n, err := es.Len()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	keys, err := es.Keys()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []interface{}{"a", "b"}, keys)
+}
+
+func TestLenAndKeysFromStore(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, nil, expiring.WithInstanceID("one"))
+	other := expiring.New(&ms, nil, expiring.WithInstanceID("two"))
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set(1, "value", nil))
+	assert.Nil(t, other.Set("b", "value", nil))
+
+	n, err := es.Len()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	keys, err := es.Keys()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []interface{}{"a", 1}, keys)
+}
+
+func TestLenAndKeysUnsupported(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	_, err := es.Len()
+	assert.Equal(t, expiring.UnsupportedError, err)
+	_, err = es.Keys()
+	assert.Equal(t, expiring.UnsupportedError, err)
+}
+
+func (ms *ListingMapStore) Keys() ([]interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	keys := make([]interface{}, 0, len(ms.cache))
+	for key := range ms.cache {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,140 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// LatencySummary summarizes the latency of one Operation against the
+	// underlying store.
+	LatencySummary struct {
+		Count uint64
+		Sum   time.Duration
+		// P50, P95 and P99 are estimated from Buckets; each is the upper
+		// bound of the bucket the percentile falls in.
+		P50 time.Duration
+		P95 time.Duration
+		P99 time.Duration
+		// Buckets holds cumulative counts: each bucket counts the calls
+		// which took at most its UpperBound. The last bucket's UpperBound is
+		// 0, and counts every call.
+		Buckets []LatencyBucket
+	}
+
+	LatencyBucket struct {
+		UpperBound time.Duration
+		Count      uint64
+	}
+
+	// latencyHistogram counts durations into fixed buckets. Bucket i counts
+	// durations in (bounds[i-1], bounds[i]]; the final bucket counts
+	// everything longer.
+	latencyHistogram struct {
+		count  uint64
+		sum    int64
+		counts []uint64
+		bounds []time.Duration
+	}
+)
+
+// latencyBounds are the upper bounds of the histogram buckets: 50µs, doubling
+// up to about 6.5s.
+var latencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 18)
+	bound := 50 * time.Microsecond
+	for i := range bounds {
+		bounds[i] = bound
+		bound *= 2
+	}
+	return bounds
+}()
+
+// histogramOperations are the operations latency is tracked for.
+var histogramOperations = []Operation{
+	OperationGet,
+	OperationSet,
+	OperationDelete,
+	OperationDeleteMulti,
+	OperationInvalidate,
+	OperationClear,
+	OperationHas,
+	OperationSetMulti,
+	OperationTransact,
+	OperationSetCounter,
+	OperationDecrement,
+	OperationIncrement,
+	OperationGetMulti,
+}
+
+func newLatencyHistograms() map[Operation]*latencyHistogram {
+	histograms := make(map[Operation]*latencyHistogram, len(histogramOperations))
+	for _, op := range histogramOperations {
+		histograms[op] = newHistogram(latencyBounds)
+	}
+	return histograms
+}
+
+func newHistogram(bounds []time.Duration) *latencyHistogram {
+	return &latencyHistogram{counts: make([]uint64, len(bounds)+1), bounds: bounds}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddUint64(&h.count, 1)
+}
+
+func (h *latencyHistogram) summary() LatencySummary {
+	s := LatencySummary{
+		Sum:     time.Duration(atomic.LoadInt64(&h.sum)),
+		Buckets: make([]LatencyBucket, len(h.counts)),
+	}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		s.Buckets[i].Count = cumulative
+		if i < len(h.bounds) {
+			s.Buckets[i].UpperBound = h.bounds[i]
+		}
+	}
+	// count the buckets rather than using h.count, which may have moved on
+	s.Count = cumulative
+	s.P50 = s.percentile(0.50)
+	s.P95 = s.percentile(0.95)
+	s.P99 = s.percentile(0.99)
+	return s
+}
+
+// percentile returns the upper bound of the bucket holding the q-th
+// quantile. Durations beyond the last bound are reported as the last bound.
+func (s LatencySummary) percentile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Buckets) < 2 {
+		return 0
+	}
+	rank := uint64(q*float64(s.Count) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	for _, b := range s.Buckets {
+		if b.Count >= rank && b.UpperBound > 0 {
+			return b.UpperBound
+		}
+	}
+	return s.Buckets[len(s.Buckets)-2].UpperBound
+}
+
+func (es Store) latencySummaries() map[Operation]LatencySummary {
+	if es.latencies == nil {
+		return nil
+	}
+	summaries := make(map[Operation]LatencySummary, len(es.latencies))
+	for op, h := range es.latencies {
+		summaries[op] = h.summary()
+	}
+	return summaries
+}
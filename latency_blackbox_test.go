@@ -0,0 +1,41 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistograms(t *testing.T) {
+	sgs := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: 2 * time.Millisecond}
+	es := expiring.New(&sgs, nil, expiring.WithLatencyHistograms())
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	for i := 0; i < 10; i++ {
+		_, err := es.Get("key")
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, es.Delete("key"))
+
+	latencies := es.Stats().Latencies
+	get := latencies[expiring.OperationGet]
+	assert.Equal(t, uint64(10), get.Count)
+	assert.True(t, get.Sum >= 10*sgs.delay)
+	assert.True(t, get.P50 >= sgs.delay)
+	assert.True(t, get.P50 <= get.P95 && get.P95 <= get.P99)
+	assert.Equal(t, uint64(10), get.Buckets[len(get.Buckets)-1].Count)
+	assert.Equal(t, time.Duration(0), get.Buckets[len(get.Buckets)-1].UpperBound)
+
+	assert.Equal(t, uint64(1), latencies[expiring.OperationSet].Count)
+	assert.Equal(t, uint64(1), latencies[expiring.OperationDelete].Count)
+	assert.Equal(t, uint64(0), latencies[expiring.OperationClear].Count)
+	assert.Equal(t, time.Duration(0), latencies[expiring.OperationClear].P99)
+}
+
+func TestLatencyHistogramsDisabled(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	_, _ = es.Get("key")
+	assert.Nil(t, es.Stats().Latencies)
+}
@@ -0,0 +1,116 @@
+package expiring_gocache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Lease grants the right to fill a key after a miss, see GetWithLease.
+	// The zero Lease grants nothing.
+	Lease struct {
+		Token string
+	}
+
+	leaseRecord struct {
+		token string
+	}
+)
+
+var (
+	LeaseHeldError    = errors.New("another caller holds the lease for this key")
+	LeaseInvalidError = errors.New("lease is not held for this key")
+)
+
+const DefaultLeaseTTL = 10 * time.Second
+
+// GetWithLease is like Get, but on a miss or an expired value it also tries
+// to take a short lived lease on the key, stored in the underlying store so
+// that it is shared by every process using the store. The caller holding the
+// lease should compute the value and write it with SetWithLease; other
+// callers get LeaseHeldError, and should back off and retry the read rather
+// than compute the value too.
+//
+// On a hit, the zero Lease is returned. On a miss for which a lease was
+// taken, the Lease is returned along with the miss error.
+//
+// Taking a lease is a read followed by a write, so two callers racing for a
+// lease may both get one; SetWithLease then accepts only the last one taken.
+func (es Store) GetWithLease(key interface{}) (interface{}, Lease, error) {
+	val, err := es.Get(key)
+	if err == nil || isCachedError(err) {
+		return val, Lease{}, err
+	}
+
+	lk := leaseKey(key)
+	if _, ok := es.currentLease(lk); ok {
+		atomic.AddUint64(&es.stats.leaseConflicts, 1)
+		return nil, Lease{}, LeaseHeldError
+	}
+
+	lease := Lease{Token: newLeaseToken()}
+	ttl := es.leaseTTL
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	record, serr := es.wrap(wrappedValue{expireAt: es.now().Add(ttl), value: leaseRecord{token: lease.Token}, instance: es.instanceID})
+	if serr != nil {
+		return nil, Lease{}, serr
+	}
+	if serr := es.innerSet(lk, record, &store.Options{Expiration: ttl}); serr != nil {
+		return nil, Lease{}, serr
+	}
+	atomic.AddUint64(&es.stats.leasesGranted, 1)
+	return nil, lease, err
+}
+
+// SetWithLease is like Set, but only writes the value if lease is still the
+// key's current lease, and releases the lease once written. Otherwise
+// LeaseInvalidError is returned, e.g. because the lease expired and another
+// caller took one.
+func (es Store) SetWithLease(key interface{}, value interface{}, lease Lease, options *store.Options) error {
+	lk := leaseKey(key)
+	if token, ok := es.currentLease(lk); !ok || lease.Token == "" || token != lease.Token {
+		return LeaseInvalidError
+	}
+	if err := es.Set(key, value, options); err != nil {
+		return err
+	}
+	_ = es.innerDelete(lk) // the lease will expire anyway
+	return nil
+}
+
+// currentLease returns the token of the unexpired lease stored at lk, if
+// there is one.
+func (es Store) currentLease(lk string) (string, bool) {
+	val, err := es.innerGet(lk)
+	if err != nil {
+		return "", false
+	}
+	ew, ok := unwrap(val)
+	if !ok || es.isExpired(ew, es.now()) {
+		return "", false
+	}
+	record, ok := ew.value.(leaseRecord)
+	return record.token, ok
+}
+
+func leaseKey(key interface{}) string {
+	return fmt.Sprintf("%slease:%v", directivePrefix, key)
+}
+
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms; fall back to a
+		// token that is at least unique within this process
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,57 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeases(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+	// another process sharing the store
+	other := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	_, lease, err := es.GetWithLease("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.NotEqual(t, "", lease.Token)
+
+	// the other process must back off, and can't fill the key
+	_, otherLease, err := other.GetWithLease("key")
+	assert.Equal(t, expiring.LeaseHeldError, err)
+	assert.Equal(t, expiring.Lease{}, otherLease)
+	assert.Equal(t, expiring.LeaseInvalidError, other.SetWithLease("key", "other", otherLease, nil))
+
+	assert.Nil(t, es.SetWithLease("key", "value", lease, nil))
+	// the lease was released
+	assert.Equal(t, expiring.LeaseInvalidError, es.SetWithLease("key", "value", lease, nil))
+
+	val, otherLease, err := other.GetWithLease("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, expiring.Lease{}, otherLease)
+
+	assert.Equal(t, uint64(1), es.Stats().LeasesGranted)
+	assert.Equal(t, uint64(1), other.Stats().LeaseConflicts)
+}
+
+func TestLeaseExpires(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithLeaseTTL(10*time.Millisecond), expiring.WithClock(clk))
+
+	_, lease, err := es.GetWithLease("key")
+	assert.Equal(t, MapStoreMiss, err)
+	clk.Advance(20 * time.Millisecond)
+
+	// the lease lapsed, so a new one can be taken, and the old one is void
+	_, newLease, err := es.GetWithLease("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.NotEqual(t, lease, newLease)
+	assert.Equal(t, expiring.LeaseInvalidError, es.SetWithLease("key", "value", lease, nil))
+	assert.Nil(t, es.SetWithLease("key", "value", newLease, nil))
+}
@@ -0,0 +1,59 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+// MemoizedFunc is a function of a key whose results can be cached, see
+// Memoize.
+type MemoizedFunc func(ctx context.Context, key interface{}) (interface{}, error)
+
+// Memoize returns a version of f which caches its results in the Store,
+// keyed by its argument, for ttl, or the Store's default expiration if ttl
+// is 0. Results implementing TTLer or ExpireAter set their own TTL instead,
+// so that it can be overridden per key. Concurrent calls for a key which
+// isn't cached share one call of f. Errors from f are returned, not cached.
+//
+// Results are returned as f returned them, and callers type assert them:
+//
+//	getUser := es.Memoize(func(ctx context.Context, key interface{}) (interface{}, error) {
+//		return db.LoadUser(ctx, key.(string))
+//	}, time.Minute)
+//	v, err := getUser(ctx, "42")
+//	user := v.(*User)
+func (es Store) Memoize(f MemoizedFunc, ttl time.Duration) MemoizedFunc {
+	calls := &fetchGroup{calls: map[interface{}]*fetchCall{}}
+	return func(ctx context.Context, key interface{}) (interface{}, error) {
+		if val, err := es.GetWithContext(ctx, key); err == nil || isCachedError(err) {
+			return val, err
+		}
+		return calls.do(ctx, key, func() (interface{}, error) {
+			var val interface{}
+			err := es.guard("memoized function", func() (err error) {
+				val, err = f(ctx, key)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			var options *store.Options
+			if ttl > 0 && !declaresTTL(val) {
+				options = &store.Options{Expiration: ttl}
+			}
+			_ = es.SetWithContext(ctx, key, val, options) // best effort
+			return val, nil
+		})
+	}
+}
+
+// declaresTTL reports whether val sets its own TTL.
+func declaresTTL(val interface{}) bool {
+	if v, ok := val.(TTLer); ok && v.CacheTTL() > 0 {
+		return true
+	}
+	v, ok := val.(ExpireAter)
+	return ok && !v.CacheExpireAt().IsZero()
+}
@@ -0,0 +1,93 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoize(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	var calls int32
+	square := es.Memoize(func(ctx context.Context, key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		n := key.(int)
+		return n * n, nil
+	}, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		v, err := square(context.Background(), 3)
+		assert.Nil(t, err)
+		assert.Equal(t, 9, v)
+	}
+	assert.Equal(t, int32(1), calls)
+	_, ttl, err := es.GetWithTTL(3)
+	assert.Nil(t, err)
+	assert.True(t, ttl > 59*time.Second && ttl <= time.Minute)
+}
+
+func TestMemoizeTTLOverride(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	f := es.Memoize(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return freshFor(time.Second), nil
+	}, time.Minute)
+	_, err := f(context.Background(), "key")
+	assert.Nil(t, err)
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl <= time.Second)
+
+	// a value declaring a time but no TTL declares its TTL too
+	f = es.Memoize(func(ctx context.Context, key interface{}) (interface{}, error) {
+		return freshBoth{at: time.Now().Add(time.Second)}, nil
+	}, time.Minute)
+	_, err = f(context.Background(), "both")
+	assert.Nil(t, err)
+	_, ttl, err = es.GetWithTTL("both")
+	assert.Nil(t, err)
+	assert.True(t, ttl <= time.Second)
+}
+
+func TestMemoizeCoalescesAndSkipsErrors(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	var calls int32
+	release := make(chan struct{})
+	failure := errors.New("unavailable")
+	f := es.Memoize(func(ctx context.Context, key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil, failure
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f(context.Background(), "key")
+			assert.Equal(t, failure, err)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.True(t, atomic.LoadInt32(&calls) < 5)
+
+	// the error wasn't cached
+	_, err := f(context.Background(), "key")
+	assert.Equal(t, failure, err)
+	assert.Empty(t, ms.cache)
+}
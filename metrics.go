@@ -0,0 +1,42 @@
+package expiring_gocache
+
+import (
+	"time"
+)
+
+type (
+	// MetricsSink receives metrics pushed by a Store as they happen, for
+	// observability stacks which aren't pull based, see WithMetricsSink.
+	// Implementations must be safe for concurrent use, and should not block.
+	MetricsSink interface {
+		// Count adds delta to the counter name.
+		Count(name string, delta int64)
+		// Timing records one duration of name.
+		Timing(name string, d time.Duration)
+	}
+)
+
+// The metrics pushed to a MetricsSink. Per-operation metrics are named with
+// the Operation, e.g. "inner.get".
+const (
+	// MetricInnerPrefix times each call to the underlying store.
+	MetricInnerPrefix = "inner."
+	// MetricErrorsSuffix, added to a call's name, counts its errors.
+	MetricErrorsSuffix = ".errors"
+	// MetricHits counts reads which found an unexpired value.
+	MetricHits = "reads.hit"
+	// MetricExpired counts reads which found an expired value.
+	MetricExpired = "reads.expired"
+	// MetricEvictions counts values deleted because the Store was over
+	// capacity.
+	MetricEvictions = "evictions"
+	// MetricWorkerRestarts counts background workers restarted after they
+	// failed, see Store.Health.
+	MetricWorkerRestarts = "workers.restarts"
+)
+
+func (es Store) count(name string, delta int64) {
+	if es.sink != nil {
+		es.sink.Count(name, delta)
+	}
+}
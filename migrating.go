@@ -0,0 +1,176 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// MigratingStore is a store.StoreInterface which moves a cache from an
+	// old backend to a new one without a cold start. It reads the new store
+	// before the old one, and writes both until the cutover, after which only
+	// the new store is written. Deletes are applied to both throughout, so
+	// that a deleted value can't be read back from the old store.
+	MigratingStore struct {
+		from, to  store.StoreInterface
+		clock     clock.Clock
+		cutover   time.Time
+		backfill  bool
+		backfills *uint64
+	}
+
+	// MigratingStoreOption configures a MigratingStore.
+	MigratingStoreOption func(*MigratingStore)
+)
+
+const MigratingStoreType = "migrating"
+
+// NewMigrating migrates from the old store to the new one, reading the new
+// store first and writing both.
+func NewMigrating(from, to store.StoreInterface, opts ...MigratingStoreOption) *MigratingStore {
+	ms := &MigratingStore{from: from, to: to, clock: clock.Real{}, backfills: new(uint64)}
+	for _, opt := range opts {
+		opt(ms)
+	}
+	return ms
+}
+
+// MigratingCutover stops writes to the old store from at on. Reads still
+// fall back to it, until its values expire.
+func MigratingCutover(at time.Time) MigratingStoreOption {
+	return func(ms *MigratingStore) {
+		ms.cutover = at
+	}
+}
+
+// MigratingBackfill writes values read from the old store into the new one,
+// with the time they have left as their native expiration, so that the new
+// store is warmed by the traffic it serves.
+func MigratingBackfill() MigratingStoreOption {
+	return func(ms *MigratingStore) {
+		ms.backfill = true
+	}
+}
+
+// MigratingClock sets the clock the MigratingStore reads the time from, for
+// the cutover and when working out what is left of a value's expiration. It
+// should be the same clock as the Store wrapping it. Defaults to clock.Real.
+func MigratingClock(c clock.Clock) MigratingStoreOption {
+	return func(ms *MigratingStore) {
+		ms.clock = c
+	}
+}
+
+// Get returns the value from the new store, or else from the old one.
+func (ms *MigratingStore) Get(key interface{}) (interface{}, error) {
+	val, _, err := ms.GetWithTTL(key)
+	return val, err
+}
+
+// GetWithTTL is like Get, also returning the value's remaining TTL, or 0 if
+// it isn't known. Old store hits which haven't expired are backfilled, if
+// MigratingBackfill was given.
+func (ms *MigratingStore) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	val, ttl, err := getWithTTL(ms.to, key)
+	if err == nil {
+		return val, ttl, nil
+	}
+	val, native, err := getWithTTL(ms.from, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ttl, expired := remainingTTL(val, native, ms.clock.Now())
+	if ms.backfill && !expired {
+		var options *store.Options
+		if ttl > 0 {
+			options = &store.Options{Expiration: ttl}
+		}
+		if ms.to.Set(key, val, options) == nil { // best effort backfill
+			atomic.AddUint64(ms.backfills, 1)
+		}
+	}
+	return val, ttl, nil
+}
+
+// Backfills returns how many values read from the old store have been
+// written to the new one.
+func (ms *MigratingStore) Backfills() uint64 {
+	return atomic.LoadUint64(ms.backfills)
+}
+
+// Set writes the value to the new store, then, before the cutover, the old
+// one. Values wrapped by a Store are written with the time they have left
+// as their native expiration.
+func (ms *MigratingStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	now := ms.clock.Now()
+	if ew, _, ok := unwrapHeader(value); ok {
+		options = withNativeExpiration(options, ew.expireAt.Sub(now))
+	}
+	if err := ms.to.Set(key, value, options); err != nil {
+		return err
+	}
+	if ms.cutOver(now) {
+		return nil
+	}
+	return ms.from.Set(key, value, options)
+}
+
+// Delete deletes the value from the old store, then the new one, so that a
+// concurrent Get can't backfill the deleted value.
+func (ms *MigratingStore) Delete(key interface{}) error {
+	if err := ms.from.Delete(key); err != nil {
+		return err
+	}
+	return ms.to.Delete(key)
+}
+
+// DeleteMulti deletes keys from the old store, then the new one, in one call
+// for stores which implement DeleteMulti themselves.
+func (ms *MigratingStore) DeleteMulti(keys []interface{}) error {
+	for _, s := range []store.StoreInterface{ms.from, ms.to} {
+		if bd, ok := s.(batchDeleter); ok {
+			if err := bd.DeleteMulti(keys); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, key := range keys {
+			if err := s.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *MigratingStore) Invalidate(options store.InvalidateOptions) error {
+	if err := ms.from.Invalidate(options); err != nil {
+		return err
+	}
+	return ms.to.Invalidate(options)
+}
+
+// Clear clears both stores, if they support it.
+func (ms *MigratingStore) Clear() error {
+	for _, s := range []store.StoreInterface{ms.from, ms.to} {
+		if c, ok := s.(clearer); ok {
+			if err := c.Clear(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *MigratingStore) GetType() string {
+	return MigratingStoreType
+}
+
+// cutOver reports whether the cutover has passed at now.
+func (ms *MigratingStore) cutOver(now time.Time) bool {
+	return !ms.cutover.IsZero() && !now.Before(ms.cutover)
+}
@@ -0,0 +1,78 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigratingBackfillsWithRemainingTTL(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	from := MapStore{cache: map[interface{}]interface{}{}}
+	to := MapStore{cache: map[interface{}]interface{}{}}
+	old := expiring.New(&from, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	assert.Nil(t, old.Set("key", "value", nil))
+
+	ms := expiring.NewMigrating(&from, &to, expiring.MigratingBackfill(), expiring.MigratingClock(clk))
+	es := expiring.New(ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	clk.Advance(20 * time.Minute)
+
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 40*time.Minute, ttl)
+	assert.Equal(t, 1, to.setCount)
+	assert.Equal(t, 40*time.Minute, to.lastSetOptions.ExpirationValue())
+	assert.Equal(t, uint64(1), ms.Backfills())
+
+	// served from the new store from now on
+	_, err = es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, from.getCount)
+}
+
+func TestMigratingExpiredNotBackfilled(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	from := MapStore{cache: map[interface{}]interface{}{}}
+	to := MapStore{cache: map[interface{}]interface{}{}}
+	old := expiring.New(&from, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk))
+	assert.Nil(t, old.Set("key", "value", nil))
+
+	ms := expiring.NewMigrating(&from, &to, expiring.MigratingBackfill(), expiring.MigratingClock(clk))
+	es := expiring.New(ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	clk.Advance(2 * time.Minute)
+
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 0, to.setCount)
+	_, ok := from.cache["key"]
+	assert.False(t, ok)
+}
+
+func TestMigratingCutover(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	from := MapStore{cache: map[interface{}]interface{}{}}
+	to := MapStore{cache: map[interface{}]interface{}{}}
+	ms := expiring.NewMigrating(&from, &to, expiring.MigratingCutover(clk.Now().Add(time.Hour)), expiring.MigratingClock(clk))
+	es := expiring.New(ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+
+	// dual writes until the cutover
+	assert.Nil(t, es.Set("before", "value", nil))
+	assert.Equal(t, 1, from.setCount)
+	assert.Equal(t, 1, to.setCount)
+
+	clk.Advance(time.Hour)
+	assert.Nil(t, es.Set("after", "value", nil))
+	assert.Equal(t, 1, from.setCount)
+	assert.Equal(t, 2, to.setCount)
+
+	// deletes still reach both
+	assert.Nil(t, es.Delete("before"))
+	assert.Equal(t, 1, from.deleteCount)
+	assert.Equal(t, 1, to.deleteCount)
+	assert.Equal(t, uint64(0), ms.Backfills())
+}
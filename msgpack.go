@@ -0,0 +1,105 @@
+package expiring_gocache
+
+import "github.com/nabowler/expiring_gocache/internal/msgpack"
+
+// The envelope as a MessagePack map, see envelopeformat.go. Fields of later
+// versions are skipped when read.
+
+// appendMsgpackEnvelope appends env to b as a MessagePack map.
+func appendMsgpackEnvelope(b []byte, env Envelope) []byte {
+	je := jsonEnvelopeOf(env)
+	fields := 4
+	for _, set := range []bool{je.Timestamp != 0, je.CreatedAt != 0, je.ETag != "", je.Instance != "", len(je.Tags) > 0, je.Generation != 0, je.MaxReads != 0} {
+		if set {
+			fields++
+		}
+	}
+	b = msgpack.AppendMapLen(b, fields)
+	b = msgpack.AppendInt(msgpack.AppendStr(b, "v"), int64(je.Version))
+	b = msgpack.AppendStr(msgpack.AppendStr(b, "kind"), string(je.Kind))
+	b = msgpack.AppendInt(msgpack.AppendStr(b, "expireAt"), je.ExpireAt)
+	if je.Timestamp != 0 {
+		b = msgpack.AppendInt(msgpack.AppendStr(b, "timestamp"), je.Timestamp)
+	}
+	if je.CreatedAt != 0 {
+		b = msgpack.AppendInt(msgpack.AppendStr(b, "createdAt"), je.CreatedAt)
+	}
+	if je.ETag != "" {
+		b = msgpack.AppendStr(msgpack.AppendStr(b, "etag"), je.ETag)
+	}
+	if je.Instance != "" {
+		b = msgpack.AppendStr(msgpack.AppendStr(b, "instance"), je.Instance)
+	}
+	if len(je.Tags) > 0 {
+		b = msgpack.AppendArrayLen(msgpack.AppendStr(b, "tags"), len(je.Tags))
+		for _, tag := range je.Tags {
+			b = msgpack.AppendStr(b, tag)
+		}
+	}
+	if je.Generation != 0 {
+		b = msgpack.AppendUint(msgpack.AppendStr(b, "generation"), je.Generation)
+	}
+	if je.MaxReads != 0 {
+		b = msgpack.AppendUint(msgpack.AppendStr(b, "maxReads"), je.MaxReads)
+	}
+	b = msgpack.AppendStr(b, "value")
+	if binaryKind(env.Kind) {
+		return msgpack.AppendBin(b, env.Value)
+	}
+	return msgpack.AppendStr(b, string(env.Value))
+}
+
+func decodeMsgpackEnvelope(b []byte) (Envelope, bool) {
+	n, b, ok := msgpack.ReadMapLen(b)
+	if !ok {
+		return Envelope{}, false
+	}
+	var (
+		je    jsonEnvelope
+		value []byte
+		key   []byte
+		i     int64
+	)
+	for ; n > 0; n-- {
+		if key, b, ok = msgpack.ReadBytes(b); !ok {
+			return Envelope{}, false
+		}
+		switch string(key) {
+		case "v":
+			i, b, ok = msgpack.ReadInt(b)
+			je.Version = int(i)
+		case "kind":
+			key, b, ok = msgpack.ReadBytes(b)
+			je.Kind = EnvelopeKind(key)
+		case "expireAt":
+			je.ExpireAt, b, ok = msgpack.ReadInt(b)
+		case "timestamp":
+			je.Timestamp, b, ok = msgpack.ReadInt(b)
+		case "createdAt":
+			je.CreatedAt, b, ok = msgpack.ReadInt(b)
+		case "etag":
+			key, b, ok = msgpack.ReadBytes(b)
+			je.ETag = string(key)
+		case "instance":
+			key, b, ok = msgpack.ReadBytes(b)
+			je.Instance = string(key)
+		case "tags":
+			je.Tags, b, ok = msgpack.ReadStrings(b)
+		case "generation":
+			i, b, ok = msgpack.ReadInt(b)
+			je.Generation, ok = uint64(i), ok && i >= 0
+		case "maxReads":
+			i, b, ok = msgpack.ReadInt(b)
+			je.MaxReads, ok = uint64(i), ok && i >= 0
+		case "value":
+			value, b, ok = msgpack.ReadBytes(b)
+		default:
+			// a field of a later version
+			b, ok = msgpack.Skip(b, 0)
+		}
+		if !ok {
+			return Envelope{}, false
+		}
+	}
+	return je.envelope(value)
+}
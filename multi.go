@@ -0,0 +1,91 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// SetItem is one value written by SetMulti.
+	SetItem struct {
+		Key     interface{}
+		Value   interface{}
+		Options *store.Options
+	}
+
+	// batchSetter is implemented by stores which can write many values in
+	// one round trip, e.g. with a Redis pipeline.
+	batchSetter interface {
+		SetMulti(items []SetItem) error
+	}
+
+	// preparedSet is a Set ready to be written to the underlying store.
+	preparedSet struct {
+		item      SetItem
+		value     interface{}
+		wrapped   bool
+		expireAt  time.Time
+		priority  Priority
+		dependsOn []interface{}
+		maxReads  uint64
+		cost      time.Duration
+	}
+)
+
+// SetMulti is like calling Set for each item, but if the underlying store
+// implements `SetMulti(items []SetItem) error` every value is written to it
+// in a single call. The values passed on are wrapped, and their keys
+// namespaced, as with Set. If any item can't be written, e.g. because its key
+// is not cacheable, its error is returned and nothing is written.
+//
+// If WithWriteThrough was given, each value is also written to the sink.
+func (es Store) SetMulti(items []SetItem) error {
+	ops := make([]sinkOp, len(items))
+	for i, item := range items {
+		ops[i] = sinkOp{op: OperationSet, key: item.Key, value: item.Value, ttl: ttlFor(item.Options, es.defaultTTL(item.Key, item.Value))}
+	}
+	return es.through(context.Background(), ops, func() error {
+		return es.setMulti(items)
+	})
+}
+
+func (es Store) setMulti(items []SetItem) error {
+	prepared := make([]preparedSet, 0, len(items))
+	for _, item := range items {
+		ttl := ttlFor(item.Options, es.defaultTTL(item.Key, item.Value))
+		p, ok, err := es.prepareSet(item.Key, item.Value, item.Options, ttl, time.Time{})
+		if err != nil {
+			return err
+		}
+		if ok {
+			prepared = append(prepared, p)
+		}
+	}
+	if len(prepared) == 0 {
+		return nil
+	}
+
+	if bs, ok := es.store.(batchSetter); ok {
+		batch := make([]SetItem, len(prepared))
+		for i, p := range prepared {
+			batch[i] = p.item
+		}
+		if err := es.innerSetMulti(bs, batch); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range prepared {
+			if err := es.innerSet(p.item.Key, p.item.Value, p.item.Options); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range prepared {
+		es.setDone(p)
+	}
+	es.evict()
+	return nil
+}
@@ -0,0 +1,63 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// SetMultiMapStore records batched writes.
+	SetMultiMapStore struct {
+		MapStore
+		batches [][]expiring.SetItem
+	}
+)
+
+func TestSetMulti(t *testing.T) {
+	ms := SetMultiMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithInstanceID("id"))
+
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{
+		{Key: "a", Value: "value a"},
+		{Key: "b", Value: "value b", Options: &store.Options{Expiration: time.Minute}},
+	}))
+	assert.Len(t, ms.batches, 1)
+	assert.Equal(t, 0, ms.setCount)
+	assert.Equal(t, "id:a", ms.batches[0][0].Key)
+
+	val, ttl, err := es.GetWithTTL("b")
+	assert.Nil(t, err)
+	assert.Equal(t, "value b", val)
+	assert.True(t, ttl > 59*time.Second && ttl <= time.Minute)
+	val, err = es.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, "value a", val)
+}
+
+func TestSetMultiWithoutBatching(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithDeniedKeys(func(key interface{}) bool { return key == "denied" }),
+		expiring.WithSuppressedSetErrors(),
+	)
+
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{{Key: "a", Value: "value a"}, {Key: "b", Value: "value b"}}))
+	assert.Equal(t, 2, ms.setCount)
+
+	assert.Equal(t, expiring.KeyNotCacheableError, es.SetMulti([]expiring.SetItem{{Key: "c", Value: "value"}, {Key: "denied", Value: "value"}}))
+	assert.Equal(t, 2, ms.setCount)
+}
+
+func (ms *SetMultiMapStore) SetMulti(items []expiring.SetItem) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.batches = append(ms.batches, items)
+	for _, item := range items {
+		ms.cache[item.Key] = item.Value
+	}
+	return nil
+}
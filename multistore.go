@@ -0,0 +1,274 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// MultiStore is a store.StoreInterface which replicates writes to several
+	// stores, e.g. paired clusters in different zones, and reads from the
+	// first which has the value. Wrap it with New to expire values across
+	// all the replicas alike.
+	MultiStore struct {
+		stores    []store.StoreInterface
+		async     bool
+		onError   func(index int, err error)
+		queueSize int
+		// queues hold each store's pending asynchronous writes, which its
+		// worker makes in order.
+		queues []chan func(store.StoreInterface) error
+		mu     *sync.RWMutex
+		closed bool
+		wg     *sync.WaitGroup
+	}
+
+	// MultiStoreOption configures a MultiStore.
+	MultiStoreOption func(*MultiStore)
+
+	// MultiStoreError holds the errors of the stores a replicated operation
+	// failed on, by the stores' indexes.
+	MultiStoreError map[int]error
+)
+
+const (
+	MultiStoreType = "multi"
+
+	DefaultMultiStoreQueueSize = 1024
+)
+
+var (
+	NoStoresError            = errors.New("multi store has no stores")
+	MultiStoreQueueFullError = errors.New("multi store queue is full")
+	MultiStoreClosedError    = errors.New("multi store is closed")
+)
+
+// NewMultiStore replicates to stores, in order. Get reads the stores in
+// order and returns the first hit.
+func NewMultiStore(stores []store.StoreInterface, opts ...MultiStoreOption) *MultiStore {
+	ms := &MultiStore{stores: stores, queueSize: DefaultMultiStoreQueueSize, mu: &sync.RWMutex{}, wg: &sync.WaitGroup{}}
+	for _, opt := range opts {
+		opt(ms)
+	}
+	if ms.async {
+		ms.queues = make([]chan func(store.StoreInterface) error, len(stores))
+		for i := range stores {
+			ms.queues[i] = make(chan func(store.StoreInterface) error, ms.queueSize)
+			ms.wg.Add(1)
+			go ms.drain(i)
+		}
+	}
+	return ms
+}
+
+// MultiStoreAsync makes writes (Set, Delete, Invalidate and Clear) return
+// immediately, replicating them in the background. Each store is given the
+// writes in the order they were made, by a goroutine of its own. Errors are
+// passed to onError, if it is not nil, with the index of the store which
+// failed, as are writes which are dropped: with MultiStoreQueueFullError
+// when the store's queue is full, see MultiStoreQueueSize, or
+// MultiStoreClosedError after Close. Call Close to wait for pending writes.
+func MultiStoreAsync(onError func(index int, err error)) MultiStoreOption {
+	return func(ms *MultiStore) {
+		ms.async = true
+		ms.onError = onError
+	}
+}
+
+// MultiStoreQueueSize sets how many asynchronous writes may be pending for
+// each store before further writes to it are dropped, instead of
+// DefaultMultiStoreQueueSize.
+func MultiStoreQueueSize(size int) MultiStoreOption {
+	return func(ms *MultiStore) {
+		if size > 0 {
+			ms.queueSize = size
+		}
+	}
+}
+
+// Get returns the value from the first store which has it. If every store
+// fails, the last store's error is returned.
+func (ms *MultiStore) Get(key interface{}) (interface{}, error) {
+	var err error
+	for _, s := range ms.stores {
+		var val interface{}
+		val, err = s.Get(key)
+		if err == nil {
+			return val, nil
+		}
+	}
+	if err == nil {
+		err = NoStoresError
+	}
+	return nil, err
+}
+
+func (ms *MultiStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		return s.Set(key, value, options)
+	})
+}
+
+func (ms *MultiStore) Delete(key interface{}) error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		return s.Delete(key)
+	})
+}
+
+// DeleteMulti deletes keys from every store, in one call for stores which
+// implement DeleteMulti themselves.
+func (ms *MultiStore) DeleteMulti(keys []interface{}) error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		if bd, ok := s.(batchDeleter); ok {
+			return bd.DeleteMulti(keys)
+		}
+		for _, key := range keys {
+			if err := s.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetMulti writes items to every store, in one call for stores which
+// implement SetMulti themselves.
+func (ms *MultiStore) SetMulti(items []SetItem) error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		if bs, ok := s.(batchSetter); ok {
+			return bs.SetMulti(items)
+		}
+		for _, item := range items {
+			if err := s.Set(item.Key, item.Value, item.Options); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ms *MultiStore) Invalidate(options store.InvalidateOptions) error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		return s.Invalidate(options)
+	})
+}
+
+// Clear clears every store which supports it.
+func (ms *MultiStore) Clear() error {
+	return ms.replicate(func(s store.StoreInterface) error {
+		if c, ok := s.(clearer); ok {
+			return c.Clear()
+		}
+		return nil
+	})
+}
+
+func (ms *MultiStore) GetType() string {
+	return MultiStoreType
+}
+
+// Close waits for pending asynchronous writes, after which further writes
+// are dropped. The stores are not closed.
+func (ms *MultiStore) Close() error {
+	ms.mu.Lock()
+	if !ms.closed {
+		ms.closed = true
+		for _, queue := range ms.queues {
+			close(queue)
+		}
+	}
+	ms.mu.Unlock()
+	ms.wg.Wait()
+	return nil
+}
+
+// replicate runs op against every store. Synchronously, it runs them
+// concurrently, waits and returns a MultiStoreError if any store failed;
+// asynchronously, it queues op for each store, reports failures and dropped
+// writes to onError and returns nil.
+func (ms *MultiStore) replicate(op func(store.StoreInterface) error) error {
+	if ms.async {
+		ms.enqueue(op)
+		return nil
+	}
+
+	var (
+		mu   sync.Mutex
+		errs MultiStoreError
+		wg   sync.WaitGroup
+	)
+	for i, s := range ms.stores {
+		wg.Add(1)
+		go func(i int, s store.StoreInterface) {
+			defer wg.Done()
+			err := op(s)
+			if err == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if errs == nil {
+				errs = MultiStoreError{}
+			}
+			errs[i] = err
+		}(i, s)
+	}
+	wg.Wait()
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// enqueue queues op on every store's queue, dropping it from those which are
+// full.
+func (ms *MultiStore) enqueue(op func(store.StoreInterface) error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for i, queue := range ms.queues {
+		if ms.closed {
+			ms.failed(i, MultiStoreClosedError)
+			continue
+		}
+		select {
+		case queue <- op:
+		default:
+			ms.failed(i, MultiStoreQueueFullError)
+		}
+	}
+}
+
+// drain makes the i'th store's queued writes until its queue is closed.
+func (ms *MultiStore) drain(i int) {
+	defer ms.wg.Done()
+	for op := range ms.queues[i] {
+		if err := op(ms.stores[i]); err != nil {
+			ms.failed(i, err)
+		}
+	}
+}
+
+func (ms *MultiStore) failed(i int, err error) {
+	if ms.onError != nil {
+		ms.onError(i, err)
+	}
+}
+
+func (e MultiStoreError) Error() string {
+	indexes := make([]int, 0, len(e))
+	for i := range e {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	msgs := make([]string, len(indexes))
+	for n, i := range indexes {
+		msgs[n] = fmt.Sprintf("store %d: %v", i, e[i])
+	}
+	return fmt.Sprintf("%d stores failed: %s", len(e), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,144 @@
+package expiring_gocache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiStoreReplicatesWrites(t *testing.T) {
+	a := MapStore{cache: map[interface{}]interface{}{}}
+	b := MapStore{cache: map[interface{}]interface{}{}}
+	multi := expiring.NewMultiStore([]store.StoreInterface{&a, &b})
+	es := expiring.New(multi, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, 1, a.setCount)
+	assert.Equal(t, 1, b.setCount)
+
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 1, a.getCount)
+	assert.Equal(t, 0, b.getCount)
+
+	assert.Nil(t, es.Delete("key"))
+	assert.Empty(t, a.cache)
+	assert.Empty(t, b.cache)
+
+	assert.Nil(t, es.Clear())
+	assert.Equal(t, 1, a.clearCount)
+	assert.Equal(t, 1, b.clearCount)
+	assert.Equal(t, expiring.MultiStoreType, multi.GetType())
+}
+
+func TestMultiStoreReadsFirstHit(t *testing.T) {
+	a := MapStore{cache: map[interface{}]interface{}{}}
+	b := MapStore{cache: map[interface{}]interface{}{"key": "b"}}
+	multi := expiring.NewMultiStore([]store.StoreInterface{&a, &b})
+
+	val, err := multi.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", val)
+
+	_, err = multi.Get("missing")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestMultiStoreAggregatesErrors(t *testing.T) {
+	a := MapStore{cache: map[interface{}]interface{}{}}
+	b := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 1}
+	multi := expiring.NewMultiStore([]store.StoreInterface{&a, &b})
+
+	err := multi.Delete("key")
+	assert.Equal(t, expiring.MultiStoreError{1: FailingDeleteError}, err)
+	assert.Equal(t, "1 stores failed: store 1: delete failed", err.Error())
+	assert.Equal(t, 1, a.deleteCount)
+}
+
+func TestMultiStoreAsync(t *testing.T) {
+	a := MapStore{cache: map[interface{}]interface{}{}}
+	b := FailingDeleteStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, failures: 1}
+	var (
+		mu     sync.Mutex
+		failed []int
+	)
+	multi := expiring.NewMultiStore([]store.StoreInterface{&a, &b}, expiring.MultiStoreAsync(func(index int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, FailingDeleteError, err)
+		failed = append(failed, index)
+	}))
+
+	assert.Nil(t, multi.Set("key", "value", nil))
+	assert.Nil(t, multi.Delete("key"))
+	assert.Nil(t, multi.Close())
+
+	assert.Equal(t, []int{1}, failed)
+	assert.Equal(t, 1, a.setCount)
+	assert.Equal(t, 1, b.setCount)
+}
+
+// GatedStore is a MapStore whose Sets wait for gate to be closed, after
+// sending to entered, if it isn't nil.
+type GatedStore struct {
+	*MapStore
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func (gs GatedStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	if gs.entered != nil {
+		gs.entered <- struct{}{}
+	}
+	<-gs.gate
+	return gs.MapStore.Set(key, value, options)
+}
+
+func TestMultiStoreAsyncKeepsOrder(t *testing.T) {
+	a := MapStore{cache: map[interface{}]interface{}{}}
+	b := GatedStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, gate: make(chan struct{})}
+	multi := expiring.NewMultiStore([]store.StoreInterface{&a, b}, expiring.MultiStoreAsync(nil))
+
+	assert.Nil(t, multi.Set("key", "value", nil))
+	assert.Nil(t, multi.Delete("key"))
+	close(b.gate)
+	assert.Nil(t, multi.Close())
+
+	assert.Empty(t, a.cache)
+	assert.Empty(t, b.cache)
+	assert.Equal(t, 1, b.setCount)
+	assert.Equal(t, 1, b.deleteCount)
+}
+
+func TestMultiStoreAsyncDropsWrites(t *testing.T) {
+	b := GatedStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, gate: make(chan struct{}), entered: make(chan struct{}, 3)}
+	var (
+		mu      sync.Mutex
+		dropped = map[error][]int{}
+	)
+	multi := expiring.NewMultiStore([]store.StoreInterface{b}, expiring.MultiStoreQueueSize(1), expiring.MultiStoreAsync(func(index int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dropped[err] = append(dropped[err], index)
+	}))
+
+	// b's worker waits on the first Set, the second fills its queue
+	assert.Nil(t, multi.Set("first", 1, nil))
+	<-b.entered
+	assert.Nil(t, multi.Set("second", 2, nil))
+	assert.Nil(t, multi.Set("third", 3, nil))
+	close(b.gate)
+	assert.Nil(t, multi.Close())
+	assert.Nil(t, multi.Set("fourth", 4, nil))
+
+	assert.Equal(t, map[error][]int{
+		expiring.MultiStoreQueueFullError: {0},
+		expiring.MultiStoreClosedError:    {0},
+	}, dropped)
+	assert.Equal(t, 2, b.setCount)
+}
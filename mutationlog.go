@@ -0,0 +1,207 @@
+package expiring_gocache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Mutation is a Set, Delete or Clear recorded by a MutationLog.
+	Mutation struct {
+		// Operation is OperationSet, OperationDelete or OperationClear.
+		Operation Operation
+		Key       interface{}
+		Value     interface{}
+		// TTL is how long the value set lives, or 0 for values passed
+		// straight through, see WithBypass.
+		TTL  time.Duration
+		Time time.Time
+	}
+
+	// MutationLog is an append-only log of Mutations, see WithMutationLog.
+	// Implementations must be safe for concurrent use.
+	MutationLog interface {
+		Append(m Mutation) error
+		// Mutations returns the Mutations held, oldest first.
+		Mutations() ([]Mutation, error)
+	}
+
+	// RingLog is a MutationLog holding the latest Mutations in memory.
+	RingLog struct {
+		mu      sync.Mutex
+		entries []Mutation
+		next    int
+		full    bool
+	}
+
+	// FileLog is a MutationLog appending gob encoded Mutations to a file.
+	// Keys and values of types other than Go's basic types must be
+	// registered with gob.Register.
+	FileLog struct {
+		mu   sync.Mutex
+		path string
+		file *os.File
+	}
+)
+
+// NewRingLog creates a RingLog of the latest size Mutations.
+func NewRingLog(size int) *RingLog {
+	if size < 1 {
+		size = 1
+	}
+	return &RingLog{entries: make([]Mutation, size)}
+}
+
+func (l *RingLog) Append(m Mutation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries[l.next] = m
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	return nil
+}
+
+func (l *RingLog) Mutations() ([]Mutation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]Mutation(nil), l.entries[:l.next]...), nil
+	}
+	return append(append([]Mutation(nil), l.entries[l.next:]...), l.entries[:l.next]...), nil
+}
+
+// OpenFileLog opens, or creates, the FileLog at path. Mutations are
+// appended to those already in the file.
+func OpenFileLog(path string) (*FileLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLog{path: path, file: f}, nil
+}
+
+// Append writes m as a record of its length, as a uvarint, and its gob
+// encoding, so that each record can be decoded on its own.
+func (l *FileLog) Append(m Mutation) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&m); err != nil {
+		return err
+	}
+	record := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+buf.Len())
+	record = append(record[:binary.PutUvarint(record, uint64(buf.Len()))], buf.Bytes()...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.file.Write(record)
+	return err
+}
+
+// Mutations reads every Mutation in the file. A record cut short, e.g. by a
+// crash while it was being written, ends the log.
+func (l *FileLog) Mutations() ([]Mutation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mutations []Mutation
+	r := bufio.NewReader(f)
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return mutations, nil
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(r, record); err != nil {
+			return mutations, nil
+		}
+		var m Mutation
+		if err := gob.NewDecoder(bytes.NewReader(record)).Decode(&m); err != nil {
+			return mutations, err
+		}
+		mutations = append(mutations, m)
+	}
+}
+
+func (l *FileLog) Close() error {
+	return l.file.Close()
+}
+
+// logMutation appends the mutation described by e to the log given to
+// WithMutationLog. Failed appends are counted by Stats.MutationLogFailures.
+func (es Store) logMutation(e Event) {
+	if es.mutationLog == nil {
+		return
+	}
+	m := Mutation{Key: e.Key, Value: e.value, TTL: e.TTL, Time: e.Time}
+	switch e.Type {
+	case EventSet:
+		m.Operation = OperationSet
+	case EventDelete:
+		m.Operation = OperationDelete
+	case EventClear:
+		m.Operation = OperationClear
+	default:
+		return
+	}
+	if err := es.mutationLog.Append(m); err != nil {
+		atomic.AddUint64(&es.stats.mutationLogFailures, 1)
+	}
+}
+
+// ReplayInto applies the Mutations of the log given to WithMutationLog to
+// dst, oldest first, e.g. to warm a new cache, or to reproduce a bug from a
+// production log. Values are set with the TTL they had left, and those
+// which have since expired are skipped. UnsupportedError is returned if the
+// Store has no log, or if a Clear is replayed into a store which can't be
+// cleared.
+func (es Store) ReplayInto(dst store.StoreInterface) error {
+	if es.mutationLog == nil {
+		return UnsupportedError
+	}
+	mutations, err := es.mutationLog.Mutations()
+	if err != nil {
+		return err
+	}
+	now := es.now()
+	for _, m := range mutations {
+		switch m.Operation {
+		case OperationSet:
+			var options *store.Options
+			if m.TTL > 0 {
+				remaining := m.TTL - now.Sub(m.Time)
+				if remaining <= 0 {
+					continue
+				}
+				options = &store.Options{Expiration: remaining}
+			}
+			err = dst.Set(m.Key, m.Value, options)
+		case OperationDelete:
+			err = dst.Delete(m.Key)
+		case OperationClear:
+			c, ok := dst.(clearer)
+			if !ok {
+				return UnsupportedError
+			}
+			err = c.Clear()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
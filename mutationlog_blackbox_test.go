@@ -0,0 +1,76 @@
+package expiring_gocache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingLog(t *testing.T) {
+	log := expiring.NewRingLog(2)
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMutationLog(log))
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set("b", "value", nil))
+	assert.Nil(t, es.Delete("a"))
+
+	mutations, err := log.Mutations()
+	assert.Nil(t, err)
+	if assert.Len(t, mutations, 2) {
+		assert.Equal(t, expiring.OperationSet, mutations[0].Operation)
+		assert.Equal(t, "b", mutations[0].Key)
+		assert.Equal(t, "value", mutations[0].Value)
+		assert.InDelta(t, float64(time.Hour), float64(mutations[0].TTL), float64(time.Second))
+		assert.Equal(t, expiring.OperationDelete, mutations[1].Operation)
+		assert.Equal(t, "a", mutations[1].Key)
+	}
+}
+
+func TestFileLogReplayInto(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	dir, err := ioutil.TempDir("", "mutationlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mutations.log")
+
+	log, err := expiring.OpenFileLog(path)
+	assert.Nil(t, err)
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour}, expiring.WithMutationLog(log), expiring.WithClock(clk))
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set("b", []byte("value"), nil))
+	assert.Nil(t, es.Set("expired", "value", &store.Options{Expiration: time.Millisecond}))
+	assert.Nil(t, es.Clear())
+	assert.Nil(t, es.Set("c", 3, nil))
+	assert.Nil(t, es.Delete("b"))
+	assert.Nil(t, log.Close())
+
+	// reopened, as after a restart
+	log, err = expiring.OpenFileLog(path)
+	assert.Nil(t, err)
+	defer log.Close()
+	assert.Nil(t, log.Append(expiring.Mutation{Operation: expiring.OperationSet, Key: "d", Value: "value"}))
+	mutations, err := log.Mutations()
+	assert.Nil(t, err)
+	assert.Len(t, mutations, 7)
+
+	clk.Advance(5 * time.Millisecond)
+	replayed := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithMutationLog(log), expiring.WithClock(clk))
+	dst := MapStore{cache: map[interface{}]interface{}{}}
+	assert.Nil(t, replayed.ReplayInto(&dst))
+	assert.Equal(t, map[interface{}]interface{}{"c": 3, "d": "value"}, dst.cache)
+	assert.Equal(t, 1, dst.clearCount)
+}
+
+func TestReplayIntoWithoutLog(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	assert.Equal(t, expiring.UnsupportedError, es.ReplayInto(&MapStore{cache: map[interface{}]interface{}{}}))
+}
@@ -0,0 +1,46 @@
+package expiring_gocache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+var (
+	NotNewerError = errors.New("a value with the same or a newer timestamp is already stored")
+)
+
+// SetIfNewer is like Set, but records timestamp with the value, and only
+// writes it if the stored value's timestamp is older. Otherwise
+// NotNewerError is returned, and the stored value is left alone, so that
+// events consumed out of order or more than once still leave the newest
+// value cached. Expired values, and values written with Set, which have no
+// timestamp, are always overwritten.
+//
+// As with GetWithLease, the check is a read followed by a write, so two
+// callers racing to write the same key may both succeed.
+func (es Store) SetIfNewer(key interface{}, value interface{}, timestamp time.Time, options *store.Options) error {
+	if current, ok := es.currentTimestamp(key); ok && !current.Before(timestamp) {
+		return NotNewerError
+	}
+	ttl := ttlFor(options, es.defaultTTL(key, value))
+	return es.through(context.Background(), []sinkOp{{op: OperationSet, key: key, value: value, ttl: ttl}}, func() error {
+		return es.set(key, value, options, ttl, timestamp)
+	})
+}
+
+// currentTimestamp returns the timestamp of the unexpired value stored for
+// key, if there is one.
+func (es Store) currentTimestamp(key interface{}) (time.Time, bool) {
+	val, err := es.innerGet(key)
+	if err != nil || val == nil {
+		return time.Time{}, false
+	}
+	ew, ok := unwrap(val)
+	if !ok || ew.instance != es.instanceID || ew.timestamp.IsZero() || es.isExpired(ew, es.now()) {
+		return time.Time{}, false
+	}
+	return ew.timestamp, true
+}
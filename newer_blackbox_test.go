@@ -0,0 +1,44 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetIfNewer(t *testing.T) {
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, append(opts, expiring.WithClock(clk))...)
+			t1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			t2 := t1.Add(time.Second)
+
+			// values without a timestamp are overwritten
+			assert.Nil(t, es.Set("key", "set", nil))
+			assert.Nil(t, es.SetIfNewer("key", "v2", t2, nil))
+			assert.Equal(t, expiring.NotNewerError, es.SetIfNewer("key", "v1", t1, nil))
+			assert.Equal(t, expiring.NotNewerError, es.SetIfNewer("key", "v2 again", t2, nil))
+
+			val, md, err := es.GetStale("key")
+			assert.Nil(t, err)
+			assert.Equal(t, "v2", val)
+			assert.True(t, t2.Equal(md.Timestamp))
+
+			// expired values don't hold back older writes
+			clk.Advance(defaultSleep)
+			assert.Nil(t, es.SetIfNewer("key", "v1", t1, nil))
+			val, err = es.Get("key")
+			assert.Nil(t, err)
+			assert.Equal(t, "v1", val)
+		})
+	}
+}
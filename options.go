@@ -0,0 +1,696 @@
+package expiring_gocache
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+// Option configures optional behavior of a Store. Options are applied in
+// order by New.
+type Option func(*Store)
+
+// WithReaper starts a background reaper which deletes expired values from
+// the underlying store every interval, instead of waiting for them to be
+// read. Keys written through the Store are tracked in expiration buckets
+// (see WithBucketWidth); once a bucket's window has passed, all of its keys
+// are deleted together. If the underlying store implements
+// `DeleteMulti(keys []interface{}) error`, a bucket is deleted with a single
+// call; otherwise its keys are deleted one at a time.
+//
+// Call Close to stop the reaper.
+func WithReaper(interval time.Duration) Option {
+	return func(es *Store) {
+		es.reaperEnabled = true
+		es.reaperInterval = interval
+	}
+}
+
+// WithBucketWidth sets the width of the expiration buckets used by the
+// reaper. Wider buckets mean fewer, larger batch deletes, at the cost of
+// expired values lingering in the underlying store for up to one width.
+// Defaults to DefaultBucketWidth.
+func WithBucketWidth(width time.Duration) Option {
+	return func(es *Store) {
+		if width > 0 {
+			es.bucketWidth = width
+		}
+	}
+}
+
+// WithTypeName sets the name returned by GetType, so that several Stores in
+// one process can be told apart, e.g. in metric labels or ChainCache tiers.
+// Defaults to ExpiringStoreType.
+func WithTypeName(name string) Option {
+	return func(es *Store) {
+		if name != "" {
+			es.typeName = name
+		}
+	}
+}
+
+// WithDeleteFailureHook registers a function which is called whenever a best
+// effort delete of an expired value fails, whether from Get, the reaper, or
+// a retry. Failures are also counted in Stats.
+func WithDeleteFailureHook(hook func(key interface{}, err error)) Option {
+	return func(es *Store) {
+		es.onDeleteFailure = hook
+	}
+}
+
+// WithDeleteRetries retries failed best effort deletes of expired values in
+// the background. Failed deletes are queued, up to queueSize at once, and
+// each is attempted up to attempts times in total, waiting backoff before
+// each retry. Deletes which don't fit in the queue, or which run out of
+// attempts, are dropped and counted in Stats.
+//
+// Call Close to stop retrying; queued retries are discarded.
+func WithDeleteRetries(queueSize, attempts int, backoff time.Duration) Option {
+	return func(es *Store) {
+		es.retryQueueSize = queueSize
+		es.retryAttempts = attempts
+		es.retryBackoff = backoff
+	}
+}
+
+// WithMaxEntries caps the number of values written through the Store which
+// are kept in the underlying store. When a Set takes the Store over max,
+// values are evicted, deleting them from the underlying store: lowest
+// priority first (see PriorityTag), least recently used first within a
+// priority. Pinned values are never evicted. Evictions are counted in Stats.
+func WithMaxEntries(max int) Option {
+	return func(es *Store) {
+		es.settings.update(func(s *settings) {
+			s.maxEntries = max
+		})
+	}
+}
+
+// WithAccessTracking records the number of hits and the time of the last hit
+// of every key written through the Store, for HotKeys and ColdKeys. Access
+// history is kept per key, across Sets, until the key is deleted, expires or
+// is evicted.
+func WithAccessTracking() Option {
+	return func(es *Store) {
+		es.trackAccess = true
+	}
+}
+
+// WithSlowThreshold records every call to the underlying store which takes at
+// least threshold, in a bounded log retrievable with SlowLog. If callback is
+// not nil, it is also called with each slow call, synchronously.
+func WithSlowThreshold(threshold time.Duration, callback func(SlowOperation)) Option {
+	return func(es *Store) {
+		es.slowThreshold = threshold
+		es.slowCallback = callback
+	}
+}
+
+// WithSlowLogSize sets how many slow calls SlowLog keeps. Defaults to
+// DefaultSlowLogSize.
+func WithSlowLogSize(size int) Option {
+	return func(es *Store) {
+		es.slowLogSize = size
+	}
+}
+
+// WithLatencyHistograms tracks the latency of calls to the underlying store
+// in per-Operation histograms, reported by Stats.
+func WithLatencyHistograms() Option {
+	return func(es *Store) {
+		es.latencies = newLatencyHistograms()
+	}
+}
+
+// WithDeadlineClamp makes SetWithContext clamp the TTL of values to multiple
+// times the time remaining until the context's deadline, for contexts which
+// have one. This suits values whose freshness is tied to the request which
+// produced them.
+func WithDeadlineClamp(multiple float64) Option {
+	return func(es *Store) {
+		es.deadlineClamp = multiple
+	}
+}
+
+// WithBypass passes keys for which match returns true straight through to
+// the underlying store: Set writes their values without an expiration
+// wrapper or tracking, and Get returns whatever the underlying store holds
+// without checking for expiry. Use it for keys managed by another system.
+func WithBypass(match func(key interface{}) bool) Option {
+	return func(es *Store) {
+		es.bypass = match
+	}
+}
+
+// WithAllowedKeys only caches values for keys for which allow returns true.
+// Sets of any other key are suppressed: nothing is written, the Set is counted
+// in Stats, and nil is returned unless WithSuppressedSetErrors was given.
+func WithAllowedKeys(allow func(key interface{}) bool) Option {
+	return func(es *Store) {
+		es.allowKeys = allow
+	}
+}
+
+// WithDeniedKeys never caches values for keys for which deny returns true,
+// e.g. keys holding per-user secrets. Sets of denied keys are suppressed as
+// with WithAllowedKeys. Denial wins over WithAllowedKeys and WithBypass.
+func WithDeniedKeys(deny func(key interface{}) bool) Option {
+	return func(es *Store) {
+		es.denyKeys = deny
+	}
+}
+
+// WithSuppressedSetErrors makes suppressed Sets return KeyNotCacheableError
+// instead of nil.
+func WithSuppressedSetErrors() Option {
+	return func(es *Store) {
+		es.errorOnSuppressedSet = true
+	}
+}
+
+// WithSetSampling only writes a random fraction, rate, of Sets, so that a
+// cache for a very high cardinality keyspace holds the hot keys without the
+// long tail. Sets which aren't sampled return nil and are counted in Stats.
+// A value previously written for the key is left in place, so prefer
+// WithDeterministicSetSampling for keys which are Set repeatedly. A rate of
+// 1 writes every Set, and a rate of 0 none; NewValidated rejects rates
+// outside that range.
+func WithSetSampling(rate float64) Option {
+	return func(es *Store) {
+		es.setSampleRate = rate
+		es.deterministicSetSampling = false
+	}
+}
+
+// WithDeterministicSetSampling is like WithSetSampling, but samples by a hash
+// of the key, so a key is either always or never written. As there, a rate
+// of 0 writes nothing.
+func WithDeterministicSetSampling(rate float64) Option {
+	return func(es *Store) {
+		es.setSampleRate = rate
+		es.deterministicSetSampling = true
+	}
+}
+
+// WithFallback consults secondary, e.g. a larger but slower tier, when Get
+// misses or finds an expired value in the underlying store. Values in
+// secondary which were written by an expiring Store are subject to their own
+// expiration. Fallback hits are counted in Stats.
+func WithFallback(secondary store.StoreInterface) Option {
+	return func(es *Store) {
+		es.fallback = secondary
+	}
+}
+
+// WithFallbackPromotion writes values found in the fallback store back into
+// the underlying store, with the Store's default expiration.
+func WithFallbackPromotion() Option {
+	return func(es *Store) {
+		es.promoteFallback = true
+	}
+}
+
+// WithHedgedReads also reads from replica when the underlying store hasn't
+// answered a Get within delay, returning whichever answers successfully
+// first. Use it to cut tail latency from an occasionally slow primary.
+// Hedged reads, and the reads won by the replica, are counted in Stats.
+func WithHedgedReads(replica store.StoreInterface, delay time.Duration) Option {
+	return func(es *Store) {
+		es.hedgeReplica = replica
+		es.hedgeDelay = delay
+	}
+}
+
+// WithShadow mirrors a sample of Gets to shadow, in the background, and
+// compares what it reads with what the underlying store read, as configured
+// by config. Mirrored reads, and those whose results disagree, are counted
+// in Stats, along with the latency of both stores. The shadow store is only
+// read; keeping it populated, e.g. with NewMigrating, is up to the caller.
+func WithShadow(shadow store.StoreInterface, config Shadow) Option {
+	return func(es *Store) {
+		es.shadow = newShadowReader(shadow, config)
+	}
+}
+
+// WithLeaseTTL sets how long a lease taken by GetWithLease lasts if it is
+// not used. Defaults to DefaultLeaseTTL.
+func WithLeaseTTL(ttl time.Duration) Option {
+	return func(es *Store) {
+		es.leaseTTL = ttl
+	}
+}
+
+// WithDeleteDelay makes Delete soft delete values, purging them delay after
+// the Delete, see DeleteAfter.
+func WithDeleteDelay(delay time.Duration) Option {
+	return func(es *Store) {
+		es.deleteDelay = delay
+	}
+}
+
+// WithInstanceID lets several differently configured Stores share one
+// underlying store. Keys are namespaced by id in the underlying store, and
+// values are marked with id; a value written by a Store with another
+// instance ID is reported by Get as ForeignValueError. Clear still clears
+// the whole underlying store.
+func WithInstanceID(id string) Option {
+	return func(es *Store) {
+		es.instanceID = id
+	}
+}
+
+// WithJitter randomly lengthens or shortens the TTL of every value by up to
+// fraction of it, e.g. 0.1 for ±10%, so that values written together don't
+// all expire together.
+func WithJitter(fraction float64) Option {
+	return func(es *Store) {
+		es.settings.update(func(s *settings) {
+			s.jitter = fraction
+		})
+	}
+}
+
+// WithPrefixTTL sets the TTL of values with string keys starting with prefix,
+// when their options don't give an expiration. The longest matching prefix
+// wins.
+func WithPrefixTTL(prefix string, ttl time.Duration) Option {
+	return func(es *Store) {
+		es.settings.update(func(s *settings) {
+			s.prefixTTLs = addPrefixTTL(s.prefixTTLs, prefix, ttl)
+		})
+	}
+}
+
+// WithTTLFunc computes the TTL of values whose options don't give an
+// expiration, so that it can depend on the value itself. It takes precedence
+// over WithPrefixTTL and the default expiration; returning 0 or less falls
+// back to them.
+func WithTTLFunc(ttl func(key, value interface{}) time.Duration) Option {
+	return func(es *Store) {
+		es.ttlFunc = ttl
+	}
+}
+
+// WithTypeTTL sets the TTL of values of prototype's type, or pointers to
+// it, when their options don't give an expiration. It takes precedence over
+// WithPrefixTTL and the default expiration, but not WithTTLFunc.
+func WithTypeTTL(prototype interface{}, ttl time.Duration) Option {
+	return func(es *Store) {
+		es.typeTTLsOf().registered[reflect.TypeOf(prototype)] = ttl
+	}
+}
+
+// WithStructTagTTLs reads the TTL of struct values without one from
+// WithTypeTTL from a field tagged with it, e.g.
+//
+//	type ProductDetails struct {
+//		_ struct{} `cache:"ttl=5m"`
+//		...
+//	}
+//
+// Tags whose TTL can't be parsed are ignored.
+func WithStructTagTTLs() Option {
+	return func(es *Store) {
+		es.typeTTLsOf().tags = true
+	}
+}
+
+// WithBinaryEnvelope writes values to the underlying store as []byte, with
+// their expiration, for stores which can't hold other Go values, such as
+// Bigcache or Redis. Only []byte and string values can be Set, unless
+// WithCodec is also given; any other value gets UnencodableValueError.
+// Values are read back with the type they were written with.
+func WithBinaryEnvelope() Option {
+	return func(es *Store) {
+		es.binaryEnvelope = true
+	}
+}
+
+// WithNativeExpiration passes each value's TTL to the underlying store as
+// its Expiration, so that stores which expire values themselves, such as
+// Redis, drop them even if they are never read again. The Store still checks
+// the expiration on every read.
+func WithNativeExpiration() Option {
+	return func(es *Store) {
+		es.nativeExpiration = true
+	}
+}
+
+// WithExpiredError returns err, instead of ValueExpiredError, for expired
+// values, e.g. so that callers see the underlying store's own miss error.
+// The error returned still matches ValueExpiredError with errors.Is.
+func WithExpiredError(err error) Option {
+	return func(es *Store) {
+		es.expiredErr = err
+	}
+}
+
+// WithMissError returns err, instead of the underlying store's own error,
+// from reads which miss, as told by the ErrorClassifier, so that callers
+// see the same error for a miss whichever store is underneath. Combined
+// with WithExpiredError, misses and expired values can share one error.
+func WithMissError(err error) Option {
+	return func(es *Store) {
+		es.missErr = err
+	}
+}
+
+// WithErrorClassifier classifies the underlying store's errors by
+// classifier, rather than DefaultErrorClassifier: misses aren't counted as
+// errors in Stats, nor retried by WithDeleteRetries, and are returned as
+// the error given to WithMissError; permanent failures aren't retried.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return func(es *Store) {
+		es.classifier = classifier
+	}
+}
+
+// WithValueCloner returns a copy of each value read, made by clone, so that
+// callers of an in-memory store can't mutate the value it holds, and which
+// other callers will read. If clone is nil, DeepCopy is used. Values read
+// from a binary envelope, see WithBinaryEnvelope, are already fresh copies,
+// and are not cloned again.
+func WithValueCloner(clone func(interface{}) interface{}) Option {
+	return func(es *Store) {
+		if clone == nil {
+			clone = DeepCopy
+		}
+		es.cloner = clone
+	}
+}
+
+// WithClockSkewTolerance treats values as expired only once tolerance has
+// passed since their expiration, so that values written by nodes whose
+// clocks are behind this one's aren't declared expired early. Skew is
+// observed in Stats as SkewedReads and MaxClockSkew.
+func WithClockSkewTolerance(tolerance time.Duration) Option {
+	return func(es *Store) {
+		es.skewTolerance = tolerance
+	}
+}
+
+// WithStalenessAlert counts the reads which find an expired value, and how
+// stale it is, over each window of alert, calling alert.Alert when a window
+// crosses one of its thresholds, e.g. because TTLs are too short or the
+// job refreshing values is failing.
+func WithStalenessAlert(alert StalenessAlert) Option {
+	return func(es *Store) {
+		if alert.Window > 0 {
+			es.staleness = newStalenessMonitor(alert)
+		}
+	}
+}
+
+// WithMetricsSink pushes metrics to sink as they happen: the latency and
+// errors of every call to the underlying store, hits and expired reads, and
+// evictions. See expiringstatsd for a StatsD sink.
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(es *Store) {
+		es.sink = sink
+	}
+}
+
+// WithEventHook calls hook, synchronously, with an Event for each change
+// made to the values held for the Store: values set, deleted, expired and
+// evicted, and the store cleared or invalidated. It may be given more than
+// once. See expiringevents for hooks which publish CloudEvents.
+func WithEventHook(hook func(Event)) Option {
+	return func(es *Store) {
+		es.eventHooks = append(es.eventHooks, hook)
+	}
+}
+
+// WithAdminWebhook POSTs an AdminNotification to url after each Clear, and
+// each InvalidateByPrefix deleting at least DefaultAdminWebhookThreshold
+// keys (see WithAdminWebhookThreshold), signed with secret as described by
+// AdminWebhookSignatureHeader. The operation waits for the webhook.
+func WithAdminWebhook(url string, secret []byte) Option {
+	return func(es *Store) {
+		es.adminWebhook = &adminWebhook{url: url, secret: secret, client: &http.Client{Timeout: adminWebhookTimeout}}
+	}
+}
+
+// WithAdminWebhookThreshold sets how many keys InvalidateByPrefix must
+// delete before the admin webhook is notified.
+func WithAdminWebhookThreshold(keys int) Option {
+	return func(es *Store) {
+		es.adminWebhookThreshold = keys
+	}
+}
+
+// WithMutationLog appends each Set, Delete and Clear made through the Store
+// to log, see ReplayInto. Deletes include soft deletes and InvalidateByPrefix,
+// but not values expired or evicted by the Store.
+func WithMutationLog(log MutationLog) Option {
+	return func(es *Store) {
+		es.mutationLog = log
+	}
+}
+
+// WithChaos injects faults into the Store as configured, e.g. in staging, so
+// that applications can verify they tolerate a misbehaving cache.
+func WithChaos(config Chaos) Option {
+	return func(es *Store) {
+		es.chaos = newChaosMonkey(config)
+	}
+}
+
+// WithClock sets the clock the Store reads the time from when setting and
+// checking expirations, e.g. a clock.Fake so that tests can expire values
+// without waiting. Defaults to clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(es *Store) {
+		es.clock = c
+	}
+}
+
+// WithTTLHistograms tracks the TTLs given to values when they are set, and
+// those they have left when they are read, reported by Stats, e.g. to see
+// whether callers give their own TTLs or leave every value to the default.
+func WithTTLHistograms() Option {
+	return func(es *Store) {
+		es.ttls = newTTLHistograms()
+	}
+}
+
+// WithReadThrough makes the Store fill itself from source: when Get misses or
+// finds an expired value, the value is fetched from source, written back
+// with the TTL source gave, and returned. Concurrent misses on the same key
+// share one fetch.
+func WithReadThrough(source Source) Option {
+	return func(es *Store) {
+		es.source = source
+	}
+}
+
+// WithWriteThrough keeps sink, e.g. a database, in step with the cache for
+// the keys written through the Store: Set, SetWithContext, SetIfNewer,
+// SetMulti and Batch also write values to sink, and Delete, DeleteAfter,
+// InvalidateByPrefix and Batch remove them from it, in the order and with the
+// error handling given by policy.
+func WithWriteThrough(sink Sink, policy WriteThroughPolicy) Option {
+	return func(es *Store) {
+		es.writeThrough = &writeThrough{sink: sink, policy: policy}
+	}
+}
+
+// WithWriteBehind persists values to sink asynchronously: Set and Delete
+// return once the cache is written, and queue the write for background
+// workers which pass them to sink in batches, retrying failures. Writes the
+// queue can't hold are dropped and counted in Stats. Sinks implementing
+// `WriteBatch(ctx context.Context, writes []SinkWrite) error` get each batch
+// in one call. Call Flush to wait for queued writes, and Close to write them
+// and stop the workers.
+func WithWriteBehind(sink Sink, config WriteBehind) Option {
+	return func(es *Store) {
+		es.writeBehindSink = sink
+		es.writeBehindConfig = config
+	}
+}
+
+// WithDependencies keeps the dependencies declared with DependsOn, deleting
+// the values which depend on a value when it is deleted, expires or is
+// evicted.
+func WithDependencies() Option {
+	return func(es *Store) {
+		es.trackDependencies = true
+	}
+}
+
+// WithTagExpiry records the tags of values written through the Store with
+// them, so that ExpireTag can expire every value carrying a tag.
+func WithTagExpiry() Option {
+	return func(es *Store) {
+		es.tagExpiry = true
+	}
+}
+
+// WithGenerations groups keys into the namespaces returned by namespaceOf,
+// e.g. the part of a string key up to its first colon, so that
+// BumpGeneration can invalidate a whole namespace at once. Keys for which it
+// returns "" aren't in a namespace. Reads and writes of namespaced keys also
+// read the namespace's generation from the underlying store.
+func WithGenerations(namespaceOf func(key interface{}) string) Option {
+	return func(es *Store) {
+		es.namespaceOf = namespaceOf
+	}
+}
+
+// WithReadLimits honours the read limits set by MaxReadsTag.
+func WithReadLimits() Option {
+	return func(es *Store) {
+		es.readLimits = true
+	}
+}
+
+// WithHitJitter rewrites a random fraction, rate, of hits with the value's
+// expiration moved randomly by up to window either way, so that values
+// written together, e.g. by a bulk import, come to expire, and be refreshed,
+// at different times. Only the expiration is rewritten.
+func WithHitJitter(rate float64, window time.Duration) Option {
+	return func(es *Store) {
+		es.hitJitterRate = rate
+		es.hitJitterWindow = window
+	}
+}
+
+// WithAdmission only caches a key once it has been requested by Get, or
+// GetWithTTL, threshold times within window, so that keys requested once
+// don't take the place of popular ones in a capped cache. Requests are
+// counted in a small sketch, which may overcount, and is reset each window.
+// Sets of keys not yet admitted return nil, and are counted in Stats. A
+// threshold of 1 or less admits every key.
+func WithAdmission(threshold int, window time.Duration) Option {
+	return func(es *Store) {
+		es.admission = nil
+		if threshold > 1 {
+			es.admission = newAdmissionSketch(threshold, window)
+		}
+	}
+}
+
+// WithCodec writes values other than []byte and strings into the binary
+// envelope encoded by codec, and decodes them again when they are read, so
+// that any value the codec supports can be Set on a store which only holds
+// []byte. It implies WithBinaryEnvelope.
+func WithCodec(codec Codec) Option {
+	return func(es *Store) {
+		es.codec = codec
+		es.binaryEnvelope = true
+	}
+}
+
+// WithRefreshBackoff sets how long a refresh scheduled by ScheduleRefresh
+// waits before retrying a failed load. The wait doubles with each failure,
+// up to MaxRefreshBackoff or the refresh's interval, whichever is shorter.
+// Defaults to DefaultRefreshBackoff.
+func WithRefreshBackoff(backoff time.Duration) Option {
+	return func(es *Store) {
+		es.refreshBackoff = backoff
+	}
+}
+
+// WithEvictionWatermarks evicts values in the background rather than in Set:
+// once the Store holds more than high times MaxEntries values, an evictor
+// deletes the least valuable ones, as WithMaxEntries would, until it holds
+// low times MaxEntries. Sets only evict values themselves if the evictor
+// falls behind and the Store reaches MaxEntries. Values the evictor deletes
+// are counted in Stats. Call Close to stop the evictor.
+func WithEvictionWatermarks(low, high float64) Option {
+	return func(es *Store) {
+		es.watermarks = &watermarks{low: low, high: high}
+	}
+}
+
+// WithEvictionPolicy orders the values of each priority of a Store capped by
+// WithMaxEntries for eviction by a policy made by newPolicy, rather than
+// least recently used first. newPolicy is called once per priority, and
+// again whenever the Store is cleared.
+func WithEvictionPolicy(newPolicy func() EvictionPolicy) Option {
+	return func(es *Store) {
+		es.evictionPolicy = newPolicy
+	}
+}
+
+// WithSegmentedLRU evicts values by NewSLRU(share), so that keys read once,
+// e.g. by a scan, are evicted before keys read repeatedly.
+func WithSegmentedLRU(share float64) Option {
+	return WithEvictionPolicy(func() EvictionPolicy {
+		return NewSLRU(share)
+	})
+}
+
+// WithWorkerRestartHook calls hook with the health of each background worker
+// which failed, before it is restarted, see Store.Health.
+func WithWorkerRestartHook(hook func(WorkerHealth)) Option {
+	return func(es *Store) {
+		es.onWorkerRestart = hook
+	}
+}
+
+// WithWorkerRestartBackoff sets how long a failed background worker waits
+// before it is first restarted. Defaults to DefaultWorkerRestartBackoff.
+func WithWorkerRestartBackoff(backoff time.Duration) Option {
+	return func(es *Store) {
+		es.workerBackoff = backoff
+	}
+}
+
+// WithIdleSuspend suspends the reaper and scheduled refreshes once no value
+// has been read, written or deleted through the Store for idle, so that
+// many mostly idle Stores in one process don't keep waking up and calling
+// their backends. They resume on the next operation, with the reaper
+// catching up on the values which expired in the meantime. Suspensions are
+// counted in Stats.
+func WithIdleSuspend(idle time.Duration) Option {
+	return func(es *Store) {
+		es.idle = newIdleState(idle)
+	}
+}
+
+// WithStartupScan sets how many entries VerifyOnStart reads, or
+// DefaultStartupScanSample if sample is 0, and what it does with those in
+// need of repair.
+func WithStartupScan(sample int, repair ScanRepair) Option {
+	return func(es *Store) {
+		es.startupScan = startupScan{sample: sample, repair: repair}
+	}
+}
+
+// WithFutureEnvelopes sets how values written by a later version of this
+// package are read, e.g. during a rolling deployment. Defaults to
+// FutureEnvelopesFail.
+func WithFutureEnvelopes(policy FutureEnvelopePolicy) Option {
+	return func(es *Store) {
+		es.futureEnvelopes = policy
+	}
+}
+
+// WithEnvelopeFormat is WithBinaryEnvelope, writing the envelope in format,
+// e.g. JSONEnvelope for services written in other languages which share the
+// underlying store to read and write too. Envelopes in any format are read.
+func WithEnvelopeFormat(format EnvelopeFormat) Option {
+	return func(es *Store) {
+		es.binaryEnvelope = true
+		es.envelopeFormat = format
+	}
+}
+
+// WithHashedIndex tracks keys by hashes of the keys rather than by the keys
+// themselves, bounding the tracker's memory per key for caches of tens of
+// millions of keys, see Stats.Index. Since the tracker holds no keys, those
+// it reaps, evicts or reports are found again by listing the underlying
+// store, which must implement `Keys() ([]interface{}, error)`: each reap or
+// eviction costs a listing, so WithEvictionWatermarks is recommended along
+// with WithMaxEntries. Should the hash of a key collide with that of a key
+// already tracked, which is vanishingly rare, the key set second is left
+// untracked and counted in IndexStats.Collisions; it still expires when it
+// is read, but isn't reaped or evicted.
+func WithHashedIndex() Option {
+	return func(es *Store) {
+		es.hashedIndex = true
+	}
+}
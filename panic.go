@@ -0,0 +1,72 @@
+package expiring_gocache
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// PanicError is returned in place of a panic in a function given to the
+	// Store, such as a Source, an event hook or a TTL function, so that one
+	// buggy callback can't take down the goroutine calling it, or one of the
+	// Store's background workers. Panics are counted in Stats. Panics in
+	// hooks and TTL functions have no caller to return the error to, and are
+	// only counted; a TTL function which panics gives no TTL.
+	PanicError struct {
+		// Callback names the function which panicked, e.g. "source".
+		Callback string
+		// Value is the value passed to panic.
+		Value interface{}
+		// Stack is the stack trace of the panicking goroutine.
+		Stack []byte
+	}
+)
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it was an error.
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic recovers a panic in the named callback, counts it, and sets
+// *err to a PanicError for it if err isn't nil. It must be deferred.
+func (es Store) recoverPanic(callback string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	atomic.AddUint64(&es.stats.panics, 1)
+	if err != nil {
+		*err = PanicError{Callback: callback, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// guard calls fn, returning a PanicError if it panics.
+func (es Store) guard(callback string, fn func() error) error {
+	return guardCallback(callback, &es.stats.panics, fn)
+}
+
+// guardCallback calls fn, returning a PanicError if it panics, and counting
+// the panic in *panics, for callbacks given to types other than Store.
+func guardCallback(callback string, panics *uint64, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(panics, 1)
+			err = PanicError{Callback: callback, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// fetchFrom reads key from src, returning a PanicError if it panics.
+func (es Store) fetchFrom(ctx context.Context, src Source, key interface{}) (val interface{}, ttl time.Duration, err error) {
+	defer es.recoverPanic("source", &err)
+	return src.Fetch(ctx, key)
+}
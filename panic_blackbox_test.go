@@ -0,0 +1,50 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanickingSource(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithReadThrough(expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+			panic(errors.New("bug"))
+		})),
+	)
+
+	_, err := es.Get("key")
+	var pe expiring.PanicError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "source", pe.Callback)
+	assert.Equal(t, "source panicked: bug", err.Error())
+	assert.EqualError(t, errors.Unwrap(err), "bug")
+	assert.NotEmpty(t, pe.Stack)
+
+	// the fetch was finished, so the next Get fetches again
+	_, err = es.Get("key")
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, uint64(2), es.Stats().Panics)
+}
+
+func TestPanickingHookAndTTLFunc(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithEventHook(func(expiring.Event) { panic("bug") }),
+		expiring.WithTTLFunc(func(key, value interface{}) time.Duration { panic("bug") }),
+	)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	// the default expiration is used instead
+	assert.True(t, ttl > 59*time.Minute)
+	assert.Equal(t, uint64(2), es.Stats().Panics)
+}
@@ -0,0 +1,121 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	pinSet struct {
+		mu   sync.RWMutex
+		keys map[interface{}]struct{}
+	}
+
+	// pinnedValue is a pinned key's stored value, kept across a Clear along
+	// with the directives it was tracked with.
+	pinnedValue struct {
+		val       interface{}
+		priority  Priority
+		dependsOn []interface{}
+		cost      time.Duration
+	}
+)
+
+// Pin exempts key from expiration: its value is never reported as expired,
+// is never reaped, and survives Clear. Pinning applies to the key, not the
+// value, so it also covers values Set after the key was pinned. Keys must be
+// comparable; UntrackableKeyError is returned otherwise.
+func (es Store) Pin(key interface{}) error {
+	if !trackable(key) {
+		return UntrackableKeyError
+	}
+	es.pins.mu.Lock()
+	defer es.pins.mu.Unlock()
+	es.pins.keys[key] = struct{}{}
+	return nil
+}
+
+// Unpin undoes Pin. If the key's value has expired in the meantime, it will
+// be reported as expired by the next Get.
+func (es Store) Unpin(key interface{}) {
+	if !trackable(key) {
+		return
+	}
+	es.pins.mu.Lock()
+	defer es.pins.mu.Unlock()
+	delete(es.pins.keys, key)
+}
+
+func (p *pinSet) has(key interface{}) bool {
+	if !trackable(key) {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.keys[key]
+	return ok
+}
+
+// without returns keys minus any pinned keys.
+func (p *pinSet) without(keys []interface{}) []interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.keys) == 0 {
+		return keys
+	}
+	unpinned := keys[:0]
+	for _, key := range keys {
+		if _, ok := p.keys[key]; !ok {
+			unpinned = append(unpinned, key)
+		}
+	}
+	return unpinned
+}
+
+// pinnedValues reads the stored values of all pinned keys, as they are held
+// by the underlying store, and the directives they are tracked with.
+func (es Store) pinnedValues() map[interface{}]pinnedValue {
+	es.pins.mu.RLock()
+	keys := make([]interface{}, 0, len(es.pins.keys))
+	for key := range es.pins.keys {
+		keys = append(keys, key)
+	}
+	es.pins.mu.RUnlock()
+
+	values := make(map[interface{}]pinnedValue, len(keys))
+	for _, key := range keys {
+		val, err := es.innerGet(key)
+		if err != nil || val == nil {
+			continue
+		}
+		pv := pinnedValue{val: val}
+		if es.tracker != nil {
+			if entry, ok := es.tracker.history(key); ok {
+				pv.priority, pv.dependsOn, pv.cost = entry.priority, entry.dependsOn, entry.cost
+			}
+		}
+		values[key] = pv
+	}
+	return values
+}
+
+// restorePinned writes back values read by pinnedValues after a Clear. With
+// WithNativeExpiration, each is given back what remained of its native TTL.
+func (es Store) restorePinned(values map[interface{}]pinnedValue) error {
+	for key, pv := range values {
+		ew, wrapped := unwrap(pv.val)
+		var options *store.Options
+		if wrapped && es.nativeExpiration {
+			options = withNativeExpiration(nil, ew.expireAt.Sub(es.now()))
+		}
+		if err := es.innerSet(key, pv.val, options); err != nil {
+			return err
+		}
+		if wrapped {
+			es.track(key, ew.expireAt, pv.priority, pv.dependsOn, pv.cost)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinnedValuesDoNotExpire(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Pin("flags"))
+	assert.Nil(t, es.Set("flags", "snapshot", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	val, err := es.Get("flags")
+	assert.Nil(t, err)
+	assert.Equal(t, "snapshot", val)
+	val, ttl, err := es.GetWithTTL("flags")
+	assert.Nil(t, err)
+	assert.Equal(t, "snapshot", val)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.Equal(t, 0, ms.deleteCount)
+
+	// once unpinned, the value is expired again
+	es.Unpin("flags")
+	_, err = es.Get("flags")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 1, ms.deleteCount)
+}
+
+func TestPinnedValuesAreNotReaped(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithClock(clk),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", "value", nil))
+	assert.Nil(t, es.Set("unpinned", "value", nil))
+
+	awaitReaper(t, clk, func() bool { return !ms.holds("unpinned") })
+	assert.Nil(t, es.Close())
+
+	_, ok := ms.cache["pinned"]
+	assert.True(t, ok)
+	_, ok = ms.cache["unpinned"]
+	assert.False(t, ok)
+}
+
+func TestPinnedValuesSurviveClear(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration})
+
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", "value", nil))
+	assert.Nil(t, es.Set("unpinned", "value", nil))
+
+	assert.Nil(t, es.Clear())
+	assert.Equal(t, 1, ms.clearCount)
+
+	val, err := es.Get("pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	_, err = es.Get("unpinned")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestPinnedValuesKeepNativeExpirationAcrossClear(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk), expiring.WithNativeExpiration())
+
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", "value", nil))
+	clk.Advance(20 * time.Minute)
+
+	assert.Nil(t, es.Clear())
+	if assert.NotNil(t, ms.lastSetOptions) {
+		assert.Equal(t, 40*time.Minute, ms.lastSetOptions.ExpirationValue())
+	}
+}
+
+func TestPinnedValuesKeepPriorityAcrossClear(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithMaxEntries(2))
+
+	assert.Nil(t, es.Pin("pinned"))
+	assert.Nil(t, es.Set("pinned", "value", &store.Options{Tags: []string{expiring.PriorityTag(expiring.PriorityHigh)}}))
+	assert.Nil(t, es.Clear())
+	es.Unpin("pinned")
+
+	// the normal priority key is evicted before the older high priority one
+	assert.Nil(t, es.Set("a", "value", nil))
+	assert.Nil(t, es.Set("b", "value", nil))
+	assert.Equal(t, []interface{}{"a"}, ms.deletedKeys)
+}
+
+func TestFailedClearKeepsTracking(t *testing.T) {
+	fs := FlakyStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&fs, &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking())
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	fs.setDown(true)
+	assert.Equal(t, FlakyStoreDown, es.Clear())
+
+	n, err := es.Len()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestPinUntrackableKey(t *testing.T) {
+	es := expiring.New(nil, nil)
+	assert.Equal(t, expiring.UntrackableKeyError, es.Pin([]string{"not", "comparable"}))
+}
@@ -0,0 +1,57 @@
+package expiring_gocache
+
+import (
+	"context"
+	"strings"
+)
+
+// InvalidateByPrefix deletes every value whose key is a string starting
+// with prefix, returning how many were deleted. Keys are listed as by Keys,
+// so UnsupportedError is returned if the Store can't list them. Pinned
+// values are kept, as by Clear. With WithWriteThrough, the values are also
+// removed from the sink.
+func (es Store) InvalidateByPrefix(prefix string) (int, error) {
+	keys, err := es.Keys()
+	if err != nil {
+		return 0, err
+	}
+	var matched []interface{}
+	for _, key := range keys {
+		if s, ok := key.(string); ok && strings.HasPrefix(s, prefix) {
+			matched = append(matched, key)
+		}
+	}
+	matched = es.pins.without(matched)
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	ops := make([]sinkOp, len(matched))
+	for i, key := range matched {
+		ops[i] = sinkOp{op: OperationDelete, key: key}
+	}
+	err = es.through(context.Background(), ops, func() error {
+		for _, key := range matched {
+			es.untrack(key)
+		}
+		if bd, ok := es.store.(batchDeleter); ok {
+			return es.innerDeleteMulti(bd, matched)
+		}
+		for _, key := range matched {
+			if err := es.innerDelete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range matched {
+		es.emit(Event{Type: EventDelete, Key: key})
+	}
+	es.cascade(matched...)
+	es.notifyAdmin(AdminNotification{Operation: OperationInvalidateByPrefix, Prefix: prefix, Keys: len(matched)})
+	return len(matched), nil
+}
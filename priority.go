@@ -0,0 +1,43 @@
+package expiring_gocache
+
+// Priority determines which values are given up first when the Store is
+// over capacity. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// priorities lists every Priority, lowest first.
+var priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+func parsePriority(s string) (Priority, bool) {
+	for _, p := range priorities {
+		if p.String() == s {
+			return p, true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// PriorityTag returns a tag which, when included in the Tags of the options
+// passed to Set, sets the priority of the value. When the Store is over
+// capacity (see WithMaxEntries), lower priority values are evicted first,
+// and the reaper deletes lower priority values first. Values without a
+// priority tag have PriorityNormal.
+func PriorityTag(p Priority) string {
+	return directiveTag(priorityDirective, p.String())
+}
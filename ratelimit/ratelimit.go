@@ -0,0 +1,208 @@
+// Package ratelimit limits the rate of events per key, such as requests per
+// client, with counters kept in an expiring Store, so that processes sharing
+// the Store's backend share the limits.
+//
+//	limiter := ratelimit.NewFixedWindow(es, 100, time.Minute)
+//	d, err := limiter.Allow("client:" + ip)
+//	if err == nil && !d.Allowed {
+//		w.Header().Set("Retry-After", strconv.Itoa(int(d.RetryAfter.Seconds())+1))
+//		w.WriteHeader(http.StatusTooManyRequests)
+//	}
+package ratelimit
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// Decision is the outcome of asking a limiter for an event.
+	Decision struct {
+		Allowed bool
+		// Remaining is how many more events would be allowed now.
+		Remaining int64
+		// RetryAfter is how long to wait before an event is allowed again,
+		// or 0 if one is allowed now.
+		RetryAfter time.Duration
+	}
+
+	// FixedWindow allows up to limit events per key in each window.
+	FixedWindow struct {
+		es     expiring.Store
+		limit  int64
+		window time.Duration
+		prefix string
+		clock  clock.Clock
+	}
+
+	// TokenBucket allows bursts of up to burst events per key, refilling at
+	// rate events per second.
+	TokenBucket struct {
+		es     expiring.Store
+		rate   float64
+		burst  float64
+		prefix string
+		clock  clock.Clock
+		locks  [64]sync.Mutex
+	}
+
+	// Option configures a limiter.
+	Option func(*config)
+
+	config struct {
+		prefix string
+		clock  clock.Clock
+	}
+)
+
+// DefaultPrefix prefixes the keys of the counters limiters write to the
+// Store.
+const DefaultPrefix = "ratelimit:"
+
+// WithPrefix sets the prefix of the keys limiters write to the Store, so that
+// limiters sharing a Store don't share counters.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithClock sets the clock limiters read the time from. It should be the
+// same clock as the Store's. Defaults to clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{prefix: DefaultPrefix, clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// NewFixedWindow allows up to limit events per key in each window. Windows
+// are aligned to the clock, so that every process agrees on them. Counts are
+// kept with the Store's Increment, so they are shared by processes sharing
+// the Store's backend, and exact if it increments atomically.
+func NewFixedWindow(es expiring.Store, limit int64, window time.Duration, opts ...Option) *FixedWindow {
+	c := newConfig(opts)
+	return &FixedWindow{es: es, limit: limit, window: window, prefix: c.prefix, clock: c.clock}
+}
+
+// Allow counts an event for key, and reports whether it is within the limit.
+// Events which aren't allowed are counted too.
+func (fw *FixedWindow) Allow(key string) (Decision, error) {
+	return fw.AllowN(key, 1)
+}
+
+// AllowN is like Allow, for n events at once.
+func (fw *FixedWindow) AllowN(key string, n int64) (Decision, error) {
+	now := fw.clock.Now()
+	window := now.UnixNano() / int64(fw.window)
+	reset := time.Unix(0, (window+1)*int64(fw.window)).Sub(now)
+	count, err := fw.es.Increment(fmt.Sprintf("%s%s:%d", fw.prefix, key, window), n, reset)
+	if err != nil {
+		return Decision{}, err
+	}
+	if count > fw.limit {
+		return Decision{RetryAfter: reset}, nil
+	}
+	return Decision{Allowed: true, Remaining: fw.limit - count}, nil
+}
+
+// NewTokenBucket allows bursts of up to burst events per key, refilling at
+// rate events per second. A bucket is read and written back for every event,
+// which by its nature can't be made atomic with Increment, so buckets are
+// only exact within a process; processes sharing the Store's backend may
+// together allow a little more than the rate when they race for the same
+// key. Buckets are written with the Store's SetExact, so that options which
+// drop or shorten Sets, such as WithSetSampling, WithAdmission or
+// WithJitter, don't lose them.
+func NewTokenBucket(es expiring.Store, rate float64, burst int64, opts ...Option) *TokenBucket {
+	c := newConfig(opts)
+	return &TokenBucket{es: es, rate: rate, burst: float64(burst), prefix: c.prefix, clock: c.clock}
+}
+
+// Allow takes a token from key's bucket, and reports whether there was one.
+func (tb *TokenBucket) Allow(key string) (Decision, error) {
+	return tb.AllowN(key, 1)
+}
+
+// AllowN is like Allow, for n tokens at once. Either all n are taken, or
+// none.
+func (tb *TokenBucket) AllowN(key string, n int64) (Decision, error) {
+	key = tb.prefix + key
+	mu := &tb.locks[hash(key)%uint64(len(tb.locks))]
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := tb.clock.Now()
+	tokens := tb.burst
+	if val, err := tb.es.Get(key); err == nil {
+		if t, at, ok := parseBucket(val); ok {
+			tokens = t + now.Sub(at).Seconds()*tb.rate
+			if tokens > tb.burst {
+				tokens = tb.burst
+			}
+		}
+	}
+
+	d := Decision{Allowed: tokens >= float64(n)}
+	if d.Allowed {
+		tokens -= float64(n)
+	} else {
+		d.RetryAfter = time.Duration((float64(n) - tokens) / tb.rate * float64(time.Second))
+	}
+	d.Remaining = int64(tokens)
+
+	// a bucket which would have refilled can be forgotten
+	full := time.Duration((tb.burst-tokens)/tb.rate*float64(time.Second)) + time.Second
+	value := strconv.FormatFloat(tokens, 'g', -1, 64) + "," + strconv.FormatInt(now.UnixNano(), 10)
+	if err := tb.es.SetExact(key, value, full); err != nil {
+		return Decision{}, err
+	}
+	return d, nil
+}
+
+// parseBucket reads a bucket written by AllowN: its tokens, and when it
+// had them.
+func parseBucket(val interface{}) (float64, time.Time, bool) {
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return 0, time.Time{}, false
+	}
+	i := strings.IndexByte(s, ',')
+	if i < 0 {
+		return 0, time.Time{}, false
+	}
+	tokens, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	at, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return tokens, time.Unix(0, at), true
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
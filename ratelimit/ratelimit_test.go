@@ -0,0 +1,80 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/nabowler/expiring_gocache/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWindow(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	limiter := ratelimit.NewFixedWindow(es, 2, time.Minute, ratelimit.WithClock(clk))
+
+	for i := int64(1); i >= 0; i-- {
+		d, err := limiter.Allow("client")
+		assert.Nil(t, err)
+		assert.Equal(t, ratelimit.Decision{Allowed: true, Remaining: i}, d)
+	}
+	d, err := limiter.Allow("client")
+	assert.Nil(t, err)
+	// the window started at 960s
+	assert.Equal(t, ratelimit.Decision{RetryAfter: 20 * time.Second}, d)
+
+	// other keys have their own limits
+	d, err = limiter.Allow("other")
+	assert.Nil(t, err)
+	assert.True(t, d.Allowed)
+
+	clk.Advance(20 * time.Second)
+	d, err = limiter.Allow("client")
+	assert.Nil(t, err)
+	assert.True(t, d.Allowed)
+}
+
+func TestTokenBucket(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	limiter := ratelimit.NewTokenBucket(es, 2, 4, ratelimit.WithClock(clk))
+
+	d, err := limiter.AllowN("client", 4)
+	assert.Nil(t, err)
+	assert.Equal(t, ratelimit.Decision{Allowed: true}, d)
+	d, err = limiter.Allow("client")
+	assert.Nil(t, err)
+	assert.Equal(t, ratelimit.Decision{RetryAfter: 500 * time.Millisecond}, d)
+
+	clk.Advance(time.Second)
+	d, err = limiter.Allow("client")
+	assert.Nil(t, err)
+	assert.Equal(t, ratelimit.Decision{Allowed: true, Remaining: 1}, d)
+
+	// the bucket refills no further than burst
+	clk.Advance(time.Hour)
+	d, err = limiter.AllowN("client", 5)
+	assert.Nil(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, int64(4), d.Remaining)
+}
+
+func TestTokenBucketIgnoresSetOptions(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk),
+		expiring.WithAdmission(100, time.Hour), expiring.WithDeterministicSetSampling(0.001), expiring.WithJitter(0.5))
+	limiter := ratelimit.NewTokenBucket(es, 1, 2, ratelimit.WithClock(clk))
+
+	for i := int64(1); i >= 0; i-- {
+		d, err := limiter.Allow("client")
+		assert.Nil(t, err)
+		assert.Equal(t, ratelimit.Decision{Allowed: true, Remaining: i}, d)
+	}
+	d, err := limiter.Allow("client")
+	assert.Nil(t, err)
+	assert.False(t, d.Allowed)
+}
@@ -0,0 +1,89 @@
+package expiring_gocache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// counter is implemented by stores which can count down atomically, e.g.
+// with Redis' DECR. Decrement returns the count left, which is negative once
+// it has run out or if the counter doesn't exist.
+type counter interface {
+	SetCounter(key interface{}, value int64, expiration time.Duration) error
+	Decrement(key interface{}) (int64, error)
+}
+
+// MaxReadsTag returns a tag which, when included in the Tags of the options
+// passed to Set, expires the value once it has been read n times, e.g. for
+// single use tokens. It needs WithReadLimits.
+//
+// Reads are counted by the underlying store if it implements
+// `SetCounter(key interface{}, value int64, expiration time.Duration) error`
+// and `Decrement(key interface{}) (int64, error)`, so that the limit holds
+// across every process sharing it, and otherwise by the Store's tracking
+// index, so that it only holds within the process which wrote the value.
+func MaxReadsTag(n int) string {
+	return directiveTag(maxReadsDirective, strconv.Itoa(n))
+}
+
+// limitReads starts counting the reads of a value just written with a read
+// limit. A value whose counter can't be written is deleted again, since it
+// couldn't be limited.
+func (es Store) limitReads(key interface{}, n uint64, ttl time.Duration) {
+	if c, ok := es.store.(counter); ok {
+		if err := es.innerSetCounter(c, readsKey(key), int64(n), ttl); err != nil {
+			es.untrack(key)
+			es.bestEffortDelete(key)
+		}
+		return
+	}
+	if es.tracker != nil {
+		es.tracker.limitReads(key, n)
+	}
+}
+
+// consumeRead counts a read of key, whose value has a read limit, returning
+// false if the limit has already been reached. The value is expired by the
+// last read it allows. The error of a counter which can't be decremented is
+// returned, since the read can't be counted; reads of values the tracking
+// index doesn't count, e.g. written by another process, are allowed.
+func (es Store) consumeRead(key interface{}) (bool, error) {
+	var left int64
+	c, counted := es.store.(counter)
+	if counted {
+		n, err := es.innerDecrement(c, readsKey(key))
+		if err != nil {
+			return false, err
+		}
+		left = n
+	} else if es.tracker != nil {
+		n, ok := es.tracker.consumeRead(key)
+		if !ok {
+			return true, nil
+		}
+		left = n
+	} else {
+		return true, nil
+	}
+
+	if left <= 0 {
+		es.expire(key)
+		if counted {
+			_ = es.innerDelete(readsKey(key)) // the counter will expire anyway
+		}
+	}
+	return left >= 0, nil
+}
+
+// readAllowed is consumeRead for ew, read for key, if it has a read limit.
+func (es Store) readAllowed(key interface{}, ew wrappedValue) (bool, error) {
+	if ew.maxReads == 0 {
+		return true, nil
+	}
+	return es.consumeRead(key)
+}
+
+func readsKey(key interface{}) string {
+	return fmt.Sprintf("%sreads:%v", directivePrefix, key)
+}
@@ -0,0 +1,152 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// CountingMapStore counts down atomically, like Redis.
+	CountingMapStore struct {
+		MapStore
+		counters map[interface{}]int64
+		err      error
+	}
+)
+
+func TestMaxReads(t *testing.T) {
+	for name, s := range map[string]func() (store.StoreInterface, *MapStore){
+		"tracked": func() (store.StoreInterface, *MapStore) {
+			ms := &MapStore{cache: map[interface{}]interface{}{}}
+			return ms, ms
+		},
+		"counted": func() (store.StoreInterface, *MapStore) {
+			cs := &CountingMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+			return cs, &cs.MapStore
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			inner, ms := s()
+			es := expiring.New(inner, &store.Options{Expiration: time.Hour}, expiring.WithReadLimits())
+
+			assert.Nil(t, es.Set("token", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(3)}}))
+			for i := 0; i < 3; i++ {
+				val, err := es.Get("token")
+				assert.Nil(t, err)
+				assert.Equal(t, "value", val)
+			}
+			// the last read deleted it
+			_, ok := ms.cache["token"]
+			assert.False(t, ok)
+			_, err := es.Get("token")
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestMaxReadsConcurrent(t *testing.T) {
+	cs := &CountingMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(cs, &store.Options{Expiration: time.Hour}, expiring.WithReadLimits())
+	assert.Nil(t, es.Set("token", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(1)}}))
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		hits int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, err := es.GetWithTTL("token")
+			if err == nil && v == "value" {
+				mu.Lock()
+				hits++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, hits)
+}
+
+func TestMaxReadsGetIfChanged(t *testing.T) {
+	for name, s := range map[string]func() store.StoreInterface{
+		"tracked": func() store.StoreInterface { return &MapStore{cache: map[interface{}]interface{}{}} },
+		"counted": func() store.StoreInterface {
+			return &CountingMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			es := expiring.New(s(), &store.Options{Expiration: time.Hour}, expiring.WithReadLimits())
+			assert.Nil(t, es.Set("token", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(1)}}))
+
+			val, _, _, err := es.GetIfChanged("token", "")
+			assert.Nil(t, err)
+			assert.Equal(t, "value", val)
+			for i := 0; i < 2; i++ {
+				val, _, _, err = es.GetIfChanged("token", "")
+				assert.NotNil(t, err)
+				assert.Nil(t, val)
+			}
+		})
+	}
+}
+
+func TestMaxReadsDeniedIfUncounted(t *testing.T) {
+	cs := &CountingMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(cs, &store.Options{Expiration: time.Hour}, expiring.WithReadLimits())
+	assert.Nil(t, es.Set("token", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(1)}}))
+
+	down := errors.New("down")
+	cs.err = down
+	val, err := es.Get("token")
+	assert.Equal(t, down, err)
+	assert.Nil(t, val)
+
+	// the failed read wasn't counted
+	cs.err = nil
+	val, err = es.Get("token")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestMaxReadsNeedsReadLimits(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("token", "value", &store.Options{Tags: []string{expiring.MaxReadsTag(1)}}))
+	for i := 0; i < 2; i++ {
+		_, err := es.Get("token")
+		assert.Nil(t, err)
+	}
+}
+
+func (cs *CountingMapStore) SetCounter(key interface{}, value int64, expiration time.Duration) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.counters == nil {
+		cs.counters = map[interface{}]int64{}
+	}
+	cs.counters[key] = value
+	return nil
+}
+
+func (cs *CountingMapStore) Decrement(key interface{}) (int64, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.counters == nil {
+		cs.counters = map[interface{}]int64{}
+	}
+	if cs.err != nil {
+		return 0, cs.err
+	}
+	cs.counters[key]--
+	return cs.counters[key], nil
+}
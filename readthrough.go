@@ -0,0 +1,94 @@
+package expiring_gocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Source is the system of record a read-through Store fills itself from,
+	// see WithReadThrough. Fetch returns the value of key along with how long
+	// it may be cached for; a TTL of 0 leaves the value to the Store's
+	// default. Errors, including the source's own not found errors, are
+	// returned from Get as they are.
+	Source interface {
+		Fetch(ctx context.Context, key interface{}) (interface{}, time.Duration, error)
+	}
+
+	// SourceFunc adapts a function to a Source.
+	SourceFunc func(ctx context.Context, key interface{}) (interface{}, time.Duration, error)
+
+	// fetchGroup coalesces concurrent fetches of the same key, so that a miss
+	// on a popular key reaches the source once.
+	fetchGroup struct {
+		mu    sync.Mutex
+		calls map[interface{}]*fetchCall
+	}
+
+	fetchCall struct {
+		done  chan struct{}
+		value interface{}
+		err   error
+	}
+)
+
+func (f SourceFunc) Fetch(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+	return f(ctx, key)
+}
+
+// readThrough fetches key from the source after a miss or an expired value,
+// and writes it back to the underlying store. Callers fetching a key which
+// is already being fetched wait for that fetch, which uses the first
+// caller's ctx.
+func (es Store) readThrough(ctx context.Context, key interface{}) (interface{}, error) {
+	return es.fetches.do(ctx, key, func() (interface{}, error) {
+		return es.fetch(ctx, key)
+	})
+}
+
+// do calls fn for key, unless a call for key is already in flight, in which
+// case it waits for that call's result instead. Keys which can't be tracked
+// aren't coalesced.
+func (g *fetchGroup) do(ctx context.Context, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	if !trackable(key) {
+		return fn()
+	}
+
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.value, call.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+func (es Store) fetch(ctx context.Context, key interface{}) (interface{}, error) {
+	atomic.AddUint64(&es.stats.sourceFetches, 1)
+	val, ttl, err := es.fetchFrom(ctx, es.source, key)
+	if err != nil {
+		atomic.AddUint64(&es.stats.sourceErrors, 1)
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = es.defaultTTL(key, val)
+	}
+	_ = es.set(key, val, nil, ttl, time.Time{}) // best effort write back
+	return val, nil
+}
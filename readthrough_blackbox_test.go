@@ -0,0 +1,124 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadThrough(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	var fetches int
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		fetches++
+		return key.(string) + "-value", time.Minute, nil
+	})
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk), expiring.WithReadThrough(source))
+
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "key-value", val)
+	assert.Equal(t, 1, ms.setCount)
+
+	// written back with the source's TTL
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	val, err = es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "key-value", val)
+	assert.Equal(t, 1, fetches)
+
+	// expired values are fetched again
+	clk.Advance(2 * time.Minute)
+	val, err = es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "key-value", val)
+	assert.Equal(t, 2, fetches)
+	assert.Equal(t, uint64(2), es.Stats().SourceFetches)
+}
+
+func TestReadThroughDefaultTTL(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return "value", 0, nil
+	})
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: time.Hour},
+		expiring.WithClock(clk), expiring.WithReadThrough(source))
+
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, ttl)
+}
+
+func TestReadThroughError(t *testing.T) {
+	failure := errors.New("not in the database")
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, failure
+	})
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithReadThrough(source))
+
+	_, err := es.Get("key")
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 0, ms.setCount)
+	assert.Equal(t, uint64(1), es.Stats().SourceErrors)
+
+	// cached errors are returned without a fetch
+	assert.Nil(t, es.SetError("cached", failure, time.Minute))
+	_, err = es.Get("cached")
+	assert.IsType(t, &expiring.CachedError{}, err)
+	assert.Equal(t, uint64(1), es.Stats().SourceFetches)
+}
+
+func TestReadThroughContext(t *testing.T) {
+	type ctxKey struct{}
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return ctx.Value(ctxKey{}), 0, nil
+	})
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithReadThrough(source))
+
+	val, err := es.GetWithContext(context.WithValue(context.Background(), ctxKey{}, "from ctx"), "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "from ctx", val)
+}
+
+func TestReadThroughCoalesces(t *testing.T) {
+	release := make(chan struct{})
+	var fetches int32
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "value", 0, nil
+	})
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithReadThrough(source))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := es.Get("key")
+			assert.Nil(t, err)
+			assert.Equal(t, "value", val)
+		}()
+	}
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
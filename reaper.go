@@ -0,0 +1,88 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	reaper struct {
+		done chan struct{}
+		once sync.Once
+		wg   sync.WaitGroup
+	}
+
+	batchDeleter interface {
+		DeleteMulti(keys []interface{}) error
+	}
+)
+
+func startReaper(es Store, interval time.Duration) *reaper {
+	r := &reaper{done: make(chan struct{})}
+	es.background = true
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		es.supervise("reaper", r.done, func() {
+			ticker := time.NewTicker(interval)
+			defer func() { ticker.Stop() }()
+			for {
+				select {
+				case <-r.done:
+					return
+				case <-ticker.C:
+				}
+				if wake := es.idleWait(); wake != nil {
+					ticker.Stop()
+					select {
+					case <-r.done:
+						return
+					case <-wake:
+					}
+					ticker = time.NewTicker(interval)
+				}
+				es.reap(es.now())
+			}
+		})
+	}()
+	return r
+}
+
+// stop signals the reaper to exit and waits for it to do so.
+func (r *reaper) stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+}
+
+// reap deletes the keys of every expiration bucket whose window has passed,
+// except pinned keys. Deletes are best effort, like the delete of an expired
+// value in Get.
+func (es Store) reap(now time.Time) {
+	for _, keys := range es.keysOf(es.tracker.due(now)...) {
+		keys = es.pins.without(keys)
+		if len(keys) > 0 {
+			es.deleteBatch(keys)
+			for _, key := range keys {
+				es.emit(Event{Type: EventExpire, Key: key})
+			}
+			es.cascade(keys...)
+		}
+	}
+}
+
+func (es Store) deleteBatch(keys []interface{}) {
+	bd, ok := es.store.(batchDeleter)
+	if !ok {
+		for _, key := range keys {
+			es.bestEffortDelete(key)
+		}
+		return
+	}
+	if err := es.innerDeleteMulti(bd, keys); err != nil {
+		for _, key := range keys {
+			es.deleteFailed(key, err, 1)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	BatchMapStore struct {
+		*MapStore
+		deleteMultiCount int
+	}
+)
+
+const (
+	reaperExpiration  = 20 * time.Millisecond
+	reaperBucketWidth = 10 * time.Millisecond
+	reaperInterval    = 5 * time.Millisecond
+	reaperSleep       = reaperExpiration + 2*reaperBucketWidth + 2*reaperInterval
+)
+
+// awaitReaper advances clk past the expiration of values set with
+// reaperExpiration, and waits for the reaper to run until reaped is true.
+func awaitReaper(t *testing.T, clk *clock.Fake, reaped func() bool) {
+	clk.Advance(reaperExpiration + 2*reaperBucketWidth)
+	deadline := time.Now().Add(time.Second)
+	for !reaped() && time.Now().Before(deadline) {
+		time.Sleep(reaperInterval)
+	}
+	assert.True(t, reaped(), "not reaped")
+}
+
+func TestReaperDeletesExpiredValues(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+
+	assert.Nil(t, es.Set("short", "value", nil))
+	assert.Nil(t, es.Set("long", "value", &store.Options{Expiration: time.Hour}))
+
+	time.Sleep(reaperSleep)
+	assert.Nil(t, es.Close())
+
+	// the expired value was reaped without being read
+	assert.Equal(t, 0, ms.getCount)
+	assert.Equal(t, 1, ms.deleteCount)
+	_, ok := ms.cache["short"]
+	assert.False(t, ok)
+	_, ok = ms.cache["long"]
+	assert.True(t, ok)
+}
+
+func TestReaperWaitsForBucketWindow(t *testing.T) {
+	bms := BatchMapStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&bms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(time.Hour),
+	)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, es.Set(key, "value", nil))
+	}
+	// all three keys share one bucket, which won't end for up to an hour
+	time.Sleep(reaperSleep)
+	assert.Equal(t, 0, bms.deleteMultiCount)
+	assert.Nil(t, es.Close())
+	assert.Len(t, bms.cache, 3)
+}
+
+func TestReaperBatchDelete(t *testing.T) {
+	bms := BatchMapStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&bms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, es.Set(key, "value", nil))
+	}
+
+	time.Sleep(reaperSleep)
+	assert.Nil(t, es.Close())
+
+	assert.Empty(t, bms.cache)
+	assert.Equal(t, 0, bms.deleteCount)
+	assert.True(t, bms.deleteMultiCount >= 1)
+}
+
+func TestReaperIgnoresDeletedAndResetKeys(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: reaperExpiration},
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+
+	assert.Nil(t, es.Set("deleted", "value", nil))
+	assert.Nil(t, es.Delete("deleted"))
+	assert.Nil(t, es.Set("reset", "value", nil))
+	assert.Nil(t, es.Set("reset", "value", &store.Options{Expiration: time.Hour}))
+
+	time.Sleep(reaperSleep)
+	assert.Nil(t, es.Close())
+
+	// only the explicit Delete reached the store
+	assert.Equal(t, 1, ms.deleteCount)
+	_, ok := ms.cache["reset"]
+	assert.True(t, ok)
+}
+
+func TestCloseWithoutReaper(t *testing.T) {
+	es := expiring.New(nil, nil)
+	assert.Nil(t, es.Close())
+}
+
+// BatchMapStore implementation
+
+func (bms *BatchMapStore) DeleteMulti(keys []interface{}) error {
+	bms.mu.Lock()
+	defer bms.mu.Unlock()
+	bms.deleteMultiCount++
+	for _, key := range keys {
+		delete(bms.cache, key)
+	}
+	return nil
+}
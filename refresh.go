@@ -0,0 +1,215 @@
+package expiring_gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// ScheduledRefresh describes a key refreshed by ScheduleRefresh.
+	ScheduledRefresh struct {
+		Key      interface{}
+		Interval time.Duration
+		// LastRefresh is when the key was last loaded successfully, or the
+		// zero time if it hasn't been yet.
+		LastRefresh time.Time
+		// Failures counts the loads which have failed since, and LastError
+		// is the error of the latest.
+		Failures  int
+		LastError error
+		// NextRefresh is when the key will next be loaded.
+		NextRefresh time.Time
+	}
+
+	// refreshScheduler runs the refreshes scheduled by ScheduleRefresh.
+	refreshScheduler struct {
+		mu     sync.Mutex
+		jobs   map[interface{}]*refreshJob
+		closed bool
+		wg     sync.WaitGroup
+	}
+
+	refreshJob struct {
+		cancel context.CancelFunc
+
+		mu     sync.Mutex
+		status ScheduledRefresh
+	}
+)
+
+const (
+	// DefaultRefreshBackoff is how long a scheduled refresh waits before
+	// its first retry after a failed load, see WithRefreshBackoff. The wait
+	// doubles with each failure, up to MaxRefreshBackoff.
+	DefaultRefreshBackoff = time.Second
+	MaxRefreshBackoff     = 5 * time.Minute
+)
+
+var (
+	InvalidRefreshIntervalError = errors.New("refresh interval must be positive")
+	RefreshClosedError          = errors.New("store is closed to scheduled refreshes")
+)
+
+// ScheduleRefresh loads key from loader, and sets it with the TTL it gives,
+// now and every interval from then on, however often it is read, so that
+// critical keys never go cold. A failed load is retried sooner, backing off
+// from WithRefreshBackoff's wait up to interval; the value already cached is
+// kept meanwhile. Scheduling a key again replaces its schedule. Refreshes
+// run until they are cancelled with CancelRefresh, or the Store is closed.
+func (es Store) ScheduleRefresh(key interface{}, interval time.Duration, loader Source) error {
+	if interval <= 0 {
+		return InvalidRefreshIntervalError
+	}
+	if !trackable(key) {
+		return UntrackableKeyError
+	}
+	s := es.refreshes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return RefreshClosedError
+	}
+	if job, ok := s.jobs[key]; ok {
+		job.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &refreshJob{cancel: cancel, status: ScheduledRefresh{Key: key, Interval: interval, NextRefresh: es.now()}}
+	s.jobs[key] = job
+	s.wg.Add(1)
+	es.background = true
+	go func() {
+		defer s.wg.Done()
+		es.supervise(fmt.Sprint("refresh ", key), ctx.Done(), func() {
+			es.runRefresh(ctx, job, loader)
+		})
+	}()
+	return nil
+}
+
+// CancelRefresh stops the refreshes of key scheduled by ScheduleRefresh,
+// reporting whether there were any. The value already cached is kept.
+func (es Store) CancelRefresh(key interface{}) bool {
+	if !trackable(key) {
+		return false
+	}
+	s := es.refreshes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[key]
+	if ok {
+		job.cancel()
+		delete(s.jobs, key)
+	}
+	return ok
+}
+
+// ScheduledRefreshes returns the refreshes scheduled by ScheduleRefresh, in
+// no particular order.
+func (es Store) ScheduledRefreshes() []ScheduledRefresh {
+	s := es.refreshes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refreshes := make([]ScheduledRefresh, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job.mu.Lock()
+		refreshes = append(refreshes, job.status)
+		job.mu.Unlock()
+	}
+	return refreshes
+}
+
+// runRefresh loads job's key until ctx is cancelled. While the Store is
+// idle, see WithIdleSuspend, loads wait for it to be used again.
+func (es Store) runRefresh(ctx context.Context, job *refreshJob, loader Source) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if wake := es.idleWait(); wake != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			}
+		}
+		wait := es.refresh(ctx, job, loader)
+		if ctx.Err() != nil {
+			return
+		}
+		timer.Reset(wait)
+	}
+}
+
+// refresh loads job's key once, returning how long to wait before the next
+// load.
+func (es Store) refresh(ctx context.Context, job *refreshJob, loader Source) time.Duration {
+	job.mu.Lock()
+	key, interval, failures := job.status.Key, job.status.Interval, job.status.Failures
+	job.mu.Unlock()
+
+	value, ttl, err := es.fetchFrom(ctx, loader, key)
+	if err == nil && ctx.Err() == nil {
+		var options *store.Options
+		if ttl > 0 {
+			options = &store.Options{Expiration: ttl}
+		}
+		err = es.Set(key, value, options)
+	}
+	if ctx.Err() != nil {
+		// cancelled; the result no longer matters
+		return 0
+	}
+
+	wait := interval
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if err != nil {
+		atomic.AddUint64(&es.stats.scheduledRefreshFailures, 1)
+		job.status.Failures, job.status.LastError = failures+1, err
+		wait = es.retryWait(failures, interval)
+	} else {
+		atomic.AddUint64(&es.stats.scheduledRefreshes, 1)
+		job.status.LastRefresh, job.status.Failures, job.status.LastError = es.now(), 0, nil
+	}
+	job.status.NextRefresh = es.now().Add(wait)
+	return wait
+}
+
+// retryWait returns how long a refresh of the given interval waits after a
+// failed load which follows failures others: the backoff, doubled for each
+// of them, up to MaxRefreshBackoff and the interval.
+func (es Store) retryWait(failures int, interval time.Duration) time.Duration {
+	wait := es.refreshBackoff
+	for i := 0; i < failures && wait < MaxRefreshBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxRefreshBackoff {
+		wait = MaxRefreshBackoff
+	}
+	if wait > interval {
+		wait = interval
+	}
+	return wait
+}
+
+// stop cancels every scheduled refresh, and waits for them to finish.
+func (s *refreshScheduler) stop() {
+	s.mu.Lock()
+	s.closed = true
+	for key, job := range s.jobs {
+		job.cancel()
+		delete(s.jobs, key)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
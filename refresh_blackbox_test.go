@@ -0,0 +1,89 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleRefresh(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithRefreshBackoff(time.Millisecond))
+	defer es.Close()
+
+	var loads int64
+	loader := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		n := atomic.AddInt64(&loads, 1)
+		if n == 1 {
+			return nil, 0, errors.New("unavailable")
+		}
+		return n, time.Minute, nil
+	})
+	assert.Nil(t, es.ScheduleRefresh("key", 10*time.Millisecond, loader))
+
+	// retried after the failure, then refreshed on the interval
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&loads) < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, atomic.LoadInt64(&loads) >= 4)
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, val.(int64) >= 3)
+	assert.True(t, ttl <= time.Minute)
+
+	refreshes := es.ScheduledRefreshes()
+	if assert.Len(t, refreshes, 1) {
+		assert.Equal(t, "key", refreshes[0].Key)
+		assert.Equal(t, 10*time.Millisecond, refreshes[0].Interval)
+		assert.False(t, refreshes[0].LastRefresh.IsZero())
+	}
+	stats := es.Stats()
+	assert.Equal(t, uint64(1), stats.ScheduledRefreshFailures)
+	assert.True(t, stats.ScheduledRefreshes >= 2)
+
+	assert.True(t, es.CancelRefresh("key"))
+	assert.False(t, es.CancelRefresh("key"))
+	assert.Empty(t, es.ScheduledRefreshes())
+}
+
+func TestScheduleRefreshBackoffCapped(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithClock(clk), expiring.WithRefreshBackoff(time.Hour))
+	defer es.Close()
+
+	loader := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, errors.New("unavailable")
+	})
+	assert.Nil(t, es.ScheduleRefresh("key", 24*time.Hour, loader))
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if refreshes := es.ScheduledRefreshes(); len(refreshes) == 1 && refreshes[0].Failures == 1 {
+			assert.Equal(t, clk.Now().Add(expiring.MaxRefreshBackoff), refreshes[0].NextRefresh)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("the load never failed")
+}
+
+func TestScheduleRefreshClosed(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	loader := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return "value", 0, nil
+	})
+
+	assert.Equal(t, expiring.InvalidRefreshIntervalError, es.ScheduleRefresh("key", 0, loader))
+	assert.Nil(t, es.ScheduleRefresh("key", time.Hour, loader))
+	assert.Nil(t, es.Close())
+	assert.Empty(t, es.ScheduledRefreshes())
+	assert.Equal(t, expiring.RefreshClosedError, es.ScheduleRefresh("key", time.Hour, loader))
+}
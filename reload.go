@@ -0,0 +1,132 @@
+package expiring_gocache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// UpdateConfig replaces the default expiration, jitter, per-prefix TTLs and
+// entry limit of a running Store with those in cfg, without losing tracked
+// state. A zero DefaultTTL keeps the current default; the other reloadable
+// fields are replaced as given, so an empty PrefixTTLs removes every prefix
+// policy. Values already written keep the expiration they were written with.
+//
+// The reaper interval, bucket width, type name and instance ID are fixed when
+// the Store is created. A *ConfigError is returned, and nothing changed, if
+// cfg is invalid or asks to change any of them. MaxEntries also can't be
+// enabled on a Store which doesn't track its keys (see WithMaxEntries).
+//
+// Lowering MaxEntries evicts values immediately.
+func (es Store) UpdateConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	var problems []string
+	fixed := func(name string, differs bool) {
+		if differs {
+			problems = append(problems, fmt.Sprintf("%s can't be changed on a running store", name))
+		}
+	}
+	fixed("reaper_interval", cfg.ReaperInterval != 0 && time.Duration(cfg.ReaperInterval) != es.reaperInterval)
+	fixed("bucket_width", cfg.BucketWidth != 0 && time.Duration(cfg.BucketWidth) != es.bucketWidth)
+	fixed("type_name", cfg.TypeName != "" && cfg.TypeName != es.typeName)
+	fixed("instance_id", cfg.InstanceID != "" && cfg.InstanceID != es.instanceID)
+	if cfg.MaxEntries > 0 && es.tracker == nil {
+		problems = append(problems, "max_entries can't be enabled on a store created without it")
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	es.settings.update(func(s *settings) {
+		if cfg.DefaultTTL > 0 {
+			s.expiration = time.Duration(cfg.DefaultTTL)
+		}
+		s.jitter = cfg.Jitter
+		s.prefixTTLs = nil
+		for _, p := range cfg.PrefixTTLs {
+			s.prefixTTLs = addPrefixTTL(s.prefixTTLs, p.Prefix, time.Duration(p.TTL))
+		}
+		s.maxEntries = cfg.MaxEntries
+	})
+	es.evict()
+	return nil
+}
+
+// DefaultExpiration returns the Store's current default expiration, for
+// values Set without one.
+func (es Store) DefaultExpiration() time.Duration {
+	return es.settings.load().expiration
+}
+
+// SetDefaultExpiration replaces the default expiration of a running Store,
+// e.g. from an admin endpoint, keeping its tracked state, hooks and
+// background workers. Values already written keep the expiration they were
+// written with. A *ConfigError is returned, and nothing changed, if d isn't
+// positive.
+func (es Store) SetDefaultExpiration(d time.Duration) error {
+	if d <= 0 {
+		return &ConfigError{Problems: []string{fmt.Sprintf("default_ttl must be positive, got %s", d)}}
+	}
+	es.settings.update(func(s *settings) {
+		s.expiration = d
+	})
+	return nil
+}
+
+// WatchConfigFile polls the file at path every interval, and applies it with
+// UpdateConfig whenever its contents change, starting with the first poll.
+// decode parses the file into a *Config, e.g. json.Unmarshal or
+// yaml.Unmarshal. Errors reading, decoding or applying the file are passed to
+// onError, which may be nil, and leave the Store's settings unchanged.
+//
+// The returned function stops watching, waiting for a poll in progress to
+// finish.
+func (es Store) WatchConfigFile(path string, interval time.Duration, decode func([]byte, interface{}) error, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	failed := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var applied []byte
+		for {
+			data, err := ioutil.ReadFile(path)
+			switch {
+			case err != nil:
+				failed(err)
+			case applied == nil || !bytes.Equal(data, applied):
+				var cfg Config
+				if err := decode(data, &cfg); err != nil {
+					failed(fmt.Errorf("decoding %s: %v", path, err))
+				} else if err := es.UpdateConfig(cfg); err != nil {
+					failed(err)
+				}
+				// a bad file is reported once, not on every poll
+				applied = data
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
@@ -0,0 +1,119 @@
+package expiring_gocache_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertTTL(t *testing.T, es expiring.Store, key string, expected time.Duration) {
+	assert.Nil(t, es.Set(key, "value", nil))
+	_, ttl, err := es.GetWithTTL(key)
+	assert.Nil(t, err)
+	assert.True(t, ttl > expected-time.Second && ttl <= expected, "%s has ttl %s", key, ttl)
+}
+
+func TestUpdateConfig(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithPrefixTTL("session:", 5*time.Minute),
+		expiring.WithMaxEntries(10),
+	)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, es.Set(i, "value", nil))
+	}
+
+	assert.Nil(t, es.UpdateConfig(expiring.Config{
+		DefaultTTL: expiring.Duration(2 * time.Hour),
+		PrefixTTLs: []expiring.PrefixPolicy{{Prefix: "catalog:", TTL: expiring.Duration(time.Minute)}},
+		MaxEntries: 3,
+	}))
+	assert.Equal(t, uint64(2), es.Stats().Evictions)
+
+	assertTTL(t, es, "other", 2*time.Hour)
+	assertTTL(t, es, "session:user", 2*time.Hour)
+	assertTTL(t, es, "catalog:item", time.Minute)
+
+	// a zero default keeps the current one
+	assert.Nil(t, es.UpdateConfig(expiring.Config{MaxEntries: 3}))
+	assertTTL(t, es, "other", 2*time.Hour)
+}
+
+func TestUpdateConfigRejectsFixedSettings(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	err := es.UpdateConfig(expiring.Config{
+		DefaultTTL:     expiring.Duration(time.Minute),
+		ReaperInterval: expiring.Duration(time.Second),
+		TypeName:       "renamed",
+		MaxEntries:     10,
+	})
+	configErr, ok := err.(*expiring.ConfigError)
+	assert.True(t, ok)
+	assert.Len(t, configErr.Problems, 3)
+	assertTTL(t, es, "key", time.Hour)
+
+	// unchanged fixed settings are accepted
+	assert.Nil(t, es.UpdateConfig(expiring.Config{DefaultTTL: expiring.Duration(time.Minute), TypeName: expiring.ExpiringStoreType}))
+	assertTTL(t, es, "key", time.Minute)
+
+	assert.IsType(t, &expiring.ConfigError{}, es.UpdateConfig(expiring.Config{Jitter: 2}))
+}
+
+func TestSetDefaultExpiration(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithPrefixTTL("user:", time.Minute))
+	assertTTL(t, es, "key", time.Hour)
+
+	assert.Nil(t, es.SetDefaultExpiration(10*time.Minute))
+	assertTTL(t, es, "key", 10*time.Minute)
+	// other settings are kept
+	assertTTL(t, es, "user:1", time.Minute)
+
+	assert.IsType(t, &expiring.ConfigError{}, es.SetDefaultExpiration(0))
+	assertTTL(t, es, "key", 10*time.Minute)
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expiring")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"default_ttl": "10m"}`), 0600))
+
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+	errs := make(chan error, 10)
+	stop := es.WatchConfigFile(path, 5*time.Millisecond, json.Unmarshal, func(err error) { errs <- err })
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	assertTTL(t, es, "key", 10*time.Minute)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"default_ttl": "soon"}`), 0600))
+	select {
+	case err := <-errs:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("bad config was not reported")
+	}
+	assertTTL(t, es, "key", 10*time.Minute)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"default_ttl": "20m"}`), 0600))
+	time.Sleep(20 * time.Millisecond)
+	assertTTL(t, es, "key", 20*time.Minute)
+
+	stop()
+	stop()
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"default_ttl": "30m"}`), 0600))
+	time.Sleep(20 * time.Millisecond)
+	assertTTL(t, es, "key", 20*time.Minute)
+}
@@ -0,0 +1,98 @@
+package expiring_gocache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	requestCacheContextKey struct{}
+
+	// requestCache holds the values read during one request, for every Store
+	// the request reads from.
+	requestCache struct {
+		mu      sync.Mutex
+		entries map[requestCacheKey]requestCacheEntry
+	}
+
+	// requestCacheKey identifies a key of one Store. Stores are told apart
+	// by their stats, which every copy of a Store shares.
+	requestCacheKey struct {
+		store *stats
+		key   interface{}
+	}
+
+	requestCacheEntry struct {
+		value    interface{}
+		expireAt time.Time
+	}
+)
+
+// WithRequestCache returns a copy of ctx carrying an empty in-memory cache,
+// so that repeated GetWithContext calls for the same key with ctx, or
+// contexts derived from it, read the underlying store once. Values are still
+// expired at the same time as in the underlying store. Misses aren't cached.
+//
+// The cache lives as long as ctx, so it should be scoped to one request.
+// Writes made with SetWithContext and ctx are seen by later reads; other
+// writes, e.g. by other requests, aren't.
+func WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheContextKey{}, &requestCache{entries: map[requestCacheKey]requestCacheEntry{}})
+}
+
+func requestCacheFrom(ctx context.Context) *requestCache {
+	rc, _ := ctx.Value(requestCacheContextKey{}).(*requestCache)
+	return rc
+}
+
+func (rc *requestCache) get(s *stats, key interface{}, now time.Time) (interface{}, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	k := requestCacheKey{store: s, key: key}
+	entry, ok := rc.entries[k]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expireAt.IsZero() && !now.Before(entry.expireAt) {
+		delete(rc.entries, k)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (rc *requestCache) put(s *stats, key interface{}, value interface{}, expireAt time.Time) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries[requestCacheKey{store: s, key: key}] = requestCacheEntry{value: value, expireAt: expireAt}
+}
+
+func (rc *requestCache) forget(s *stats, key interface{}) {
+	if !trackable(key) {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.entries, requestCacheKey{store: s, key: key})
+}
+
+// getRequestCached reads key from rc, or else from the underlying store,
+// keeping hits in rc until they expire.
+func (es Store) getRequestCached(ctx context.Context, rc *requestCache, key interface{}) (interface{}, error) {
+	if val, ok := rc.get(es.stats, key, es.now()); ok {
+		atomic.AddUint64(&es.stats.requestCacheHits, 1)
+		return es.clone(val), nil
+	}
+
+	val, ttl, err := es.getWithTTL(key)
+	if err != nil {
+		return es.miss(ctx, key, val, err)
+	}
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = es.now().Add(ttl)
+	}
+	rc.put(es.stats, key, val, expireAt)
+	return es.clone(val), nil
+}
@@ -0,0 +1,64 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCache(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk))
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	ctx := expiring.WithRequestCache(context.Background())
+	for i := 0; i < 5; i++ {
+		val, err := es.GetWithContext(ctx, "key")
+		assert.Nil(t, err)
+		assert.Equal(t, "value", val)
+	}
+	assert.Equal(t, 1, ms.getCount)
+	assert.Equal(t, uint64(4), es.Stats().RequestCacheHits)
+
+	// another request reads the store again
+	_, err := es.GetWithContext(expiring.WithRequestCache(context.Background()), "key")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, ms.getCount)
+
+	// writes with the request's context are seen
+	assert.Nil(t, es.SetWithContext(ctx, "key", "newer", nil))
+	val, err := es.GetWithContext(ctx, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "newer", val)
+
+	// values still expire
+	clk.Advance(2 * time.Minute)
+	_, err = es.GetWithContext(ctx, "key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
+
+func TestRequestCacheMisses(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+	other := expiring.New(&MapStore{cache: map[interface{}]interface{}{"key": "other"}}, nil)
+	ctx := expiring.WithRequestCache(context.Background())
+
+	_, err := es.GetWithContext(ctx, "key")
+	assert.Equal(t, MapStoreMiss, err)
+	_, err = es.GetWithContext(ctx, "key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Equal(t, 2, ms.getCount)
+
+	// Stores sharing a request don't see each other's values
+	val, err := other.GetWithContext(ctx, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "other", val)
+	_, err = es.GetWithContext(ctx, "key")
+	assert.Equal(t, MapStoreMiss, err)
+}
@@ -0,0 +1,229 @@
+package expiring_gocache
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// RotatingStore is a store.StoreInterface which writes values under a
+	// namespace for the time window they are written in, e.g. the hour, and
+	// drops each namespace in one go once every value in it has expired.
+	// For caches where every value has the same TTL, this makes mass expiry
+	// one call per window, rather than a delete per value. Wrap it with New
+	// to expire values individually too.
+	//
+	// Namespaces are dropped with `DeletePrefix(prefix string) error` if the
+	// underlying store implements it, or else by listing its keys with
+	// `Keys() ([]interface{}, error)` and deleting those in the namespace.
+	// DeletePrefix only drops string keys.
+	RotatingStore struct {
+		store   store.StoreInterface
+		window  time.Duration
+		retain  int64
+		onError func(err error)
+		wg      *sync.WaitGroup
+
+		mu   *sync.Mutex
+		live []int64
+	}
+
+	// RotatingStoreOption configures a RotatingStore.
+	RotatingStoreOption func(*RotatingStore)
+
+	// rotatedKey is the key in the underlying store for a non-string key of
+	// a RotatingStore.
+	rotatedKey struct {
+		window int64
+		key    interface{}
+	}
+
+	prefixDeleter interface {
+		DeletePrefix(prefix string) error
+	}
+)
+
+const RotatingStoreType = "rotating"
+
+// NewRotatingStore rotates the namespaces of store every window. Each
+// namespace is dropped once ttl has passed since its window ended, so values
+// written with a longer TTL are dropped early.
+func NewRotatingStore(store store.StoreInterface, window, ttl time.Duration, opts ...RotatingStoreOption) *RotatingStore {
+	retain := int64(ttl / window)
+	if ttl%window != 0 {
+		retain++
+	}
+	rs := &RotatingStore{
+		store:  store,
+		window: window,
+		retain: retain,
+		wg:     &sync.WaitGroup{},
+		mu:     &sync.Mutex{},
+		live:   []int64{windowOf(time.Now(), window)},
+	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	return rs
+}
+
+// RotatingStoreErrorHook passes errors dropping a namespace to onError.
+func RotatingStoreErrorHook(onError func(err error)) RotatingStoreOption {
+	return func(rs *RotatingStore) {
+		rs.onError = onError
+	}
+}
+
+// Get returns the value from the newest namespace which has it.
+func (rs *RotatingStore) Get(key interface{}) (interface{}, error) {
+	var err error
+	live := rs.rotate(time.Now())
+	for i := len(live) - 1; i >= 0; i-- {
+		var val interface{}
+		val, err = rs.store.Get(rotateKey(live[i], key))
+		if err == nil && val != nil {
+			return val, nil
+		}
+	}
+	return nil, err
+}
+
+// Set writes the value in the current window's namespace.
+func (rs *RotatingStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	live := rs.rotate(time.Now())
+	return rs.store.Set(rotateKey(live[len(live)-1], key), value, options)
+}
+
+// Delete deletes the value from every namespace which may hold it.
+func (rs *RotatingStore) Delete(key interface{}) error {
+	for _, w := range rs.rotate(time.Now()) {
+		if err := rs.store.Delete(rotateKey(w, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *RotatingStore) Invalidate(options store.InvalidateOptions) error {
+	return rs.store.Invalidate(options)
+}
+
+// Clear clears the underlying store, if it can be cleared.
+func (rs *RotatingStore) Clear() error {
+	if c, ok := rs.store.(clearer); ok {
+		return c.Clear()
+	}
+	return nil
+}
+
+func (rs *RotatingStore) GetType() string {
+	return RotatingStoreType
+}
+
+// Close waits for namespaces being dropped. The underlying store is not
+// closed.
+func (rs *RotatingStore) Close() error {
+	rs.wg.Wait()
+	return nil
+}
+
+// rotate moves to the window containing now, dropping the namespaces which
+// have expired in the background, and returns the windows whose namespaces
+// may hold values, oldest first.
+func (rs *RotatingStore) rotate(now time.Time) []int64 {
+	current := windowOf(now, rs.window)
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if current <= rs.live[len(rs.live)-1] {
+		return rs.live
+	}
+
+	// rs.live is replaced, never modified, so callers can keep using it
+	var live, expired []int64
+	for _, w := range rs.live {
+		if w < current-rs.retain {
+			expired = append(expired, w)
+		} else {
+			live = append(live, w)
+		}
+	}
+	rs.live = append(live, current)
+	for _, w := range expired {
+		rs.wg.Add(1)
+		go func(w int64) {
+			defer rs.wg.Done()
+			if err := rs.drop(w); err != nil && rs.onError != nil {
+				rs.onError(err)
+			}
+		}(w)
+	}
+	return rs.live
+}
+
+// drop deletes every value in the namespace of window w.
+func (rs *RotatingStore) drop(w int64) error {
+	if pd, ok := rs.store.(prefixDeleter); ok {
+		return pd.DeletePrefix(windowPrefix(w))
+	}
+	kl, ok := rs.store.(keyLister)
+	if !ok {
+		return UnsupportedError
+	}
+	keys, err := kl.Keys()
+	if err != nil {
+		return err
+	}
+	var dropped []interface{}
+	for _, key := range keys {
+		if inWindow(w, key) {
+			dropped = append(dropped, key)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	if bd, ok := rs.store.(batchDeleter); ok {
+		return bd.DeleteMulti(dropped)
+	}
+	for _, key := range dropped {
+		if err := rs.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func windowOf(t time.Time, window time.Duration) int64 {
+	return t.UnixNano() / int64(window)
+}
+
+// windowPrefix is the prefix of string keys in the namespace of window w.
+func windowPrefix(w int64) string {
+	return "rot" + strconv.FormatInt(w, 10) + ":"
+}
+
+// rotateKey returns the key used in the underlying store for key in the
+// namespace of window w. As with instance IDs, string keys are prefixed so
+// that they remain strings, and other keys are paired with the window.
+func rotateKey(w int64, key interface{}) interface{} {
+	if s, ok := key.(string); ok {
+		return windowPrefix(w) + s
+	}
+	return rotatedKey{window: w, key: key}
+}
+
+// inWindow reports whether key of the underlying store is in the namespace
+// of window w.
+func inWindow(w int64, key interface{}) bool {
+	switch k := key.(type) {
+	case string:
+		return strings.HasPrefix(k, windowPrefix(w))
+	case rotatedKey:
+		return k.window == w
+	}
+	return false
+}
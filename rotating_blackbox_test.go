@@ -0,0 +1,101 @@
+package expiring_gocache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// PrefixMapStore can delete every key with a prefix.
+	PrefixMapStore struct {
+		MapStore
+		prefixes []string
+	}
+)
+
+const rotationWindow = 50 * time.Millisecond
+
+// waitForWindow sleeps until the start of the next rotation window, so that
+// tests don't straddle one.
+func waitForWindow() {
+	now := time.Now()
+	time.Sleep(now.Truncate(rotationWindow).Add(rotationWindow).Sub(now))
+}
+
+func TestRotatingStore(t *testing.T) {
+	ms := PrefixMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	waitForWindow()
+	rs := expiring.NewRotatingStore(&ms, rotationWindow, rotationWindow)
+	es := expiring.New(rs, &store.Options{Expiration: rotationWindow})
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+
+	// the value is still read from the previous window
+	time.Sleep(rotationWindow)
+	assert.Nil(t, es.Set("other", "value", nil))
+	_, err = rs.Get("key")
+	assert.Nil(t, err)
+
+	// two windows on, the first namespace is dropped at once
+	time.Sleep(rotationWindow)
+	_, err = rs.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Nil(t, rs.Close())
+	assert.Len(t, ms.prefixes, 1)
+	assert.Len(t, ms.cache, 1)
+	assert.Equal(t, 0, ms.deleteCount)
+}
+
+func TestRotatingStoreListingKeys(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	waitForWindow()
+	rs := expiring.NewRotatingStore(&ms, rotationWindow, rotationWindow/2)
+
+	assert.Nil(t, rs.Set("key", "value", nil))
+	assert.Nil(t, rs.Set(42, "value", nil))
+	time.Sleep(rotationWindow)
+	assert.Nil(t, rs.Set("key", "newer", nil))
+	val, err := rs.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "newer", val)
+
+	time.Sleep(rotationWindow)
+	_, _ = rs.Get("key")
+	assert.Nil(t, rs.Close())
+	assert.Len(t, ms.cache, 1)
+
+	assert.Nil(t, rs.Delete("key"))
+	assert.Len(t, ms.cache, 0)
+}
+
+func TestRotatingStoreUnsupported(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	errs := make(chan error, 1)
+	waitForWindow()
+	rs := expiring.NewRotatingStore(&ms, rotationWindow, rotationWindow, expiring.RotatingStoreErrorHook(func(err error) { errs <- err }))
+
+	assert.Nil(t, rs.Set("key", "value", nil))
+	time.Sleep(2 * rotationWindow)
+	_, _ = rs.Get("key")
+	assert.Equal(t, expiring.UnsupportedError, <-errs)
+}
+
+func (ms *PrefixMapStore) DeletePrefix(prefix string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.prefixes = append(ms.prefixes, prefix)
+	for key := range ms.cache {
+		if s, ok := key.(string); ok && strings.HasPrefix(s, prefix) {
+			delete(ms.cache, key)
+		}
+	}
+	return nil
+}
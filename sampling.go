@@ -0,0 +1,29 @@
+package expiring_gocache
+
+import (
+	"math"
+	"math/rand"
+)
+
+// sampled reports whether a Set of key should be written, see
+// WithSetSampling. Rates of 0 or less, or NaN, write nothing.
+func (es Store) sampled(key interface{}) bool {
+	if es.setSampleRate >= 1 {
+		return true
+	}
+	if es.deterministicSetSampling {
+		return float64(mix64(keyHash(key)))/math.MaxUint64 < es.setSampleRate
+	}
+	return rand.Float64() < es.setSampleRate
+}
+
+// mix64 spreads the bits of h across the whole range. FNV hashes of short
+// keys differ mostly in their low bits.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,73 @@
+package expiring_gocache_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampledSets = 1000
+
+func TestSetSampling(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithSetSampling(0.25))
+
+	for i := 0; i < sampledSets; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint(i), "value", nil))
+	}
+
+	// allow plenty of slack around the expected 250 writes
+	assert.True(t, ms.setCount > 150 && ms.setCount < 350, "%d sets were sampled", ms.setCount)
+	assert.Equal(t, uint64(sampledSets-ms.setCount), es.Stats().SampledOutSets)
+}
+
+func TestDeterministicSetSampling(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithDeterministicSetSampling(0.25))
+
+	for i := 0; i < sampledSets; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint(i), "value", nil))
+	}
+	sampled := ms.setCount
+	assert.True(t, sampled > 150 && sampled < 350, "%d sets were sampled", sampled)
+
+	// the same keys are sampled again
+	for i := 0; i < sampledSets; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint(i), "value", nil))
+	}
+	assert.Equal(t, 2*sampled, ms.setCount)
+	assert.Len(t, ms.cache, sampled)
+}
+
+func TestSetSamplingDisabled(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithSetSampling(1))
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint(i), "value", nil))
+	}
+	assert.Equal(t, 10, ms.setCount)
+}
+
+func TestSetSamplingNone(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithSetSampling(0))
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint(i), "value", nil))
+	}
+	assert.Equal(t, 0, ms.setCount)
+	assert.Equal(t, uint64(10), es.Stats().SampledOutSets)
+
+	es = expiring.New(&ms, nil, expiring.WithDeterministicSetSampling(0))
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, 0, ms.setCount)
+}
+
+func TestSetSamplingRejectsNaN(t *testing.T) {
+	_, err := expiring.NewValidated(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithSetSampling(math.NaN()))
+	assert.NotNil(t, err)
+}
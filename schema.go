@@ -0,0 +1,50 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+)
+
+type (
+	// FutureEnvelopePolicy is how a Store reads values written by a later
+	// version of this package, in an envelope version it doesn't know, see
+	// WithFutureEnvelopes.
+	FutureEnvelopePolicy int
+
+	// futureValue is the value of an envelope of a later version, which
+	// holds a kind of value this version doesn't know.
+	futureValue struct{}
+)
+
+const (
+	// FutureEnvelopesFail fails reads of such values with
+	// FutureEnvelopeError, as for values of another instance, and leaves
+	// them as they are.
+	FutureEnvelopesFail FutureEnvelopePolicy = iota
+	// FutureEnvelopesIgnore reads such values as expired, without deleting
+	// them, so that the nodes which can read them still do. A value loaded
+	// or set in their place is written in this version's envelope.
+	FutureEnvelopesIgnore
+	// FutureEnvelopesMigrate reads such values as far as the fields this
+	// version knows, and rewrites them in this version's envelope, dropping
+	// the rest. Values of a kind this version doesn't know fail as with
+	// FutureEnvelopesFail.
+	FutureEnvelopesMigrate
+)
+
+// futureEnvelope applies the policy given to WithFutureEnvelopes to ew, read
+// for key, if it was written by a later version of this package.
+func (es Store) futureEnvelope(key interface{}, ew wrappedValue) (wrappedValue, error) {
+	if ew.version <= envelopeVersion {
+		return ew, nil
+	}
+	atomic.AddUint64(&es.stats.futureEnvelopes, 1)
+	switch _, unknown := ew.value.(futureValue); {
+	case es.futureEnvelopes == FutureEnvelopesIgnore:
+		return wrappedValue{}, es.expired()
+	case es.futureEnvelopes == FutureEnvelopesMigrate && !unknown:
+		_ = es.rewrite(key, ew, es.now()) // best effort
+		ew.version = envelopeVersion
+		return ew, nil
+	}
+	return wrappedValue{}, FutureEnvelopeError
+}
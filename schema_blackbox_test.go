@@ -0,0 +1,101 @@
+package expiring_gocache_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeVersions(t *testing.T) {
+	for version := byte(1); version <= 8; version++ {
+		ms := MapStore{cache: map[interface{}]interface{}{}}
+		es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+		ms.cache["key"] = envelopeOf(version, 1, time.Now().Add(time.Hour), nil, "value")
+
+		val, err := es.Get("key")
+		assert.Nil(t, err, "version %d", version)
+		assert.Equal(t, "value", val, "version %d", version)
+	}
+}
+
+func TestFutureEnvelopes(t *testing.T) {
+	future := envelopeOf(9, 1, time.Now().Add(time.Hour), []byte("new field"), "value")
+	for _, tc := range []struct {
+		policy   expiring.FutureEnvelopePolicy
+		value    interface{}
+		err      error
+		rewrites bool
+	}{
+		{policy: expiring.FutureEnvelopesFail, err: expiring.FutureEnvelopeError},
+		{policy: expiring.FutureEnvelopesIgnore, err: expiring.ValueExpiredError},
+		{policy: expiring.FutureEnvelopesMigrate, value: "value", rewrites: true},
+	} {
+		ms := MapStore{cache: map[interface{}]interface{}{}}
+		es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope(), expiring.WithFutureEnvelopes(tc.policy))
+		ms.cache["key"] = future
+
+		val, err := es.Get("key")
+		assert.Equal(t, tc.err, err, "policy %d", tc.policy)
+		assert.Equal(t, tc.value, val, "policy %d", tc.policy)
+		assert.Equal(t, uint64(1), es.Stats().FutureEnvelopes)
+
+		stored, ok := ms.cache["key"].([]byte)
+		assert.True(t, ok)
+		if tc.rewrites {
+			assert.Equal(t, byte(8), stored[2])
+			val, err = es.Get("key")
+			assert.Nil(t, err)
+			assert.Equal(t, "value", val)
+			assert.Equal(t, uint64(1), es.Stats().FutureEnvelopes)
+		} else {
+			assert.Equal(t, future, stored)
+		}
+	}
+}
+
+func TestFutureEnvelopeOfUnknownKind(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope(), expiring.WithFutureEnvelopes(expiring.FutureEnvelopesMigrate))
+	ms.cache["key"] = envelopeOf(9, 42, time.Now().Add(time.Hour), nil, "value")
+
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.FutureEnvelopeError, err)
+	has, err := es.Has("key")
+	assert.Nil(t, err)
+	assert.False(t, has)
+}
+
+func TestParseFutureEnvelope(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour)
+	value, parsedExpireAt, ok := expiring.ParseEnvelope(envelopeOf(9, 0, expireAt, []byte("new field"), "value"))
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, expireAt.UnixNano(), parsedExpireAt.UnixNano())
+}
+
+// envelopeOf returns value in a binary envelope of version, with no
+// instance, tags, generation or read limit. ext is the envelope's
+// extensions, for versions after 7.
+func envelopeOf(version, kind byte, expireAt time.Time, ext []byte, value string) []byte {
+	b := []byte{0xe7, 0x78, version, kind}
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(expireAt.UnixNano()))
+	b = append(b, n[:]...)
+	for v := byte(2); v <= 4 && v <= version; v++ {
+		// no timestamp, etag or createdAt
+		b = append(b, make([]byte, 8)...)
+	}
+	b = append(b, 0) // instance
+	for v := byte(5); v <= 7 && v <= version; v++ {
+		// no tags, generation or read limit
+		b = append(b, 0)
+	}
+	if version >= 8 {
+		b = append(b, byte(len(ext)))
+		b = append(b, ext...)
+	}
+	return append(b, value...)
+}
@@ -0,0 +1,230 @@
+// Package sessions keeps HTTP sessions in an expiring Store. Sessions expire
+// after a period of inactivity which slides forward each time they are read,
+// and optionally after an absolute lifetime however active they are.
+//
+// Manager's Find, Commit and Delete methods match the store interface of
+// session middleware such as github.com/alexedwards/scs, so it can be used
+// as their backend:
+//
+//	m := sessions.New(es, 30*time.Minute, sessions.WithMaxLifetime(24*time.Hour))
+//	sessionManager := scs.New()
+//	sessionManager.Store = m
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// Session is a session's data, along with when it was created and when
+	// it will expire unless it is read again.
+	Session struct {
+		ID        string
+		Data      []byte
+		CreatedAt time.Time
+		ExpiresAt time.Time
+	}
+
+	// Manager creates, reads and destroys sessions.
+	Manager struct {
+		es          expiring.Store
+		idle        time.Duration
+		maxLifetime time.Duration
+		prefix      string
+		clock       clock.Clock
+	}
+
+	// Option configures a Manager.
+	Option func(*Manager)
+
+	// record is a session as it is written to the Store.
+	record struct {
+		Data      []byte `json:"data"`
+		CreatedAt int64  `json:"created_at"`
+		Deadline  int64  `json:"deadline,omitempty"`
+	}
+)
+
+// DefaultPrefix prefixes the keys sessions are written to the Store under.
+const DefaultPrefix = "session:"
+
+var (
+	NotFoundError = errors.New("session not found")
+)
+
+// New creates a Manager whose sessions expire after idle without being
+// read. Reads of es which fail other than with a miss, as classified by its
+// WithErrorClassifier, are returned rather than taken for a missing session.
+func New(es expiring.Store, idle time.Duration, opts ...Option) *Manager {
+	m := &Manager{es: es, idle: idle, prefix: DefaultPrefix, clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithMaxLifetime expires sessions at most d after they were created, however
+// often they are read.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(m *Manager) {
+		m.maxLifetime = d
+	}
+}
+
+// WithPrefix sets the prefix of the keys sessions are written under.
+func WithPrefix(prefix string) Option {
+	return func(m *Manager) {
+		m.prefix = prefix
+	}
+}
+
+// WithClock sets the clock the Manager reads the time from. It should be
+// the same clock as the Store's. Defaults to clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// CreateSession starts a session holding data, with a new random ID.
+func (m *Manager) CreateSession(data []byte) (Session, error) {
+	id, err := newID()
+	if err != nil {
+		return Session{}, err
+	}
+	now := m.clock.Now()
+	rec := record{Data: data, CreatedAt: now.UnixNano()}
+	if m.maxLifetime > 0 {
+		rec.Deadline = now.Add(m.maxLifetime).UnixNano()
+	}
+	return m.write(id, rec, now.Add(m.idle))
+}
+
+// GetSession returns the session with id, and slides its expiration forward
+// by the idle timeout, up to its absolute lifetime. NotFoundError is returned
+// if there is no such session, or it has expired.
+func (m *Manager) GetSession(id string) (Session, error) {
+	rec, err := m.read(id)
+	if err != nil {
+		return Session{}, err
+	}
+	return m.write(id, rec, m.clock.Now().Add(m.idle))
+}
+
+// SaveSession replaces the data of an existing session, which also counts
+// as activity.
+func (m *Manager) SaveSession(id string, data []byte) (Session, error) {
+	rec, err := m.read(id)
+	if err != nil {
+		return Session{}, err
+	}
+	rec.Data = data
+	return m.write(id, rec, m.clock.Now().Add(m.idle))
+}
+
+// DestroySession deletes the session with id.
+func (m *Manager) DestroySession(id string) error {
+	return m.es.Delete(m.prefix + id)
+}
+
+// Find returns the data of the session with token, and whether it was
+// found, without sliding its expiration.
+func (m *Manager) Find(token string) ([]byte, bool, error) {
+	rec, err := m.read(token)
+	if err == NotFoundError {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return rec.Data, true, nil
+}
+
+// Commit writes the session with token, expiring at expiry, or sooner if it
+// would outlive its absolute lifetime. Sessions which don't exist yet are
+// created.
+func (m *Manager) Commit(token string, b []byte, expiry time.Time) error {
+	now := m.clock.Now()
+	rec, err := m.read(token)
+	if err == NotFoundError {
+		rec = record{CreatedAt: now.UnixNano()}
+		if m.maxLifetime > 0 {
+			rec.Deadline = now.Add(m.maxLifetime).UnixNano()
+		}
+	} else if err != nil {
+		return err
+	}
+	rec.Data = b
+	_, err = m.write(token, rec, expiry)
+	return err
+}
+
+// Delete deletes the session with token.
+func (m *Manager) Delete(token string) error {
+	return m.DestroySession(token)
+}
+
+func (m *Manager) read(id string) (record, error) {
+	val, err := m.es.Get(m.prefix + id)
+	if err != nil {
+		if errors.Is(err, expiring.ValueExpiredError) || m.es.IsMiss(err) {
+			return record{}, NotFoundError
+		}
+		// e.g. a transient failure, which mustn't pass for a new session
+		return record{}, err
+	}
+	var b []byte
+	switch v := val.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return record{}, NotFoundError
+	}
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		// not a session, and can't be read as one
+		return record{}, NotFoundError
+	}
+	return rec, nil
+}
+
+// write writes rec, expiring at expiresAt, or at its deadline if that is
+// sooner.
+func (m *Manager) write(id string, rec record, expiresAt time.Time) (Session, error) {
+	if rec.Deadline != 0 {
+		if deadline := time.Unix(0, rec.Deadline); deadline.Before(expiresAt) {
+			expiresAt = deadline
+		}
+	}
+	ttl := expiresAt.Sub(m.clock.Now())
+	if ttl <= 0 {
+		_ = m.es.Delete(m.prefix + id)
+		return Session{}, NotFoundError
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return Session{}, err
+	}
+	if err := m.es.Set(m.prefix+id, b, &store.Options{Expiration: ttl}); err != nil {
+		return Session{}, err
+	}
+	return Session{ID: id, Data: rec.Data, CreatedAt: time.Unix(0, rec.CreatedAt), ExpiresAt: expiresAt}, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
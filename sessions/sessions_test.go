@@ -0,0 +1,127 @@
+package sessions_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/nabowler/expiring_gocache/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func newManager(opts ...sessions.Option) (*sessions.Manager, *clock.Fake) {
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	return sessions.New(es, 10*time.Minute, append(opts, sessions.WithClock(clk))...), clk
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	m, clk := newManager()
+
+	s, err := m.CreateSession([]byte("data"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, s.ID)
+	assert.Equal(t, clk.Now().Add(10*time.Minute), s.ExpiresAt)
+
+	// each read slides the expiration forward
+	for i := 0; i < 3; i++ {
+		clk.Advance(8 * time.Minute)
+		got, err := m.GetSession(s.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("data"), got.Data)
+		assert.Equal(t, s.CreatedAt, got.CreatedAt)
+		assert.Equal(t, clk.Now().Add(10*time.Minute), got.ExpiresAt)
+	}
+
+	clk.Advance(11 * time.Minute)
+	_, err = m.GetSession(s.ID)
+	assert.Equal(t, sessions.NotFoundError, err)
+}
+
+func TestMaxLifetime(t *testing.T) {
+	m, clk := newManager(sessions.WithMaxLifetime(15 * time.Minute))
+
+	s, err := m.CreateSession(nil)
+	assert.Nil(t, err)
+	clk.Advance(8 * time.Minute)
+	got, err := m.GetSession(s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, s.CreatedAt.Add(15*time.Minute), got.ExpiresAt)
+
+	clk.Advance(8 * time.Minute)
+	_, err = m.GetSession(s.ID)
+	assert.Equal(t, sessions.NotFoundError, err)
+}
+
+func TestSaveAndDestroySession(t *testing.T) {
+	m, _ := newManager()
+
+	s, err := m.CreateSession([]byte("v1"))
+	assert.Nil(t, err)
+	_, err = m.SaveSession(s.ID, []byte("v2"))
+	assert.Nil(t, err)
+	got, err := m.GetSession(s.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), got.Data)
+
+	assert.Nil(t, m.DestroySession(s.ID))
+	_, err = m.GetSession(s.ID)
+	assert.Equal(t, sessions.NotFoundError, err)
+	_, err = m.SaveSession(s.ID, []byte("v3"))
+	assert.Equal(t, sessions.NotFoundError, err)
+}
+
+func TestMiddlewareStore(t *testing.T) {
+	m, clk := newManager()
+
+	_, found, err := m.Find("token")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, m.Commit("token", []byte("data"), clk.Now().Add(time.Minute)))
+	b, found, err := m.Find("token")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("data"), b)
+
+	clk.Advance(2 * time.Minute)
+	_, found, err = m.Find("token")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, m.Commit("token", []byte("data"), clk.Now().Add(time.Minute)))
+	assert.Nil(t, m.Delete("token"))
+	_, found, err = m.Find("token")
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestFailedReadKeepsLifetime(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	inner := expiringtest.New()
+	es := expiring.New(inner, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk),
+		expiring.WithErrorClassifier(expiring.MissClassifier(func(err error) bool { return err == expiringtest.NotFoundError })))
+	m := sessions.New(es, 10*time.Minute, sessions.WithMaxLifetime(time.Hour), sessions.WithClock(clk))
+
+	s, err := m.CreateSession([]byte("data"))
+	assert.Nil(t, err)
+	clk.Advance(5 * time.Minute)
+
+	down := errors.New("connection refused")
+	inner.FailNext(expiring.OperationGet, down)
+	assert.Equal(t, down, m.Commit(s.ID, []byte("new data"), clk.Now().Add(10*time.Minute)))
+	inner.FailNext(expiring.OperationGet, down)
+	_, _, err = m.Find(s.ID)
+	assert.Equal(t, down, err)
+
+	// the session still ends at its original deadline
+	assert.Nil(t, m.Commit(s.ID, []byte("new data"), clk.Now().Add(2*time.Hour)))
+	clk.Advance(56 * time.Minute)
+	_, found, err := m.Find(s.ID)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
@@ -0,0 +1,50 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// settings are the parts of a Store's configuration which can change
+	// while it is in use. They are replaced as a whole, never modified.
+	settings struct {
+		expiration time.Duration
+		jitter     float64
+		prefixTTLs []prefixTTL
+		maxEntries int
+	}
+
+	// dynamicSettings holds the current settings, shared by every copy of a
+	// Store.
+	dynamicSettings struct {
+		v atomic.Value
+		// mu serializes updates, so that none is lost.
+		mu sync.Mutex
+	}
+)
+
+func newDynamicSettings(initial settings) *dynamicSettings {
+	d := &dynamicSettings{}
+	d.v.Store(initial)
+	return d
+}
+
+func (d *dynamicSettings) load() settings {
+	return d.v.Load().(settings)
+}
+
+func (d *dynamicSettings) store(s settings) {
+	d.v.Store(s)
+}
+
+// update replaces the settings with a modified copy. Readers see either the
+// old settings or the new, never a mix.
+func (d *dynamicSettings) update(modify func(*settings)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.load()
+	modify(&s)
+	d.store(s)
+}
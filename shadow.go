@@ -0,0 +1,132 @@
+package expiring_gocache
+
+import (
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Shadow configures WithShadow, which mirrors Gets to a second store so
+	// that a new backend, or a new configuration of one, can be checked
+	// against production traffic before it is switched to.
+	Shadow struct {
+		// SampleRate is the fraction of Gets mirrored, from 0 to 1.
+		SampleRate float64
+		// MaxInFlight is how many mirrored Gets may be waiting on the shadow
+		// store at once; Gets sampled beyond it aren't mirrored. Defaults to
+		// DefaultShadowMaxInFlight.
+		MaxInFlight int
+		// Equal reports whether the values read from the two stores agree.
+		// It is given the values as they were cached, with the expiration
+		// wrapped around them removed. Defaults to reflect.DeepEqual.
+		Equal func(primary, shadow interface{}) bool
+		// OnDivergence, if set, is called with each mirrored Get whose
+		// results disagree.
+		OnDivergence func(ShadowDivergence)
+	}
+
+	// ShadowDivergence is a mirrored Get whose results disagree: one store
+	// missed and the other didn't, or they read different values.
+	ShadowDivergence struct {
+		Key                           interface{}
+		Primary, Shadow               interface{}
+		PrimaryErr, ShadowErr         error
+		PrimaryLatency, ShadowLatency time.Duration
+	}
+
+	shadowReader struct {
+		store    store.StoreInterface
+		config   Shadow
+		inFlight int64
+
+		primaryLatency *latencyHistogram
+		shadowLatency  *latencyHistogram
+	}
+)
+
+const DefaultShadowMaxInFlight = 100
+
+func newShadowReader(s store.StoreInterface, config Shadow) *shadowReader {
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = DefaultShadowMaxInFlight
+	}
+	if config.Equal == nil {
+		config.Equal = reflect.DeepEqual
+	}
+	return &shadowReader{
+		store:          s,
+		config:         config,
+		primaryLatency: newHistogram(latencyBounds),
+		shadowLatency:  newHistogram(latencyBounds),
+	}
+}
+
+// mirror reads innerKey from the shadow store, if the Get which read val and
+// err from the underlying store in latency is sampled, and compares the
+// results. The shadow store is read in the background, so that it can't
+// slow the Get down.
+func (es Store) mirror(innerKey interface{}, val interface{}, err error, latency time.Duration) {
+	sr := es.shadow
+	if sr == nil || sr.config.SampleRate <= 0 || rand.Float64() >= sr.config.SampleRate {
+		return
+	}
+	if atomic.AddInt64(&sr.inFlight, 1) > int64(sr.config.MaxInFlight) {
+		atomic.AddInt64(&sr.inFlight, -1)
+		return
+	}
+	atomic.AddUint64(&es.stats.shadowReads, 1)
+	go func() {
+		defer atomic.AddInt64(&sr.inFlight, -1)
+		defer es.recoverPanic("shadow read", nil)
+		start := time.Now()
+		shadowVal, shadowErr := sr.store.Get(innerKey)
+		shadowLatency := time.Since(start)
+		sr.primaryLatency.observe(latency)
+		sr.shadowLatency.observe(shadowLatency)
+
+		if sr.agree(val, err, shadowVal, shadowErr) {
+			return
+		}
+		atomic.AddUint64(&es.stats.shadowDivergences, 1)
+		if sr.config.OnDivergence != nil {
+			sr.config.OnDivergence(ShadowDivergence{
+				Key:            innerKey,
+				Primary:        cachedValue(val),
+				Shadow:         cachedValue(shadowVal),
+				PrimaryErr:     err,
+				ShadowErr:      shadowErr,
+				PrimaryLatency: latency,
+				ShadowLatency:  shadowLatency,
+			})
+		}
+	}()
+}
+
+// agree reports whether two reads agree. Two misses agree, whatever their
+// errors.
+func (sr *shadowReader) agree(primary interface{}, primaryErr error, shadow interface{}, shadowErr error) bool {
+	if primaryErr != nil || shadowErr != nil {
+		return primaryErr != nil && shadowErr != nil
+	}
+	return sr.config.Equal(cachedValue(primary), cachedValue(shadow))
+}
+
+// cachedValue returns the value cached as val, unwrapped if it is wrapped.
+func cachedValue(val interface{}) interface{} {
+	if ew, ok := unwrap(val); ok {
+		return ew.value
+	}
+	return val
+}
+
+func (es Store) shadowSummaries() (primary, shadow *LatencySummary) {
+	if es.shadow == nil {
+		return nil, nil
+	}
+	p, s := es.shadow.primaryLatency.summary(), es.shadow.shadowLatency.summary()
+	return &p, &s
+}
@@ -0,0 +1,65 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadow(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	shadow := MapStore{cache: map[interface{}]interface{}{}}
+	divergences := make(chan expiring.ShadowDivergence, 10)
+	es := expiring.New(&primary, &store.Options{Expiration: time.Hour}, expiring.WithShadow(&shadow, expiring.Shadow{
+		SampleRate:   1,
+		OnDivergence: func(d expiring.ShadowDivergence) { divergences <- d },
+	}))
+	populated := expiring.New(&shadow, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("same", "value", nil))
+	assert.Nil(t, populated.Set("same", "value", nil))
+	assert.Nil(t, es.Set("different", "value", nil))
+	assert.Nil(t, populated.Set("different", "other", nil))
+	assert.Nil(t, es.Set("missing", "value", nil))
+
+	for _, key := range []string{"same", "different", "missing", "neither"} {
+		_, _ = es.Get(key)
+	}
+
+	found := map[interface{}]expiring.ShadowDivergence{}
+	for len(found) < 2 {
+		select {
+		case d := <-divergences:
+			found[d.Key] = d
+		case <-time.After(time.Second):
+			t.Fatal("divergences weren't reported")
+		}
+	}
+	assert.Equal(t, "value", found["different"].Primary)
+	assert.Equal(t, "other", found["different"].Shadow)
+	assert.Equal(t, "value", found["missing"].Primary)
+	assert.NotNil(t, found["missing"].ShadowErr)
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(4), stats.ShadowReads)
+	assert.Equal(t, uint64(2), stats.ShadowDivergences)
+	if assert.NotNil(t, stats.ShadowLatency) {
+		// the agreeing reads may still be being compared
+		assert.True(t, stats.ShadowLatency.Count >= 2)
+	}
+}
+
+func TestShadowNotSampled(t *testing.T) {
+	primary := MapStore{cache: map[interface{}]interface{}{}}
+	shadow := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&primary, &store.Options{Expiration: time.Hour}, expiring.WithShadow(&shadow, expiring.Shadow{}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), es.Stats().ShadowReads)
+	assert.Equal(t, 0, shadow.getCount)
+}
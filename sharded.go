@@ -0,0 +1,493 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// ShardedStore is a store.StoreInterface which spreads keys across
+	// several stores by consistent hashing, e.g. to scale out a cluster of
+	// independent cache nodes. Each shard is given many points on a hash
+	// ring, so that adding or removing a shard only moves the keys of that
+	// shard's points. Keys which move are misses on their new shard. Wrap it
+	// with New to expire values across every shard alike.
+	ShardedStore struct {
+		hasher   Hasher
+		replicas int
+		health   *ShardHealth
+		clock    clock.Clock
+
+		mu     sync.RWMutex
+		shards map[string]*shard
+		ring   []ringPoint
+	}
+
+	// ShardedStoreOption configures a ShardedStore.
+	ShardedStoreOption func(*ShardedStore)
+
+	// Hasher hashes keys onto a ShardedStore's ring. It is also given the
+	// names of the ring's points, as strings.
+	Hasher func(key interface{}) uint64
+
+	// ShardStats counts the calls made to one shard of a ShardedStore.
+	// Ejected, Ejections and Reinstatements are only set with
+	// ShardHealthCheck.
+	ShardStats struct {
+		Name           string
+		Gets           uint64
+		Sets           uint64
+		Deletes        uint64
+		Errors         uint64
+		Ejected        bool
+		Ejections      uint64
+		Reinstatements uint64
+		// Panics counts the panics recovered from the health check's
+		// OnTransition for the shard.
+		Panics uint64
+	}
+
+	// ShardErrors holds the errors of the shards an operation sent to every
+	// shard failed on, by the shards' names.
+	ShardErrors map[string]error
+
+	shard struct {
+		name           string
+		store          store.StoreInterface
+		health         *shardHealth
+		gets           uint64
+		sets           uint64
+		deletes        uint64
+		errors         uint64
+		ejections      uint64
+		reinstatements uint64
+		panics         uint64
+	}
+
+	ringPoint struct {
+		hash  uint64
+		shard *shard
+	}
+)
+
+const (
+	ShardedStoreType = "sharded"
+
+	DefaultShardReplicas = 100
+)
+
+var (
+	NoShardsError       = errors.New("sharded store has no shards")
+	DuplicateShardError = errors.New("a shard with this name already exists")
+	UnknownShardError   = errors.New("no shard with this name")
+)
+
+// NewSharded spreads keys across stores, which are named by their index,
+// "0", "1" and so on. If hasher is nil, DefaultHasher is used.
+func NewSharded(stores []store.StoreInterface, hasher Hasher, opts ...ShardedStoreOption) *ShardedStore {
+	if hasher == nil {
+		hasher = DefaultHasher
+	}
+	ss := &ShardedStore{hasher: hasher, replicas: DefaultShardReplicas, clock: clock.Real{}, shards: map[string]*shard{}}
+	for _, opt := range opts {
+		opt(ss)
+	}
+	for i, s := range stores {
+		ss.shards[strconv.Itoa(i)] = ss.newShard(strconv.Itoa(i), s)
+	}
+	ss.rebuild()
+	return ss
+}
+
+// ShardReplicas sets how many points each shard has on the ring. More points
+// spread keys more evenly. Defaults to DefaultShardReplicas.
+func ShardReplicas(n int) ShardedStoreOption {
+	return func(ss *ShardedStore) {
+		if n > 0 {
+			ss.replicas = n
+		}
+	}
+}
+
+// DefaultHasher hashes the key's default format with FNV-1a.
+func DefaultHasher(key interface{}) uint64 {
+	return mix64(keyHash(key))
+}
+
+// AddShard adds a shard named name. Only the keys which hash to its points
+// move to it.
+func (ss *ShardedStore) AddShard(name string, s store.StoreInterface) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if _, ok := ss.shards[name]; ok {
+		return DuplicateShardError
+	}
+	ss.shards[name] = ss.newShard(name, s)
+	ss.rebuild()
+	return nil
+}
+
+// RemoveShard removes the shard named name. Its keys move to the shards
+// following its points on the ring; the values stored in it are left alone.
+func (ss *ShardedStore) RemoveShard(name string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if _, ok := ss.shards[name]; !ok {
+		return UnknownShardError
+	}
+	delete(ss.shards, name)
+	ss.rebuild()
+	return nil
+}
+
+func (ss *ShardedStore) newShard(name string, s store.StoreInterface) *shard {
+	sh := &shard{name: name, store: s}
+	if ss.health != nil {
+		sh.health = newShardHealth(ss.health.Window)
+	}
+	return sh
+}
+
+// ShardFor returns the name of the shard key is stored in.
+func (ss *ShardedStore) ShardFor(key interface{}) (string, error) {
+	sh, err := ss.shardFor(key)
+	if err != nil {
+		return "", err
+	}
+	return sh.name, nil
+}
+
+// Get reads the value from its shard. If the shard is ejected by
+// ShardHealthCheck with MissOnly, ShardEjectedError is returned.
+func (ss *ShardedStore) Get(key interface{}) (interface{}, error) {
+	sh, err := ss.shardFor(key)
+	if err != nil {
+		return nil, err
+	}
+	if sh.ejected() {
+		return nil, ShardEjectedError
+	}
+	atomic.AddUint64(&sh.gets, 1)
+	start := time.Now()
+	val, err := sh.store.Get(key)
+	return val, ss.record(sh, start, err, true)
+}
+
+// GetWithTTL is like Get, also returning the value's native TTL if its shard
+// can report one, or else 0.
+func (ss *ShardedStore) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	sh, err := ss.shardFor(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sh.ejected() {
+		return nil, 0, ShardEjectedError
+	}
+	atomic.AddUint64(&sh.gets, 1)
+	start := time.Now()
+	val, ttl, err := getWithTTL(sh.store, key)
+	return val, ttl, ss.record(sh, start, err, true)
+}
+
+// Set writes the value to its shard. Writes to shards ejected with MissOnly
+// are dropped.
+func (ss *ShardedStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	sh, err := ss.shardFor(key)
+	if err != nil || sh.ejected() {
+		return err
+	}
+	atomic.AddUint64(&sh.sets, 1)
+	start := time.Now()
+	return ss.record(sh, start, sh.store.Set(key, value, options), false)
+}
+
+func (ss *ShardedStore) Delete(key interface{}) error {
+	sh, err := ss.shardFor(key)
+	if err != nil || sh.ejected() {
+		return err
+	}
+	atomic.AddUint64(&sh.deletes, 1)
+	start := time.Now()
+	return ss.record(sh, start, sh.store.Delete(key), false)
+}
+
+// DeleteMulti deletes keys from their shards, in one call per shard for
+// shards which implement DeleteMulti themselves.
+func (ss *ShardedStore) DeleteMulti(keys []interface{}) error {
+	groups, err := ss.group(len(keys), func(i int) interface{} { return keys[i] })
+	if err != nil {
+		return err
+	}
+	for sh, indexes := range groups {
+		shardKeys := make([]interface{}, len(indexes))
+		for n, i := range indexes {
+			shardKeys[n] = keys[i]
+		}
+		atomic.AddUint64(&sh.deletes, uint64(len(shardKeys)))
+		if bd, ok := sh.store.(batchDeleter); ok {
+			start := time.Now()
+			if err := ss.record(sh, start, bd.DeleteMulti(shardKeys), false); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, key := range shardKeys {
+			start := time.Now()
+			if err := ss.record(sh, start, sh.store.Delete(key), false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetMulti writes items to their shards, in one call per shard for shards
+// which implement SetMulti themselves.
+func (ss *ShardedStore) SetMulti(items []SetItem) error {
+	groups, err := ss.group(len(items), func(i int) interface{} { return items[i].Key })
+	if err != nil {
+		return err
+	}
+	for sh, indexes := range groups {
+		shardItems := make([]SetItem, len(indexes))
+		for n, i := range indexes {
+			shardItems[n] = items[i]
+		}
+		atomic.AddUint64(&sh.sets, uint64(len(shardItems)))
+		if bs, ok := sh.store.(batchSetter); ok {
+			start := time.Now()
+			if err := ss.record(sh, start, bs.SetMulti(shardItems), false); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, item := range shardItems {
+			start := time.Now()
+			if err := ss.record(sh, start, sh.store.Set(item.Key, item.Value, item.Options), false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Invalidate invalidates the tags on every shard.
+func (ss *ShardedStore) Invalidate(options store.InvalidateOptions) error {
+	return ss.each(func(sh *shard) error {
+		return sh.store.Invalidate(options)
+	})
+}
+
+// Clear clears every shard which supports it.
+func (ss *ShardedStore) Clear() error {
+	return ss.each(func(sh *shard) error {
+		if c, ok := sh.store.(clearer); ok {
+			return c.Clear()
+		}
+		return nil
+	})
+}
+
+// Keys returns the keys of every shard, if they can all list them.
+// Otherwise UnsupportedError is returned.
+func (ss *ShardedStore) Keys() ([]interface{}, error) {
+	if !ss.all(func(s store.StoreInterface) bool { _, ok := s.(keyLister); return ok }) {
+		return nil, UnsupportedError
+	}
+	var keys []interface{}
+	err := ss.each(func(sh *shard) error {
+		shardKeys, err := sh.store.(keyLister).Keys()
+		keys = append(keys, shardKeys...)
+		return err
+	})
+	return keys, err
+}
+
+// Len returns the number of values in every shard, if they can all report
+// it or list their keys. Otherwise UnsupportedError is returned.
+func (ss *ShardedStore) Len() (int, error) {
+	if !ss.all(func(s store.StoreInterface) bool {
+		_, lr := s.(lenReporter)
+		_, kl := s.(keyLister)
+		return lr || kl
+	}) {
+		return 0, UnsupportedError
+	}
+	var n int
+	err := ss.each(func(sh *shard) error {
+		if lr, ok := sh.store.(lenReporter); ok {
+			shardLen, err := lr.Len()
+			n += shardLen
+			return err
+		}
+		keys, err := sh.store.(keyLister).Keys()
+		n += len(keys)
+		return err
+	})
+	return n, err
+}
+
+func (ss *ShardedStore) GetType() string {
+	return ShardedStoreType
+}
+
+// Stats returns the call counts and health of every shard, ordered by name.
+func (ss *ShardedStore) Stats() []ShardStats {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	stats := make([]ShardStats, 0, len(ss.shards))
+	for _, sh := range ss.shards {
+		stats = append(stats, ShardStats{
+			Name:    sh.name,
+			Gets:    atomic.LoadUint64(&sh.gets),
+			Sets:    atomic.LoadUint64(&sh.sets),
+			Deletes: atomic.LoadUint64(&sh.deletes),
+			Errors:  atomic.LoadUint64(&sh.errors),
+
+			Ejected:        sh.ejected(),
+			Ejections:      atomic.LoadUint64(&sh.ejections),
+			Reinstatements: atomic.LoadUint64(&sh.reinstatements),
+			Panics:         atomic.LoadUint64(&sh.panics),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// rebuild recomputes the ring from the shards. ss.mu must be held, or ss not
+// yet shared.
+func (ss *ShardedStore) rebuild() {
+	ring := make([]ringPoint, 0, len(ss.shards)*ss.replicas)
+	for name, sh := range ss.shards {
+		for i := 0; i < ss.replicas; i++ {
+			ring = append(ring, ringPoint{hash: ss.hasher(name + "#" + strconv.Itoa(i)), shard: sh})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		// break ties the same way on every node
+		return ring[i].shard.name < ring[j].shard.name
+	})
+	ss.ring = ring
+}
+
+func (ss *ShardedStore) shardFor(key interface{}) (*shard, error) {
+	h := ss.hasher(key)
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.shardForLocked(h)
+}
+
+// shardForLocked returns the shard of the first point on the ring at or
+// after h. Ejected shards are skipped, unless they are ejected with
+// MissOnly, whose keys stay where they are.
+func (ss *ShardedStore) shardForLocked(h uint64) (*shard, error) {
+	if len(ss.ring) == 0 {
+		return nil, NoShardsError
+	}
+	i := sort.Search(len(ss.ring), func(i int) bool { return ss.ring[i].hash >= h })
+	for n := 0; n < len(ss.ring); n++ {
+		sh := ss.ring[(i+n)%len(ss.ring)].shard
+		if ss.available(sh) || ss.health.MissOnly {
+			return sh, nil
+		}
+	}
+	return nil, NoHealthyShardsError
+}
+
+// group returns the indexes of n keys by the shard they are stored in.
+func (ss *ShardedStore) group(n int, key func(i int) interface{}) (map[*shard][]int, error) {
+	hashes := make([]uint64, n)
+	for i := range hashes {
+		hashes[i] = ss.hasher(key(i))
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	groups := map[*shard][]int{}
+	for i, h := range hashes {
+		sh, err := ss.shardForLocked(h)
+		if err != nil {
+			return nil, err
+		}
+		if !sh.ejected() {
+			groups[sh] = append(groups[sh], i)
+		}
+	}
+	return groups, nil
+}
+
+// all reports whether every shard's store satisfies ok.
+func (ss *ShardedStore) all(ok func(store.StoreInterface) bool) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	for _, sh := range ss.shards {
+		if !ok(sh.store) {
+			return false
+		}
+	}
+	return true
+}
+
+// each runs op against every shard in turn, returning ShardErrors if any
+// failed. Ejected shards are skipped; they are cleared when they are
+// reinstated.
+func (ss *ShardedStore) each(op func(*shard) error) error {
+	ss.mu.RLock()
+	shards := make([]*shard, 0, len(ss.shards))
+	for _, sh := range ss.shards {
+		shards = append(shards, sh)
+	}
+	ss.mu.RUnlock()
+	if len(shards) == 0 {
+		return NoShardsError
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].name < shards[j].name })
+
+	var errs ShardErrors
+	for _, sh := range shards {
+		if !ss.available(sh) {
+			continue
+		}
+		start := time.Now()
+		if err := ss.record(sh, start, op(sh), false); err != nil {
+			if errs == nil {
+				errs = ShardErrors{}
+			}
+			errs[sh.name] = err
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// ejected reports whether the shard is ejected by ShardHealthCheck.
+func (sh *shard) ejected() bool {
+	return sh.health != nil && sh.health.isEjected()
+}
+
+func (e ShardErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for n, name := range names {
+		msgs[n] = fmt.Sprintf("shard %s: %v", name, e[name])
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,122 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type FailingInvalidateStore struct {
+	MapStore
+}
+
+func (s *FailingInvalidateStore) Invalidate(options store.InvalidateOptions) error {
+	return errors.New("tags unsupported")
+}
+
+func newShards(n int) ([]*ListingMapStore, []store.StoreInterface) {
+	maps := make([]*ListingMapStore, n)
+	stores := make([]store.StoreInterface, n)
+	for i := range maps {
+		maps[i] = &ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+		stores[i] = maps[i]
+	}
+	return maps, stores
+}
+
+func TestSharded(t *testing.T) {
+	maps, stores := newShards(3)
+	ss := expiring.NewSharded(stores, nil)
+	es := expiring.New(ss, &store.Options{Expiration: time.Hour})
+
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, es.Set(fmt.Sprint("key", i), i, nil))
+	}
+	for _, m := range maps {
+		// every shard gets a fair share
+		assert.True(t, len(m.cache) > 50, "shard has %d keys", len(m.cache))
+	}
+	val, err := es.Get("key7")
+	assert.Nil(t, err)
+	assert.Equal(t, 7, val)
+
+	n, err := es.Len()
+	assert.Nil(t, err)
+	assert.Equal(t, 300, n)
+
+	var sets uint64
+	for _, s := range ss.Stats() {
+		sets += s.Sets
+	}
+	assert.Equal(t, uint64(300), sets)
+
+	assert.Nil(t, es.Clear())
+	for _, m := range maps {
+		assert.Equal(t, 1, m.clearCount)
+		assert.Empty(t, m.cache)
+	}
+}
+
+func TestShardedMinimalMovement(t *testing.T) {
+	_, stores := newShards(3)
+	ss := expiring.NewSharded(stores, nil)
+	before := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint("key", i)
+		before[key], _ = ss.ShardFor(key)
+	}
+
+	assert.Nil(t, ss.AddShard("3", &MapStore{cache: map[interface{}]interface{}{}}))
+	assert.Equal(t, expiring.DuplicateShardError, ss.AddShard("3", &MapStore{}))
+	var moved int
+	for key, shard := range before {
+		now, _ := ss.ShardFor(key)
+		if now != shard {
+			// keys only move to the new shard
+			assert.Equal(t, "3", now)
+			moved++
+		}
+	}
+	assert.True(t, moved > 100 && moved < 400, "moved %d keys", moved)
+
+	assert.Nil(t, ss.RemoveShard("3"))
+	for key, shard := range before {
+		now, _ := ss.ShardFor(key)
+		assert.Equal(t, shard, now)
+	}
+
+	assert.Nil(t, ss.RemoveShard("0"))
+	for key, shard := range before {
+		now, _ := ss.ShardFor(key)
+		if shard != "0" {
+			assert.Equal(t, shard, now)
+		}
+	}
+	assert.Equal(t, expiring.UnknownShardError, ss.RemoveShard("0"))
+}
+
+func TestShardedErrors(t *testing.T) {
+	ss := expiring.NewSharded([]store.StoreInterface{
+		&MapStore{cache: map[interface{}]interface{}{}},
+		&FailingInvalidateStore{MapStore{cache: map[interface{}]interface{}{}}},
+	}, nil)
+
+	err := ss.Invalidate(store.InvalidateOptions{Tags: []string{"tag"}})
+	if assert.IsType(t, expiring.ShardErrors{}, err) {
+		assert.Equal(t, "shard 1: tags unsupported", err.Error())
+	}
+	assert.Equal(t, uint64(1), ss.Stats()[1].Errors)
+
+	// MapStore can't list its keys
+	_, err = ss.Keys()
+	assert.Equal(t, expiring.UnsupportedError, err)
+
+	empty := expiring.NewSharded(nil, nil)
+	_, err = empty.Get("key")
+	assert.Equal(t, expiring.NoShardsError, err)
+}
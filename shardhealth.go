@@ -0,0 +1,232 @@
+package expiring_gocache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// ShardHealth configures ShardHealthCheck. Zero fields take their
+	// defaults.
+	ShardHealth struct {
+		// Window is how many of a shard's latest calls its health is judged
+		// on, and MinCalls how many of them it must have made to be judged.
+		Window   int
+		MinCalls int
+		// MaxErrorRate is the fraction of failed calls in the window above
+		// which a shard is ejected.
+		MaxErrorRate float64
+		// MaxLatency is the mean latency of the calls in the window above
+		// which a shard is ejected. Zero disables it.
+		MaxLatency time.Duration
+		// Cooldown is how long a shard stays ejected before it is tried
+		// again.
+		Cooldown time.Duration
+		// MissOnly keeps the keys of ejected shards where they are, reading
+		// them as misses and dropping their writes, rather than moving them
+		// to the next shards on the ring.
+		MissOnly bool
+		// IsFailure reports whether an error from a shard's Get is a failure
+		// rather than a miss. By default Get errors are never failures,
+		// since stores report misses as errors.
+		IsFailure func(err error) bool
+		// Classifier, if set and IsFailure isn't, judges errors from every
+		// call: transient errors and timeouts are failures, while misses
+		// and permanent errors, such as a key the shard can't hold, say
+		// nothing of its health.
+		Classifier ErrorClassifier
+		// OnTransition, if set, is called when a shard is ejected or
+		// reinstated. Panics in it are recovered, and counted in the
+		// shard's ShardStats.
+		OnTransition func(shard string, healthy bool)
+	}
+
+	// shardHealth judges a shard on the outcomes of its latest calls.
+	shardHealth struct {
+		mu        sync.Mutex
+		failed    []bool
+		latencies []time.Duration
+		next      int
+		calls     int
+		failures  int
+		latency   time.Duration
+		ejected   int32
+		ejectedAt time.Time
+	}
+)
+
+const (
+	DefaultShardHealthWindow   = 100
+	DefaultShardHealthMinCalls = 10
+	DefaultShardMaxErrorRate   = 0.5
+	DefaultShardCooldown       = 30 * time.Second
+)
+
+var (
+	ShardEjectedError    = errors.New("shard is ejected")
+	NoHealthyShardsError = errors.New("every shard is ejected")
+)
+
+// ShardHealthCheck ejects shards whose calls fail too often, or are too
+// slow, and tries them again once the cooldown has passed. A shard is
+// cleared before it is reinstated, if it supports Clear, since it missed the
+// writes and deletes made while it was ejected; if the Clear fails, it stays
+// ejected for another cooldown. Ejections and reinstatements are counted in
+// ShardStats.
+func ShardHealthCheck(config ShardHealth) ShardedStoreOption {
+	if config.Window <= 0 {
+		config.Window = DefaultShardHealthWindow
+	}
+	if config.MinCalls <= 0 {
+		config.MinCalls = DefaultShardHealthMinCalls
+	}
+	if config.MinCalls > config.Window {
+		config.MinCalls = config.Window
+	}
+	if config.MaxErrorRate <= 0 {
+		config.MaxErrorRate = DefaultShardMaxErrorRate
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultShardCooldown
+	}
+	return func(ss *ShardedStore) {
+		ss.health = &config
+	}
+}
+
+// ShardedClock sets the clock the ShardedStore reads the time from when
+// judging shards' cooldowns. Defaults to clock.Real.
+func ShardedClock(c clock.Clock) ShardedStoreOption {
+	return func(ss *ShardedStore) {
+		ss.clock = c
+	}
+}
+
+func newShardHealth(window int) *shardHealth {
+	return &shardHealth{failed: make([]bool, window), latencies: make([]time.Duration, window)}
+}
+
+func (h *shardHealth) isEjected() bool {
+	return atomic.LoadInt32(&h.ejected) == 1
+}
+
+// record adds the outcome of a call, returning true if it ejected the shard.
+func (h *shardHealth) record(config *ShardHealth, failed bool, latency time.Duration, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.calls == len(h.failed) {
+		if h.failed[h.next] {
+			h.failures--
+		}
+		h.latency -= h.latencies[h.next]
+	} else {
+		h.calls++
+	}
+	h.failed[h.next] = failed
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % len(h.failed)
+	if failed {
+		h.failures++
+	}
+	h.latency += latency
+
+	if h.isEjected() || h.calls < config.MinCalls {
+		return false
+	}
+	unhealthy := float64(h.failures)/float64(h.calls) > config.MaxErrorRate ||
+		(config.MaxLatency > 0 && h.latency/time.Duration(h.calls) > config.MaxLatency)
+	if !unhealthy {
+		return false
+	}
+	atomic.StoreInt32(&h.ejected, 1)
+	h.ejectedAt = now
+	return true
+}
+
+// record counts the outcome of a call to sh, started at start, and returns
+// err. Errors from reads are only failures if the health check's IsFailure,
+// or else its Classifier, says so.
+func (ss *ShardedStore) record(sh *shard, start time.Time, err error, read bool) error {
+	failed := err != nil
+	switch {
+	case !failed:
+	case ss.health != nil && ss.health.IsFailure == nil && ss.health.Classifier != nil:
+		op := OperationSet
+		if read {
+			op = OperationGet
+		}
+		class := ss.health.Classifier.Classify(op, err)
+		failed = class == ErrorTransient || class == ErrorTimeout
+	case read:
+		failed = ss.health != nil && ss.health.IsFailure != nil && ss.health.IsFailure(err)
+	}
+	if failed {
+		atomic.AddUint64(&sh.errors, 1)
+	}
+	if sh.health == nil {
+		return err
+	}
+	if sh.health.record(ss.health, failed, time.Since(start), ss.clock.Now()) {
+		atomic.AddUint64(&sh.ejections, 1)
+		ss.transition(sh, false)
+	}
+	return err
+}
+
+// available reports whether sh can be used, reinstating it if it is ejected
+// and its cooldown has passed.
+func (ss *ShardedStore) available(sh *shard) bool {
+	h := sh.health
+	if h == nil || !h.isEjected() {
+		return true
+	}
+	ok, reinstated := ss.reinstate(sh)
+	if reinstated {
+		ss.transition(sh, true)
+	}
+	return ok
+}
+
+// transition calls the health check's OnTransition, if set, counting a panic
+// in it against sh.
+func (ss *ShardedStore) transition(sh *shard, healthy bool) {
+	if ss.health.OnTransition == nil {
+		return
+	}
+	_ = guardCallback("shard transition hook", &sh.panics, func() error {
+		ss.health.OnTransition(sh.name, healthy)
+		return nil
+	})
+}
+
+// reinstate clears an ejected shard whose cooldown has passed, and marks it
+// healthy. It reports whether the shard is healthy, and whether this call
+// reinstated it.
+func (ss *ShardedStore) reinstate(sh *shard) (bool, bool) {
+	h := sh.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.isEjected() {
+		// reinstated by another caller
+		return true, false
+	}
+	now := ss.clock.Now()
+	if now.Sub(h.ejectedAt) < ss.health.Cooldown {
+		return false, false
+	}
+	if c, ok := sh.store.(clearer); ok {
+		if err := c.Clear(); err != nil {
+			h.ejectedAt = now
+			return false, false
+		}
+	}
+	// judge it afresh
+	h.next, h.calls, h.failures, h.latency = 0, 0, 0, 0
+	atomic.StoreInt32(&h.ejected, 0)
+	atomic.AddUint64(&sh.reinstatements, 1)
+	return true, true
+}
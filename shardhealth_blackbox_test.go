@@ -0,0 +1,191 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type FlakyStore struct {
+	MapStore
+	down bool
+}
+
+var FlakyStoreDown = errors.New("connection refused")
+
+func (s *FlakyStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	if s.isDown() {
+		return FlakyStoreDown
+	}
+	return s.MapStore.Set(key, value, options)
+}
+
+func (s *FlakyStore) Clear() error {
+	if s.isDown() {
+		return FlakyStoreDown
+	}
+	return s.MapStore.Clear()
+}
+
+func (s *FlakyStore) isDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.down
+}
+
+func (s *FlakyStore) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// keyOn returns a key stored in the named shard.
+func keyOn(t *testing.T, ss *expiring.ShardedStore, name string) string {
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint("key", i)
+		if shard, _ := ss.ShardFor(key); shard == name {
+			return key
+		}
+	}
+	t.Fatalf("no key found for shard %s", name)
+	return ""
+}
+
+func TestShardEjection(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	healthy := &MapStore{cache: map[interface{}]interface{}{}}
+	flaky := &FlakyStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+	ss := expiring.NewSharded([]store.StoreInterface{healthy, flaky}, nil,
+		expiring.ShardedClock(clk),
+		expiring.ShardHealthCheck(expiring.ShardHealth{
+			Window:   4,
+			MinCalls: 4,
+			Cooldown: time.Minute,
+			OnTransition: func(shard string, ok bool) {
+				mu.Lock()
+				defer mu.Unlock()
+				transitions = append(transitions, fmt.Sprint(shard, ok))
+			},
+		}))
+	key := keyOn(t, ss, "1")
+
+	flaky.setDown(true)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, FlakyStoreDown, ss.Set(key, "value", nil))
+	}
+	// the key moved to the healthy shard
+	shard, err := ss.ShardFor(key)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", shard)
+	assert.Nil(t, ss.Set(key, "value", nil))
+	assert.Equal(t, "value", healthy.cache[key])
+	assert.True(t, ss.Stats()[1].Ejected)
+
+	// still down after the cooldown, so it stays ejected
+	clk.Advance(time.Minute)
+	shard, _ = ss.ShardFor(key)
+	assert.Equal(t, "0", shard)
+
+	flaky.setDown(false)
+	clk.Advance(time.Minute)
+	shard, _ = ss.ShardFor(key)
+	assert.Equal(t, "1", shard)
+	assert.Equal(t, 1, flaky.clearCount)
+
+	stats := ss.Stats()[1]
+	assert.False(t, stats.Ejected)
+	assert.Equal(t, uint64(1), stats.Ejections)
+	assert.Equal(t, uint64(1), stats.Reinstatements)
+	assert.Equal(t, uint64(4), stats.Errors)
+	assert.Equal(t, []string{"1false", "1true"}, transitions)
+}
+
+func TestShardTransitionPanicRecovered(t *testing.T) {
+	flaky := &FlakyStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	ss := expiring.NewSharded([]store.StoreInterface{&MapStore{cache: map[interface{}]interface{}{}}, flaky}, nil,
+		expiring.ShardHealthCheck(expiring.ShardHealth{
+			Window:       2,
+			Cooldown:     time.Hour,
+			OnTransition: func(string, bool) { panic("transition") },
+		}))
+	key := keyOn(t, ss, "1")
+
+	flaky.setDown(true)
+	assert.NotPanics(t, func() {
+		assert.NotNil(t, ss.Set(key, "value", nil))
+		assert.NotNil(t, ss.Set(key, "value", nil))
+	})
+	stats := ss.Stats()[1]
+	assert.True(t, stats.Ejected)
+	assert.Equal(t, uint64(1), stats.Panics)
+}
+
+func TestShardEjectionMissOnly(t *testing.T) {
+	flaky := &FlakyStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	ss := expiring.NewSharded([]store.StoreInterface{&MapStore{cache: map[interface{}]interface{}{}}, flaky}, nil,
+		expiring.ShardHealthCheck(expiring.ShardHealth{Window: 2, MissOnly: true, Cooldown: time.Hour}))
+	key := keyOn(t, ss, "1")
+
+	flaky.setDown(true)
+	assert.NotNil(t, ss.Set(key, "value", nil))
+	assert.NotNil(t, ss.Set(key, "value", nil))
+
+	// the key stays on the ejected shard, and is a miss
+	shard, _ := ss.ShardFor(key)
+	assert.Equal(t, "1", shard)
+	_, err := ss.Get(key)
+	assert.Equal(t, expiring.ShardEjectedError, err)
+	assert.Nil(t, ss.Set(key, "value", nil))
+	assert.Equal(t, 0, flaky.setCount)
+}
+
+func TestShardEjectionReadFailures(t *testing.T) {
+	down := errors.New("connection refused")
+	ss := expiring.NewSharded([]store.StoreInterface{&MapStore{cache: map[interface{}]interface{}{}}}, nil,
+		expiring.ShardHealthCheck(expiring.ShardHealth{
+			Window:    2,
+			IsFailure: func(err error) bool { return err == down },
+		}))
+
+	// misses aren't failures
+	for i := 0; i < 10; i++ {
+		_, err := ss.Get("missing")
+		assert.Equal(t, MapStoreMiss, err)
+	}
+	assert.False(t, ss.Stats()[0].Ejected)
+	assert.Equal(t, uint64(0), ss.Stats()[0].Errors)
+}
+
+func TestShardEjectionClassifier(t *testing.T) {
+	flaky := &FlakyStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}, down: true}
+	ss := expiring.NewSharded([]store.StoreInterface{flaky}, nil,
+		expiring.ShardHealthCheck(expiring.ShardHealth{
+			Window: 2,
+			Classifier: expiring.ErrorClassifierFunc(func(op expiring.Operation, err error) expiring.ErrorClass {
+				if err == MapStoreMiss {
+					return expiring.ErrorMiss
+				}
+				return expiring.ErrorPermanent
+			}),
+		}))
+
+	// neither misses nor permanent failures say the shard is unhealthy
+	for i := 0; i < 10; i++ {
+		_, err := ss.Get("missing")
+		assert.Equal(t, MapStoreMiss, err)
+		assert.Equal(t, FlakyStoreDown, ss.Set("key", "value", nil))
+	}
+	assert.False(t, ss.Stats()[0].Ejected)
+	assert.Equal(t, uint64(0), ss.Stats()[0].Errors)
+}
@@ -0,0 +1,28 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// isExpired reports whether ew has expired by now, allowing for the clock
+// skew tolerance given to WithClockSkewTolerance. See also WithChaos.
+func (es Store) isExpired(ew wrappedValue, now time.Time) bool {
+	return ew.expireAt.Add(es.skewTolerance).Before(now) || es.expireEarly()
+}
+
+// observeSkew records the clock skew shown by a value written in the future
+// by the local clock, i.e. by a node whose clock is ahead of this one's.
+func (es Store) observeSkew(ew wrappedValue, now time.Time) {
+	if ew.createdAt.IsZero() || !ew.createdAt.After(now) {
+		return
+	}
+	skew := int64(ew.createdAt.Sub(now))
+	atomic.AddUint64(&es.stats.skewedReads, 1)
+	for {
+		max := atomic.LoadInt64(&es.stats.maxClockSkew)
+		if skew <= max || atomic.CompareAndSwapInt64(&es.stats.maxClockSkew, max, skew) {
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package expiring_gocache_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+// envelopeFrom returns a binary envelope of value as written by a node
+// whose clock reads createdAt, expiring at expireAt.
+func envelopeFrom(value string, createdAt, expireAt time.Time) []byte {
+	// magic, version 4, string kind
+	b := []byte{0xe7, 0x78, 4, 1}
+	var field [8]byte
+	for _, v := range []int64{expireAt.UnixNano(), 0, 0, createdAt.UnixNano()} {
+		binary.BigEndian.PutUint64(field[:], uint64(v))
+		b = append(b, field[:]...)
+	}
+	// no instance
+	return append(append(b, 0), value...)
+}
+
+func TestClockSkewObserved(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+
+	now := time.Now()
+	ms.cache["ahead"] = envelopeFrom("value", now.Add(time.Minute), now.Add(time.Hour))
+	val, err := es.Get("ahead")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	ms.cache["behind"] = envelopeFrom("value", now.Add(-time.Minute), now.Add(time.Hour))
+	_, err = es.Get("behind")
+	assert.Nil(t, err)
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(1), stats.SkewedReads)
+	assert.True(t, stats.MaxClockSkew > 59*time.Second && stats.MaxClockSkew <= time.Minute)
+
+	// values written by this Store are never skewed
+	assert.Nil(t, es.Set("local", "value", nil))
+	_, err = es.Get("local")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), es.Stats().SkewedReads)
+}
+
+func TestClockSkewTolerance(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	strict := expiring.New(&ms, nil, expiring.WithBinaryEnvelope())
+	tolerant := expiring.New(&ms, nil, expiring.WithBinaryEnvelope(), expiring.WithClockSkewTolerance(time.Second))
+
+	now := time.Now()
+	ms.cache["key"] = envelopeFrom("value", now.Add(-time.Hour), now.Add(-100*time.Millisecond))
+	val, ttl, err := tolerant.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.True(t, ttl > 0 && ttl <= 900*time.Millisecond)
+	_, md, err := tolerant.GetStale("key")
+	assert.Nil(t, err)
+	assert.False(t, md.Expired)
+
+	_, err = strict.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
@@ -0,0 +1,95 @@
+package expiring_gocache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+type (
+	// SlowOperation describes a call to the underlying store which took at
+	// least the threshold given to WithSlowThreshold.
+	SlowOperation struct {
+		Operation Operation
+		// KeyHash is a 64-bit FNV-1a hash of the key formatted with %v, so
+		// that keys can be correlated without being logged. It is 0 for
+		// operations without a single key.
+		KeyHash  uint64
+		Start    time.Time
+		Duration time.Duration
+		Err      error
+		// Context is the context given to the call, as for Event. It is
+		// only passed to the callback, and isn't kept in the slow log.
+		Context context.Context
+	}
+
+	slowLog struct {
+		threshold time.Duration
+		callback  func(SlowOperation)
+
+		mu      sync.Mutex
+		entries []SlowOperation
+		next    int
+		full    bool
+	}
+)
+
+const DefaultSlowLogSize = 128
+
+// SlowLog returns the most recent slow calls to the underlying store, oldest
+// first. It returns nil unless WithSlowThreshold was given.
+func (es Store) SlowLog() []SlowOperation {
+	if es.slowLog == nil {
+		return nil
+	}
+	return es.slowLog.snapshot()
+}
+
+func newSlowLog(threshold time.Duration, callback func(SlowOperation), size int) *slowLog {
+	if size <= 0 {
+		size = DefaultSlowLogSize
+	}
+	return &slowLog{
+		threshold: threshold,
+		callback:  callback,
+		entries:   make([]SlowOperation, size),
+	}
+}
+
+func (l *slowLog) record(op SlowOperation) {
+	l.mu.Lock()
+	l.entries[l.next] = op
+	// don't keep the call's values alive
+	l.entries[l.next].Context = nil
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.mu.Unlock()
+
+	if l.callback != nil {
+		l.callback(op)
+	}
+}
+
+func (l *slowLog) snapshot() []SlowOperation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]SlowOperation(nil), l.entries[:l.next]...)
+	}
+	ops := make([]SlowOperation, 0, len(l.entries))
+	ops = append(ops, l.entries[l.next:]...)
+	return append(ops, l.entries[:l.next]...)
+}
+
+func keyHash(key interface{}) uint64 {
+	if key == nil {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%v", key)
+	return h.Sum64()
+}
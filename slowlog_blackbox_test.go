@@ -0,0 +1,87 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// SlowGetStore delays every Get by delay.
+	SlowGetStore struct {
+		*MapStore
+		delay time.Duration
+	}
+)
+
+func TestSlowLog(t *testing.T) {
+	sgs := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: 5 * time.Millisecond}
+	var reported []expiring.SlowOperation
+	es := expiring.New(&sgs, nil, expiring.WithSlowThreshold(time.Millisecond, func(op expiring.SlowOperation) {
+		reported = append(reported, op)
+	}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	_, err = es.Get("missing")
+	assert.Equal(t, MapStoreMiss, err)
+
+	slow := es.SlowLog()
+	assert.Equal(t, reported, slow)
+	assert.Len(t, slow, 2)
+	assert.Equal(t, expiring.OperationGet, slow[0].Operation)
+	assert.NotEqual(t, uint64(0), slow[0].KeyHash)
+	assert.NotEqual(t, slow[0].KeyHash, slow[1].KeyHash)
+	assert.True(t, slow[0].Duration >= sgs.delay)
+	assert.Nil(t, slow[0].Err)
+	assert.Equal(t, MapStoreMiss, slow[1].Err)
+}
+
+func TestSlowLogContext(t *testing.T) {
+	sgs := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: 5 * time.Millisecond}
+	var reported []expiring.SlowOperation
+	es := expiring.New(&sgs, nil, expiring.WithSlowThreshold(time.Millisecond, func(op expiring.SlowOperation) {
+		reported = append(reported, op)
+	}))
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "trace")
+
+	_, _ = es.GetWithContext(ctx, "key")
+	if assert.Len(t, reported, 1) {
+		assert.Equal(t, ctx, reported[0].Context)
+	}
+	// the log doesn't keep the context
+	assert.Nil(t, es.SlowLog()[0].Context)
+}
+
+func TestSlowLogIsBounded(t *testing.T) {
+	sgs := SlowGetStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, delay: time.Millisecond}
+	es := expiring.New(&sgs, nil,
+		expiring.WithSlowThreshold(time.Millisecond, nil),
+		expiring.WithSlowLogSize(2),
+	)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, _ = es.Get(key)
+	}
+
+	slow := es.SlowLog()
+	assert.Len(t, slow, 2)
+	assert.True(t, slow[0].Start.Before(slow[1].Start))
+}
+
+func TestSlowLogDisabled(t *testing.T) {
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	_, _ = es.Get("key")
+	assert.Nil(t, es.SlowLog())
+}
+
+// SlowGetStore implementation
+
+func (sgs *SlowGetStore) Get(key interface{}) (interface{}, error) {
+	time.Sleep(sgs.delay)
+	return sgs.MapStore.Get(key)
+}
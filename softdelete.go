@@ -0,0 +1,64 @@
+package expiring_gocache
+
+import (
+	"context"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+// DeleteAfter soft deletes the value: rather than removing it, its
+// expiration is brought forward to delay from now, so that in-flight readers
+// can finish and replicas can converge before it is purged like any other
+// expired value. Values which would expire sooner anyway are left alone.
+// Values which were not written through the Store are deleted immediately.
+// The error of a read which fails other than with a miss is returned. If
+// WithWriteThrough was given, the value is removed from the sink at once.
+func (es Store) DeleteAfter(key interface{}, delay time.Duration) error {
+	return es.through(context.Background(), []sinkOp{{op: OperationDelete, key: key}}, func() error {
+		return es.deleteAfter(key, delay)
+	})
+}
+
+func (es Store) deleteAfter(key interface{}, delay time.Duration) error {
+	val, err := es.innerGet(key)
+	if err != nil && !es.IsMiss(err) {
+		return err
+	}
+	if err != nil || val == nil {
+		// nothing to soft delete
+		return nil
+	}
+	ew, ok := unwrap(val)
+	if !ok || delay <= 0 {
+		es.untrack(key)
+		if err := es.innerDelete(key); err != nil {
+			return err
+		}
+		es.emit(Event{Type: EventDelete, Key: key})
+		es.cascade(key)
+		return nil
+	}
+
+	expireAt := es.now().Add(delay)
+	if !expireAt.Before(ew.expireAt) {
+		return nil
+	}
+	ew.expireAt = expireAt
+	wrapped, err := es.wrap(ew)
+	if err != nil {
+		return err
+	}
+	var options *store.Options
+	if es.nativeExpiration {
+		options = withNativeExpiration(nil, delay)
+	}
+	if err := es.innerSet(key, wrapped, options); err != nil {
+		return err
+	}
+	if es.tracker != nil {
+		es.tracker.reschedule(key, expireAt)
+	}
+	es.emit(Event{Type: EventDelete, Key: key})
+	return nil
+}
@@ -0,0 +1,98 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAfter(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	assert.Nil(t, es.DeleteAfter("key", 10*time.Millisecond))
+	assert.Equal(t, 0, ms.deleteCount)
+
+	// in-flight readers still see the value
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+
+	clk.Advance(20 * time.Millisecond)
+	_, err = es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 1, ms.deleteCount)
+}
+
+func TestDeleteAfterKeepsSoonerExpiration(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute})
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	assert.Nil(t, es.DeleteAfter("key", time.Hour))
+	assert.Equal(t, 1, ms.setCount)
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl <= time.Minute)
+}
+
+func TestDeleteAfterUnwrappedValue(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{"raw": "raw"}}
+	es := expiring.New(&ms, nil)
+
+	assert.Nil(t, es.DeleteAfter("raw", time.Hour))
+	assert.Empty(t, ms.cache)
+	assert.Nil(t, es.DeleteAfter("missing", time.Hour))
+}
+
+func TestDeleteDelayReapedOnTime(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithClock(clk),
+		expiring.WithDeleteDelay(reaperExpiration),
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+	)
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Delete("key"))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+
+	// the soft deleted value was moved to an earlier expiration bucket
+	awaitReaper(t, clk, func() bool { return !ms.holds("key") })
+	assert.Nil(t, es.Close())
+	assert.Empty(t, ms.cache)
+}
+
+func TestDeleteAfterFailedRead(t *testing.T) {
+	fs := FailingGetStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&fs, &store.Options{Expiration: time.Hour}, expiring.WithDeleteDelay(time.Minute),
+		expiring.WithErrorClassifier(expiring.MissClassifier(func(err error) bool { return err == MapStoreMiss })))
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	fs.err = FailingGetError
+	assert.Equal(t, FailingGetError, es.Delete("key"))
+	fs.err = nil
+	assert.Nil(t, es.Delete("missing"))
+}
+
+func TestDeleteAfterNativeExpiration(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.DeleteAfter("key", time.Minute))
+	assert.Nil(t, ms.lastSetOptions)
+
+	native := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithNativeExpiration())
+	assert.Nil(t, native.Set("key", "value", nil))
+	assert.Nil(t, native.DeleteAfter("key", time.Minute))
+	assert.Equal(t, time.Minute, ms.lastSetOptions.ExpirationValue())
+}
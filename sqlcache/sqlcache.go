@@ -0,0 +1,281 @@
+// Package sqlcache caches the results of database/sql queries in an
+// expiring Store.
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// Querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+	Querier interface {
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	}
+
+	// Cache runs queries against a Querier, caching their results. Results
+	// are tagged with the tables they read, so that writes to a table can
+	// invalidate them; the cache must support tag invalidation for
+	// Invalidate to have any effect.
+	Cache struct {
+		db    Querier
+		cache store.StoreInterface
+		ttl   time.Duration
+	}
+
+	// Option configures a Cache.
+	Option func(*Cache)
+
+	// Result is the full result of a query. Each row holds one value per
+	// column, as returned by the driver.
+	Result struct {
+		Columns []string
+		Rows    [][]interface{}
+	}
+)
+
+// TagPrefix starts the tag given to results which read a table, followed by
+// the table name.
+const TagPrefix = "sqlcache:table:"
+
+// New creates a Cache which runs queries with db and caches their results in
+// cache.
+func New(db Querier, cache store.StoreInterface, opts ...Option) *Cache {
+	c := &Cache{db: db, cache: cache}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithTTL caches results for ttl, instead of the cache's default expiration.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// Query returns the cached result of query with args, running it if there is
+// none. tables names the tables the query reads, see Invalidate. Queries are
+// cached by their text, with runs of whitespace outside quotes collapsed, and
+// their arguments, as the driver is given them: int(1) and int64(1), or a
+// pointer and the value it points to, are the same argument. Queries with
+// arguments database/sql doesn't convert itself, such as sql.Out, aren't
+// cached.
+//
+// Each call is given its own copy of the result, which it may change.
+func (c *Cache) Query(ctx context.Context, tables []string, query string, args ...interface{}) (*Result, error) {
+	key, ok := cacheKey(query, args)
+	if !ok {
+		return c.query(ctx, query, args)
+	}
+	if val, err := c.cache.Get(key); err == nil {
+		if result, ok := val.(*Result); ok {
+			return result.clone(), nil
+		}
+	}
+
+	result, err := c.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	options := &store.Options{Expiration: c.ttl, Tags: tags(tables)}
+	_ = c.cache.Set(key, result.clone(), options) // best effort
+	return result, nil
+}
+
+// Invalidate removes the cached results of every query which reads any of
+// tables.
+func (c *Cache) Invalidate(tables ...string) error {
+	return c.cache.Invalidate(store.InvalidateOptions{Tags: tags(tables)})
+}
+
+func (c *Cache) query(ctx context.Context, query string, args []interface{}) (*Result, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Columns: columns}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range row {
+			// the driver may reuse the memory of []byte values
+			if b, ok := v.([]byte); ok {
+				row[i] = append([]byte(nil), b...)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// cacheKey identifies query with args, or reports false if an argument
+// can't be encoded.
+func cacheKey(query string, args []interface{}) (string, bool) {
+	var b strings.Builder
+	b.WriteString("sqlcache:")
+	writeString(&b, normalize(query))
+	for _, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok {
+			b.WriteByte('@')
+			writeString(&b, named.Name)
+			arg = named.Value
+		}
+		if !writeArg(&b, arg) {
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// normalize collapses each run of whitespace in query to a space, and trims
+// it, leaving quoted strings and identifiers, and comments, as they are.
+// Where query's dialect might make the end of a quote unclear, at a
+// backslash in quotes, a dollar quote or a nested comment, the rest of query
+// is left as it is too.
+func normalize(query string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v' {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		end, ok := verbatim(query, i)
+		if !ok {
+			b.WriteString(query[i:])
+			return b.String()
+		}
+		b.WriteString(query[i:end])
+		i = end - 1
+	}
+	return b.String()
+}
+
+// verbatim returns the end of the quote or comment starting at query[i], or
+// of the single character there if neither does, to be left as it is. It
+// reports false if the end is unclear.
+func verbatim(query string, i int) (int, bool) {
+	rest := query[i:]
+	switch {
+	case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+		for j := 1; j < len(rest); j++ {
+			switch rest[j] {
+			case '\\':
+				return 0, false
+			case rest[0]:
+				return i + j + 1, true
+			}
+		}
+		return len(query), true
+	case strings.HasPrefix(rest, "--"):
+		if j := strings.IndexByte(rest, '\n'); j >= 0 {
+			return i + j + 1, true
+		}
+		return len(query), true
+	case strings.HasPrefix(rest, "/*"):
+		j := strings.Index(rest[2:], "*/")
+		if j < 0 || strings.Contains(rest[2:2+j], "/*") {
+			return 0, false
+		}
+		return i + 2 + j + 2, true
+	case rest[0] == '$' && (len(rest) == 1 || rest[1] < '0' || rest[1] > '9'):
+		return 0, false
+	}
+	return i + 1, true
+}
+
+// writeArg writes arg, converted as database/sql converts it for the driver,
+// tagged with its type, or reports false if it can't be converted.
+func writeArg(b *strings.Builder, arg interface{}) bool {
+	val, err := driver.DefaultParameterConverter.ConvertValue(arg)
+	if err != nil {
+		return false
+	}
+	switch v := val.(type) {
+	case nil:
+		b.WriteByte('n')
+	case int64:
+		b.WriteByte('i')
+		writeString(b, strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteByte('f')
+		writeString(b, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		b.WriteByte('t')
+		writeString(b, strconv.FormatBool(v))
+	case []byte:
+		b.WriteByte('b')
+		writeString(b, string(v))
+	case string:
+		b.WriteByte('s')
+		writeString(b, v)
+	case time.Time:
+		b.WriteByte('d')
+		writeString(b, v.Format(time.RFC3339Nano))
+	default:
+		return false
+	}
+	return true
+}
+
+// writeString writes s prefixed with its length, so that no two sequences
+// of strings are written alike.
+func writeString(b *strings.Builder, s string) {
+	b.WriteString(strconv.Itoa(len(s)))
+	b.WriteByte(':')
+	b.WriteString(s)
+}
+
+// clone returns a copy of r which shares no memory with it.
+func (r *Result) clone() *Result {
+	c := &Result{Columns: append([]string(nil), r.Columns...)}
+	if r.Rows != nil {
+		c.Rows = make([][]interface{}, len(r.Rows))
+	}
+	for i, row := range r.Rows {
+		c.Rows[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			if b, ok := v.([]byte); ok {
+				v = append([]byte(nil), b...)
+			}
+			c.Rows[i][j] = v
+		}
+	}
+	return c
+}
+
+func tags(tables []string) []string {
+	tags := make([]string, len(tables))
+	for i, table := range tables {
+		tags[i] = TagPrefix + table
+	}
+	return tags
+}
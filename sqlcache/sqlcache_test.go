@@ -0,0 +1,223 @@
+package sqlcache_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/sqlcache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// TaggedMapStore is a store which supports tag invalidation.
+	TaggedMapStore struct {
+		mu    sync.Mutex
+		cache map[interface{}]interface{}
+		tags  map[string][]interface{}
+	}
+
+	// CountingDriver answers every query with the rows (1, "one") and
+	// (2, "two"), counting the queries it runs.
+	CountingDriver struct {
+		queries uint64
+	}
+
+	countingConn struct {
+		driver *CountingDriver
+	}
+
+	countingRows struct {
+		next int
+	}
+)
+
+var countingDriver = &CountingDriver{}
+
+func init() {
+	sql.Register("sqlcache-counting", countingDriver)
+}
+
+func TestQuery(t *testing.T) {
+	db, err := sql.Open("sqlcache-counting", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	cache := expiring.New(&TaggedMapStore{cache: map[interface{}]interface{}{}, tags: map[string][]interface{}{}}, &store.Options{Expiration: time.Hour})
+	c := sqlcache.New(db, cache)
+	ctx := context.Background()
+	before := atomic.LoadUint64(&countingDriver.queries)
+
+	result, err := c.Query(ctx, []string{"numbers"}, "SELECT id, name FROM numbers WHERE id > ?", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name"}, result.Columns)
+	assert.Equal(t, [][]interface{}{{int64(1), "one"}, {int64(2), "two"}}, result.Rows)
+
+	// whitespace doesn't matter, arguments do
+	again, err := c.Query(ctx, []string{"numbers"}, "SELECT id, name\n\tFROM numbers   WHERE id > ?", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, result, again)
+	assert.Equal(t, before+1, atomic.LoadUint64(&countingDriver.queries))
+
+	_, err = c.Query(ctx, []string{"numbers"}, "SELECT id, name FROM numbers WHERE id > ?", "0")
+	assert.Nil(t, err)
+	assert.Equal(t, before+2, atomic.LoadUint64(&countingDriver.queries))
+
+	assert.Nil(t, c.Invalidate("numbers"))
+	_, err = c.Query(ctx, []string{"numbers"}, "SELECT id, name FROM numbers WHERE id > ?", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, before+3, atomic.LoadUint64(&countingDriver.queries))
+}
+
+func TestQueryKeys(t *testing.T) {
+	db, err := sql.Open("sqlcache-counting", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	cache := expiring.New(&TaggedMapStore{cache: map[interface{}]interface{}{}, tags: map[string][]interface{}{}}, &store.Options{Expiration: time.Hour})
+	c := sqlcache.New(db, cache)
+	ctx := context.Background()
+	queries := func() uint64 { return atomic.LoadUint64(&countingDriver.queries) }
+
+	// whitespace in quotes and comments matters
+	before := queries()
+	for _, query := range []string{
+		"SELECT id, name FROM numbers WHERE name = 'a b'",
+		"SELECT id, name FROM numbers WHERE name = 'a  b'",
+		"SELECT id, name FROM numbers WHERE \"a b\" = 1",
+		"SELECT id, name FROM numbers WHERE \"a  b\" = 1",
+		"SELECT id, name FROM numbers -- a\nWHERE id > 1",
+		"SELECT id, name FROM numbers -- a WHERE id > 1",
+		"SELECT id, name FROM numbers WHERE name = 'it''s  so'",
+		"SELECT id, name FROM numbers WHERE name = 'it''s so'",
+		"SELECT id, name FROM numbers WHERE name = 'a\\'  AND ' b'",
+		"SELECT id, name FROM numbers WHERE name = 'a\\' AND ' b'",
+	} {
+		_, err := c.Query(ctx, nil, query)
+		assert.Nil(t, err)
+		_, err = c.Query(ctx, nil, query)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, before+10, queries())
+
+	// arguments are keyed as the driver is given them
+	before = queries()
+	one := 1
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", int64(1))
+	assert.Nil(t, err)
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", 1)
+	assert.Nil(t, err)
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", &one)
+	assert.Nil(t, err)
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", &one)
+	assert.Nil(t, err)
+	assert.Equal(t, before+1, queries())
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", "1")
+	assert.Nil(t, err)
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", []byte("1"))
+	assert.Nil(t, err)
+	_, err = c.Query(ctx, nil, "SELECT id, name FROM numbers WHERE id > ?", 1.0)
+	assert.Nil(t, err)
+	assert.Equal(t, before+4, queries())
+}
+
+func TestQueryResultsAreCopies(t *testing.T) {
+	db, err := sql.Open("sqlcache-counting", "")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	cache := expiring.New(&TaggedMapStore{cache: map[interface{}]interface{}{}, tags: map[string][]interface{}{}}, &store.Options{Expiration: time.Hour})
+	c := sqlcache.New(db, cache)
+	ctx := context.Background()
+	want := [][]interface{}{{int64(1), "one"}, {int64(2), "two"}}
+
+	result, err := c.Query(ctx, nil, "SELECT id, name FROM copies")
+	assert.Nil(t, err)
+	result.Columns[0] = "changed"
+	result.Rows[0][1] = "changed"
+
+	hit, err := c.Query(ctx, nil, "SELECT id, name FROM copies")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "name"}, hit.Columns)
+	assert.Equal(t, want, hit.Rows)
+	hit.Rows[1] = nil
+
+	again, err := c.Query(ctx, nil, "SELECT id, name FROM copies")
+	assert.Nil(t, err)
+	assert.Equal(t, want, again.Rows)
+}
+
+func (ms *TaggedMapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.cache[key], nil
+}
+
+func (ms *TaggedMapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cache[key] = value
+	for _, tag := range options.TagsValue() {
+		ms.tags[tag] = append(ms.tags[tag], key)
+	}
+	return nil
+}
+
+func (ms *TaggedMapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.cache, key)
+	return nil
+}
+
+func (ms *TaggedMapStore) Invalidate(options store.InvalidateOptions) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, tag := range options.TagsValue() {
+		for _, key := range ms.tags[tag] {
+			delete(ms.cache, key)
+		}
+		delete(ms.tags, tag)
+	}
+	return nil
+}
+
+func (ms *TaggedMapStore) GetType() string { return "tagged-map" }
+
+func (d *CountingDriver) Open(name string) (driver.Conn, error) {
+	return countingConn{driver: d}, nil
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) { return c, nil }
+func (c countingConn) Close() error                              { return nil }
+func (c countingConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+func (c countingConn) NumInput() int                             { return -1 }
+
+func (c countingConn) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c countingConn) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddUint64(&c.driver.queries, 1)
+	return &countingRows{}, nil
+}
+
+func (r *countingRows) Columns() []string { return []string{"id", "name"} }
+func (r *countingRows) Close() error      { return nil }
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	rows := [][]driver.Value{{int64(1), "one"}, {int64(2), "two"}}
+	if r.next >= len(rows) {
+		return io.EOF
+	}
+	copy(dest, rows[r.next])
+	r.next++
+	return nil
+}
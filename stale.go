@@ -0,0 +1,83 @@
+package expiring_gocache
+
+import (
+	"time"
+)
+
+type (
+	// Metadata describes a stored value.
+	Metadata struct {
+		// ExpireAt is when the value expires. It is zero for values which
+		// were not written through the Store.
+		ExpireAt time.Time
+		// Expired reports whether ExpireAt has passed.
+		Expired bool
+		// Staleness is how long ago the value expired, or 0 if it hasn't.
+		Staleness time.Duration
+		// Timestamp is the timestamp the value was written with by
+		// SetIfNewer, or zero.
+		Timestamp time.Time
+	}
+)
+
+// GetStale returns whatever value is stored for key, even if it has expired,
+// along with Metadata saying how stale it is. Expired values are not
+// deleted, and the read doesn't count as an access.
+func (es Store) GetStale(key interface{}) (interface{}, Metadata, error) {
+	val, md, err := es.getStale(key)
+	return es.clone(val), md, err
+}
+
+func (es Store) getStale(key interface{}) (interface{}, Metadata, error) {
+	val, err := es.innerGet(key)
+	if err != nil || val == nil || es.bypassed(key) {
+		return val, Metadata{}, err
+	}
+	ew, ok := unwrap(val)
+	if !ok {
+		return val, Metadata{}, nil
+	}
+	if ew.instance != es.instanceID {
+		return nil, Metadata{}, ForeignValueError
+	}
+	if ew, err = es.futureEnvelope(key, ew); err != nil {
+		return nil, Metadata{}, err
+	}
+	if ew, err = es.decoded(ew); err != nil {
+		return nil, Metadata{}, err
+	}
+	if ew, err = es.withGeneration(key, es.withTagExpiry(ew)); err != nil {
+		return nil, Metadata{}, err
+	}
+	now := es.now()
+	es.observeSkew(ew, now)
+	if cerr, ok := es.cachedError(ew); ok {
+		return nil, es.metadataFor(ew, now), cerr
+	}
+	return ew.value, es.metadataFor(ew, now), nil
+}
+
+func (es Store) metadataFor(ew wrappedValue, now time.Time) Metadata {
+	md := Metadata{ExpireAt: ew.expireAt, Timestamp: ew.timestamp}
+	if es.isExpired(ew, now) {
+		md.Expired = true
+		md.Staleness = now.Sub(ew.expireAt)
+	}
+	return md
+}
+
+// Peek is like Get, but without side effects: an expired value is not
+// deleted, the read doesn't count as an access for access tracking or
+// eviction, and the fallback store is not consulted.
+func (es Store) Peek(key interface{}) (interface{}, error) {
+	val, err := es.peek(key)
+	return es.clone(val), err
+}
+
+func (es Store) peek(key interface{}) (interface{}, error) {
+	val, md, err := es.getStale(key)
+	if (err == nil || isCachedError(err)) && md.Expired && !es.pins.has(key) {
+		return val, es.expired()
+	}
+	return val, err
+}
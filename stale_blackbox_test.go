@@ -0,0 +1,67 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStale(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Millisecond}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("fresh", "value", &store.Options{Expiration: time.Hour}))
+	clk.Advance(5 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		val, md, err := es.GetStale("key")
+		assert.Nil(t, err)
+		assert.Equal(t, "value", val)
+		assert.True(t, md.Expired)
+		assert.Equal(t, 4*time.Millisecond, md.Staleness)
+	}
+	assert.Equal(t, 0, ms.deleteCount)
+
+	_, md, err := es.GetStale("fresh")
+	assert.Nil(t, err)
+	assert.False(t, md.Expired)
+	assert.Equal(t, time.Duration(0), md.Staleness)
+	assert.Equal(t, clk.Now().Add(time.Hour-5*time.Millisecond), md.ExpireAt)
+
+	ms.cache["raw"] = "raw"
+	val, md, err := es.GetStale("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, "raw", val)
+	assert.Equal(t, expiring.Metadata{}, md)
+
+	_, _, err = es.GetStale("missing")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestPeek(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk), expiring.WithAccessTracking())
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("short", "value", &store.Options{Expiration: time.Millisecond}))
+	clk.Advance(5 * time.Millisecond)
+
+	val, err := es.Peek("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, uint64(0), es.HotKeys(1)[0].Hits)
+
+	_, err = es.Peek("short")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 0, ms.deleteCount)
+	_, err = es.Get("short")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 1, ms.deleteCount)
+}
@@ -0,0 +1,114 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// StalenessAlert configures WithStalenessAlert. A threshold of 0 is not
+	// checked.
+	StalenessAlert struct {
+		// Window is how long reads are counted for before the thresholds
+		// are checked.
+		Window time.Duration
+		// MinReads is how many reads a window needs for its thresholds to
+		// be checked, so that a quiet window doesn't raise an alert.
+		MinReads uint64
+		// MaxExpiredRate is the fraction of reads which may find an expired
+		// value.
+		MaxExpiredRate float64
+		// MaxAverageStaleness is how long ago, on average, the expired
+		// values read may have expired.
+		MaxAverageStaleness time.Duration
+		// Alert is called with the report of each window which crosses a
+		// threshold, synchronously, by the read which ends the window.
+		Alert func(StalenessReport)
+	}
+
+	// StalenessReport describes the reads of values written through the
+	// Store over one window of a StalenessAlert. Misses are not counted.
+	StalenessReport struct {
+		Start            time.Time
+		Window           time.Duration
+		Reads            uint64
+		Expired          uint64
+		ExpiredRate      float64
+		AverageStaleness time.Duration
+	}
+
+	stalenessMonitor struct {
+		alert StalenessAlert
+
+		mu        sync.Mutex
+		start     time.Time
+		reads     uint64
+		expired   uint64
+		staleness time.Duration
+	}
+)
+
+func newStalenessMonitor(alert StalenessAlert) *stalenessMonitor {
+	return &stalenessMonitor{alert: alert}
+}
+
+// observeRead records a read of ew, which found it expired or not.
+func (es Store) observeRead(ew wrappedValue, now time.Time, expired bool) {
+	if expired {
+		es.count(MetricExpired, 1)
+	} else {
+		es.count(MetricHits, 1)
+	}
+	if es.staleness == nil {
+		return
+	}
+	if report, crossed := es.staleness.record(now, expired, now.Sub(ew.expireAt)); crossed {
+		_ = es.guard("staleness alert", func() error {
+			es.staleness.alert.Alert(report)
+			return nil
+		})
+	}
+}
+
+// record counts a read, returning the report of the window it ended, if it
+// ended one which crossed a threshold.
+func (m *stalenessMonitor) record(now time.Time, expired bool, staleness time.Duration) (StalenessReport, bool) {
+	m.mu.Lock()
+	var (
+		report StalenessReport
+		ended  bool
+	)
+	if m.start.IsZero() {
+		m.start = now
+	} else if now.Sub(m.start) >= m.alert.Window {
+		report, ended = m.reportLocked(), true
+		m.start, m.reads, m.expired, m.staleness = now, 0, 0, 0
+	}
+	m.reads++
+	if expired {
+		m.expired++
+		m.staleness += staleness
+	}
+	m.mu.Unlock()
+	return report, ended && m.crossed(report)
+}
+
+func (m *stalenessMonitor) reportLocked() StalenessReport {
+	r := StalenessReport{Start: m.start, Window: m.alert.Window, Reads: m.reads, Expired: m.expired}
+	if m.reads > 0 {
+		r.ExpiredRate = float64(m.expired) / float64(m.reads)
+	}
+	if m.expired > 0 {
+		r.AverageStaleness = m.staleness / time.Duration(m.expired)
+	}
+	return r
+}
+
+// crossed reports whether r crosses one of the alert's thresholds.
+func (m *stalenessMonitor) crossed(r StalenessReport) bool {
+	if m.alert.Alert == nil || r.Reads == 0 || r.Reads < m.alert.MinReads {
+		return false
+	}
+	return (m.alert.MaxExpiredRate > 0 && r.ExpiredRate > m.alert.MaxExpiredRate) ||
+		(m.alert.MaxAverageStaleness > 0 && r.AverageStaleness > m.alert.MaxAverageStaleness)
+}
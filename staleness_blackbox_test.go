@@ -0,0 +1,90 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStalenessAlert(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	var reports []expiring.StalenessReport
+	es := expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithStalenessAlert(expiring.StalenessAlert{
+		Window:         100 * time.Millisecond,
+		MinReads:       2,
+		MaxExpiredRate: 0.25,
+		Alert:          func(r expiring.StalenessReport) { reports = append(reports, r) },
+	}), expiring.WithClock(clk))
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, es.Set(key, "value", &store.Options{Expiration: time.Hour}))
+	}
+	assert.Nil(t, es.Set("short", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	// one expired read in four doesn't cross the threshold
+	for _, key := range []string{"a", "b", "c", "short"} {
+		_, _ = es.Get(key)
+	}
+	clk.Advance(100 * time.Millisecond)
+	_, _ = es.Get("a")
+	assert.Empty(t, reports)
+
+	// ending a window with two expired reads of four does
+	assert.Nil(t, es.Set("short", "value", nil))
+	assert.Nil(t, es.Set("shorter", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+	_, _ = es.Get("short")
+	_, _, _ = es.GetWithTTL("shorter")
+	_, _ = es.Get("b")
+	clk.Advance(100 * time.Millisecond)
+	_, _ = es.Get("a")
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, uint64(4), reports[0].Reads)
+		assert.Equal(t, uint64(2), reports[0].Expired)
+		assert.Equal(t, 0.5, reports[0].ExpiredRate)
+		assert.True(t, reports[0].AverageStaleness >= 10*time.Millisecond)
+	}
+}
+
+func TestStalenessAlertAverageStaleness(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	alerts := 0
+	es := expiring.New(&ms, &store.Options{Expiration: time.Millisecond}, expiring.WithStalenessAlert(expiring.StalenessAlert{
+		Window:              50 * time.Millisecond,
+		MaxAverageStaleness: 20 * time.Millisecond,
+		Alert:               func(expiring.StalenessReport) { alerts++ },
+	}), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(40 * time.Millisecond)
+	_, _ = es.Get("key")
+	clk.Advance(50 * time.Millisecond)
+	assert.Nil(t, es.Set("key", "value", &store.Options{Expiration: time.Hour}))
+	_, _ = es.Get("key")
+	assert.Equal(t, 1, alerts)
+}
+
+func TestStalenessAlertPanicRecovered(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk), expiring.WithStalenessAlert(expiring.StalenessAlert{
+		Window:         time.Minute,
+		MaxExpiredRate: 0.5,
+		Alert:          func(expiring.StalenessReport) { panic("alert") },
+	}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(2 * time.Minute)
+	_, _ = es.Get("key")
+	clk.Advance(time.Minute)
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.NotPanics(t, func() { _, _ = es.Get("key") })
+	assert.Equal(t, uint64(1), es.Stats().Panics)
+}
@@ -0,0 +1,243 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Stats is a point-in-time snapshot of a Store's counters.
+	Stats struct {
+		// DeleteFailures counts failed best effort deletes of expired values,
+		// including failed retries.
+		DeleteFailures uint64
+		// DeleteRetriesDropped counts failed deletes which were given up on,
+		// either because the retry queue was full or disabled, or because they
+		// ran out of attempts.
+		DeleteRetriesDropped uint64
+		// DeletesDeduplicated counts best effort deletes of expired values
+		// which were skipped because a delete of the same key was in flight.
+		DeletesDeduplicated uint64
+		// Evictions counts values deleted because the Store was over capacity.
+		Evictions uint64
+		// TransientErrors, PermanentErrors and Timeouts count the calls to
+		// the underlying store which failed, by the class the
+		// ErrorClassifier gave their error. Misses aren't counted.
+		TransientErrors uint64
+		PermanentErrors uint64
+		Timeouts        uint64
+		// Panics counts the panics recovered from functions given to the
+		// Store, see PanicError.
+		Panics uint64
+		// WorkerRestarts counts the background workers restarted after
+		// they failed, see Store.Health.
+		WorkerRestarts uint64
+		// IdleSuspensions counts the times the Store's background workers
+		// were suspended because it was idle, see WithIdleSuspend.
+		IdleSuspensions uint64
+		// FutureEnvelopes counts reads of values written by a later version
+		// of this package, see WithFutureEnvelopes.
+		FutureEnvelopes uint64
+		// BackgroundEvictions counts the Evictions made by the evictor, see
+		// WithEvictionWatermarks.
+		BackgroundEvictions uint64
+		// SuppressedSets counts Sets which were not written because their key
+		// may not be cached.
+		SuppressedSets uint64
+		// SampledOutSets counts Sets which were not written because they
+		// weren't sampled, see WithSetSampling.
+		SampledOutSets uint64
+		// UnadmittedSets counts Sets which were not written because their
+		// key hadn't been requested often enough, see WithAdmission.
+		UnadmittedSets uint64
+		// ErrorHits counts reads which found an error cached by SetError.
+		ErrorHits uint64
+		// FallbackHits counts Gets served by the fallback store.
+		FallbackHits uint64
+		// SourceFetches counts values fetched from the Source given to
+		// WithReadThrough, and SourceErrors the fetches which failed.
+		SourceFetches uint64
+		SourceErrors  uint64
+		// ScheduledRefreshes counts keys loaded by ScheduleRefresh, and
+		// ScheduledRefreshFailures the loads which failed.
+		ScheduledRefreshes       uint64
+		ScheduledRefreshFailures uint64
+		// SinkFailures counts failed writes to the sink given to
+		// WithWriteThrough, including those the policy ignores.
+		SinkFailures uint64
+		// WriteBehindQueued counts writes queued for the sink given to
+		// WithWriteBehind, and WriteBehindWritten those written to it.
+		// WriteBehindRetries counts failed attempts which were retried.
+		// Writes are lost when they are dropped, because the queue was full
+		// or closed, or failed, because they ran out of attempts.
+		WriteBehindQueued  uint64
+		WriteBehindWritten uint64
+		WriteBehindRetries uint64
+		WriteBehindDropped uint64
+		WriteBehindFailed  uint64
+		// RequestCacheHits counts GetWithContext calls served by the request
+		// cache of their context, see WithRequestCache.
+		RequestCacheHits uint64
+		// CascadedDeletes counts values deleted because a value they depend
+		// on was removed, see DependsOn.
+		CascadedDeletes uint64
+		// HitJitterRewrites counts values rewritten with a nudged expiration
+		// after a hit, see WithHitJitter.
+		HitJitterRewrites uint64
+		// DrainedSets counts Sets which were not written because the Store
+		// is draining, see Drain.
+		DrainedSets uint64
+		// HedgedReads counts Gets which were also sent to the hedge replica.
+		HedgedReads uint64
+		// HedgeWins counts hedged reads answered by the replica.
+		HedgeWins uint64
+		// ShadowReads counts Gets mirrored to the shadow store given to
+		// WithShadow, and ShadowDivergences those whose results disagreed.
+		ShadowReads       uint64
+		ShadowDivergences uint64
+		// LeasesGranted counts leases taken by GetWithLease.
+		LeasesGranted uint64
+		// LeaseConflicts counts GetWithLease misses which found the lease held
+		// by another caller.
+		LeaseConflicts uint64
+		// SkewedReads counts reads of values written by a node whose clock
+		// is ahead of this one's, i.e. whose write time is in the future.
+		SkewedReads uint64
+		// MaxClockSkew is the largest skew shown by those reads.
+		MaxClockSkew time.Duration
+		// AdminWebhookFailures counts notifications which couldn't be
+		// delivered to the admin webhook, see WithAdminWebhook.
+		AdminWebhookFailures uint64
+		// MutationLogFailures counts mutations which couldn't be appended to
+		// the log given to WithMutationLog.
+		MutationLogFailures uint64
+		// SetTTLs summarizes the TTLs given to values set, and RemainingTTLs
+		// those left to the unexpired values read. They are nil unless
+		// WithTTLHistograms was given.
+		SetTTLs       *LatencySummary
+		RemainingTTLs *LatencySummary
+		// Latencies summarizes the latency of calls to the underlying store,
+		// by Operation. It is nil unless WithLatencyHistograms was given.
+		Latencies map[Operation]LatencySummary
+		// PrimaryLatency and ShadowLatency summarize the latency of the
+		// mirrored Gets, on the underlying store and on the shadow store.
+		// They are nil unless WithShadow was given.
+		PrimaryLatency *LatencySummary
+		ShadowLatency  *LatencySummary
+		// Drain reports the progress of the drain started by Drain. It is nil
+		// unless the Store is draining.
+		Drain *DrainProgress
+		// Index describes the index of the keys the Store tracks, with an
+		// estimate of its memory. It is nil unless keys are tracked, e.g.
+		// for WithMaxEntries or WithReaper.
+		Index *IndexStats
+	}
+
+	stats struct {
+		deleteFailures           uint64
+		deleteRetriesDropped     uint64
+		deletesDeduplicated      uint64
+		evictions                uint64
+		backgroundEvictions      uint64
+		transientErrors          uint64
+		permanentErrors          uint64
+		timeouts                 uint64
+		panics                   uint64
+		workerRestarts           uint64
+		idleSuspensions          uint64
+		futureEnvelopes          uint64
+		suppressedSets           uint64
+		sampledOutSets           uint64
+		unadmittedSets           uint64
+		errorHits                uint64
+		fallbackHits             uint64
+		sourceFetches            uint64
+		sourceErrors             uint64
+		scheduledRefreshes       uint64
+		scheduledRefreshFailures uint64
+		sinkFailures             uint64
+		writeBehindQueued        uint64
+		writeBehindWritten       uint64
+		writeBehindRetries       uint64
+		writeBehindDropped       uint64
+		writeBehindFailed        uint64
+		requestCacheHits         uint64
+		cascadedDeletes          uint64
+		hitJitterRewrites        uint64
+		drainedSets              uint64
+		hedgedReads              uint64
+		hedgeWins                uint64
+		shadowReads              uint64
+		shadowDivergences        uint64
+		leasesGranted            uint64
+		leaseConflicts           uint64
+		skewedReads              uint64
+		maxClockSkew             int64
+		adminWebhookFailures     uint64
+		mutationLogFailures      uint64
+	}
+)
+
+// Stats returns a snapshot of the Store's counters.
+func (es Store) Stats() Stats {
+	setTTLs, remainingTTLs := es.ttlSummaries()
+	primaryLatency, shadowLatency := es.shadowSummaries()
+	return Stats{
+		DeleteFailures:           atomic.LoadUint64(&es.stats.deleteFailures),
+		DeleteRetriesDropped:     atomic.LoadUint64(&es.stats.deleteRetriesDropped),
+		DeletesDeduplicated:      atomic.LoadUint64(&es.stats.deletesDeduplicated),
+		Evictions:                atomic.LoadUint64(&es.stats.evictions),
+		BackgroundEvictions:      atomic.LoadUint64(&es.stats.backgroundEvictions),
+		TransientErrors:          atomic.LoadUint64(&es.stats.transientErrors),
+		PermanentErrors:          atomic.LoadUint64(&es.stats.permanentErrors),
+		Timeouts:                 atomic.LoadUint64(&es.stats.timeouts),
+		Panics:                   atomic.LoadUint64(&es.stats.panics),
+		WorkerRestarts:           atomic.LoadUint64(&es.stats.workerRestarts),
+		IdleSuspensions:          atomic.LoadUint64(&es.stats.idleSuspensions),
+		FutureEnvelopes:          atomic.LoadUint64(&es.stats.futureEnvelopes),
+		SuppressedSets:           atomic.LoadUint64(&es.stats.suppressedSets),
+		SampledOutSets:           atomic.LoadUint64(&es.stats.sampledOutSets),
+		UnadmittedSets:           atomic.LoadUint64(&es.stats.unadmittedSets),
+		ErrorHits:                atomic.LoadUint64(&es.stats.errorHits),
+		FallbackHits:             atomic.LoadUint64(&es.stats.fallbackHits),
+		SourceFetches:            atomic.LoadUint64(&es.stats.sourceFetches),
+		SourceErrors:             atomic.LoadUint64(&es.stats.sourceErrors),
+		ScheduledRefreshes:       atomic.LoadUint64(&es.stats.scheduledRefreshes),
+		ScheduledRefreshFailures: atomic.LoadUint64(&es.stats.scheduledRefreshFailures),
+		SinkFailures:             atomic.LoadUint64(&es.stats.sinkFailures),
+		WriteBehindQueued:        atomic.LoadUint64(&es.stats.writeBehindQueued),
+		WriteBehindWritten:       atomic.LoadUint64(&es.stats.writeBehindWritten),
+		WriteBehindRetries:       atomic.LoadUint64(&es.stats.writeBehindRetries),
+		WriteBehindDropped:       atomic.LoadUint64(&es.stats.writeBehindDropped),
+		WriteBehindFailed:        atomic.LoadUint64(&es.stats.writeBehindFailed),
+		RequestCacheHits:         atomic.LoadUint64(&es.stats.requestCacheHits),
+		CascadedDeletes:          atomic.LoadUint64(&es.stats.cascadedDeletes),
+		HitJitterRewrites:        atomic.LoadUint64(&es.stats.hitJitterRewrites),
+		DrainedSets:              atomic.LoadUint64(&es.stats.drainedSets),
+		HedgedReads:              atomic.LoadUint64(&es.stats.hedgedReads),
+		HedgeWins:                atomic.LoadUint64(&es.stats.hedgeWins),
+		ShadowReads:              atomic.LoadUint64(&es.stats.shadowReads),
+		ShadowDivergences:        atomic.LoadUint64(&es.stats.shadowDivergences),
+		LeasesGranted:            atomic.LoadUint64(&es.stats.leasesGranted),
+		LeaseConflicts:           atomic.LoadUint64(&es.stats.leaseConflicts),
+		SkewedReads:              atomic.LoadUint64(&es.stats.skewedReads),
+		MaxClockSkew:             time.Duration(atomic.LoadInt64(&es.stats.maxClockSkew)),
+		AdminWebhookFailures:     atomic.LoadUint64(&es.stats.adminWebhookFailures),
+		MutationLogFailures:      atomic.LoadUint64(&es.stats.mutationLogFailures),
+		SetTTLs:                  setTTLs,
+		RemainingTTLs:            remainingTTLs,
+		Latencies:                es.latencySummaries(),
+		PrimaryLatency:           primaryLatency,
+		ShadowLatency:            shadowLatency,
+		Drain:                    es.drainProgress(),
+		Index:                    es.indexStats(),
+	}
+}
+
+func (es Store) indexStats() *IndexStats {
+	if es.tracker == nil {
+		return nil
+	}
+	stats := es.tracker.indexStats()
+	return &stats
+}
@@ -1,88 +1,582 @@
 package expiring_gocache
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/eko/gocache/store"
+	"github.com/nabowler/expiring_gocache/clock"
 )
 
 type (
 	Store struct {
-		expiration time.Duration
-		store      store.StoreInterface
+		store    store.StoreInterface
+		typeName string
+		settings *dynamicSettings
+
+		bucketWidth       time.Duration
+		reaperEnabled     bool
+		reaperInterval    time.Duration
+		tracker           *tracker
+		reaper            *reaper
+		watermarks        *watermarks
+		evictionPolicy    func() EvictionPolicy
+		hashedIndex       bool
+		evictor           *evictor
+		trackAccess       bool
+		trackDependencies bool
+
+		onDeleteFailure func(key interface{}, err error)
+		retryQueueSize  int
+		retryAttempts   int
+		retryBackoff    time.Duration
+		retrier         *deleteRetrier
+		inflight        *inflightDeletes
+
+		pins *pinSet
+
+		slowThreshold time.Duration
+		slowCallback  func(SlowOperation)
+		slowLogSize   int
+		slowLog       *slowLog
+
+		latencies map[Operation]*latencyHistogram
+		ttls      *ttlHistograms
+
+		deadlineClamp float64
+
+		bypass func(key interface{}) bool
+
+		allowKeys            func(key interface{}) bool
+		denyKeys             func(key interface{}) bool
+		errorOnSuppressedSet bool
+
+		setSampleRate            float64
+		deterministicSetSampling bool
+
+		admission *admissionSketch
+
+		fallback        store.StoreInterface
+		promoteFallback bool
+
+		source    Source
+		fetches   *fetchGroup
+		refreshes *refreshScheduler
+
+		refreshBackoff time.Duration
+
+		workers *workerSupervisor
+		idle    *idleState
+		// background is set on the copies of the Store its background
+		// workers use, so that their calls don't count as activity.
+		background      bool
+		workerBackoff   time.Duration
+		onWorkerRestart func(WorkerHealth)
+
+		startupScan startupScan
+
+		counters *counterLocks
+
+		drain *drainState
+
+		hitJitterRate   float64
+		hitJitterWindow time.Duration
+
+		writeThrough *writeThrough
+
+		writeBehindSink   Sink
+		writeBehindConfig WriteBehind
+		writeBehind       *writeBehindQueue
+
+		hedgeReplica store.StoreInterface
+		hedgeDelay   time.Duration
+
+		shadow *shadowReader
+
+		leaseTTL time.Duration
+
+		deleteDelay time.Duration
+
+		instanceID string
+
+		ttlFunc  func(key, value interface{}) time.Duration
+		typeTTLs *typeTTLs
+
+		binaryEnvelope   bool
+		futureEnvelopes  FutureEnvelopePolicy
+		envelopeFormat   EnvelopeFormat
+		codec            Codec
+		tagExpiry        bool
+		readLimits       bool
+		namespaceOf      func(key interface{}) string
+		nativeExpiration bool
+
+		expiredErr error
+		missErr    error
+		classifier ErrorClassifier
+
+		cloner func(interface{}) interface{}
+
+		skewTolerance time.Duration
+
+		staleness *stalenessMonitor
+
+		sink MetricsSink
+
+		eventHooks []func(Event)
+
+		mutationLog MutationLog
+
+		chaos *chaosMonkey
+
+		clock clock.Clock
+
+		// ctx is the context given to the context-aware call this copy of
+		// the Store is serving, if any, for the hooks it calls.
+		ctx context.Context
+
+		adminWebhook          *adminWebhook
+		adminWebhookThreshold int
+
+		stats *stats
 	}
 
 	wrappedValue struct {
-		expireAt time.Time
-		value    interface{}
+		expireAt   time.Time
+		value      interface{}
+		instance   string
+		timestamp  time.Time
+		etag       uint64
+		createdAt  time.Time
+		tags       []string
+		generation uint64
+		maxReads   uint64
+		// version is the envelope version the value was written with.
+		version byte
 	}
 
 	clearer interface {
 		Clear() error
 	}
+
+	ttlGetter interface {
+		GetWithTTL(key interface{}) (interface{}, time.Duration, error)
+	}
 )
 
 const (
 	ExpiringStoreType = "expiring"
 
 	DefaultExpiration = 720 * time.Hour
+
+	DefaultBucketWidth = 1 * time.Minute
 )
 
 var (
 	ValueExpiredError = errors.New("cached value has expired")
+
+	UntrackableKeyError = errors.New("key is not comparable and can't be tracked")
+
+	KeyNotCacheableError = errors.New("key may not be cached")
+
+	ForeignValueError = errors.New("cached value was written by another instance")
+
+	FutureEnvelopeError = errors.New("cached value was written by a later version of this package")
 )
 
-func New(store store.StoreInterface, options *store.Options) Store {
+// New creates a Store around store. The default expiration is that of
+// options, or DefaultExpiration. See NewValidated to have the configuration
+// checked.
+func New(store store.StoreInterface, options *store.Options, opts ...Option) Store {
+	return newStore(store, options, opts).start()
+}
+
+// newStore creates a Store and applies its options, without starting it.
+func newStore(store store.StoreInterface, options *store.Options, opts []Option) Store {
 	expiration := DefaultExpiration
 	if options != nil {
 		expiration = options.ExpirationValue()
 	}
 
-	return Store{
-		expiration: expiration,
-		store:      store,
+	es := Store{
+		store:       store,
+		settings:    newDynamicSettings(settings{expiration: expiration}),
+		typeName:    ExpiringStoreType,
+		bucketWidth: DefaultBucketWidth,
+		inflight:    &inflightDeletes{keys: map[interface{}]struct{}{}},
+		pins:        &pinSet{keys: map[interface{}]struct{}{}},
+		fetches:     &fetchGroup{calls: map[interface{}]*fetchCall{}},
+		refreshes:   &refreshScheduler{jobs: map[interface{}]*refreshJob{}},
+		counters:    &counterLocks{},
+		drain:       &drainState{},
+		workers:     &workerSupervisor{workers: map[string]*WorkerHealth{}},
+		stats:       &stats{},
+		clock:       clock.Real{},
+
+		adminWebhookThreshold: DefaultAdminWebhookThreshold,
+		refreshBackoff:        DefaultRefreshBackoff,
+		workerBackoff:         DefaultWorkerRestartBackoff,
+		setSampleRate:         1,
 	}
+	for _, opt := range opts {
+		opt(&es)
+	}
+	return es
+}
+
+// start sets up the Store's indexes and starts its background workers.
+func (es Store) start() Store {
+	if es.slowThreshold > 0 {
+		callback := es.slowCallback
+		if callback != nil {
+			callback = func(op SlowOperation) {
+				defer es.recoverPanic("slow operation callback", nil)
+				es.slowCallback(op)
+			}
+		}
+		es.slowLog = newSlowLog(es.slowThreshold, callback, es.slowLogSize)
+	}
+
+	if es.retryQueueSize > 0 && es.retryAttempts > 0 {
+		es.retrier = startDeleteRetrier(es)
+	}
+
+	if es.reaperInterval > 0 || es.settings.load().maxEntries > 0 || es.trackAccess || es.trackDependencies || es.readLimits {
+		es.tracker = newTracker(es.bucketWidth, es.evictionPolicy, es.hashedIndex)
+	}
+	if es.idle != nil {
+		es.idle.last = es.now().UnixNano()
+	}
+	if es.reaperInterval > 0 {
+		es.reaper = startReaper(es, es.reaperInterval)
+	}
+	if es.watermarks != nil && es.tracker != nil {
+		es.evictor = startEvictor(es)
+	}
+	if es.writeBehindSink != nil {
+		es.writeBehind = startWriteBehind(es, es.writeBehindSink, es.writeBehindConfig)
+	}
+
+	return es
 }
 
 // Get retrieves the value from the underlying store. If the value is
-// expired, `(_, ValueExpiredError)` is returned; no guarantee is made
-// about the first returned value.
+// expired, `(_, ValueExpiredError)` is returned, or the error given to
+// WithExpiredError; no guarantee is made about the first returned value.
+//
+// If a fallback store was given with WithFallback, it is consulted when the
+// underlying store misses or holds an expired value, and then the Source
+// given to WithReadThrough.
 func (es Store) Get(key interface{}) (interface{}, error) {
-	val, err := es.store.Get(key)
-	if err != nil || val == nil {
-		return val, err
+	return es.load(context.Background(), key)
+}
+
+// load is Get, fetching from the Source with ctx.
+func (es Store) load(ctx context.Context, key interface{}) (interface{}, error) {
+	es.active()
+	es.requested(key)
+	val, err := es.get(key)
+	return es.miss(ctx, key, val, err)
+}
+
+// miss returns the result of a read from the underlying store, consulting
+// the fallback store and the Source if it failed with err.
+func (es Store) miss(ctx context.Context, key interface{}, val interface{}, err error) (interface{}, error) {
+	if err != nil && es.fallback != nil && !isCachedError(err) {
+		if fval, ok := es.getFallback(key); ok {
+			return es.clone(fval), nil
+		}
+	}
+	if err != nil && es.source != nil && !isCachedError(err) && err != ForeignValueError && err != FutureEnvelopeError {
+		val, err = es.readThrough(ctx, key)
+	}
+	return es.clone(val), err
+}
+
+// get retrieves the value from the underlying store only.
+func (es Store) get(key interface{}) (interface{}, error) {
+	val, err := es.innerGet(key)
+	if err != nil || val == nil || es.bypassed(key) {
+		return val, es.missed(err)
 	}
 
-	ew, ok := val.(wrappedValue)
+	ew, ok := unwrap(val)
 	if !ok {
 		// value was not a wrapped value. return it directly.
 		return val, nil
 	}
+	if ew.instance != es.instanceID {
+		return nil, ForeignValueError
+	}
+	if ew, err = es.futureEnvelope(key, ew); err != nil {
+		return nil, err
+	}
+	if ew, err = es.decoded(ew); err != nil {
+		return nil, err
+	}
+	if ew, err = es.withGeneration(key, es.withTagExpiry(ew)); err != nil {
+		return nil, err
+	}
 
-	if ew.expireAt.Before(time.Now()) {
+	now := es.now()
+	es.observeSkew(ew, now)
+	if es.isExpired(ew, now) && !es.pins.has(key) {
 		// value is expired. try to delete it from the store and return ValueExpiredError
-		_ = es.store.Delete(key) //best effort delete
-		return ew.value, ValueExpiredError
+		es.observeRead(ew, now, true)
+		es.expire(key)
+		return ew.value, es.expired()
+	}
+	es.observeRead(ew, now, false)
+	if ok, err := es.readAllowed(key, ew); err != nil {
+		return nil, err
+	} else if !ok {
+		return ew.value, es.expired()
 	}
+	es.observeRemainingTTL(ew.expireAt.Sub(now))
 
+	es.touch(key)
+	es.nudge(key, ew, now)
+	if cerr, ok := es.cachedError(ew); ok {
+		return nil, cerr
+	}
 	return ew.value, nil
 }
 
+// GetWithTTL retrieves the value from the underlying store along with its
+// remaining time to live. If the underlying store implements
+// `GetWithTTL(key interface{}) (interface{}, time.Duration, error)`, the
+// shorter of its native TTL and the Store's remaining time is returned, so
+// callers never cache the value for longer than either layer allows. Values
+// which were not written through the Store are returned with the native TTL,
+// or 0 if the underlying store can't report one.
+//
+// Expired values are handled as in Get.
+func (es Store) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	es.active()
+	es.requested(key)
+	val, ttl, err := es.getWithTTL(key)
+	return es.clone(val), ttl, err
+}
+
+func (es Store) getWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	var (
+		val       interface{}
+		nativeTTL time.Duration
+		err       error
+	)
+	if tg, ok := es.store.(ttlGetter); ok {
+		val, nativeTTL, err = es.innerGetWithTTL(tg, key)
+	} else {
+		val, err = es.innerGet(key)
+	}
+	if err != nil || val == nil || es.bypassed(key) {
+		return val, nativeTTL, es.missed(err)
+	}
+
+	ew, ok := unwrap(val)
+	if !ok {
+		return val, nativeTTL, nil
+	}
+	if ew.instance != es.instanceID {
+		return nil, 0, ForeignValueError
+	}
+	if ew, err = es.futureEnvelope(key, ew); err != nil {
+		return nil, 0, err
+	}
+	if ew, err = es.decoded(ew); err != nil {
+		return nil, 0, err
+	}
+	if ew, err = es.withGeneration(key, es.withTagExpiry(ew)); err != nil {
+		return nil, 0, err
+	}
+
+	now := es.now()
+	es.observeSkew(ew, now)
+	ttl := ew.expireAt.Add(es.skewTolerance).Sub(now)
+	if ttl <= 0 || es.expireEarly() {
+		if es.pins.has(key) {
+			return ew.value, 0, nil
+		}
+		es.observeRead(ew, now, true)
+		es.expire(key)
+		return ew.value, 0, es.expired()
+	}
+	es.observeRead(ew, now, false)
+	if ok, err := es.readAllowed(key, ew); err != nil {
+		return nil, 0, err
+	} else if !ok {
+		return ew.value, 0, es.expired()
+	}
+	if nativeTTL > 0 && nativeTTL < ttl {
+		ttl = nativeTTL
+	}
+	es.observeRemainingTTL(ttl)
+	es.touch(key)
+	es.nudge(key, ew, now)
+	if cerr, ok := es.cachedError(ew); ok {
+		return nil, ttl, cerr
+	}
+	return ew.value, ttl, nil
+}
+
+// expire removes an expired value from the underlying store.
+func (es Store) expire(key interface{}) {
+	if es.tracker != nil {
+		es.tracker.expired(key, es.now())
+	}
+	es.bestEffortDelete(key)
+	es.emit(Event{Type: EventExpire, Key: key})
+	es.cascade(key)
+}
+
+// Set wraps the value with its expiration and writes it to the underlying
+// store. If the options don't give an expiration, values implementing TTLer
+// or ExpireAter set their own. Tags created by this package, such as
+// PriorityTag, are removed from the options before they are passed on.
+//
+// If WithWriteThrough was given, the value is also written to the sink.
 func (es Store) Set(key interface{}, value interface{}, options *store.Options) error {
-	expireAt := time.Now().Add(es.expiration)
+	ttl := ttlFor(options, es.defaultTTL(key, value))
+	return es.through(context.Background(), []sinkOp{{op: OperationSet, key: key, value: value, ttl: ttl}}, func() error {
+		return es.set(key, value, options, ttl, time.Time{})
+	})
+}
+
+// set writes the value, expiring it after ttl. timestamp is recorded for
+// SetIfNewer, and may be zero.
+func (es Store) set(key interface{}, value interface{}, options *store.Options, ttl time.Duration, timestamp time.Time) error {
+	p, ok, err := es.prepareSet(key, value, options, ttl, timestamp)
+	if err != nil || !ok {
+		return err
+	}
+	if err := es.innerSet(p.item.Key, p.item.Value, p.item.Options); err != nil {
+		return err
+	}
+	es.setDone(p)
+	es.evict()
+	return nil
+}
+
+// prepareSet works out what to write to the underlying store for a Set of
+// key, returning false if nothing should be written.
+func (es Store) prepareSet(key interface{}, value interface{}, options *store.Options, ttl time.Duration, timestamp time.Time) (preparedSet, bool, error) {
+	es.active()
+	if !es.cacheable(key) {
+		return preparedSet{}, false, es.suppressSet()
+	}
+	if !es.sampled(key) {
+		atomic.AddUint64(&es.stats.sampledOutSets, 1)
+		return preparedSet{}, false, nil
+	}
+	if es.draining() {
+		atomic.AddUint64(&es.stats.drainedSets, 1)
+		return preparedSet{}, false, nil
+	}
+	if !es.admitted(key) {
+		atomic.AddUint64(&es.stats.unadmittedSets, 1)
+		return preparedSet{}, false, nil
+	}
+	options, d := parseDirectives(options)
+	if es.bypassed(key) {
+		return preparedSet{item: SetItem{Key: key, Value: value, Options: options}, value: value}, true, nil
+	}
+	now := es.now()
+	expireAt := now.Add(es.jittered(ttl))
+	ew := wrappedValue{expireAt: expireAt, value: value, instance: es.instanceID, timestamp: timestamp, etag: etagFor(value), createdAt: now}
+	if es.tagExpiry && options != nil {
+		ew.tags = append([]string(nil), options.Tags...)
+	}
+	generation, err := es.keyGeneration(key)
+	if err != nil {
+		return preparedSet{}, false, err
+	}
+	ew.generation = generation
+	if es.readLimits {
+		ew.maxReads = d.maxReads
+	}
+	wrapped, err := es.wrap(ew)
+	if err != nil {
+		return preparedSet{}, false, err
+	}
+	if es.nativeExpiration {
+		options = withNativeExpiration(options, expireAt.Sub(now))
+	}
+	return preparedSet{
+		item:      SetItem{Key: key, Value: wrapped, Options: options},
+		value:     value,
+		wrapped:   true,
+		expireAt:  expireAt,
+		priority:  d.priority,
+		dependsOn: d.dependsOn,
+		maxReads:  ew.maxReads,
+		cost:      d.cost,
+	}, true, nil
+}
+
+// setDone records a prepared Set once it has been written.
+func (es Store) setDone(p preparedSet) {
+	var ttl time.Duration
+	if p.wrapped {
+		now := es.now()
+		es.track(p.item.Key, p.expireAt, p.priority, p.dependsOn, p.cost)
+		if es.tracker != nil {
+			es.tracker.recordSet(p.item.Key, now)
+		}
+		ttl = p.expireAt.Sub(now)
+		es.observeSetTTL(ttl)
+		if p.maxReads > 0 {
+			es.limitReads(p.item.Key, p.maxReads, ttl)
+		}
+	}
+	es.emit(Event{Type: EventSet, Key: p.item.Key, TTL: ttl, value: p.value})
+}
+
+// ttlFor returns the expiration given by options, or fallback if there is
+// none.
+func ttlFor(options *store.Options, fallback time.Duration) time.Duration {
 	if options != nil && options.ExpirationValue() > 0 {
-		expireAt = time.Now().Add(options.ExpirationValue())
+		return options.ExpirationValue()
 	}
-	return es.store.Set(key, wrappedValue{expireAt: expireAt, value: value}, options)
+	return fallback
 }
 
+// bypassed reports whether key is passed straight through to the underlying
+// store, see WithBypass.
+func (es Store) bypassed(key interface{}) bool {
+	return es.bypass != nil && es.bypass(key)
+}
+
+// Delete removes the value from the underlying store. If WithDeleteDelay was
+// given, the value is soft deleted instead, as with DeleteAfter. If
+// WithWriteThrough was given, the value is also removed from the sink.
 func (es Store) Delete(key interface{}) error {
-	return es.store.Delete(key)
+	return es.through(context.Background(), []sinkOp{{op: OperationDelete, key: key}}, func() error {
+		return es.delete(key)
+	})
+}
+
+func (es Store) delete(key interface{}) error {
+	es.active()
+	if es.deleteDelay > 0 {
+		return es.deleteAfter(key, es.deleteDelay)
+	}
+	es.untrack(key)
+	if err := es.innerDelete(key); err != nil {
+		return err
+	}
+	es.emit(Event{Type: EventDelete, Key: key})
+	es.cascade(key)
+	return nil
 }
 
 func (es Store) Invalidate(options store.InvalidateOptions) error {
-	return es.store.Invalidate(options)
+	if err := es.innerInvalidate(options); err != nil {
+		return err
+	}
+	es.emit(Event{Type: EventInvalidate, Tags: options.TagsValue()})
+	return nil
 }
 
 func (es Store) Clear() error {
@@ -90,11 +584,56 @@ func (es Store) Clear() error {
 	// Target v0.2.0, support current HEAD on Master
 	clear, ok := es.store.(clearer)
 	if ok {
-		return clear.Clear()
+		var cleared int
+		if es.tracker != nil {
+			cleared = es.tracker.len()
+		}
+		pinned := es.pinnedValues()
+		if err := es.innerClear(clear); err != nil {
+			return err
+		}
+		if es.tracker != nil {
+			es.tracker.clear()
+		}
+		es.emit(Event{Type: EventClear})
+		es.notifyAdmin(AdminNotification{Operation: OperationClear, Keys: cleared})
+		return es.restorePinned(pinned)
+	}
+	return nil
+}
+
+// now reads the Store's clock, see WithClock.
+func (es Store) now() time.Time {
+	return es.clock.Now()
+}
+
+// Clock returns the clock the Store reads the time from, see WithClock.
+func (es Store) Clock() clock.Clock {
+	return es.clock
+}
+
+// Close stops any background workers started by the Store. The
+// underlying store is not closed.
+func (es Store) Close() error {
+	if es.reaper != nil {
+		es.reaper.stop()
+	}
+	if es.evictor != nil {
+		es.evictor.stop()
+	}
+	if es.retrier != nil {
+		es.retrier.stop()
+	}
+	if es.writeBehind != nil {
+		es.writeBehind.stop()
+	}
+	if es.refreshes != nil {
+		es.refreshes.stop()
 	}
 	return nil
 }
 
+// GetType returns ExpiringStoreType, or the name given by WithTypeName.
 func (es Store) GetType() string {
-	return ExpiringStoreType
+	return es.typeName
 }
@@ -2,22 +2,27 @@ package expiring_gocache_test
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/eko/gocache/store"
 	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
 	"github.com/stretchr/testify/assert"
 )
 
 type (
 	MapStore struct {
+		mu              sync.Mutex
 		cache           map[interface{}]interface{}
 		setCount        int
 		getCount        int
 		deleteCount     int
 		clearCount      int
 		invalidateCount int
+		lastSetOptions  *store.Options
+		deletedKeys     []interface{}
 	}
 
 	NonClearable struct{}
@@ -36,8 +41,9 @@ func TestDefaultExpiration(t *testing.T) {
 	key := "key"
 	value := "value"
 
+	clk := clock.NewFake(time.Now())
 	ms := MapStore{cache: map[interface{}]interface{}{}}
-	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration})
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, expiring.WithClock(clk))
 
 	// Nothing inserted yet. Should miss.
 	val, err := es.Get(key)
@@ -56,7 +62,7 @@ func TestDefaultExpiration(t *testing.T) {
 	assert.Equal(t, value, val)
 	assert.Equal(t, 2, ms.getCount)
 
-	time.Sleep(defaultSleep)
+	clk.Advance(defaultSleep)
 	// the cached value should be expired
 	_, err = es.Get(key)
 	assert.Equal(t, expiring.ValueExpiredError, err)
@@ -73,8 +79,9 @@ func TestPerKeyExpiration(t *testing.T) {
 	shorterKey := "shorter"
 	value := "value"
 
+	clk := clock.NewFake(time.Now())
 	ms := MapStore{cache: map[interface{}]interface{}{}}
-	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration})
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, expiring.WithClock(clk))
 
 	// Nothing inserted yet. Should miss.
 	val, err := es.Get(longerKey)
@@ -103,7 +110,7 @@ func TestPerKeyExpiration(t *testing.T) {
 	assert.Equal(t, value, val)
 	assert.Equal(t, 3, ms.getCount)
 
-	time.Sleep(defaultSleep)
+	clk.Advance(defaultSleep)
 	//the value should be expired because the expiration was shorter than the default
 	_, err = es.Get(shorterKey)
 	assert.Equal(t, expiring.ValueExpiredError, err)
@@ -114,7 +121,7 @@ func TestPerKeyExpiration(t *testing.T) {
 	assert.Equal(t, value, val)
 	assert.Equal(t, 5, ms.getCount)
 
-	time.Sleep(longerExpiration - defaultExpiration)
+	clk.Advance(longerExpiration - defaultExpiration)
 	// the cached value should be expired
 	_, err = es.Get(longerKey)
 	assert.Equal(t, expiring.ValueExpiredError, err)
@@ -130,8 +137,9 @@ func TestDelete(t *testing.T) {
 	key := "key"
 	value := "value"
 
+	clk := clock.NewFake(time.Now())
 	ms := MapStore{cache: map[interface{}]interface{}{}}
-	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration})
+	es := expiring.New(&ms, &store.Options{Expiration: defaultExpiration}, expiring.WithClock(clk))
 
 	// Nothing inserted yet. Should miss.
 	val, err := es.Get(key)
@@ -159,7 +167,7 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, err, MapStoreMiss)
 	assert.Equal(t, 3, ms.getCount)
 
-	time.Sleep(defaultSleep)
+	clk.Advance(defaultSleep)
 	// the value should continue to miss
 	_, err = es.Get(key)
 	assert.Equal(t, err, MapStoreMiss)
@@ -213,9 +221,19 @@ func TestGetType(t *testing.T) {
 	assert.Equal(t, expiring.ExpiringStoreType, es.GetType())
 }
 
+func TestWithTypeName(t *testing.T) {
+	es := expiring.New(nil, nil, expiring.WithTypeName("expiring-sessions"))
+	assert.Equal(t, "expiring-sessions", es.GetType())
+
+	es = expiring.New(nil, nil, expiring.WithTypeName(""))
+	assert.Equal(t, expiring.ExpiringStoreType, es.GetType())
+}
+
 // mapstore implementation
 
 func (ms *MapStore) Get(key interface{}) (interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.getCount++
 	val, ok := ms.cache[key]
 	if !ok {
@@ -225,23 +243,33 @@ func (ms *MapStore) Get(key interface{}) (interface{}, error) {
 }
 
 func (ms *MapStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.setCount++
+	ms.lastSetOptions = options
 	ms.cache[key] = value
 	return nil
 }
 
 func (ms *MapStore) Delete(key interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.deleteCount++
+	ms.deletedKeys = append(ms.deletedKeys, key)
 	delete(ms.cache, key)
 	return nil
 }
 
 func (ms *MapStore) Invalidate(options store.InvalidateOptions) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.invalidateCount++
 	return nil
 }
 
 func (ms *MapStore) Clear() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.clearCount++
 	for k := range ms.cache {
 		delete(ms.cache, k)
@@ -249,7 +277,21 @@ func (ms *MapStore) Clear() error {
 	return nil
 }
 
-func (ms MapStore) GetType() string {
+// holds reports whether key is held, for values deleted in the background.
+func (ms *MapStore) holds(key interface{}) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	_, ok := ms.cache[key]
+	return ok
+}
+
+func (ms *MapStore) len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.cache)
+}
+
+func (ms *MapStore) GetType() string {
 	return "MapStore"
 }
 
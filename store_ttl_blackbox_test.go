@@ -0,0 +1,92 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	TTLMapStore struct {
+		*MapStore
+		ttl time.Duration
+	}
+)
+
+func TestGetWithTTL(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	_, _, err := es.GetWithTTL("key")
+	assert.Equal(t, MapStoreMiss, err)
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.True(t, ttl > time.Hour-time.Second && ttl <= time.Hour)
+
+	// values not written through the wrapper have no known TTL
+	ms.cache["raw"] = "raw"
+	val, ttl, err = es.GetWithTTL("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, "raw", val)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func TestGetWithTTLExpired(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	clk := clock.NewFake(time.Now())
+	es := expiring.New(&ms, &store.Options{Expiration: 10 * time.Millisecond}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	clk.Advance(20 * time.Millisecond)
+
+	_, ttl, err := es.GetWithTTL("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.Equal(t, 1, ms.deleteCount)
+}
+
+func TestGetWithTTLUsesShorterNativeTTL(t *testing.T) {
+	tms := TTLMapStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&tms, &store.Options{Expiration: time.Hour})
+	assert.Nil(t, es.Set("key", "value", nil))
+
+	// the native TTL is shorter than the wrapper's
+	tms.ttl = time.Minute
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, time.Minute, ttl)
+
+	// the native TTL is longer than the wrapper's
+	tms.ttl = 2 * time.Hour
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > time.Hour-time.Second && ttl <= time.Hour)
+
+	// the native store doesn't expire the value
+	tms.ttl = 0
+	_, ttl, err = es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > time.Hour-time.Second && ttl <= time.Hour)
+
+	// values not written through the wrapper keep the native TTL
+	tms.cache["raw"] = "raw"
+	tms.ttl = time.Minute
+	_, ttl, err = es.GetWithTTL("raw")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+// TTLMapStore implementation
+
+func (tms *TTLMapStore) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	val, err := tms.Get(key)
+	return val, tms.ttl, err
+}
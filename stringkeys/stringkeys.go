@@ -0,0 +1,157 @@
+// Package stringkeys is a string keyed facade over an expiring Store, for
+// callers such as HTTP and command line frontends whose keys are always
+// strings. Keys are checked before they reach the Store, so that keys a
+// remote backend would reject, or truncate, fail early with a clear error.
+//
+//	sk := stringkeys.New(es, stringkeys.WithPrintableKeys())
+//	if err := sk.Set(ctx, "user:42", user, nil); err != nil {
+//		// ...
+//	}
+package stringkeys
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// Store reads and writes an expiring Store by string key.
+	Store struct {
+		es        expiring.Store
+		maxLength int
+		printable bool
+		validate  func(key string) error
+	}
+
+	// Option configures a Store.
+	Option func(*Store)
+)
+
+// DefaultMaxKeyLength is memcached's limit, which is also a reasonable one
+// for other remote stores.
+const DefaultMaxKeyLength = 250
+
+var (
+	EmptyKeyError   = errors.New("key is empty")
+	KeyTooLongError = errors.New("key is too long")
+	InvalidKeyError = errors.New("key contains whitespace or control characters")
+)
+
+// New creates a Store around es. Keys may be up to DefaultMaxKeyLength bytes
+// long.
+func New(es expiring.Store, opts ...Option) Store {
+	s := Store{es: es, maxLength: DefaultMaxKeyLength}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// WithMaxKeyLength limits keys to n bytes, or lifts the limit if n is 0.
+func WithMaxKeyLength(n int) Option {
+	return func(s *Store) {
+		s.maxLength = n
+	}
+}
+
+// WithPrintableKeys rejects keys containing spaces or control characters,
+// which text protocols such as memcached's can't carry.
+func WithPrintableKeys() Option {
+	return func(s *Store) {
+		s.printable = true
+	}
+}
+
+// WithKeyValidator also checks keys with validate, e.g. to require a
+// namespace prefix. Its errors are returned as they are.
+func WithKeyValidator(validate func(key string) error) Option {
+	return func(s *Store) {
+		s.validate = validate
+	}
+}
+
+// Validate returns the error reading or writing key would fail with, if
+// key isn't allowed.
+func (s Store) Validate(key string) error {
+	if key == "" {
+		return EmptyKeyError
+	}
+	if s.maxLength > 0 && len(key) > s.maxLength {
+		return KeyTooLongError
+	}
+	if s.printable {
+		for i := 0; i < len(key); i++ {
+			if key[i] <= ' ' || key[i] == 0x7f {
+				return InvalidKeyError
+			}
+		}
+	}
+	if s.validate != nil {
+		return s.validate(key)
+	}
+	return nil
+}
+
+// Get is like the Store's GetWithContext.
+func (s Store) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := s.Validate(key); err != nil {
+		return nil, err
+	}
+	return s.es.GetWithContext(ctx, key)
+}
+
+// GetWithTTL is like the Store's GetWithTTL. If ctx is already done,
+// ctx.Err() is returned without calling the Store.
+func (s Store) GetWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	if err := s.Validate(key); err != nil {
+		return nil, 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return s.es.GetWithTTL(key)
+}
+
+// Set is like the Store's SetWithContext.
+func (s Store) Set(ctx context.Context, key string, value interface{}, options *store.Options) error {
+	if err := s.Validate(key); err != nil {
+		return err
+	}
+	return s.es.SetWithContext(ctx, key, value, options)
+}
+
+// Delete is like the Store's Delete. If ctx is already done, ctx.Err() is
+// returned without calling the Store.
+func (s Store) Delete(ctx context.Context, key string) error {
+	if err := s.Validate(key); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.es.Delete(key)
+}
+
+// Keys returns the Store's string keys; keys of other types are skipped.
+func (s Store) Keys() ([]string, error) {
+	keys, err := s.es.Keys()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if str, ok := key.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs, nil
+}
+
+// Unwrap returns the Store the facade reads and writes.
+func (s Store) Unwrap() expiring.Store {
+	return s.es
+}
@@ -0,0 +1,74 @@
+package stringkeys_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/nabowler/expiring_gocache/stringkeys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s := expiringtest.New()
+	sk := stringkeys.New(expiring.New(s, &store.Options{Expiration: time.Hour}))
+	ctx := context.Background()
+
+	assert.Nil(t, sk.Set(ctx, "key", "value", &store.Options{Expiration: time.Minute}))
+	val, err := sk.Get(ctx, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	_, ttl, err := sk.GetWithTTL(ctx, "key")
+	assert.Nil(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	keys, err := sk.Keys()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	assert.Nil(t, sk.Delete(ctx, "key"))
+	_, err = sk.Get(ctx, "key")
+	assert.Equal(t, expiringtest.NotFoundError, err)
+}
+
+func TestValidate(t *testing.T) {
+	s := expiringtest.New()
+	reserved := errors.New("reserved prefix")
+	sk := stringkeys.New(expiring.New(s, nil),
+		stringkeys.WithPrintableKeys(),
+		stringkeys.WithKeyValidator(func(key string) error {
+			if strings.HasPrefix(key, "_") {
+				return reserved
+			}
+			return nil
+		}),
+	)
+	ctx := context.Background()
+
+	assert.Equal(t, stringkeys.EmptyKeyError, sk.Set(ctx, "", "value", nil))
+	assert.Equal(t, stringkeys.KeyTooLongError, sk.Set(ctx, strings.Repeat("k", stringkeys.DefaultMaxKeyLength+1), "value", nil))
+	assert.Equal(t, stringkeys.InvalidKeyError, sk.Set(ctx, "two words", "value", nil))
+	_, err := sk.Get(ctx, "_internal")
+	assert.Equal(t, reserved, err)
+	assert.Equal(t, 0, s.Calls(expiring.OperationSet)+s.Calls(expiring.OperationGet))
+
+	long := stringkeys.New(expiring.New(s, nil), stringkeys.WithMaxKeyLength(0))
+	assert.Nil(t, long.Set(ctx, strings.Repeat("k", 1000), "value", nil))
+}
+
+func TestContextDone(t *testing.T) {
+	s := expiringtest.New()
+	sk := stringkeys.New(expiring.New(s, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := sk.Get(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, sk.Delete(ctx, "key"))
+	assert.Equal(t, 0, s.Calls(expiring.OperationGet)+s.Calls(expiring.OperationDelete))
+}
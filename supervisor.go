@@ -0,0 +1,146 @@
+package expiring_gocache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// WorkerHealth reports on one of a Store's background workers: the
+	// reaper, the delete retrier, the evictor, a write-behind worker or a
+	// scheduled refresh.
+	WorkerHealth struct {
+		// Name names the worker, e.g. "reaper", or "refresh user:42".
+		Name string
+		// Running is false while the worker waits to be restarted.
+		Running bool
+		// Restarts counts the times the worker failed and was restarted,
+		// and LastFailure and LastError describe the latest failure.
+		Restarts    uint64
+		LastFailure time.Time
+		LastError   error
+	}
+
+	// Health reports on a Store's background workers, see Store.Health.
+	Health struct {
+		// Healthy is true unless a worker is waiting to be restarted.
+		Healthy bool
+		// Workers are the workers which run, sorted by name.
+		Workers []WorkerHealth
+	}
+
+	// workerSupervisor tracks the health of the workers it runs; it is
+	// shared by the copies of a Store.
+	workerSupervisor struct {
+		mu      sync.Mutex
+		workers map[string]*WorkerHealth
+	}
+)
+
+const (
+	// DefaultWorkerRestartBackoff is how long a failed worker waits before
+	// it is first restarted. The wait doubles with each failure, up to
+	// MaxWorkerRestartBackoff, and starts over once a worker has run for
+	// that long without failing.
+	DefaultWorkerRestartBackoff = 100 * time.Millisecond
+	MaxWorkerRestartBackoff     = 30 * time.Second
+)
+
+// Health reports on the Store's background workers. A worker fails if it
+// panics, e.g. in a hook it calls; it is restarted with backoff, and the
+// restart is counted in Stats, pushed to the MetricsSink and passed to the
+// hook given to WithWorkerRestartHook.
+func (es Store) Health() Health {
+	s := es.workers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := Health{Healthy: true, Workers: make([]WorkerHealth, 0, len(s.workers))}
+	for _, w := range s.workers {
+		h.Workers = append(h.Workers, *w)
+		h.Healthy = h.Healthy && w.Running
+	}
+	sort.Slice(h.Workers, func(i, j int) bool { return h.Workers[i].Name < h.Workers[j].Name })
+	return h
+}
+
+// supervise runs the named worker until it returns, restarting it with
+// backoff whenever it panics, unless done is closed first.
+func (es Store) supervise(name string, done <-chan struct{}, run func()) {
+	w := es.workers.add(name)
+	defer es.workers.remove(w)
+
+	backoff := es.workerBackoff
+	for {
+		start := time.Now()
+		err := es.runWorker(name, run)
+		if err == nil {
+			return
+		}
+		if time.Since(start) >= MaxWorkerRestartBackoff {
+			backoff = es.workerBackoff
+		}
+		failure := es.workers.failed(w, err, es.now())
+		atomic.AddUint64(&es.stats.workerRestarts, 1)
+		es.count(MetricWorkerRestarts, 1)
+		if es.onWorkerRestart != nil {
+			_ = es.guard("worker restart hook", func() error {
+				es.onWorkerRestart(failure)
+				return nil
+			})
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > MaxWorkerRestartBackoff {
+			backoff = MaxWorkerRestartBackoff
+		}
+		es.workers.restarted(w)
+	}
+}
+
+// runWorker runs a worker once, returning a PanicError if it panics.
+func (es Store) runWorker(name string, run func()) (err error) {
+	defer es.recoverPanic(name, &err)
+	run()
+	return nil
+}
+
+func (s *workerSupervisor) add(name string) *WorkerHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := &WorkerHealth{Name: name, Running: true}
+	s.workers[name] = w
+	return w
+}
+
+// remove forgets w, unless another worker of the same name has replaced it.
+func (s *workerSupervisor) remove(w *WorkerHealth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers[w.Name] == w {
+		delete(s.workers, w.Name)
+	}
+}
+
+// failed records a failure of w, returning a copy of its health.
+func (s *workerSupervisor) failed(w *WorkerHealth, err error, now time.Time) WorkerHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Running = false
+	w.Restarts++
+	w.LastFailure, w.LastError = now, err
+	return *w
+}
+
+func (s *workerSupervisor) restarted(w *WorkerHealth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Running = true
+}
@@ -0,0 +1,84 @@
+package expiring_gocache_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// PanickingBatchStore panics in its first `panics` DeleteMultis.
+	PanickingBatchStore struct {
+		*MapStore
+		panics int32
+	}
+)
+
+func TestSupervisorRestartsReaper(t *testing.T) {
+	ps := PanickingBatchStore{MapStore: &MapStore{cache: map[interface{}]interface{}{}}, panics: 1}
+	var (
+		mu       sync.Mutex
+		restarts []expiring.WorkerHealth
+	)
+	es := expiring.New(&ps, &store.Options{Expiration: reaperExpiration},
+		expiring.WithReaper(reaperInterval),
+		expiring.WithBucketWidth(reaperBucketWidth),
+		expiring.WithWorkerRestartBackoff(time.Millisecond),
+		expiring.WithWorkerRestartHook(func(w expiring.WorkerHealth) {
+			mu.Lock()
+			defer mu.Unlock()
+			restarts = append(restarts, w)
+		}),
+	)
+	defer es.Close()
+
+	assert.Nil(t, es.Set("first", "value", nil))
+	deadline := time.Now().Add(time.Second)
+	for es.Stats().WorkerRestarts == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(1), es.Stats().WorkerRestarts)
+
+	// the restarted reaper goes on reaping
+	assert.Nil(t, es.Set("second", "value", nil))
+	time.Sleep(reaperSleep)
+	_, err := ps.MapStore.Get("second")
+	assert.Equal(t, MapStoreMiss, err)
+
+	health := es.Health()
+	assert.True(t, health.Healthy)
+	if assert.Len(t, health.Workers, 1) {
+		reaper := health.Workers[0]
+		assert.Equal(t, "reaper", reaper.Name)
+		assert.True(t, reaper.Running)
+		assert.Equal(t, uint64(1), reaper.Restarts)
+		var pe expiring.PanicError
+		assert.True(t, errors.As(reaper.LastError, &pe))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, restarts, 1) {
+		assert.False(t, restarts[0].Running)
+	}
+
+	assert.Nil(t, es.Close())
+	assert.Empty(t, es.Health().Workers)
+}
+
+func (ps *PanickingBatchStore) DeleteMulti(keys []interface{}) error {
+	if atomic.AddInt32(&ps.panics, -1) >= 0 {
+		panic("bug")
+	}
+	for _, key := range keys {
+		if err := ps.MapStore.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package expiring_gocache
+
+import (
+	"time"
+)
+
+// tagExpiryPrefix prefixes the keys of the records ExpireTag writes to the
+// underlying store.
+const tagExpiryPrefix = "expiring:tag-expiry:"
+
+// tagExpiry is when the values carrying a tag expire: values written by
+// deadline expire at deadline, and values written by an earlier deadline
+// which has passed have already expired.
+type tagExpiry struct {
+	deadline time.Time
+	previous time.Time
+}
+
+// ExpireTag expires every value written with tag in its options, and not
+// written again since, ttl from now, or at once if ttl isn't positive. Values
+// written after that aren't affected. Calling it again for the same tag moves
+// the deadline, but values already expired by an earlier deadline stay
+// expired.
+//
+// The deadline is written to the underlying store, under a key made from the
+// tag, so that every Store sharing it sees it. Values are checked against
+// it whenever they are read, including by Peek, GetStale and Has, which
+// costs a read of the underlying store per tag. It needs WithTagExpiry, and returns
+// UnsupportedError otherwise.
+func (es Store) ExpireTag(tag string, ttl time.Duration) error {
+	if !es.tagExpiry {
+		return UnsupportedError
+	}
+	now := es.now()
+	if ttl < 0 {
+		ttl = 0
+	}
+	expiry := tagExpiry{deadline: now.Add(ttl)}
+	if current, ok := es.loadTagExpiry(tag); ok {
+		expiry.previous = current.previous
+		if !current.deadline.After(now) {
+			expiry.previous = current.deadline
+		}
+	}
+
+	record, err := es.wrap(wrappedValue{expireAt: expiry.deadline, timestamp: expiry.previous, value: "", instance: es.instanceID, createdAt: now})
+	if err != nil {
+		return err
+	}
+	return es.innerSet(tagExpiryPrefix+tag, record, nil)
+}
+
+// loadTagExpiry reads the expiry of tag from the underlying store.
+func (es Store) loadTagExpiry(tag string) (tagExpiry, bool) {
+	val, err := es.innerGet(tagExpiryPrefix + tag)
+	if err != nil || val == nil {
+		return tagExpiry{}, false
+	}
+	ew, _, ok := unwrapHeader(val)
+	if !ok || ew.instance != es.instanceID {
+		return tagExpiry{}, false
+	}
+	return tagExpiry{deadline: ew.expireAt, previous: ew.timestamp}, true
+}
+
+// withTagExpiry brings ew's expiration forward to the earliest expiry of
+// its tags which applies to it.
+func (es Store) withTagExpiry(ew wrappedValue) wrappedValue {
+	for _, tag := range ew.tags {
+		expiry, ok := es.loadTagExpiry(tag)
+		if !ok {
+			continue
+		}
+		var expireAt time.Time
+		switch {
+		case !ew.createdAt.After(expiry.previous) && !expiry.previous.IsZero():
+			expireAt = expiry.previous
+		case !ew.createdAt.After(expiry.deadline):
+			expireAt = expiry.deadline
+		default:
+			continue
+		}
+		if expireAt.Before(ew.expireAt) {
+			ew.expireAt = expireAt
+		}
+	}
+	return ew
+}
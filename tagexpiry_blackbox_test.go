@@ -0,0 +1,94 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireTag(t *testing.T) {
+	for name, opts := range map[string][]expiring.Option{
+		"wrapped":  nil,
+		"envelope": {expiring.WithBinaryEnvelope()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clk := clock.NewFake(time.Now())
+			ms := MapStore{cache: map[interface{}]interface{}{}}
+			es := expiring.New(&ms, &store.Options{Expiration: 24 * time.Hour},
+				append(opts, expiring.WithTagExpiry(), expiring.WithClock(clk))...)
+
+			assert.Nil(t, es.Set("profile", "value", &store.Options{Tags: []string{"user:1"}}))
+			assert.Nil(t, es.Set("settings", "value", &store.Options{Tags: []string{"other", "user:1"}}))
+			assert.Nil(t, es.Set("untagged", "value", nil))
+			assert.Nil(t, es.ExpireTag("user:1", time.Hour))
+
+			// the TTL is capped by the tag's deadline
+			_, ttl, err := es.GetWithTTL("profile")
+			assert.Nil(t, err)
+			assert.Equal(t, time.Hour, ttl)
+
+			clk.Advance(2 * time.Hour)
+			_, err = es.Get("profile")
+			assert.Equal(t, expiring.ValueExpiredError, err)
+			_, _, err = es.GetWithTTL("settings")
+			assert.Equal(t, expiring.ValueExpiredError, err)
+			val, err := es.Get("untagged")
+			assert.Nil(t, err)
+			assert.Equal(t, "value", val)
+
+			// values written after the deadline aren't affected
+			assert.Nil(t, es.Set("profile", "new value", &store.Options{Tags: []string{"user:1"}}))
+			val, err = es.Get("profile")
+			assert.Nil(t, err)
+			assert.Equal(t, "new value", val)
+		})
+	}
+}
+
+func TestExpireTagAgainKeepsEarlierExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: 24 * time.Hour}, expiring.WithTagExpiry(), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("old", "value", &store.Options{Tags: []string{"tag"}}))
+	assert.Nil(t, es.ExpireTag("tag", 0))
+	clk.Advance(time.Minute)
+	assert.Nil(t, es.Set("new", "value", &store.Options{Tags: []string{"tag"}}))
+	assert.Nil(t, es.ExpireTag("tag", time.Hour))
+
+	_, err := es.Get("old")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	val, err := es.Get("new")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestExpireTagSideEffectFreeReads(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithTagExpiry(), expiring.WithAccessTracking(), expiring.WithClock(clk))
+	assert.Nil(t, es.Set("profile", "value", &store.Options{Tags: []string{"user:1"}}))
+	assert.Nil(t, es.ExpireTag("user:1", time.Minute))
+	clk.Advance(2 * time.Minute)
+
+	_, err := es.Peek("profile")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	_, md, err := es.GetStale("profile")
+	assert.Nil(t, err)
+	assert.True(t, md.Expired)
+	has, err := es.Has("profile")
+	assert.Nil(t, err)
+	assert.False(t, has)
+}
+
+func TestExpireTagUnsupported(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil)
+
+	assert.Equal(t, expiring.UnsupportedError, es.ExpireTag("tag", time.Hour))
+	assert.Empty(t, ms.cache)
+}
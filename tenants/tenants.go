@@ -0,0 +1,396 @@
+// Package tenants partitions an expiring Store between tenants, such as the
+// customers of a multi-tenant service. The tenant of each call is read from
+// its context, and its keys and tags are prefixed with the tenant's ID, so
+// that tenants can't read, or invalidate, each other's values. Each tenant's
+// usage is counted, and can be capped by a quota.
+//
+//	t := tenants.New(nil, tenants.WithQuota(10000))
+//	es := expiring.New(redisStore, nil, expiring.WithEventHook(t.Hook))
+//	ts := t.Bind(es)
+//	err := ts.Set(tenants.WithTenant(ctx, "acme"), "user:42", user, nil)
+package tenants
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+)
+
+type (
+	// TenantFromContext returns the tenant a call is made for, or false if
+	// the call isn't made for one.
+	TenantFromContext func(ctx context.Context) (string, bool)
+
+	// Tenants keeps the usage of the tenants sharing a Store, and their
+	// quotas.
+	Tenants struct {
+		fromContext TenantFromContext
+		prefix      string
+		quota       int
+		quotas      map[string]int
+
+		mu      sync.Mutex
+		tenants map[string]*usage
+	}
+
+	// Option configures Tenants.
+	Option func(*Tenants)
+
+	// Stats is a snapshot of one tenant's usage.
+	Stats struct {
+		// Entries is how many values the tenant holds, including expired
+		// values not yet deleted. Values removed by Invalidate are counted
+		// until they are set again or deleted.
+		Entries int
+		Hits    uint64
+		Misses  uint64
+		Sets    uint64
+		Deletes uint64
+		// QuotaRejections counts Sets refused because the tenant was at its
+		// quota.
+		QuotaRejections uint64
+	}
+
+	usage struct {
+		keys  map[string]struct{}
+		stats Stats
+	}
+
+	// Store reads and writes an expiring Store on behalf of the tenant of
+	// each call's context.
+	Store struct {
+		es expiring.Store
+		t  *Tenants
+	}
+
+	tenantContextKey struct{}
+)
+
+// DefaultPrefix prefixes the keys and tags of every tenant, ahead of the
+// tenant's ID.
+const DefaultPrefix = "tenant:"
+
+// tenantSeparator ends a tenant's ID in its keys, so tenant IDs can't
+// contain it.
+const tenantSeparator = ":"
+
+// dependsOnPrefix starts the tags made by expiring.DependsOn, ahead of the
+// key depended on.
+var dependsOnPrefix = expiring.DependsOn("")[0]
+
+var (
+	NoTenantError      = errors.New("context doesn't carry a tenant")
+	InvalidTenantError = errors.New("tenant is empty or contains a colon")
+	QuotaExceededError = errors.New("tenant is at its quota")
+)
+
+// WithTenant returns a copy of ctx carrying tenant, for FromContext.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// FromContext returns the tenant set with WithTenant, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok
+}
+
+// New creates Tenants which read each call's tenant with fromContext, or
+// FromContext if it is nil. Tenants have no quota.
+func New(fromContext TenantFromContext, opts ...Option) *Tenants {
+	if fromContext == nil {
+		fromContext = FromContext
+	}
+	t := &Tenants{
+		fromContext: fromContext,
+		prefix:      DefaultPrefix,
+		quotas:      map[string]int{},
+		tenants:     map[string]*usage{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// WithPrefix prefixes the keys and tags of every tenant with prefix rather
+// than DefaultPrefix.
+func WithPrefix(prefix string) Option {
+	return func(t *Tenants) {
+		t.prefix = prefix
+	}
+}
+
+// WithQuota limits each tenant to n values, or lifts the limit if n is 0.
+func WithQuota(n int) Option {
+	return func(t *Tenants) {
+		t.quota = n
+	}
+}
+
+// WithTenantQuota limits tenant to n values, whatever WithQuota gives the
+// others. An n of 0 lifts the limit.
+func WithTenantQuota(tenant string, n int) Option {
+	return func(t *Tenants) {
+		t.quotas[tenant] = n
+	}
+}
+
+// Bind returns a Store which reads and writes es for the tenants.
+func (t *Tenants) Bind(es expiring.Store) Store {
+	return Store{es: es, t: t}
+}
+
+// Hook keeps the tenants' usage up to date with values which expire, are
+// evicted or are cleared from the Store. Pass it to expiring.WithEventHook;
+// without it, such values go on counting against their tenant's quota.
+func (t *Tenants) Hook(e expiring.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e.Type == expiring.EventClear {
+		for _, u := range t.tenants {
+			u.keys = map[string]struct{}{}
+		}
+		return
+	}
+	tenant, key, ok := t.split(e.Key)
+	if !ok {
+		return
+	}
+	u := t.usageLocked(tenant)
+	switch e.Type {
+	case expiring.EventSet:
+		u.keys[key] = struct{}{}
+	case expiring.EventDelete, expiring.EventExpire, expiring.EventEvict:
+		delete(u.keys, key)
+	}
+}
+
+// Stats returns a snapshot of tenant's usage.
+func (t *Tenants) Stats(tenant string) Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.tenants[tenant]
+	if !ok {
+		return Stats{}
+	}
+	stats := u.stats
+	stats.Entries = len(u.keys)
+	return stats
+}
+
+// Tenants returns the tenants which have used the Store, sorted.
+func (t *Tenants) Tenants() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tenants := make([]string, 0, len(t.tenants))
+	for tenant := range t.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// tenant returns the tenant of ctx.
+func (t *Tenants) tenant(ctx context.Context) (string, error) {
+	tenant, ok := t.fromContext(ctx)
+	if !ok {
+		return "", NoTenantError
+	}
+	if tenant == "" || strings.Contains(tenant, tenantSeparator) {
+		return "", InvalidTenantError
+	}
+	return tenant, nil
+}
+
+// namespace returns the prefix of tenant's keys and tags.
+func (t *Tenants) namespace(tenant string) string {
+	return t.prefix + tenant + tenantSeparator
+}
+
+// split reverses namespace, returning the tenant and key of a key written
+// for one.
+func (t *Tenants) split(key interface{}) (string, string, bool) {
+	s, ok := key.(string)
+	if !ok || !strings.HasPrefix(s, t.prefix) {
+		return "", "", false
+	}
+	s = strings.TrimPrefix(s, t.prefix)
+	i := strings.Index(s, tenantSeparator)
+	if i <= 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+func (t *Tenants) usageLocked(tenant string) *usage {
+	u, ok := t.tenants[tenant]
+	if !ok {
+		u = &usage{keys: map[string]struct{}{}}
+		t.tenants[tenant] = u
+	}
+	return u
+}
+
+func (t *Tenants) quotaFor(tenant string) int {
+	if n, ok := t.quotas[tenant]; ok {
+		return n
+	}
+	return t.quota
+}
+
+// admit reserves a place in tenant's quota for key, counting the Set. It
+// reports whether key is new to the tenant.
+func (t *Tenants) admit(tenant, key string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u := t.usageLocked(tenant)
+	_, held := u.keys[key]
+	if !held {
+		if quota := t.quotaFor(tenant); quota > 0 && len(u.keys) >= quota {
+			u.stats.QuotaRejections++
+			return false, QuotaExceededError
+		}
+		u.keys[key] = struct{}{}
+	}
+	u.stats.Sets++
+	return !held, nil
+}
+
+// update applies fn to tenant's usage.
+func (t *Tenants) update(tenant string, fn func(u *usage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t.usageLocked(tenant))
+}
+
+// Get is like the Store's GetWithContext, for the tenant of ctx.
+func (s Store) Get(ctx context.Context, key string) (interface{}, error) {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := s.es.GetWithContext(ctx, s.t.namespace(tenant)+key)
+	s.countRead(tenant, err)
+	return val, err
+}
+
+// GetWithTTL is like the Store's GetWithTTL, for the tenant of ctx.
+func (s Store) GetWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	val, ttl, err := s.es.GetWithTTL(s.t.namespace(tenant) + key)
+	s.countRead(tenant, err)
+	return val, ttl, err
+}
+
+func (s Store) countRead(tenant string, err error) {
+	s.t.update(tenant, func(u *usage) {
+		if err == nil {
+			u.stats.Hits++
+		} else {
+			u.stats.Misses++
+		}
+	})
+}
+
+// Set is like the Store's SetWithContext, for the tenant of ctx. The
+// options' tags are prefixed as the key is, apart from those written by the
+// expiring package, such as expiring.DependsOn's. QuotaExceededError is
+// returned if key is new and the tenant is at its quota.
+func (s Store) Set(ctx context.Context, key string, value interface{}, options *store.Options) error {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	added, err := s.t.admit(tenant, key)
+	if err != nil {
+		return err
+	}
+	if options != nil && len(options.Tags) > 0 {
+		copied := *options
+		copied.Tags = s.tags(tenant, options.Tags)
+		options = &copied
+	}
+	err = s.es.SetWithContext(ctx, s.t.namespace(tenant)+key, value, options)
+	if err != nil && added {
+		s.t.update(tenant, func(u *usage) { delete(u.keys, key) })
+	}
+	return err
+}
+
+// tags prefixes tenant's tags, and the keys named by the expiring package's
+// depends-on directives, leaving its other directives be.
+func (s Store) tags(tenant string, tags []string) []string {
+	prefixed := make([]string, len(tags))
+	for i, tag := range tags {
+		switch {
+		case strings.HasPrefix(tag, dependsOnPrefix):
+			prefixed[i] = expiring.DependsOn(s.t.namespace(tenant) + strings.TrimPrefix(tag, dependsOnPrefix))[0]
+		case strings.HasPrefix(tag, "expiring:"):
+			prefixed[i] = tag
+		default:
+			prefixed[i] = s.t.namespace(tenant) + tag
+		}
+	}
+	return prefixed
+}
+
+// Delete is like the Store's Delete, for the tenant of ctx.
+func (s Store) Delete(ctx context.Context, key string) error {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.es.Delete(s.t.namespace(tenant) + key); err != nil {
+		return err
+	}
+	s.t.update(tenant, func(u *usage) {
+		delete(u.keys, key)
+		u.stats.Deletes++
+	})
+	return nil
+}
+
+// Clear deletes every value of the tenant of ctx, returning how many were
+// deleted. Keys are listed as by the Store's InvalidateByPrefix, so
+// expiring.UnsupportedError is returned if the Store can't list them.
+func (s Store) Clear(ctx context.Context) (int, error) {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, err := s.es.InvalidateByPrefix(s.t.namespace(tenant))
+	if err != nil {
+		return 0, err
+	}
+	s.t.update(tenant, func(u *usage) {
+		u.keys = map[string]struct{}{}
+		u.stats.Deletes += uint64(n)
+	})
+	return n, nil
+}
+
+// Invalidate is like the Store's Invalidate, for the tags of the tenant of
+// ctx.
+func (s Store) Invalidate(ctx context.Context, options store.InvalidateOptions) error {
+	tenant, err := s.t.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	options.Tags = s.tags(tenant, options.Tags)
+	return s.es.Invalidate(options)
+}
+
+// Unwrap returns the Store the tenants share.
+func (s Store) Unwrap() expiring.Store {
+	return s.es
+}
@@ -0,0 +1,137 @@
+package tenants_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/nabowler/expiring_gocache/expiringtest"
+	"github.com/nabowler/expiring_gocache/tenants"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStore(opts ...tenants.Option) (tenants.Store, *tenants.Tenants, *clock.Fake) {
+	clk := clock.NewFake(time.Now())
+	t := tenants.New(nil, opts...)
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour},
+		expiring.WithClock(clk), expiring.WithEventHook(t.Hook))
+	return t.Bind(es), t, clk
+}
+
+func TestPartitioning(t *testing.T) {
+	ts, tn, _ := newStore()
+	acme := tenants.WithTenant(context.Background(), "acme")
+	globex := tenants.WithTenant(context.Background(), "globex")
+
+	assert.Nil(t, ts.Set(acme, "key", "acme's", nil))
+	assert.Nil(t, ts.Set(globex, "key", "globex's", nil))
+
+	val, err := ts.Get(acme, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme's", val)
+	val, err = ts.Get(globex, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "globex's", val)
+
+	// written under the tenant's prefix
+	val, err = ts.Unwrap().Get("tenant:acme:key")
+	assert.Nil(t, err)
+	assert.Equal(t, "acme's", val)
+
+	assert.Nil(t, ts.Delete(acme, "key"))
+	_, err = ts.Get(acme, "key")
+	assert.NotNil(t, err)
+	_, err = ts.Get(globex, "key")
+	assert.Nil(t, err)
+
+	assert.Equal(t, tenants.Stats{Hits: 1, Misses: 1, Sets: 1, Deletes: 1}, tn.Stats("acme"))
+	assert.Equal(t, tenants.Stats{Entries: 1, Hits: 2, Sets: 1}, tn.Stats("globex"))
+	assert.Equal(t, []string{"acme", "globex"}, tn.Tenants())
+}
+
+func TestTenantRequired(t *testing.T) {
+	ts, _, _ := newStore()
+
+	_, err := ts.Get(context.Background(), "key")
+	assert.Equal(t, tenants.NoTenantError, err)
+	err = ts.Set(tenants.WithTenant(context.Background(), "a:b"), "key", "value", nil)
+	assert.Equal(t, tenants.InvalidTenantError, err)
+}
+
+func TestQuota(t *testing.T) {
+	ts, tn, clk := newStore(tenants.WithQuota(2), tenants.WithTenantQuota("big", 0))
+	ctx := tenants.WithTenant(context.Background(), "acme")
+
+	assert.Nil(t, ts.Set(ctx, "a", 1, nil))
+	assert.Nil(t, ts.Set(ctx, "b", 2, &store.Options{Expiration: time.Minute}))
+	assert.Equal(t, tenants.QuotaExceededError, ts.Set(ctx, "c", 3, nil))
+	// values already held can be rewritten
+	assert.Nil(t, ts.Set(ctx, "a", 4, nil))
+
+	// the expired value no longer counts once it is deleted
+	clk.Advance(2 * time.Minute)
+	_, err := ts.Get(ctx, "b")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Nil(t, ts.Set(ctx, "c", 3, nil))
+
+	stats := tn.Stats("acme")
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(1), stats.QuotaRejections)
+
+	big := tenants.WithTenant(context.Background(), "big")
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, ts.Set(big, key, 1, nil))
+	}
+}
+
+func TestClearAndInvalidate(t *testing.T) {
+	ts, tn, _ := newStore()
+	acme := tenants.WithTenant(context.Background(), "acme")
+	globex := tenants.WithTenant(context.Background(), "globex")
+
+	for _, ctx := range []context.Context{acme, globex} {
+		assert.Nil(t, ts.Set(ctx, "a", 1, &store.Options{Tags: []string{"tag"}}))
+		assert.Nil(t, ts.Set(ctx, "b", 2, nil))
+	}
+
+	assert.Nil(t, ts.Invalidate(acme, store.InvalidateOptions{Tags: []string{"tag"}}))
+	_, err := ts.Get(acme, "a")
+	assert.NotNil(t, err)
+	_, err = ts.Get(globex, "a")
+	assert.Nil(t, err)
+
+	n, err := ts.Clear(globex)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, tn.Stats("globex").Entries)
+	_, err = ts.Get(acme, "b")
+	assert.Nil(t, err)
+}
+
+func TestDependsOnStaysWithinTenant(t *testing.T) {
+	tn := tenants.New(nil)
+	es := expiring.New(expiringtest.New(), &store.Options{Expiration: time.Hour}, expiring.WithAccessTracking())
+	ts := tn.Bind(es)
+	acme := tenants.WithTenant(context.Background(), "acme")
+	globex := tenants.WithTenant(context.Background(), "globex")
+
+	for _, ctx := range []context.Context{acme, globex} {
+		assert.Nil(t, ts.Set(ctx, "user:1", "user", nil))
+		assert.Nil(t, ts.Set(ctx, "profile", "profile", &store.Options{Tags: expiring.DependsOn("user:1")}))
+	}
+	assert.Nil(t, es.Set("user:1", "global", nil))
+
+	// deleting a key of the same name outside the tenant leaves its dependents
+	assert.Nil(t, es.Delete("user:1"))
+	_, err := ts.Get(globex, "profile")
+	assert.Nil(t, err)
+
+	assert.Nil(t, ts.Delete(acme, "user:1"))
+	_, err = ts.Get(acme, "profile")
+	assert.NotNil(t, err)
+	_, err = ts.Get(globex, "profile")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,197 @@
+package expiring_gocache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/store"
+	"github.com/nabowler/expiring_gocache/clock"
+)
+
+type (
+	// TieredStore is a store.StoreInterface which reads a small, fast L1 tier
+	// before a larger L2 tier, and writes both. Wrap it with New to expire
+	// values across both tiers alike.
+	//
+	// Unlike gocache's ChainCache, the expiration of values wrapped by a
+	// Store is kept across tiers: values are written to each tier with the
+	// time the wrapper has left as their native expiration, and L2 hits are
+	// promoted into L1 with the time they have left, rather than a fresh TTL.
+	//
+	// An expired value read from L1 is repaired from L2 if L2 has a copy
+	// which hasn't expired, e.g. because another node refreshed it.
+	TieredStore struct {
+		l1, l2  store.StoreInterface
+		clock   clock.Clock
+		repairs *uint64
+	}
+
+	// TieredStoreOption configures a TieredStore.
+	TieredStoreOption func(*TieredStore)
+)
+
+const TieredStoreType = "tiered"
+
+// NewTiered reads l1 before l2, promoting l2 hits into l1.
+func NewTiered(l1, l2 store.StoreInterface, opts ...TieredStoreOption) *TieredStore {
+	ts := &TieredStore{l1: l1, l2: l2, clock: clock.Real{}, repairs: new(uint64)}
+	for _, opt := range opts {
+		opt(ts)
+	}
+	return ts
+}
+
+// TieredClock sets the clock the TieredStore reads the time from when
+// working out what is left of a value's expiration. It should be the same
+// clock as the Store wrapping it. Defaults to clock.Real.
+func TieredClock(c clock.Clock) TieredStoreOption {
+	return func(ts *TieredStore) {
+		ts.clock = c
+	}
+}
+
+// Get returns the value from l1, or else from l2. An l2 hit which hasn't
+// expired is written to l1 with its remaining TTL. If the l1 value has
+// expired, l2 is read too, and its value returned instead if it hasn't.
+func (ts *TieredStore) Get(key interface{}) (interface{}, error) {
+	val, _, err := ts.GetWithTTL(key)
+	return val, err
+}
+
+// GetWithTTL is like Get, also returning the value's remaining TTL, or 0 if
+// it isn't known.
+func (ts *TieredStore) GetWithTTL(key interface{}) (interface{}, time.Duration, error) {
+	l1Val, l1TTL, err := getWithTTL(ts.l1, key)
+	if err == nil {
+		if _, expired := ts.remaining(l1Val, l1TTL); !expired {
+			return l1Val, l1TTL, nil
+		}
+	}
+	val, native, l2Err := getWithTTL(ts.l2, key)
+	if l2Err != nil {
+		if err == nil {
+			// let the Store see the expired value
+			return l1Val, l1TTL, nil
+		}
+		return nil, 0, l2Err
+	}
+
+	ttl, expired := ts.remaining(val, native)
+	if expired {
+		if err == nil {
+			return l1Val, l1TTL, nil
+		}
+		return val, ttl, nil
+	}
+	var options *store.Options
+	if ttl > 0 {
+		options = &store.Options{Expiration: ttl}
+	}
+	_ = ts.l1.Set(key, val, options) // best effort promotion
+	if err == nil {
+		atomic.AddUint64(ts.repairs, 1)
+	}
+	return val, ttl, nil
+}
+
+// Repairs returns how many expired l1 values have been replaced by fresher
+// ones from l2.
+func (ts *TieredStore) Repairs() uint64 {
+	return atomic.LoadUint64(ts.repairs)
+}
+
+// Set writes the value to l2, then l1. Values wrapped by a Store are written
+// with the time they have left as their native expiration.
+func (ts *TieredStore) Set(key interface{}, value interface{}, options *store.Options) error {
+	if ew, _, ok := unwrapHeader(value); ok {
+		options = withNativeExpiration(options, ew.expireAt.Sub(ts.clock.Now()))
+	}
+	if err := ts.l2.Set(key, value, options); err != nil {
+		return err
+	}
+	return ts.l1.Set(key, value, options)
+}
+
+// Delete deletes the value from l2, then l1, so that a concurrent Get can't
+// promote the deleted value back into l1.
+func (ts *TieredStore) Delete(key interface{}) error {
+	if err := ts.l2.Delete(key); err != nil {
+		return err
+	}
+	return ts.l1.Delete(key)
+}
+
+// DeleteMulti deletes keys from l2, then l1, in one call for tiers which
+// implement DeleteMulti themselves.
+func (ts *TieredStore) DeleteMulti(keys []interface{}) error {
+	for _, s := range []store.StoreInterface{ts.l2, ts.l1} {
+		if bd, ok := s.(batchDeleter); ok {
+			if err := bd.DeleteMulti(keys); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, key := range keys {
+			if err := s.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ts *TieredStore) Invalidate(options store.InvalidateOptions) error {
+	if err := ts.l2.Invalidate(options); err != nil {
+		return err
+	}
+	return ts.l1.Invalidate(options)
+}
+
+// Clear clears both tiers, if they support it.
+func (ts *TieredStore) Clear() error {
+	for _, s := range []store.StoreInterface{ts.l2, ts.l1} {
+		if c, ok := s.(clearer); ok {
+			if err := c.Clear(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ts *TieredStore) GetType() string {
+	return TieredStoreType
+}
+
+// remaining returns how long val has left, given the native TTL reported by
+// the tier it was read from, and whether it has expired. Values not wrapped
+// by a Store have the native TTL, and never expire here.
+func (ts *TieredStore) remaining(val interface{}, native time.Duration) (time.Duration, bool) {
+	return remainingTTL(val, native, ts.clock.Now())
+}
+
+// remainingTTL is TieredStore.remaining, as of now.
+func remainingTTL(val interface{}, native time.Duration, now time.Time) (time.Duration, bool) {
+	ew, _, ok := unwrapHeader(val)
+	if !ok {
+		return native, false
+	}
+	ttl := ew.expireAt.Sub(now)
+	if ttl <= 0 {
+		return 0, true
+	}
+	if native > 0 && native < ttl {
+		ttl = native
+	}
+	return ttl, false
+}
+
+// getWithTTL reads key from s, along with its native TTL if s can report
+// one.
+func getWithTTL(s store.StoreInterface, key interface{}) (interface{}, time.Duration, error) {
+	if tg, ok := s.(ttlGetter); ok {
+		return tg.GetWithTTL(key)
+	}
+	val, err := s.Get(key)
+	return val, 0, err
+}
@@ -0,0 +1,101 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredPromotesWithRemainingTTL(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	l1 := MapStore{cache: map[interface{}]interface{}{}}
+	l2 := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(expiring.NewTiered(&l1, &l2, expiring.TieredClock(clk)), &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, time.Hour, l1.lastSetOptions.ExpirationValue())
+	assert.Equal(t, time.Hour, l2.lastSetOptions.ExpirationValue())
+
+	// L1 evicted the value
+	delete(l1.cache, "key")
+	clk.Advance(20 * time.Minute)
+
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 40*time.Minute, ttl)
+	assert.Equal(t, 2, l1.setCount)
+	assert.Equal(t, 40*time.Minute, l1.lastSetOptions.ExpirationValue())
+
+	// served from L1 from now on
+	val, err = es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, 1, l2.getCount)
+}
+
+func TestTieredExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	l1 := MapStore{cache: map[interface{}]interface{}{}}
+	l2 := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(expiring.NewTiered(&l1, &l2, expiring.TieredClock(clk)), &store.Options{Expiration: time.Minute}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	delete(l1.cache, "key")
+	clk.Advance(2 * time.Minute)
+
+	// expired values aren't promoted, and are deleted from both tiers
+	_, err := es.Get("key")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+	assert.Equal(t, 1, l1.setCount)
+	_, ok := l2.cache["key"]
+	assert.False(t, ok)
+}
+
+func TestTieredUnwrapped(t *testing.T) {
+	l1 := MapStore{cache: map[interface{}]interface{}{}}
+	l2 := MapStore{cache: map[interface{}]interface{}{"key": "value"}}
+	ts := expiring.NewTiered(&l1, &l2)
+
+	val, err := ts.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, "value", l1.cache["key"])
+	assert.Nil(t, l1.lastSetOptions)
+
+	assert.Nil(t, ts.Delete("key"))
+	_, err = ts.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+	assert.Equal(t, 1, l1.deleteCount)
+	assert.Equal(t, 1, l2.deleteCount)
+}
+
+func TestTieredReadRepair(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	l1 := MapStore{cache: map[interface{}]interface{}{}}
+	l2 := MapStore{cache: map[interface{}]interface{}{}}
+	ts := expiring.NewTiered(&l1, &l2, expiring.TieredClock(clk))
+	es := expiring.New(ts, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("key", "stale", nil))
+	clk.Advance(2 * time.Minute)
+	// another node refreshed L2
+	other := expiring.New(&l2, &store.Options{Expiration: time.Hour}, expiring.WithClock(clk))
+	assert.Nil(t, other.Set("key", "fresh", nil))
+
+	val, ttl, err := es.GetWithTTL("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "fresh", val)
+	assert.Equal(t, time.Hour, ttl)
+	assert.Equal(t, uint64(1), ts.Repairs())
+	assert.Equal(t, time.Hour, l1.lastSetOptions.ExpirationValue())
+
+	val, err = es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "fresh", val)
+	assert.Equal(t, 1, l2.getCount)
+}
@@ -0,0 +1,594 @@
+package expiring_gocache
+
+import (
+	"container/list"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// tracker keeps metadata about the keys written through the Store. Keys
+	// are grouped into buckets by the time they expire, and kept in least
+	// recently used order per priority, or the order of the EvictionPolicy
+	// given to WithEvictionPolicy. Keys written with DependsOn are
+	// indexed by the keys they depend on. The history of the latest
+	// retiredKeys keys to expire or be evicted is kept, for KeyStats; the
+	// history of deleted keys is forgotten. See trackedIndex for how the
+	// keys themselves are kept: in a hashed index, every key the tracker
+	// takes or returns is a ref, see trackedIndex.ref.
+	//
+	// mu guards the buckets, policies, history and dependents, and the
+	// fields of entries every change of which holds it: the access counts
+	// and read limits of entries are guarded by the lock of their index
+	// shard instead, so that reads of keys in different shards don't wait
+	// on each other. mu is taken before a shard's lock, never after.
+	tracker struct {
+		mu           sync.Mutex
+		width        time.Duration
+		hashed       bool
+		entries      *trackedIndex
+		buckets      map[int64]map[*trackedEntry]struct{}
+		newPolicy    func() EvictionPolicy
+		policies     map[Priority]EvictionPolicy
+		retired      map[interface{}]*list.Element
+		retiredOrder *list.List
+		dependents   map[interface{}]map[interface{}]struct{}
+	}
+
+	trackedEntry struct {
+		key         interface{}
+		expireAt    time.Time
+		bucket      int64
+		priority    Priority
+		cost        time.Duration
+		lastExpired time.Time
+		dependsOn   []interface{}
+		// guarded by the lock of the entry's shard while it is indexed
+		hits       uint64
+		lastAccess time.Time
+		sets       uint64
+		lastSet    time.Time
+		limited    bool
+		readsLeft  uint64
+	}
+)
+
+// retiredKeys is how many removed keys the tracker keeps the history of.
+const retiredKeys = 10000
+
+func newTracker(width time.Duration, newPolicy func() EvictionPolicy, hashed bool) *tracker {
+	if newPolicy == nil {
+		newPolicy = NewLRU
+	}
+	t := &tracker{width: width, newPolicy: newPolicy, hashed: hashed, entries: newTrackedIndex(hashed)}
+	t.reset()
+	return t
+}
+
+// reset forgets every key. The index is cleared in place, as it is read
+// without mu.
+func (t *tracker) reset() {
+	t.entries.clear()
+	t.buckets = map[int64]map[*trackedEntry]struct{}{}
+	t.policies = map[Priority]EvictionPolicy{}
+	for _, p := range priorities {
+		t.policies[p] = t.newPolicy()
+	}
+	t.retired = map[interface{}]*list.Element{}
+	t.retiredOrder = list.New()
+	t.dependents = map[interface{}]map[interface{}]struct{}{}
+}
+
+// ref returns what stands for key in the tracker.
+func (t *tracker) ref(key interface{}) interface{} {
+	return t.entries.ref(key)
+}
+
+// bucketFor returns the bucket whose window contains expireAt.
+func (t *tracker) bucketFor(expireAt time.Time) int64 {
+	return expireAt.UnixNano() / int64(t.width)
+}
+
+// bucketEnd returns the end of the bucket's window. Every key in the bucket
+// has expired by then.
+func (t *tracker) bucketEnd(bucket int64) time.Time {
+	return time.Unix(0, (bucket+1)*int64(t.width))
+}
+
+// track starts tracking key, or updates its metadata. In a hashed index, a
+// key whose hash collides with that of another tracked key is left
+// untracked, and counted in IndexStats.Collisions.
+func (t *tracker) track(key interface{}, expireAt time.Time, priority Priority, dependsOn []interface{}, cost time.Duration) {
+	if !trackable(key) {
+		return
+	}
+	ref := t.ref(key)
+	refs := make([]interface{}, len(dependsOn))
+	for i, dependency := range dependsOn {
+		refs[i] = t.ref(dependency)
+	}
+	entry := &trackedEntry{key: ref, expireAt: expireAt, bucket: t.bucketFor(expireAt), priority: priority, dependsOn: refs, cost: cost}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// a Set of a key already held at the same priority counts as an access
+	previous, held := t.entries.get(ref)
+	if held {
+		t.dropLocked(previous)
+		if previous.priority != priority {
+			t.policies[previous.priority].Removed(ref)
+		}
+	} else if element, ok := t.retired[ref]; ok {
+		previous = element.Value.(*trackedEntry)
+	}
+	if previous != nil {
+		// access history belongs to the key, not the value; previous is
+		// no longer indexed, so its counts can't change underneath
+		entry.hits = previous.hits
+		entry.lastAccess = previous.lastAccess
+		entry.sets = previous.sets
+		entry.lastSet = previous.lastSet
+		entry.lastExpired = previous.lastExpired
+	}
+	if !t.entries.put(entry) {
+		return
+	}
+	t.unretireLocked(ref)
+	t.addToBucketLocked(entry)
+	if held && previous.priority == priority {
+		t.policies[priority].Accessed(ref)
+	} else {
+		t.policies[priority].Added(ref)
+	}
+	for _, dependency := range refs {
+		dependents, ok := t.dependents[dependency]
+		if !ok {
+			dependents = map[interface{}]struct{}{}
+			t.dependents[dependency] = dependents
+		}
+		dependents[ref] = struct{}{}
+	}
+}
+
+// reschedule moves a tracked key to the bucket for its new expireAt, keeping
+// the rest of its metadata.
+func (t *tracker) reschedule(key interface{}, expireAt time.Time) {
+	if !trackable(key) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries.get(key)
+	if !ok {
+		return
+	}
+	t.removeFromBucketLocked(entry)
+	t.entries.with(key, func(entry *trackedEntry) {
+		entry.expireAt = expireAt
+		entry.bucket = t.bucketFor(expireAt)
+	})
+	t.addToBucketLocked(entry)
+}
+
+// recordSet counts a Set of a tracked key.
+func (t *tracker) recordSet(key interface{}, now time.Time) {
+	if !trackable(key) {
+		return
+	}
+	t.entries.with(key, func(entry *trackedEntry) {
+		entry.sets++
+		entry.lastSet = now
+	})
+}
+
+// expired removes a key whose value was found expired, recording when.
+func (t *tracker) expired(key interface{}, now time.Time) {
+	if !trackable(key) {
+		return
+	}
+	ref := t.ref(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries.get(ref)
+	if ok {
+		t.removeLocked(ref)
+	} else if element, ok := t.retired[ref]; ok {
+		entry = element.Value.(*trackedEntry)
+	} else {
+		entry = &trackedEntry{key: ref}
+	}
+	entry.lastExpired = now
+	t.retireLocked(entry)
+}
+
+// history returns the entry of a key, whether it is tracked or retired.
+func (t *tracker) history(key interface{}) (trackedEntry, bool) {
+	ref := t.ref(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var history trackedEntry
+	if t.entries.with(ref, func(entry *trackedEntry) { history = *entry }) {
+		return history, true
+	}
+	if element, ok := t.retired[ref]; ok {
+		return *element.Value.(*trackedEntry), true
+	}
+	return trackedEntry{}, false
+}
+
+// retireLocked keeps the history of a removed entry, dropping the oldest
+// history kept if there is too much.
+func (t *tracker) retireLocked(entry *trackedEntry) {
+	t.unretireLocked(entry.key)
+	t.retired[entry.key] = t.retiredOrder.PushFront(entry)
+	for t.retiredOrder.Len() > retiredKeys {
+		oldest := t.retiredOrder.Back()
+		t.retiredOrder.Remove(oldest)
+		delete(t.retired, oldest.Value.(*trackedEntry).key)
+	}
+}
+
+func (t *tracker) unretireLocked(ref interface{}) {
+	if element, ok := t.retired[ref]; ok {
+		t.retiredOrder.Remove(element)
+		delete(t.retired, ref)
+	}
+}
+
+func (t *tracker) addToBucketLocked(entry *trackedEntry) {
+	entries, ok := t.buckets[entry.bucket]
+	if !ok {
+		entries = map[*trackedEntry]struct{}{}
+		t.buckets[entry.bucket] = entries
+	}
+	entries[entry] = struct{}{}
+}
+
+func (t *tracker) removeFromBucketLocked(entry *trackedEntry) {
+	entries := t.buckets[entry.bucket]
+	delete(entries, entry)
+	if len(entries) == 0 {
+		delete(t.buckets, entry.bucket)
+	}
+}
+
+// touch records a hit on key, and marks it as the most recently used key of
+// its priority.
+func (t *tracker) touch(key interface{}, now time.Time) {
+	if !trackable(key) {
+		return
+	}
+	ref := t.ref(key)
+	var touched *trackedEntry
+	t.entries.with(ref, func(entry *trackedEntry) {
+		entry.hits++
+		entry.lastAccess = now
+		touched = entry
+	})
+	if touched == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry, ok := t.entries.get(ref); ok && entry == touched {
+		t.policies[entry.priority].Accessed(ref)
+	}
+}
+
+// limitReads allows n more reads of a tracked key.
+func (t *tracker) limitReads(key interface{}, n uint64) {
+	if !trackable(key) {
+		return
+	}
+	t.entries.with(key, func(entry *trackedEntry) {
+		entry.limited = true
+		entry.readsLeft = n
+	})
+}
+
+// consumeRead counts a read of a tracked key with a read limit, returning
+// how many reads it has left, which is negative if it had none left, and
+// whether its reads are counted.
+func (t *tracker) consumeRead(key interface{}) (int64, bool) {
+	if !trackable(key) {
+		return 0, false
+	}
+	left, counted := int64(0), false
+	t.entries.with(key, func(entry *trackedEntry) {
+		if !entry.limited {
+			return
+		}
+		counted = true
+		if entry.readsLeft == 0 {
+			left = -1
+			return
+		}
+		entry.readsLeft--
+		left = int64(entry.readsLeft)
+	})
+	return left, counted
+}
+
+// expireAt returns when the value of a tracked key expires, and whether the
+// key is tracked.
+func (t *tracker) expireAt(key interface{}) (time.Time, bool) {
+	if !trackable(key) {
+		return time.Time{}, false
+	}
+	var expireAt time.Time
+	ok := t.entries.with(key, func(entry *trackedEntry) { expireAt = entry.expireAt })
+	return expireAt, ok
+}
+
+func (t *tracker) untrack(key interface{}) {
+	if !trackable(key) {
+		return
+	}
+	ref := t.ref(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removeLocked(ref)
+	t.unretireLocked(ref)
+}
+
+func (t *tracker) removeLocked(ref interface{}) {
+	entry, ok := t.entries.get(ref)
+	if !ok {
+		return
+	}
+	t.dropLocked(entry)
+	t.policies[entry.priority].Removed(ref)
+}
+
+// dropLocked removes entry from the indexes, but not its eviction policy.
+func (t *tracker) dropLocked(entry *trackedEntry) {
+	t.entries.remove(entry)
+	t.removeFromBucketLocked(entry)
+	for _, dependency := range entry.dependsOn {
+		dependents := t.dependents[dependency]
+		delete(dependents, entry.key)
+		if len(dependents) == 0 {
+			delete(t.dependents, dependency)
+		}
+	}
+}
+
+// dependentsOf removes and returns the keys which depend on any of keys,
+// directly or through other dependents. Keys for which keep returns true are
+// left, along with their own dependents. Like deleted keys, their history is
+// forgotten.
+func (t *tracker) dependentsOf(keys []interface{}, keep func(key interface{}) bool) []interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.dependents) == 0 {
+		return nil
+	}
+
+	seen := make(map[interface{}]struct{}, len(keys))
+	queue := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if trackable(key) {
+			ref := t.ref(key)
+			seen[ref] = struct{}{}
+			queue = append(queue, ref)
+		}
+	}
+	var removed []interface{}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		dependents := t.dependents[key]
+		delete(t.dependents, key)
+		for dependent := range dependents {
+			if _, ok := seen[dependent]; ok {
+				// a cycle
+				continue
+			}
+			seen[dependent] = struct{}{}
+			if keep(dependent) {
+				continue
+			}
+			t.removeLocked(dependent)
+			t.unretireLocked(dependent)
+			removed = append(removed, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+	return removed
+}
+
+// due removes and returns the keys of every bucket whose window ended at or
+// before now, one slice per bucket, oldest bucket first. Within a bucket,
+// lower priority keys come first.
+func (t *tracker) due(now time.Time) [][]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var buckets []int64
+	for bucket := range t.buckets {
+		if !t.bucketEnd(bucket).After(now) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	due := make([][]interface{}, 0, len(buckets))
+	for _, bucket := range buckets {
+		entries := make([]*trackedEntry, 0, len(t.buckets[bucket]))
+		for entry := range t.buckets[bucket] {
+			entries = append(entries, entry)
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].priority < entries[j].priority })
+
+		batch := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			batch[i] = entry.key
+			t.removeLocked(entry.key)
+			entry.lastExpired = now
+			t.retireLocked(entry)
+		}
+		due = append(due, batch)
+	}
+	return due
+}
+
+// evict removes and returns keys until at most max keys are tracked, taking
+// the keys of the lowest priority first, in the order of its eviction
+// policy, cheapest first among the next few. Keys for which keep returns
+// true are never evicted.
+func (t *tracker) evict(max int, keep func(key interface{}) bool) []interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var evicted []interface{}
+	for _, p := range priorities {
+		over := t.entries.len() - max
+		if over <= 0 {
+			break
+		}
+		candidates := t.policies[p].Victims(over*costWindow, keep)
+		for _, key := range t.cheapest(candidates, over) {
+			entry, ok := t.entries.get(key)
+			if !ok {
+				continue
+			}
+			t.removeLocked(key)
+			t.retireLocked(entry)
+			evicted = append(evicted, key)
+		}
+	}
+	return evicted
+}
+
+// keys returns every tracked key, in no particular order.
+func (t *tracker) keys() []interface{} {
+	keys := make([]interface{}, 0, t.entries.len())
+	t.entries.each(func(entry *trackedEntry) {
+		keys = append(keys, entry.key)
+	})
+	return keys
+}
+
+func (t *tracker) len() int {
+	return t.entries.len()
+}
+
+func (t *tracker) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+}
+
+// indexStats describes the tracker's index, and estimates its memory along
+// with that of the retired keys' history.
+func (t *tracker) indexStats() IndexStats {
+	t.mu.Lock()
+	retired := len(t.retired)
+	t.mu.Unlock()
+	return IndexStats{
+		Entries:     t.entries.len(),
+		Retired:     retired,
+		Shards:      len(t.entries.shards),
+		Hashed:      t.hashed,
+		Bytes:       t.entries.bytes() + t.entries.retiredBytes(retired),
+		Compactions: atomic.LoadUint64(&t.entries.compactions),
+		Collisions:  atomic.LoadUint64(&t.entries.collisions),
+	}
+}
+
+// trackable reports whether key can be used as a map key.
+func trackable(key interface{}) bool {
+	return key != nil && reflect.TypeOf(key).Comparable()
+}
+
+func (es Store) track(key interface{}, expireAt time.Time, priority Priority, dependsOn []interface{}, cost time.Duration) {
+	if es.tracker != nil {
+		es.tracker.track(key, expireAt, priority, dependsOn, cost)
+	}
+}
+
+func (es Store) untrack(key interface{}) {
+	if es.tracker != nil {
+		es.tracker.untrack(key)
+	}
+}
+
+// keysOf gives back the keys the tracker's refs stand for, batch by batch.
+// The refs of a hashed index are resolved with a single listing of the
+// underlying store; refs of keys it no longer holds are dropped, as there is
+// nothing left of them to delete. Should the listing fail, every ref is
+// dropped and counted as a failed delete.
+func (es Store) keysOf(batches ...[]interface{}) [][]interface{} {
+	if !es.hashedIndex {
+		return batches
+	}
+	var refs []interface{}
+	for _, batch := range batches {
+		refs = append(refs, batch...)
+	}
+	if len(refs) == 0 {
+		return batches
+	}
+	keys, err := es.refKeys(refs)
+	if err != nil {
+		atomic.AddUint64(&es.stats.deleteFailures, uint64(len(refs)))
+	}
+	resolved := make([][]interface{}, len(batches))
+	for i, batch := range batches {
+		resolved[i] = make([]interface{}, 0, len(batch))
+		for _, ref := range batch {
+			if key, ok := keys[ref]; ok {
+				resolved[i] = append(resolved[i], key)
+			}
+		}
+	}
+	return resolved
+}
+
+// refKeys maps each of refs to the key it stands for, listing the
+// underlying store for the keys of a hashed index.
+func (es Store) refKeys(refs []interface{}) (map[interface{}]interface{}, error) {
+	keys := make(map[interface{}]interface{}, len(refs))
+	for _, ref := range refs {
+		keys[ref] = nil
+	}
+	listed, err := es.listKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range listed {
+		if !trackable(key) {
+			continue
+		}
+		if _, ok := keys[es.tracker.ref(key)]; ok {
+			keys[es.tracker.ref(key)] = key
+		}
+	}
+	for ref, key := range keys {
+		if key == nil {
+			delete(keys, ref)
+		}
+	}
+	return keys, nil
+}
+
+// pinnedRef returns a func reporting whether a ref taken from the tracker
+// stands for a pinned key.
+func (es Store) pinnedRef() func(ref interface{}) bool {
+	if !es.hashedIndex {
+		return es.pins.has
+	}
+	es.pins.mu.RLock()
+	refs := make(map[interface{}]struct{}, len(es.pins.keys))
+	for key := range es.pins.keys {
+		refs[es.tracker.ref(key)] = struct{}{}
+	}
+	es.pins.mu.RUnlock()
+	return func(ref interface{}) bool {
+		_, ok := refs[ref]
+		return ok
+	}
+}
@@ -0,0 +1,120 @@
+package expiring_gocache
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// TTLer is implemented by values which declare how long they may be
+	// cached.
+	TTLer interface {
+		CacheTTL() time.Duration
+	}
+
+	// ExpireAter is implemented by values which declare when they stop being
+	// fresh.
+	ExpireAter interface {
+		CacheExpireAt() time.Time
+	}
+
+	prefixTTL struct {
+		prefix string
+		ttl    time.Duration
+	}
+)
+
+// defaultTTL returns the TTL for a value whose options don't give an
+// expiration: the TTL the value declares as a TTLer or ExpireAter, the TTL
+// from WithTTLFunc if it gives one, the TTL of the value's type, see
+// WithTypeTTL, the TTL of the longest matching WithPrefixTTL prefix, or the
+// Store's default expiration.
+func (es Store) defaultTTL(key interface{}, value interface{}) time.Duration {
+	// a value may be both, declaring only one of them
+	if v, ok := value.(TTLer); ok {
+		if ttl := v.CacheTTL(); ttl > 0 {
+			return ttl
+		}
+	}
+	if v, ok := value.(ExpireAter); ok {
+		if at := v.CacheExpireAt(); !at.IsZero() {
+			// a time already passed is kept, so the value is stored expired
+			return at.Sub(es.now())
+		}
+	}
+	if es.ttlFunc != nil {
+		if ttl := es.ttlFromFunc(key, value); ttl > 0 {
+			return ttl
+		}
+	}
+	if es.typeTTLs != nil {
+		if ttl := es.typeTTLs.ttlFor(value); ttl > 0 {
+			return ttl
+		}
+	}
+	settings := es.settings.load()
+	if len(settings.prefixTTLs) > 0 {
+		if s, ok := key.(string); ok {
+			for _, p := range settings.prefixTTLs {
+				if strings.HasPrefix(s, p.prefix) {
+					return p.ttl
+				}
+			}
+		}
+	}
+	return settings.expiration
+}
+
+// jittered randomly lengthens or shortens ttl by up to the Store's jitter
+// fraction.
+func (es Store) jittered(ttl time.Duration) time.Duration {
+	jitter := es.settings.load().jitter
+	if jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	jittered := time.Duration(float64(ttl) * (1 + jitter*(2*rand.Float64()-1)))
+	if jittered <= 0 {
+		return ttl
+	}
+	return jittered
+}
+
+// addPrefixTTL adds or replaces the TTL for prefix, keeping the longest
+// prefixes first so that the most specific one matches.
+func addPrefixTTL(prefixTTLs []prefixTTL, prefix string, ttl time.Duration) []prefixTTL {
+	updated := make([]prefixTTL, 0, len(prefixTTLs)+1)
+	for _, p := range prefixTTLs {
+		if p.prefix != prefix {
+			updated = append(updated, p)
+		}
+	}
+	updated = append(updated, prefixTTL{prefix: prefix, ttl: ttl})
+	sort.SliceStable(updated, func(i, j int) bool { return len(updated[i].prefix) > len(updated[j].prefix) })
+	return updated
+}
+
+// withNativeExpiration returns a copy of options whose expiration is ttl, see
+// WithNativeExpiration.
+func withNativeExpiration(options *store.Options, ttl time.Duration) *store.Options {
+	native := store.Options{}
+	if options != nil {
+		native = *options
+	}
+	if ttl <= 0 {
+		// the underlying store may treat 0 as never expiring
+		ttl = time.Nanosecond
+	}
+	native.Expiration = ttl
+	return &native
+}
+
+// ttlFromFunc returns the TTL given by the function given to WithTTLFunc, or
+// 0 if it panics.
+func (es Store) ttlFromFunc(key interface{}, value interface{}) (ttl time.Duration) {
+	defer es.recoverPanic("TTL function", nil)
+	return es.ttlFunc(key, value)
+}
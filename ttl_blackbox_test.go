@@ -0,0 +1,135 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type cachedResponse struct {
+	maxAge time.Duration
+}
+
+func TestTTLFunc(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithPrefixTTL("session:", 5*time.Minute),
+		expiring.WithTTLFunc(func(key, value interface{}) time.Duration {
+			if r, ok := value.(cachedResponse); ok {
+				return r.maxAge
+			}
+			return 0
+		}),
+	)
+
+	for _, tc := range []struct {
+		key      string
+		value    interface{}
+		options  *store.Options
+		expected time.Duration
+	}{
+		{key: "response", value: cachedResponse{maxAge: time.Minute}, expected: time.Minute},
+		{key: "session:response", value: cachedResponse{maxAge: time.Minute}, expected: time.Minute},
+		{key: "response", value: cachedResponse{maxAge: time.Minute}, options: &store.Options{Expiration: 2 * time.Minute}, expected: 2 * time.Minute},
+		{key: "response", value: cachedResponse{}, expected: time.Hour},
+		{key: "session:user", value: "user", expected: 5 * time.Minute},
+		{key: "other", value: "other", expected: time.Hour},
+	} {
+		assert.Nil(t, es.Set(tc.key, tc.value, tc.options))
+		_, ttl, err := es.GetWithTTL(tc.key)
+		assert.Nil(t, err)
+		assert.True(t, ttl > tc.expected-time.Second && ttl <= tc.expected, "%s has ttl %s", tc.key, ttl)
+	}
+}
+
+type freshFor time.Duration
+
+func (f freshFor) CacheTTL() time.Duration { return time.Duration(f) }
+
+type freshUntil time.Time
+
+func (f freshUntil) CacheExpireAt() time.Time { return time.Time(f) }
+
+// freshBoth declares either a TTL or a time, leaving the other zero.
+type freshBoth struct {
+	ttl time.Duration
+	at  time.Time
+}
+
+func (f freshBoth) CacheTTL() time.Duration { return f.ttl }
+
+func (f freshBoth) CacheExpireAt() time.Time { return f.at }
+
+func TestValuesDeclaringTTL(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithTTLFunc(func(key, value interface{}) time.Duration { return 2 * time.Hour }),
+	)
+
+	for _, tc := range []struct {
+		value    interface{}
+		options  *store.Options
+		expected time.Duration
+	}{
+		{value: freshFor(time.Minute), expected: time.Minute},
+		{value: freshFor(time.Minute), options: &store.Options{Expiration: 3 * time.Minute}, expected: 3 * time.Minute},
+		{value: freshFor(0), expected: 2 * time.Hour},
+		{value: freshUntil(time.Now().Add(10 * time.Minute)), expected: 10 * time.Minute},
+		{value: freshUntil(time.Time{}), expected: 2 * time.Hour},
+		{value: freshBoth{ttl: time.Minute}, expected: time.Minute},
+		{value: freshBoth{at: time.Now().Add(10 * time.Minute)}, expected: 10 * time.Minute},
+		{value: freshBoth{}, expected: 2 * time.Hour},
+	} {
+		assert.Nil(t, es.Set("key", tc.value, tc.options))
+		_, ttl, err := es.GetWithTTL("key")
+		assert.Nil(t, err)
+		assert.True(t, ttl > tc.expected-time.Second && ttl <= tc.expected, "%v has ttl %s", tc.value, ttl)
+	}
+
+	assert.Nil(t, es.Set("stale", freshUntil(time.Now().Add(-time.Minute)), nil))
+	_, err := es.Get("stale")
+	assert.Equal(t, expiring.ValueExpiredError, err)
+}
+
+type productDetails struct {
+	_    struct{} `cache:"ttl=5m"`
+	Name string
+}
+
+type userProfile struct {
+	Name string
+}
+
+type badlyTagged struct {
+	Name string `cache:"ttl=soon"`
+}
+
+func TestTypeTTLs(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Minute},
+		expiring.WithPrefixTTL("user:", 10*time.Minute),
+		expiring.WithTypeTTL(userProfile{}, time.Hour),
+		expiring.WithStructTagTTLs(),
+	)
+
+	for _, tc := range []struct {
+		key      string
+		value    interface{}
+		expected time.Duration
+	}{
+		{key: "product", value: productDetails{Name: "widget"}, expected: 5 * time.Minute},
+		{key: "product", value: &productDetails{Name: "widget"}, expected: 5 * time.Minute},
+		{key: "user:42", value: userProfile{Name: "Ada"}, expected: time.Hour},
+		{key: "user:42", value: &userProfile{Name: "Ada"}, expected: time.Hour},
+		{key: "user:43", value: "Grace", expected: 10 * time.Minute},
+		{key: "bad", value: badlyTagged{}, expected: time.Minute},
+	} {
+		assert.Nil(t, es.Set(tc.key, tc.value, nil))
+		_, ttl, err := es.GetWithTTL(tc.key)
+		assert.Nil(t, err)
+		assert.True(t, ttl > tc.expected-time.Second && ttl <= tc.expected, "%T has ttl %s", tc.value, ttl)
+	}
+}
@@ -0,0 +1,55 @@
+package expiring_gocache
+
+import (
+	"time"
+)
+
+type (
+	// ttlHistograms count the TTLs given to values when they are set, and
+	// those they have left when they are read, see WithTTLHistograms.
+	ttlHistograms struct {
+		set       *latencyHistogram
+		remaining *latencyHistogram
+	}
+)
+
+// ttlBounds are the upper bounds of the TTL histogram buckets, from a
+// second up to DefaultExpiration.
+var ttlBounds = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	DefaultExpiration,
+}
+
+func newTTLHistograms() *ttlHistograms {
+	return &ttlHistograms{set: newHistogram(ttlBounds), remaining: newHistogram(ttlBounds)}
+}
+
+// observeSetTTL records the TTL given to a value set.
+func (es Store) observeSetTTL(ttl time.Duration) {
+	if es.ttls != nil {
+		es.ttls.set.observe(ttl)
+	}
+}
+
+// observeRemainingTTL records the TTL left to a value read.
+func (es Store) observeRemainingTTL(ttl time.Duration) {
+	if es.ttls != nil {
+		es.ttls.remaining.observe(ttl)
+	}
+}
+
+func (es Store) ttlSummaries() (set, remaining *LatencySummary) {
+	if es.ttls == nil {
+		return nil, nil
+	}
+	s, r := es.ttls.set.summary(), es.ttls.remaining.summary()
+	return &s, &r
+}
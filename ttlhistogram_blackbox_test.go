@@ -0,0 +1,45 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLHistograms(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithTTLHistograms(), expiring.WithClock(clk))
+
+	assert.Nil(t, es.Set("default", "value", nil))
+	assert.Nil(t, es.Set("short", "value", &store.Options{Expiration: 30 * time.Second}))
+	assert.Nil(t, es.Set("shorter", "value", &store.Options{Expiration: 20 * time.Second}))
+	clk.Advance(15 * time.Second)
+	_, err := es.Get("short")
+	assert.Nil(t, err)
+	_, _, err = es.GetWithTTL("shorter")
+	assert.Nil(t, err)
+
+	stats := es.Stats()
+	if assert.NotNil(t, stats.SetTTLs) {
+		assert.Equal(t, uint64(3), stats.SetTTLs.Count)
+		// 30s and 20s fall in the (10s, 1m] bucket, the default in the last
+		// bounded bucket
+		assert.Equal(t, time.Minute, stats.SetTTLs.P50)
+		assert.Equal(t, expiring.DefaultExpiration, stats.SetTTLs.P99)
+	}
+	if assert.NotNil(t, stats.RemainingTTLs) {
+		assert.Equal(t, uint64(2), stats.RemainingTTLs.Count)
+		assert.Equal(t, 20*time.Second, stats.RemainingTTLs.Sum)
+		assert.Equal(t, 10*time.Second, stats.RemainingTTLs.P50)
+		assert.Equal(t, time.Minute, stats.RemainingTTLs.P99)
+	}
+
+	stats = expiring.New(&ms, nil).Stats()
+	assert.Nil(t, stats.SetTTLs)
+	assert.Nil(t, stats.RemainingTTLs)
+}
@@ -0,0 +1,96 @@
+package expiring_gocache
+
+import (
+	"time"
+)
+
+type (
+	// batchGetter is implemented by stores which can read many values in
+	// one round trip, e.g. with MGET. Keys which aren't found are left out
+	// of the map returned.
+	batchGetter interface {
+		GetMulti(keys []interface{}) (map[interface{}]interface{}, error)
+	}
+)
+
+// TTLMulti returns the time each of keys has left, e.g. for a dashboard or a
+// job refreshing values before they expire. Keys which aren't found, or
+// whose values have expired, are left out; values which weren't written
+// through the Store have a TTL of 0, as with GetWithTTL. If the underlying
+// store implements `GetMulti(keys []interface{}) (map[interface{}]interface{}, error)`
+// every key is read in a single call, so native TTLs aren't considered;
+// otherwise keys are read one at a time, as by GetWithTTL. Unlike
+// GetWithTTL, reads have no side effects: expired values aren't deleted,
+// and reads don't count as accesses.
+func (es Store) TTLMulti(keys []interface{}) (map[interface{}]time.Duration, error) {
+	for _, key := range keys {
+		if !trackable(key) {
+			return nil, UntrackableKeyError
+		}
+	}
+	ttls := make(map[interface{}]time.Duration, len(keys))
+	if bg, ok := es.store.(batchGetter); ok {
+		vals, err := es.innerGetMulti(bg, keys)
+		if err != nil {
+			return nil, err
+		}
+		now := es.now()
+		for key, val := range vals {
+			if ttl, ok := es.ttlOf(key, val, 0, now); ok {
+				ttls[key] = ttl
+			}
+		}
+		return ttls, nil
+	}
+
+	tg, hasTTLs := es.store.(ttlGetter)
+	for _, key := range keys {
+		var (
+			val    interface{}
+			native time.Duration
+			err    error
+		)
+		if hasTTLs {
+			val, native, err = es.innerGetWithTTL(tg, key)
+		} else {
+			val, err = es.innerGet(key)
+		}
+		if err != nil {
+			// a miss
+			continue
+		}
+		if ttl, ok := es.ttlOf(key, val, native, es.now()); ok {
+			ttls[key] = ttl
+		}
+	}
+	return ttls, nil
+}
+
+// ttlOf returns the time val, read for key with the native TTL native, has
+// left at now, reporting false if it has expired.
+func (es Store) ttlOf(key interface{}, val interface{}, native time.Duration, now time.Time) (time.Duration, bool) {
+	if val == nil {
+		return 0, false
+	}
+	ew, _, ok := unwrapHeader(val)
+	if !ok || es.bypassed(key) {
+		return native, true
+	}
+	if ew.instance != es.instanceID {
+		return 0, false
+	}
+	ew, err := es.withGeneration(key, es.withTagExpiry(ew))
+	if err != nil {
+		// as with a failed read, the key is left out
+		return 0, false
+	}
+	ttl := ew.expireAt.Add(es.skewTolerance).Sub(now)
+	if ttl <= 0 {
+		// pinned values are kept past their expiration, with no time left
+		return 0, es.pins.has(key)
+	}
+	if native > 0 && native < ttl {
+		ttl = native
+	}
+	return ttl, true
+}
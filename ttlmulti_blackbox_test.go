@@ -0,0 +1,69 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	// GetMultiMapStore counts batched reads.
+	GetMultiMapStore struct {
+		MapStore
+		batches int
+	}
+)
+
+func TestTTLMulti(t *testing.T) {
+	ms := GetMultiMapStore{MapStore: MapStore{cache: map[interface{}]interface{}{}}}
+	fake := clock.NewFake(time.Unix(0, 0))
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithClock(fake), expiring.WithInstanceID("id"))
+
+	assert.Nil(t, es.Set("a", "value a", nil))
+	assert.Nil(t, es.Set("b", "value b", &store.Options{Expiration: time.Minute}))
+	assert.Nil(t, es.Set("c", "value c", &store.Options{Expiration: time.Second}))
+	fake.Advance(2 * time.Second)
+
+	ttls, err := es.TTLMulti([]interface{}{"a", "b", "c", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, ms.batches)
+	assert.Equal(t, 0, ms.getCount)
+	assert.Equal(t, map[interface{}]time.Duration{"a": time.Hour - 2*time.Second, "b": 58 * time.Second}, ttls)
+	// expired values are left for reads to delete
+	assert.Len(t, ms.cache, 3)
+
+	_, err = es.TTLMulti([]interface{}{[]string{"uncomparable"}})
+	assert.Equal(t, expiring.UntrackableKeyError, err)
+}
+
+func TestTTLMultiWithoutBatching(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+
+	assert.Nil(t, es.Set("a", "value a", nil))
+	ms.cache["raw"] = "not wrapped"
+
+	ttls, err := es.TTLMulti([]interface{}{"a", "raw", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, ms.getCount)
+	assert.Len(t, ttls, 2)
+	assert.True(t, ttls["a"] > 59*time.Minute && ttls["a"] <= time.Hour)
+	assert.Equal(t, time.Duration(0), ttls["raw"])
+}
+
+func (ms *GetMultiMapStore) GetMulti(keys []interface{}) (map[interface{}]interface{}, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.batches++
+	vals := map[interface{}]interface{}{}
+	for _, key := range keys {
+		if val, ok := ms.cache[key]; ok {
+			vals[key] = val
+		}
+	}
+	return vals, nil
+}
@@ -0,0 +1,76 @@
+package expiring_gocache
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// typeTTLs holds the TTLs of value types, see WithTypeTTL and
+	// WithStructTagTTLs.
+	typeTTLs struct {
+		registered map[reflect.Type]time.Duration
+		tags       bool
+		// tagged caches the TTL read from each struct type's tags, or 0.
+		tagged sync.Map
+	}
+)
+
+// TTLTag is the struct tag read by WithStructTagTTLs.
+const TTLTag = "cache"
+
+// ttlFor returns the TTL of value's type, or 0 if it has none.
+func (t *typeTTLs) ttlFor(value interface{}) time.Duration {
+	typ := reflect.TypeOf(value)
+	if typ == nil {
+		return 0
+	}
+	if ttl, ok := t.registered[typ]; ok {
+		return ttl
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		if ttl, ok := t.registered[typ]; ok {
+			return ttl
+		}
+	}
+	if !t.tags || typ.Kind() != reflect.Struct {
+		return 0
+	}
+	if ttl, ok := t.tagged.Load(typ); ok {
+		return ttl.(time.Duration)
+	}
+	ttl := tagTTL(typ)
+	t.tagged.Store(typ, ttl)
+	return ttl
+}
+
+// tagTTL returns the TTL given by the first field of typ tagged with one,
+// e.g. `cache:"ttl=5m"`, or 0.
+func tagTTL(typ reflect.Type) time.Duration {
+	for i := 0; i < typ.NumField(); i++ {
+		tag, ok := typ.Field(i).Tag.Lookup(TTLTag)
+		if !ok {
+			continue
+		}
+		for _, part := range strings.Split(tag, ",") {
+			if !strings.HasPrefix(part, "ttl=") {
+				continue
+			}
+			if ttl, err := time.ParseDuration(strings.TrimPrefix(part, "ttl=")); err == nil && ttl > 0 {
+				return ttl
+			}
+		}
+	}
+	return 0
+}
+
+// typeTTLsOf returns the Store's type TTLs, creating them for an option.
+func (es *Store) typeTTLsOf() *typeTTLs {
+	if es.typeTTLs == nil {
+		es.typeTTLs = &typeTTLs{registered: map[reflect.Type]time.Duration{}}
+	}
+	return es.typeTTLs
+}
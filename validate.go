@@ -0,0 +1,167 @@
+package expiring_gocache
+
+import (
+	"fmt"
+
+	"github.com/eko/gocache/store"
+)
+
+// NewValidated is like New, but checks the configuration first, returning a
+// *ConfigError describing every problem found, and no Store, rather than a
+// Store which quietly ignores or misuses the bad settings.
+func NewValidated(store store.StoreInterface, options *store.Options, opts ...Option) (Store, error) {
+	es := newStore(store, options, opts)
+	if err := es.validate(); err != nil {
+		return Store{}, err
+	}
+	return es.start(), nil
+}
+
+// MustNew is like NewValidated, but panics if the configuration is invalid,
+// for Stores configured in code, where a bad setting is a bug.
+func MustNew(store store.StoreInterface, options *store.Options, opts ...Option) Store {
+	es, err := NewValidated(store, options, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return es
+}
+
+// validate checks the Store's configuration, once its options have been
+// applied.
+func (es Store) validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	fraction := func(name string, f float64) {
+		if !(f >= 0 && f <= 1) {
+			problem("%s must be between 0 and 1, got %v", name, f)
+		}
+	}
+
+	s := es.settings.load()
+	if s.expiration < 0 {
+		problem("expiration must not be negative, got %s", s.expiration)
+	}
+	if s.jitter < 0 || s.jitter >= 1 {
+		problem("jitter must be at least 0 and less than 1, got %v", s.jitter)
+	}
+	for _, p := range s.prefixTTLs {
+		if p.ttl <= 0 {
+			problem("prefix TTL of %q must be positive, got %s", p.prefix, p.ttl)
+		}
+	}
+	if s.maxEntries < 0 {
+		problem("max entries must not be negative, got %d", s.maxEntries)
+	}
+	if w := es.watermarks; w != nil && (w.low <= 0 || w.low >= w.high || w.high > 1) {
+		problem("eviction watermarks must satisfy 0 < low < high <= 1, got %v and %v", w.low, w.high)
+	}
+
+	if _, ok := es.store.(keyLister); es.hashedIndex && !ok {
+		problem("a hashed index needs an underlying store which lists its keys")
+	}
+
+	if es.reaperEnabled && es.reaperInterval <= 0 {
+		problem("reaper interval must be positive, got %s", es.reaperInterval)
+	}
+	if es.retryQueueSize < 0 || es.retryAttempts < 0 || es.retryBackoff < 0 {
+		problem("delete retries must not be negative, got queue size %d, %d attempts and backoff %s", es.retryQueueSize, es.retryAttempts, es.retryBackoff)
+	}
+	if es.slowThreshold < 0 {
+		problem("slow threshold must not be negative, got %s", es.slowThreshold)
+	}
+	if es.slowLogSize < 0 {
+		problem("slow log size must not be negative, got %d", es.slowLogSize)
+	}
+	if es.deadlineClamp < 0 {
+		problem("deadline clamp must not be negative, got %v", es.deadlineClamp)
+	}
+	if es.typeTTLs != nil {
+		for typ, ttl := range es.typeTTLs.registered {
+			if ttl <= 0 {
+				problem("TTL of type %s must be positive, got %s", typ, ttl)
+			}
+		}
+	}
+	fraction("set sampling rate", es.setSampleRate)
+	if a := es.admission; a != nil && a.window <= 0 {
+		problem("admission window must be positive, got %s", a.window)
+	}
+	fraction("hit jitter rate", es.hitJitterRate)
+	if es.hitJitterWindow < 0 {
+		problem("hit jitter window must not be negative, got %s", es.hitJitterWindow)
+	}
+	if es.hedgeReplica != nil && es.hedgeDelay < 0 {
+		problem("hedge delay must not be negative, got %s", es.hedgeDelay)
+	}
+	if es.shadow != nil {
+		if es.shadow.store == nil {
+			problem("shadow store must not be nil")
+		}
+		fraction("shadow sample rate", es.shadow.config.SampleRate)
+	}
+	if es.refreshBackoff <= 0 {
+		problem("refresh backoff must be positive, got %s", es.refreshBackoff)
+	}
+	if es.idle != nil && es.idle.period <= 0 {
+		problem("idle period must be positive, got %s", es.idle.period)
+	}
+	if es.workerBackoff <= 0 {
+		problem("worker restart backoff must be positive, got %s", es.workerBackoff)
+	}
+	if es.envelopeFormat < BinaryEnvelope || es.envelopeFormat > MsgpackEnvelope {
+		problem("unknown envelope format %d", es.envelopeFormat)
+	}
+	if es.futureEnvelopes < FutureEnvelopesFail || es.futureEnvelopes > FutureEnvelopesMigrate {
+		problem("unknown future envelope policy %d", es.futureEnvelopes)
+	}
+	if es.startupScan.sample < 0 {
+		problem("startup scan sample must not be negative, got %d", es.startupScan.sample)
+	}
+	if es.startupScan.repair < ScanReportOnly || es.startupScan.repair > ScanPurge {
+		problem("unknown startup scan repair %d", es.startupScan.repair)
+	}
+	if es.leaseTTL < 0 {
+		problem("lease TTL must not be negative, got %s", es.leaseTTL)
+	}
+	if es.deleteDelay < 0 {
+		problem("delete delay must not be negative, got %s", es.deleteDelay)
+	}
+	if es.skewTolerance < 0 {
+		problem("clock skew tolerance must not be negative, got %s", es.skewTolerance)
+	}
+	if es.chaos != nil {
+		c := es.chaos.config
+		fraction("chaos latency rate", c.LatencyRate)
+		fraction("chaos error rate", c.ErrorRate)
+		fraction("chaos expire rate", c.ExpireRate)
+		fraction("chaos drop delete rate", c.DropDeleteRate)
+	}
+	if wt := es.writeThrough; wt != nil {
+		if wt.sink == nil {
+			problem("write-through sink must not be nil")
+		}
+		if wt.policy.Order != SinkFirst && wt.policy.Order != CacheFirst {
+			problem("write-through order must be SinkFirst or CacheFirst, got %d", wt.policy.Order)
+		}
+	}
+	if es.writeBehindSink != nil {
+		if es.writeThrough != nil {
+			problem("write-through and write-behind can't both be used")
+		}
+		c := es.writeBehindConfig
+		if c.QueueSize < 0 || c.Workers < 0 || c.BatchSize < 0 || c.Attempts < 0 {
+			problem("write-behind sizes must not be negative, got queue size %d, %d workers, batch size %d and %d attempts", c.QueueSize, c.Workers, c.BatchSize, c.Attempts)
+		}
+		if c.BatchDelay < 0 || c.Backoff < 0 {
+			problem("write-behind delays must not be negative, got batch delay %s and backoff %s", c.BatchDelay, c.Backoff)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package expiring_gocache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewValidated(t *testing.T) {
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es, err := expiring.NewValidated(&ms, &store.Options{Expiration: time.Minute},
+		expiring.WithReaper(time.Second),
+		expiring.WithJitter(0.1),
+	)
+	assert.Nil(t, err)
+	defer es.Close()
+	assert.Nil(t, es.Set("key", "value", nil))
+}
+
+func TestNewValidatedProblems(t *testing.T) {
+	_, err := expiring.NewValidated(&MapStore{cache: map[interface{}]interface{}{}}, &store.Options{Expiration: -time.Minute},
+		expiring.WithReaper(0),
+		expiring.WithJitter(1.5),
+		expiring.WithPrefixTTL("user:", -time.Second),
+		expiring.WithSetSampling(2),
+		expiring.WithChaos(expiring.Chaos{ErrorRate: -1}),
+		expiring.WithWriteThrough(nil, expiring.WriteThroughPolicy{Order: 7}),
+	)
+	if assert.IsType(t, &expiring.ConfigError{}, err) {
+		assert.Equal(t, []string{
+			"expiration must not be negative, got -1m0s",
+			"jitter must be at least 0 and less than 1, got 1.5",
+			`prefix TTL of "user:" must be positive, got -1s`,
+			"reaper interval must be positive, got 0s",
+			"set sampling rate must be between 0 and 1, got 2",
+			"chaos error rate must be between 0 and 1, got -1",
+			"write-through sink must not be nil",
+			"write-through order must be SinkFirst or CacheFirst, got 7",
+		}, err.(*expiring.ConfigError).Problems)
+	}
+}
+
+func TestMustNew(t *testing.T) {
+	assert.NotPanics(t, func() {
+		expiring.MustNew(&MapStore{cache: map[interface{}]interface{}{}}, nil)
+	})
+
+	defer func() {
+		err, ok := recover().(error)
+		if assert.True(t, ok) {
+			assert.Equal(t, "invalid expiring config: max entries must not be negative, got -1", err.Error())
+		}
+	}()
+	expiring.MustNew(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithMaxEntries(-1))
+}
@@ -0,0 +1,208 @@
+package expiring_gocache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/eko/gocache/store"
+)
+
+type (
+	// ScanRepair is what VerifyOnStart does with the entries it finds
+	// wanting.
+	ScanRepair int
+
+	// ScanReport is the outcome of VerifyOnStart. Every sampled entry which
+	// could be read is counted as one of Current, Outdated, Expired,
+	// Unwrapped or Future, and every other as Failed.
+	ScanReport struct {
+		// Sampled is how many of the Store's entries were read.
+		Sampled int
+		// Current is how many were wrapped in the form, and envelope
+		// version, the Store writes.
+		Current int
+		// Outdated is how many were wrapped in the other form, e.g. by a
+		// Store created without WithBinaryEnvelope, or in an envelope of an
+		// earlier version.
+		Outdated int
+		// Expired is how many had expired, and not been deleted yet.
+		Expired int
+		// Unwrapped is how many weren't wrapped at all, e.g. because they
+		// were written to the underlying store directly.
+		Unwrapped int
+		// Future is how many were written by a later version of this
+		// package. They are never repaired.
+		Future int
+		// Migrated is how many were rewritten in the Store's current form,
+		// and Purged how many were deleted.
+		Migrated int
+		Purged   int
+		// Failed is how many could not be read, migrated or purged.
+		Failed int
+	}
+
+	startupScan struct {
+		sample int
+		repair ScanRepair
+	}
+)
+
+const (
+	// ScanReportOnly only counts the entries.
+	ScanReportOnly ScanRepair = iota
+	// ScanMigrate rewrites outdated entries in the Store's current form,
+	// wraps unwrapped ones with the time the underlying store has left for
+	// them, or else the Store's default expiration, and deletes expired
+	// ones.
+	ScanMigrate
+	// ScanPurge deletes outdated, unwrapped and expired entries.
+	ScanPurge
+)
+
+// DefaultStartupScanSample is how many entries VerifyOnStart reads unless
+// WithStartupScan says otherwise.
+const DefaultStartupScanSample = 100
+
+// VerifyOnStart reads a sample of the entries already in the underlying
+// store, given by WithStartupScan, and reports how many are in the form the
+// Store writes, so that a change of configuration or of this package's
+// version can be checked before the Store takes traffic. It is meant to be
+// called once, just after New. Entries are repaired as WithStartupScan
+// says; by default they are only counted.
+//
+// The underlying store must implement `Keys() ([]interface{}, error)`;
+// UnsupportedError is returned if it doesn't. Entries of other instances are
+// skipped. VerifyOnStart stops and returns ctx.Err() if ctx is done.
+func (es Store) VerifyOnStart(ctx context.Context) (ScanReport, error) {
+	var report ScanReport
+	kl, ok := es.store.(keyLister)
+	if !ok {
+		return report, UnsupportedError
+	}
+	innerKeys, err := kl.Keys()
+	if err != nil {
+		return report, err
+	}
+	scan := es.startupScan
+	if scan.sample <= 0 {
+		scan.sample = DefaultStartupScanSample
+	}
+
+	for _, innerKey := range innerKeys {
+		if report.Sampled == scan.sample {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		key, ok := es.outerKey(innerKey)
+		if s, reserved := key.(string); !ok || (reserved && strings.HasPrefix(s, directivePrefix)) {
+			continue
+		}
+		val, native, err := es.scanned(key)
+		if err != nil || val == nil {
+			// deleted since it was listed, or unreadable
+			if err != nil && es.classify(OperationGet, err) != ErrorMiss {
+				report.Sampled++
+				report.Failed++
+			}
+			continue
+		}
+		ew, ok := unwrap(val)
+		if ok && ew.instance != es.instanceID {
+			continue
+		}
+		report.Sampled++
+		es.verifyEntry(key, val, ew, ok, native, scan.repair, &report)
+	}
+	return report, nil
+}
+
+// scanned reads key for VerifyOnStart, along with its native TTL if the
+// underlying store can report one.
+func (es Store) scanned(key interface{}) (interface{}, time.Duration, error) {
+	if tg, ok := es.store.(ttlGetter); ok {
+		return es.innerGetWithTTL(tg, key)
+	}
+	val, err := es.innerGet(key)
+	return val, 0, err
+}
+
+// verifyEntry counts the entry val read for key, repairing it as repair
+// says. ew is val unwrapped, if wrapped is true.
+func (es Store) verifyEntry(key, val interface{}, ew wrappedValue, wrapped bool, native time.Duration, repair ScanRepair, report *ScanReport) {
+	now := es.now()
+	switch {
+	case !wrapped:
+		report.Unwrapped++
+		if repair == ScanMigrate {
+			ttl := native
+			if ttl <= 0 {
+				ttl = es.defaultTTL(key, val)
+			}
+			es.scanRepaired(es.set(key, val, nil, ttl, time.Time{}), &report.Migrated, report)
+			return
+		}
+	case ew.version > envelopeVersion:
+		report.Future++
+		return
+	case ew.expireAt.Add(es.skewTolerance).Before(now) && !es.pins.has(key):
+		report.Expired++
+		if repair != ScanReportOnly {
+			es.scanRepaired(es.innerDelete(key), &report.Purged, report)
+		}
+		return
+	case es.currentForm(val, ew):
+		report.Current++
+		return
+	default:
+		report.Outdated++
+		if repair == ScanMigrate {
+			es.scanRepaired(es.rewrite(key, ew, now), &report.Migrated, report)
+			return
+		}
+	}
+	if repair == ScanPurge {
+		es.scanRepaired(es.innerDelete(key), &report.Purged, report)
+	}
+}
+
+// scanRepaired counts the outcome of a repair made by VerifyOnStart.
+func (es Store) scanRepaired(err error, count *int, report *ScanReport) {
+	if err != nil {
+		report.Failed++
+		return
+	}
+	*count++
+}
+
+// currentForm reports whether val, holding ew, is in the form, envelope
+// format and version the Store writes.
+func (es Store) currentForm(val interface{}, ew wrappedValue) bool {
+	var b []byte
+	switch v := val.(type) {
+	case wrappedValue:
+		return !es.binaryEnvelope
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	}
+	format, _ := envelopeFormatOf(b)
+	return es.binaryEnvelope && format == es.envelopeFormat && ew.version == envelopeVersion
+}
+
+// rewrite writes ew back in the Store's current form, with the time it has
+// left.
+func (es Store) rewrite(key interface{}, ew wrappedValue, now time.Time) error {
+	wrapped, err := es.wrap(ew)
+	if err != nil {
+		return err
+	}
+	var options *store.Options
+	if es.nativeExpiration {
+		options = withNativeExpiration(nil, ew.expireAt.Sub(now))
+	}
+	return es.innerSet(key, wrapped, options)
+}
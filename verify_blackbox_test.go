@@ -0,0 +1,81 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/nabowler/expiring_gocache/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyOnStart(t *testing.T) {
+	for _, tc := range []struct {
+		repair    expiring.ScanRepair
+		want      expiring.ScanReport
+		remaining []interface{}
+	}{
+		{
+			repair:    expiring.ScanReportOnly,
+			want:      expiring.ScanReport{Sampled: 5, Current: 1, Outdated: 2, Expired: 1, Unwrapped: 1},
+			remaining: []interface{}{"current", "struct", "v1", "expired", "raw"},
+		},
+		{
+			repair:    expiring.ScanMigrate,
+			want:      expiring.ScanReport{Sampled: 5, Current: 1, Outdated: 2, Expired: 1, Unwrapped: 1, Migrated: 3, Purged: 1},
+			remaining: []interface{}{"current", "struct", "v1", "raw"},
+		},
+		{
+			repair:    expiring.ScanPurge,
+			want:      expiring.ScanReport{Sampled: 5, Current: 1, Outdated: 2, Expired: 1, Unwrapped: 1, Purged: 4},
+			remaining: []interface{}{"current"},
+		},
+	} {
+		clk := clock.NewFake(time.Now())
+		ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+		old := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk))
+		assert.Nil(t, old.Set("struct", []byte("value"), nil))
+		assert.Nil(t, old.Set("expired", []byte("value"), &store.Options{Expiration: time.Second}))
+		ms.cache["raw"] = []byte("value")
+		ms.cache["v1"] = envelopeOf(1, 0, clk.Now().Add(time.Minute), nil, "value")
+
+		es := expiring.New(&ms, &store.Options{Expiration: time.Minute}, expiring.WithClock(clk),
+			expiring.WithBinaryEnvelope(), expiring.WithStartupScan(0, tc.repair))
+		assert.Nil(t, es.Set("current", []byte("value"), nil))
+		clk.Advance(2 * time.Second)
+
+		report, err := es.VerifyOnStart(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, report)
+		keys, err := ms.Keys()
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, tc.remaining, keys)
+
+		if tc.repair == expiring.ScanMigrate {
+			report, err = es.VerifyOnStart(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, expiring.ScanReport{Sampled: 4, Current: 4}, report)
+			for _, key := range tc.remaining {
+				val, err := es.Get(key)
+				assert.Nil(t, err)
+				assert.Equal(t, []byte("value"), val)
+			}
+		}
+	}
+}
+
+func TestVerifyOnStartSample(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, nil, expiring.WithStartupScan(2, expiring.ScanReportOnly))
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, es.Set(key, "value", nil))
+	}
+	report, err := es.VerifyOnStart(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, expiring.ScanReport{Sampled: 2, Current: 2}, report)
+
+	_, err = expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil).VerifyOnStart(context.Background())
+	assert.Equal(t, expiring.UnsupportedError, err)
+}
@@ -0,0 +1,71 @@
+package expiring_gocache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// watermarks are the fractions of MaxEntries at which the evictor
+	// started by WithEvictionWatermarks starts, and stops, evicting.
+	watermarks struct {
+		low, high float64
+	}
+
+	// evictor trims the Store to its low watermark in the background.
+	evictor struct {
+		wake chan struct{}
+		done chan struct{}
+		once sync.Once
+		wg   sync.WaitGroup
+	}
+)
+
+func startEvictor(es Store) *evictor {
+	ev := &evictor{wake: make(chan struct{}, 1), done: make(chan struct{})}
+	ev.wg.Add(1)
+	go func() {
+		defer ev.wg.Done()
+		es.supervise("evictor", ev.done, func() {
+			for {
+				select {
+				case <-ev.done:
+					return
+				case <-ev.wake:
+					max := es.settings.load().maxEntries
+					if max > 0 {
+						n := es.evictTo(watermark(max, es.watermarks.low))
+						atomic.AddUint64(&es.stats.backgroundEvictions, uint64(n))
+					}
+				}
+			}
+		})
+	}()
+	return ev
+}
+
+// signal wakes the evictor, unless it is already due to run.
+func (ev *evictor) signal() {
+	select {
+	case ev.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop signals the evictor to exit and waits for it to do so.
+func (ev *evictor) stop() {
+	ev.once.Do(func() {
+		close(ev.done)
+	})
+	ev.wg.Wait()
+}
+
+// watermark returns the number of entries the fraction f of max allows,
+// keeping at least one.
+func watermark(max int, f float64) int {
+	n := int(float64(max) * f)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+package expiring_gocache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// AdminNotification is the body, as JSON, of the requests sent to the
+	// admin webhook given to WithAdminWebhook.
+	AdminNotification struct {
+		Operation Operation `json:"operation"`
+		// Store is the Store's type name, see WithTypeName.
+		Store    string `json:"store"`
+		Instance string `json:"instance,omitempty"`
+		// Prefix is the prefix given to InvalidateByPrefix.
+		Prefix string `json:"prefix,omitempty"`
+		// Keys is how many values were deleted, or 0 if the Store doesn't
+		// know.
+		Keys int       `json:"keys"`
+		Time time.Time `json:"time"`
+	}
+
+	adminWebhook struct {
+		url    string
+		secret []byte
+		client *http.Client
+	}
+)
+
+const (
+	// OperationInvalidateByPrefix is reported to admin webhooks for
+	// InvalidateByPrefix.
+	OperationInvalidateByPrefix Operation = "invalidate_by_prefix"
+
+	// AdminWebhookSignatureHeader holds the signature of each admin webhook
+	// request: "sha256=" and the hex HMAC-SHA256 of the body, keyed by the
+	// secret given to WithAdminWebhook. See VerifyAdminWebhook.
+	AdminWebhookSignatureHeader = "X-Expiring-Signature"
+
+	// DefaultAdminWebhookThreshold is how many keys InvalidateByPrefix must
+	// delete before the admin webhook is notified.
+	DefaultAdminWebhookThreshold = 100
+
+	adminWebhookTimeout = 5 * time.Second
+)
+
+// notifyAdmin sends n to the admin webhook, if there is one and n is over
+// the threshold. Delivery failures don't fail the operation; they are counted
+// by Stats.AdminWebhookFailures.
+func (es Store) notifyAdmin(n AdminNotification) {
+	if es.adminWebhook == nil {
+		return
+	}
+	if n.Operation == OperationInvalidateByPrefix && n.Keys < es.adminWebhookThreshold {
+		return
+	}
+	n.Store = es.typeName
+	n.Instance = es.instanceID
+	n.Time = time.Now()
+	if err := es.adminWebhook.send(n); err != nil {
+		atomic.AddUint64(&es.stats.adminWebhookFailures, 1)
+	}
+}
+
+func (w *adminWebhook) send(n AdminNotification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(AdminWebhookSignatureHeader, signAdminWebhook(body, w.secret))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("admin webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+func signAdminWebhook(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAdminWebhook reports whether signature, the AdminWebhookSignatureHeader
+// of a request, was made for body with secret.
+func VerifyAdminWebhook(body []byte, signature string, secret []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(signAdminWebhook(body, secret)))
+}
@@ -0,0 +1,104 @@
+package expiring_gocache_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidateByPrefix(t *testing.T) {
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour})
+	assert.Nil(t, es.Set("user:1", "value", nil))
+	assert.Nil(t, es.Set("user:2", "value", nil))
+	assert.Nil(t, es.Set("group:1", "value", nil))
+	assert.Nil(t, es.Set(1, "value", nil))
+
+	n, err := es.InvalidateByPrefix("user:")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, ms.cache, 2)
+	assert.Contains(t, ms.cache, "group:1")
+
+	n, err = expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil).InvalidateByPrefix("user:")
+	assert.Equal(t, expiring.UnsupportedError, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestAdminWebhook(t *testing.T) {
+	secret := []byte("secret")
+	var (
+		mu            sync.Mutex
+		notifications []expiring.AdminNotification
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !expiring.VerifyAdminWebhook(body, r.Header.Get(expiring.AdminWebhookSignatureHeader), secret) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var n expiring.AdminNotification
+		assert.Nil(t, json.Unmarshal(body, &n))
+		mu.Lock()
+		defer mu.Unlock()
+		notifications = append(notifications, n)
+	}))
+	defer server.Close()
+
+	ms := ListingMapStore{MapStore{cache: map[interface{}]interface{}{}}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour},
+		expiring.WithAdminWebhook(server.URL, secret),
+		expiring.WithAdminWebhookThreshold(2),
+		expiring.WithTypeName("sessions"),
+	)
+	assert.Nil(t, es.Set("user:1", "value", nil))
+	assert.Nil(t, es.Set("user:2", "value", nil))
+	assert.Nil(t, es.Set("group:1", "value", nil))
+
+	// under the threshold
+	_, err := es.InvalidateByPrefix("group:")
+	assert.Nil(t, err)
+	_, err = es.InvalidateByPrefix("user:")
+	assert.Nil(t, err)
+	assert.Nil(t, es.Clear())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, notifications, 2) {
+		assert.Equal(t, expiring.OperationInvalidateByPrefix, notifications[0].Operation)
+		assert.Equal(t, "user:", notifications[0].Prefix)
+		assert.Equal(t, 2, notifications[0].Keys)
+		assert.Equal(t, "sessions", notifications[0].Store)
+		assert.Equal(t, expiring.OperationClear, notifications[1].Operation)
+	}
+	assert.Equal(t, uint64(0), es.Stats().AdminWebhookFailures)
+}
+
+func TestAdminWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithAdminWebhook(server.URL, []byte("secret")))
+	// the operation still succeeds
+	assert.Nil(t, es.Clear())
+	assert.Equal(t, uint64(1), es.Stats().AdminWebhookFailures)
+}
+
+func TestVerifyAdminWebhook(t *testing.T) {
+	body := []byte(`{"operation":"clear"}`)
+	// HMAC-SHA256 of body keyed by "secret"
+	assert.True(t, expiring.VerifyAdminWebhook(body, "sha256=bf0856d466b146fa282424ba5bdb24f20d196d7c4363a856e909cae2981970f6", []byte("secret")))
+	assert.False(t, expiring.VerifyAdminWebhook(body, "sha256=bf0856d466b146fa282424ba5bdb24f20d196d7c4363a856e909cae2981970f6", []byte("other")))
+	assert.False(t, expiring.VerifyAdminWebhook(body, "sha256=00", []byte("secret")))
+	assert.False(t, expiring.VerifyAdminWebhook(body, "", []byte("secret")))
+}
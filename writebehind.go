@@ -0,0 +1,286 @@
+package expiring_gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// WriteBehind configures WithWriteBehind. Zero fields take their
+	// defaults.
+	WriteBehind struct {
+		// QueueSize is how many writes each worker queues before further
+		// writes are dropped.
+		QueueSize int
+		// Workers is how many goroutines write to the sink. Writes of the
+		// same key are always made by the same worker, in order.
+		Workers int
+		// BatchSize is the most writes passed to the sink at once.
+		BatchSize int
+		// BatchDelay is how long a worker waits for a batch to fill before
+		// writing what it has.
+		BatchDelay time.Duration
+		// Attempts is how many times a write is tried before it is given up
+		// on, and Backoff how long to wait between attempts.
+		Attempts int
+		Backoff  time.Duration
+		// OnError, if set, is called with writes which were lost, either
+		// because the queue was full or because they ran out of attempts.
+		OnError func(writes []SinkWrite, err error)
+	}
+
+	// SinkWrite is a write queued for the sink by WithWriteBehind. Remove
+	// writes are for deleted keys, and have no value.
+	SinkWrite struct {
+		Key    interface{}
+		Value  interface{}
+		TTL    time.Duration
+		Remove bool
+	}
+
+	// batchSink is implemented by sinks which can take many writes in one
+	// call, e.g. in one database transaction.
+	batchSink interface {
+		WriteBatch(ctx context.Context, writes []SinkWrite) error
+	}
+
+	writeBehindQueue struct {
+		es      Store
+		sink    Sink
+		config  WriteBehind
+		workers []chan writeBehindItem
+
+		mu     sync.RWMutex
+		closed bool
+		wg     sync.WaitGroup
+	}
+
+	// writeBehindItem is a queued write, or a Flush waiting for the writes
+	// queued before it.
+	writeBehindItem struct {
+		write   SinkWrite
+		flushed chan struct{}
+	}
+)
+
+const (
+	DefaultWriteBehindQueueSize  = 1024
+	DefaultWriteBehindBatchSize  = 100
+	DefaultWriteBehindBatchDelay = 100 * time.Millisecond
+	DefaultWriteBehindAttempts   = 3
+	DefaultWriteBehindBackoff    = 100 * time.Millisecond
+)
+
+var (
+	WriteBehindQueueFullError = errors.New("write-behind queue is full")
+	WriteBehindClosedError    = errors.New("write-behind queue is closed")
+)
+
+func startWriteBehind(es Store, sink Sink, config WriteBehind) *writeBehindQueue {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultWriteBehindQueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultWriteBehindBatchSize
+	}
+	if config.BatchDelay <= 0 {
+		config.BatchDelay = DefaultWriteBehindBatchDelay
+	}
+	if config.Attempts <= 0 {
+		config.Attempts = DefaultWriteBehindAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultWriteBehindBackoff
+	}
+
+	q := &writeBehindQueue{es: es, sink: sink, config: config, workers: make([]chan writeBehindItem, config.Workers)}
+	for i := range q.workers {
+		q.workers[i] = make(chan writeBehindItem, config.QueueSize)
+		q.wg.Add(1)
+		go q.supervise(i)
+	}
+	return q
+}
+
+// enqueue queues writes for the sink, dropping those which don't fit.
+func (q *writeBehindQueue) enqueue(ops []sinkOp) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	for _, op := range ops {
+		write := SinkWrite{Key: op.key, Value: op.value, TTL: op.ttl, Remove: op.op == OperationDelete}
+		if q.closed {
+			q.lost([]SinkWrite{write}, WriteBehindClosedError)
+			continue
+		}
+		select {
+		case q.workers[q.worker(op.key)] <- writeBehindItem{write: write}:
+			atomic.AddUint64(&q.es.stats.writeBehindQueued, 1)
+		default:
+			q.lost([]SinkWrite{write}, WriteBehindQueueFullError)
+		}
+	}
+}
+
+// worker returns the worker writes of key are queued on.
+func (q *writeBehindQueue) worker(key interface{}) int {
+	if len(q.workers) == 1 {
+		return 0
+	}
+	return int(mix64(keyHash(key)) % uint64(len(q.workers)))
+}
+
+// supervise runs the i'th worker until its queue is closed.
+func (q *writeBehindQueue) supervise(i int) {
+	defer q.wg.Done()
+	q.es.supervise(fmt.Sprint("write-behind ", i), nil, func() {
+		q.run(q.workers[i])
+	})
+}
+
+func (q *writeBehindQueue) run(queue chan writeBehindItem) {
+	for item := range queue {
+		var (
+			batch   []SinkWrite
+			flushed []chan struct{}
+		)
+		add := func(item writeBehindItem) {
+			if item.flushed != nil {
+				flushed = append(flushed, item.flushed)
+			} else {
+				batch = append(batch, item.write)
+			}
+		}
+		add(item)
+
+		timer := time.NewTimer(q.config.BatchDelay)
+	collect:
+		for len(batch) < q.config.BatchSize && len(flushed) == 0 {
+			select {
+			case item, ok := <-queue:
+				if !ok {
+					break collect
+				}
+				add(item)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		if len(batch) > 0 {
+			q.write(batch)
+		}
+		for _, f := range flushed {
+			close(f)
+		}
+	}
+}
+
+// write passes batch to the sink, retrying failed writes.
+func (q *writeBehindQueue) write(batch []SinkWrite) {
+	if bs, ok := q.sink.(batchSink); ok {
+		q.retry(batch, func() error { return bs.WriteBatch(context.Background(), batch) })
+		return
+	}
+	for _, w := range batch {
+		w := w
+		q.retry([]SinkWrite{w}, func() error {
+			if w.Remove {
+				return q.sink.Remove(context.Background(), w.Key)
+			}
+			return q.sink.Write(context.Background(), w.Key, w.Value, w.TTL)
+		})
+	}
+}
+
+func (q *writeBehindQueue) retry(writes []SinkWrite, fn func() error) {
+	for attempt := 1; ; attempt++ {
+		err := q.es.guard("sink", fn)
+		if err == nil {
+			atomic.AddUint64(&q.es.stats.writeBehindWritten, uint64(len(writes)))
+			return
+		}
+		if attempt >= q.config.Attempts {
+			q.lost(writes, err)
+			return
+		}
+		atomic.AddUint64(&q.es.stats.writeBehindRetries, 1)
+		time.Sleep(q.config.Backoff)
+	}
+}
+
+func (q *writeBehindQueue) lost(writes []SinkWrite, err error) {
+	if errors.Is(err, WriteBehindQueueFullError) || errors.Is(err, WriteBehindClosedError) {
+		atomic.AddUint64(&q.es.stats.writeBehindDropped, uint64(len(writes)))
+	} else {
+		atomic.AddUint64(&q.es.stats.writeBehindFailed, uint64(len(writes)))
+	}
+	if q.config.OnError != nil {
+		_ = q.es.guard("write-behind error hook", func() error {
+			q.config.OnError(writes, err)
+			return nil
+		})
+	}
+}
+
+// flush waits until every write queued before it was called has been
+// written or given up on.
+func (q *writeBehindQueue) flush(ctx context.Context) error {
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return nil
+	}
+	waits := make([]chan struct{}, 0, len(q.workers))
+	for _, worker := range q.workers {
+		flushed := make(chan struct{})
+		select {
+		case worker <- writeBehindItem{flushed: flushed}:
+			waits = append(waits, flushed)
+		case <-ctx.Done():
+			q.mu.RUnlock()
+			return ctx.Err()
+		}
+	}
+	q.mu.RUnlock()
+
+	for _, flushed := range waits {
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// stop writes what is queued and waits for the workers to exit. Later
+// writes are dropped.
+func (q *writeBehindQueue) stop() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		for _, worker := range q.workers {
+			close(worker)
+		}
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// Flush waits until every write queued for the sink given to WithWriteBehind
+// before it was called has been written, or given up on, or until ctx is
+// done. It returns nil at once if WithWriteBehind wasn't given.
+func (es Store) Flush(ctx context.Context) error {
+	if es.writeBehind == nil {
+		return nil
+	}
+	return es.writeBehind.flush(ctx)
+}
@@ -0,0 +1,145 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type batchRecordingSink struct {
+	*recordingSink
+	batches [][]expiring.SinkWrite
+}
+
+func (s *batchRecordingSink) WriteBatch(ctx context.Context, writes []expiring.SinkWrite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, writes)
+	return nil
+}
+
+func TestWriteBehind(t *testing.T) {
+	sink := newRecordingSink()
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithWriteBehind(sink, expiring.WriteBehind{BatchDelay: time.Hour}))
+	defer es.Close()
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Set("other", "value", nil))
+	assert.Nil(t, es.Delete("other"))
+	assert.Equal(t, 2, ms.setCount)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, es.Flush(ctx))
+	sink.mu.Lock()
+	assert.Equal(t, map[interface{}]interface{}{"key": "value"}, sink.values)
+	assert.Equal(t, []string{"write", "write", "remove"}, sink.calls)
+	sink.mu.Unlock()
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(3), stats.WriteBehindQueued)
+	assert.Equal(t, uint64(3), stats.WriteBehindWritten)
+}
+
+func TestWriteBehindBatches(t *testing.T) {
+	sink := &batchRecordingSink{recordingSink: newRecordingSink()}
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil,
+		expiring.WithWriteBehind(sink, expiring.WriteBehind{BatchSize: 2, BatchDelay: time.Hour}))
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(t, es.Set(key, key, nil))
+	}
+	// Close writes what is queued
+	assert.Nil(t, es.Close())
+	if assert.Len(t, sink.batches, 2) {
+		assert.Len(t, sink.batches[0], 2)
+		assert.Equal(t, expiring.SinkWrite{Key: "c", Value: "c", TTL: expiring.DefaultExpiration}, sink.batches[1][0])
+	}
+	assert.Empty(t, sink.calls)
+
+	// writes after Close are dropped
+	assert.Nil(t, es.Set("d", "d", nil))
+	assert.Equal(t, uint64(1), es.Stats().WriteBehindDropped)
+}
+
+func TestWriteBehindQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	sink := blockingSink{recordingSink: newRecordingSink(), release: release}
+	var dropped []expiring.SinkWrite
+	var mu sync.Mutex
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil,
+		expiring.WithWriteBehind(sink, expiring.WriteBehind{QueueSize: 1, BatchSize: 1, OnError: func(writes []expiring.SinkWrite, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, expiring.WriteBehindQueueFullError, err)
+			dropped = append(dropped, writes...)
+		}}))
+	defer es.Close()
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, es.Set(i, i, nil))
+	}
+	close(release)
+	assert.Nil(t, es.Flush(context.Background()))
+
+	stats := es.Stats()
+	assert.True(t, stats.WriteBehindDropped > 0)
+	assert.Equal(t, uint64(10), stats.WriteBehindQueued+stats.WriteBehindDropped)
+	assert.Equal(t, stats.WriteBehindQueued, stats.WriteBehindWritten)
+	mu.Lock()
+	assert.Len(t, dropped, int(stats.WriteBehindDropped))
+	mu.Unlock()
+}
+
+func TestWriteBehindRetries(t *testing.T) {
+	failure := errors.New("database unavailable")
+	sink := newRecordingSink()
+	sink.failErr = failure
+	var lost error
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil,
+		expiring.WithWriteBehind(sink, expiring.WriteBehind{Attempts: 3, Backoff: time.Millisecond, OnError: func(writes []expiring.SinkWrite, err error) {
+			lost = err
+		}}))
+	defer es.Close()
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Nil(t, es.Flush(context.Background()))
+
+	stats := es.Stats()
+	assert.Equal(t, uint64(2), stats.WriteBehindRetries)
+	assert.Equal(t, uint64(1), stats.WriteBehindFailed)
+	assert.Equal(t, uint64(0), stats.WriteBehindWritten)
+	assert.Equal(t, failure, lost)
+	assert.Len(t, sink.calls, 3)
+}
+
+func TestFlushContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	sink := blockingSink{recordingSink: newRecordingSink(), release: release}
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil, expiring.WithWriteBehind(sink, expiring.WriteBehind{}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, es.Flush(ctx))
+
+	// without write-behind there is nothing to flush
+	assert.Nil(t, expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil).Flush(ctx))
+}
+
+type blockingSink struct {
+	*recordingSink
+	release chan struct{}
+}
+
+func (s blockingSink) Write(ctx context.Context, key interface{}, value interface{}, ttl time.Duration) error {
+	<-s.release
+	return s.recordingSink.Write(ctx, key, value, ttl)
+}
@@ -0,0 +1,130 @@
+package expiring_gocache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Sink is the durable system of record a write-through Store keeps in
+	// step with the cache, see WithWriteThrough.
+	Sink interface {
+		Write(ctx context.Context, key interface{}, value interface{}, ttl time.Duration) error
+		Remove(ctx context.Context, key interface{}) error
+	}
+
+	// WriteOrder is which of the sink and the cache a write-through Store
+	// writes first.
+	WriteOrder int
+
+	// WriteThroughPolicy configures WithWriteThrough. The zero value writes
+	// to the sink first, and fails the call if the sink fails.
+	WriteThroughPolicy struct {
+		// Order is which of the sink and the cache is written first.
+		Order WriteOrder
+		// IgnoreErrors returns success to the caller when only the sink
+		// failed. Failures are still counted and given to OnError.
+		IgnoreErrors bool
+		// OnError, if set, is called with each failed call to the sink.
+		OnError func(op Operation, key interface{}, err error)
+	}
+
+	writeThrough struct {
+		sink   Sink
+		policy WriteThroughPolicy
+	}
+
+	// sinkOp is one write to pass on to the sink.
+	sinkOp struct {
+		op    Operation
+		key   interface{}
+		value interface{}
+		ttl   time.Duration
+	}
+)
+
+const (
+	// SinkFirst writes to the sink before the cache, and leaves the cache
+	// alone if the sink fails, so that the cache never holds a value the
+	// sink doesn't.
+	SinkFirst WriteOrder = iota
+	// CacheFirst writes to the cache before the sink. A value whose write to
+	// the sink fails is deleted from the cache again.
+	CacheFirst
+)
+
+// through applies ops to the sink given to WithWriteThrough, in the
+// configured order with cache, which writes them to the cache. With
+// WithWriteBehind, ops are queued for the sink once they are cached.
+func (es Store) through(ctx context.Context, ops []sinkOp, cache func() error) error {
+	if es.draining() {
+		ops = withoutSets(ops)
+	}
+	if es.writeBehind != nil {
+		if err := cache(); err != nil {
+			return err
+		}
+		es.writeBehind.enqueue(ops)
+		return nil
+	}
+	wt := es.writeThrough
+	if wt == nil {
+		return cache()
+	}
+
+	if wt.policy.Order == CacheFirst {
+		if err := cache(); err != nil {
+			return err
+		}
+		var first error
+		for _, op := range ops {
+			if err := es.toSink(ctx, op); err != nil {
+				if op.op == OperationSet {
+					// don't keep a value the sink doesn't have
+					es.untrack(op.key)
+					_ = es.innerDelete(op.key)
+				}
+				if first == nil {
+					first = err
+				}
+			}
+		}
+		return first
+	}
+
+	for _, op := range ops {
+		if err := es.toSink(ctx, op); err != nil {
+			return err
+		}
+	}
+	return cache()
+}
+
+// toSink applies op to the sink, returning its error unless the policy
+// ignores it.
+func (es Store) toSink(ctx context.Context, op sinkOp) error {
+	wt := es.writeThrough
+	var err error
+	err = es.guard("sink", func() error {
+		if op.op == OperationDelete {
+			return wt.sink.Remove(ctx, op.key)
+		}
+		return wt.sink.Write(ctx, op.key, op.value, op.ttl)
+	})
+	if err == nil {
+		return nil
+	}
+
+	atomic.AddUint64(&es.stats.sinkFailures, 1)
+	if wt.policy.OnError != nil {
+		_ = es.guard("write-through error hook", func() error {
+			wt.policy.OnError(op.op, op.key, err)
+			return nil
+		})
+	}
+	if wt.policy.IgnoreErrors {
+		return nil
+	}
+	return err
+}
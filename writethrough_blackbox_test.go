@@ -0,0 +1,163 @@
+package expiring_gocache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eko/gocache/store"
+	expiring "github.com/nabowler/expiring_gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	values  map[interface{}]interface{}
+	ttls    map[interface{}]time.Duration
+	calls   []string
+	failErr error
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{values: map[interface{}]interface{}{}, ttls: map[interface{}]time.Duration{}}
+}
+
+func (s *recordingSink) Write(ctx context.Context, key interface{}, value interface{}, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, "write")
+	if s.failErr != nil {
+		return s.failErr
+	}
+	s.values[key] = value
+	s.ttls[key] = ttl
+	return nil
+}
+
+func (s *recordingSink) Remove(ctx context.Context, key interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, "remove")
+	if s.failErr != nil {
+		return s.failErr
+	}
+	delete(s.values, key)
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	sink := newRecordingSink()
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{}))
+
+	assert.Nil(t, es.Set("key", "value", &store.Options{Expiration: time.Minute}))
+	assert.Equal(t, "value", sink.values["key"])
+	assert.Equal(t, time.Minute, sink.ttls["key"])
+	assert.Equal(t, 1, ms.setCount)
+
+	assert.Nil(t, es.SetMulti([]expiring.SetItem{{Key: "a", Value: 1}, {Key: "b", Value: 2}}))
+	assert.Equal(t, 2, sink.values["b"])
+	assert.Equal(t, time.Hour, sink.ttls["b"])
+
+	assert.Nil(t, es.Delete("key"))
+	_, ok := sink.values["key"]
+	assert.False(t, ok)
+	assert.Equal(t, 1, ms.deleteCount)
+}
+
+func TestWriteThroughEveryMutation(t *testing.T) {
+	sink := newRecordingSink()
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, &store.Options{Expiration: time.Hour}, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{}),
+		expiring.WithAccessTracking())
+
+	_, err := es.Batch(func(tx expiring.BatchTx) error {
+		tx.Set("user:1", "one", nil)
+		tx.Set("user:2", "two", &store.Options{Expiration: time.Minute})
+		tx.Set("other", "value", nil)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[interface{}]interface{}{"user:1": "one", "user:2": "two", "other": "value"}, sink.values)
+	assert.Equal(t, time.Minute, sink.ttls["user:2"])
+
+	_, err = es.Batch(func(tx expiring.BatchTx) error {
+		tx.Delete("other")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, es.DeleteAfter("user:1", time.Minute))
+	assert.Equal(t, map[interface{}]interface{}{"user:2": "two"}, sink.values)
+
+	n, err := es.InvalidateByPrefix("user:")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Empty(t, sink.values)
+}
+
+func TestWriteThroughSinkFirst(t *testing.T) {
+	failure := errors.New("database unavailable")
+	sink := newRecordingSink()
+	sink.failErr = failure
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	var failed []interface{}
+	es := expiring.New(&ms, nil, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{
+		OnError: func(op expiring.Operation, key interface{}, err error) { failed = append(failed, key) },
+	}))
+
+	// the cache is left alone when the sink fails
+	assert.Equal(t, failure, es.Set("key", "value", nil))
+	assert.Equal(t, 0, ms.setCount)
+	assert.Equal(t, failure, es.Delete("key"))
+	assert.Equal(t, 0, ms.deleteCount)
+	assert.Equal(t, []interface{}{"key", "key"}, failed)
+	assert.Equal(t, uint64(2), es.Stats().SinkFailures)
+}
+
+func TestWriteThroughCacheFirst(t *testing.T) {
+	failure := errors.New("database unavailable")
+	sink := newRecordingSink()
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{Order: expiring.CacheFirst}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	assert.Equal(t, "value", sink.values["key"])
+
+	// a value the sink couldn't take is deleted from the cache again
+	sink.failErr = failure
+	assert.Equal(t, failure, es.Set("key", "newer", nil))
+	assert.Equal(t, 2, ms.setCount)
+	_, err := es.Get("key")
+	assert.Equal(t, MapStoreMiss, err)
+}
+
+func TestWriteThroughIgnoreErrors(t *testing.T) {
+	sink := newRecordingSink()
+	sink.failErr = errors.New("database unavailable")
+	ms := MapStore{cache: map[interface{}]interface{}{}}
+	es := expiring.New(&ms, nil, expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{IgnoreErrors: true}))
+
+	assert.Nil(t, es.Set("key", "value", nil))
+	val, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, uint64(1), es.Stats().SinkFailures)
+}
+
+func TestWriteThroughSkipsReadThrough(t *testing.T) {
+	sink := newRecordingSink()
+	source := expiring.SourceFunc(func(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+		return "value", 0, nil
+	})
+	es := expiring.New(&MapStore{cache: map[interface{}]interface{}{}}, nil,
+		expiring.WithReadThrough(source),
+		expiring.WithWriteThrough(sink, expiring.WriteThroughPolicy{}),
+	)
+
+	// values filled from the source aren't written back to the sink
+	_, err := es.Get("key")
+	assert.Nil(t, err)
+	assert.Empty(t, sink.calls)
+}